@@ -0,0 +1,298 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one mutation in the audit journal: exactly what was sent to Shopify and what came back
+type AuditEntry struct {
+	Time           time.Time `json:"time"`
+	Shop           string    `json:"shop"`
+	APIVersion     string    `json:"apiVersion"`
+	Kind           string    `json:"kind"` // "graphql" or "rest"
+	Operation      string    `json:"operation"`
+	Method         string    `json:"method,omitempty"`
+	Path           string    `json:"path,omitempty"`
+	IdempotencyKey string    `json:"idempotencyKey,omitempty"`
+	// Orders, draft orders and calculated orders the call refers to, as GIDs and names like "#1001"
+	Refs       []string        `json:"refs,omitempty"`
+	Query      string          `json:"query,omitempty"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Status     int             `json:"status,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	UserErrors []UserError     `json:"userErrors,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"durationMs"`
+	Attempts   int             `json:"attempts"`
+}
+
+// AuditJournal is an append-only JSONL file with one AuditEntry per mutation.
+// Requests are stored unredacted so support can see exactly what was sent;
+// the file is created readable by its owner only.
+type AuditJournal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAuditJournal opens the journal at path for appending, creating it if needed
+func OpenAuditJournal(path string) (*AuditJournal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit journal: %w", err)
+	}
+	return &AuditJournal{file: file}, nil
+}
+
+// Record appends an entry to the journal
+func (j *AuditJournal) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file
+func (j *AuditJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+var (
+	defaultAuditJournalOnce sync.Once
+	defaultAuditJournal     *AuditJournal
+)
+
+// DefaultAuditJournal returns the journal used by clients without WithAuditJournal:
+// SHOPIFY_AUDIT_FILE when that variable is set, otherwise nil (no journal).
+func DefaultAuditJournal() *AuditJournal {
+	defaultAuditJournalOnce.Do(func() {
+		if path := os.Getenv("SHOPIFY_AUDIT_FILE"); path != "" {
+			j, err := OpenAuditJournal(path)
+			if err != nil {
+				DefaultLogger().Warn("mutations are not journaled", "error", err)
+				return
+			}
+			defaultAuditJournal = j
+		}
+	})
+	return defaultAuditJournal
+}
+
+func (c *Client) auditJournal() *AuditJournal {
+	if c.AuditJournal != nil {
+		return c.AuditJournal
+	}
+	return DefaultAuditJournal()
+}
+
+// auditEntry builds the journal entry of a finished mutation
+func (c *Client) auditEntry(ctx context.Context, call *apiCall, duration time.Duration, userErrs *UserErrors) AuditEntry {
+	entry := AuditEntry{
+		Time:           call.start.UTC(),
+		Shop:           c.ShopDomain,
+		APIVersion:     c.apiVersion(),
+		Kind:           call.kind,
+		Operation:      call.operation,
+		Method:         call.method,
+		Path:           call.path,
+		IdempotencyKey: IdempotencyKey(ctx),
+		Query:          call.query,
+		Status:         call.status,
+		DurationMS:     duration.Milliseconds(),
+		Attempts:       call.attempts,
+	}
+	if json.Valid(call.request) {
+		entry.Request = call.request
+	}
+	if json.Valid(call.response) {
+		entry.Response = call.response
+	} else if len(call.response) > 0 {
+		entry.Response, _ = json.Marshal(string(call.response))
+	}
+	if userErrs != nil {
+		entry.UserErrors = userErrs.Errors
+	}
+	if call.err != nil {
+		entry.Error = call.err.Error()
+	}
+
+	refs := map[string]bool{}
+	if call.kind == "rest" {
+		if m := restOrderPath.FindStringSubmatch(call.path); m != nil {
+			refs["gid://shopify/Order/"+m[1]] = true
+		}
+	}
+	collectRefs(entry.Request, refs)
+	collectRefs(entry.Response, refs)
+	for ref := range refs {
+		entry.Refs = append(entry.Refs, ref)
+	}
+	sort.Strings(entry.Refs)
+	return entry
+}
+
+var (
+	// orderGID matches order-like GIDs; a ?key=... suffix on calculated orders is dropped
+	orderGID = regexp.MustCompile(`^gid://shopify/(Order|DraftOrder|CalculatedOrder)/\d+`)
+	// orderName matches order names such as "#1001" and draft names such as "#D12"
+	orderName     = regexp.MustCompile(`^#D?\d+$`)
+	restOrderPath = regexp.MustCompile(`^/?orders/(\d+)`)
+)
+
+// restResourceTypes maps REST object keys to the GraphQL type of their numeric "id"
+var restResourceTypes = map[string]string{
+	"order":       "Order",
+	"orders":      "Order",
+	"draft_order": "DraftOrder",
+}
+
+// collectRefs adds the order references found anywhere in a JSON document to refs
+func collectRefs(data json.RawMessage, refs map[string]bool) {
+	if len(data) == 0 {
+		return
+	}
+	var v interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if decoder.Decode(&v) != nil {
+		return
+	}
+	walkRefs(v, "", refs)
+}
+
+func walkRefs(v interface{}, parent string, refs map[string]bool) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			switch field := field.(type) {
+			case string:
+				if m := orderGID.FindString(field); m != "" {
+					refs[m] = true
+				} else if key == "name" && orderName.MatchString(field) {
+					refs[field] = true
+				}
+			case json.Number:
+				if typeName, ok := restResourceTypes[parent]; ok && key == "id" {
+					refs["gid://shopify/"+typeName+"/"+field.String()] = true
+				} else if key == "order_id" {
+					refs["gid://shopify/Order/"+field.String()] = true
+				}
+			default:
+				walkRefs(field, key, refs)
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			walkRefs(item, parent, refs)
+		}
+	case string:
+		if m := orderGID.FindString(value); m != "" {
+			refs[m] = true
+		}
+	}
+}
+
+// ReadAuditJournal reads every entry of the journal at path
+func ReadAuditJournal(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read audit journal: %w", err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit journal %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read audit journal: %w", err)
+	}
+	return entries, nil
+}
+
+// AuditTrail returns the entries about an order, oldest first. ref is an order name
+// ("#2291" or "2291"), a numeric ID or a GID. Entries are linked transitively through
+// shared references and idempotency keys within the same shop, so the trail of an
+// order includes the draft order it was completed from and the edits applied to it.
+func AuditTrail(entries []AuditEntry, ref string) []AuditEntry {
+	var start []string
+	ref = strings.TrimSpace(ref)
+	if _, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		start = []string{"#" + ref, "gid://shopify/Order/" + ref}
+	} else {
+		start = []string{ref}
+	}
+
+	// linked holds "shop ref" and "shop key:idempotency-key" of the included entries
+	linked := map[string]bool{}
+	matches := func(entry AuditEntry) bool {
+		for _, r := range entry.Refs {
+			if linked[entry.Shop+" "+r] {
+				return true
+			}
+			for _, s := range start {
+				if r == s {
+					return true
+				}
+			}
+		}
+		return entry.IdempotencyKey != "" && linked[entry.Shop+" key:"+entry.IdempotencyKey]
+	}
+
+	included := make([]bool, len(entries))
+	for changed := true; changed; {
+		changed = false
+		for i, entry := range entries {
+			if included[i] || !matches(entry) {
+				continue
+			}
+			included[i] = true
+			changed = true
+			for _, r := range entry.Refs {
+				linked[entry.Shop+" "+r] = true
+			}
+			if entry.IdempotencyKey != "" {
+				linked[entry.Shop+" key:"+entry.IdempotencyKey] = true
+			}
+		}
+	}
+
+	var trail []AuditEntry
+	for i, entry := range entries {
+		if included[i] {
+			trail = append(trail, entry)
+		}
+	}
+	sort.SliceStable(trail, func(i, j int) bool {
+		return trail[i].Time.Before(trail[j].Time)
+	})
+	return trail
+}
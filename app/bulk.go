@@ -0,0 +1,749 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bulk operation statuses (BulkOperationStatus)
+const (
+	BulkOperationCreated   = "CREATED"
+	BulkOperationRunning   = "RUNNING"
+	BulkOperationCompleted = "COMPLETED"
+	BulkOperationCanceling = "CANCELING"
+	BulkOperationCanceled  = "CANCELED"
+	BulkOperationFailed    = "FAILED"
+	BulkOperationExpired   = "EXPIRED"
+)
+
+// Bulk operation types (BulkOperationType)
+const (
+	BulkOperationTypeQuery    = "QUERY"
+	BulkOperationTypeMutation = "MUTATION"
+)
+
+// DefaultBulkPollInterval is how often WaitBulkOperation polls when no interval is given
+const DefaultBulkPollInterval = 5 * time.Second
+
+// maxBulkLineSize is the longest JSONL line accepted when reading bulk results
+const maxBulkLineSize = 16 * 1024 * 1024
+
+// BulkOperation is the state of a bulk query or mutation
+type BulkOperation struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	ErrorCode string `json:"errorCode"`
+	CreatedAt string `json:"createdAt"`
+	// Empty until the operation finished
+	CompletedAt string `json:"completedAt"`
+	// Number of objects processed so far (UnsignedInt64 as a string)
+	ObjectCount string `json:"objectCount"`
+	// Size of the result file in bytes (UnsignedInt64 as a string)
+	FileSize string `json:"fileSize"`
+	// Signed URL of the JSONL result, set once the operation completed with results
+	URL string `json:"url"`
+	// Signed URL of the partial result when the operation failed midway
+	PartialDataURL string `json:"partialDataUrl"`
+}
+
+// Done reports whether the operation reached a final status
+func (op *BulkOperation) Done() bool {
+	switch op.Status {
+	case BulkOperationCompleted, BulkOperationCanceled, BulkOperationFailed, BulkOperationExpired:
+		return true
+	}
+	return false
+}
+
+// BulkOperationError is returned when a bulk operation ends in any status other than COMPLETED
+type BulkOperationError struct {
+	Operation *BulkOperation
+}
+
+func (e *BulkOperationError) Error() string {
+	if e.Operation.ErrorCode != "" {
+		return fmt.Sprintf("bulk operation %s %s: %s", e.Operation.ID, e.Operation.Status, e.Operation.ErrorCode)
+	}
+	return fmt.Sprintf("bulk operation %s %s", e.Operation.ID, e.Operation.Status)
+}
+
+// bulkOperationFields is the BulkOperation selection used by every query below
+const bulkOperationFields = `
+	id
+	type
+	status
+	errorCode
+	createdAt
+	completedAt
+	objectCount
+	fileSize
+	url
+	partialDataUrl`
+
+// BulkOperationRunQuery submits a bulk query. query is a regular GraphQL query
+// with a single top-level connection and no pagination arguments.
+func (c *Client) BulkOperationRunQuery(ctx context.Context, query string) (*BulkOperation, error) {
+	const mutation = `
+		mutation BulkOperationRunQuery($query: String!) {
+			bulkOperationRunQuery(query: $query) {
+				bulkOperation {` + bulkOperationFields + `
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	var result struct {
+		BulkOperation *BulkOperation `json:"bulkOperation"`
+	}
+	if err := c.queryPath(ctx, mutation, map[string]interface{}{"query": query}, []string{"bulkOperationRunQuery"}, &result); err != nil {
+		return nil, err
+	}
+	if result.BulkOperation == nil {
+		return nil, fmt.Errorf("bulkOperationRunQuery returned no bulk operation")
+	}
+	return result.BulkOperation, nil
+}
+
+// CurrentBulkOperation returns the shop's most recent bulk operation of the given type
+// (BulkOperationTypeQuery or BulkOperationTypeMutation), or ErrNotFound if there is none
+func (c *Client) CurrentBulkOperation(ctx context.Context, operationType string) (*BulkOperation, error) {
+	const query = `
+		query CurrentBulkOperation($type: BulkOperationType!) {
+			currentBulkOperation(type: $type) {` + bulkOperationFields + `
+			}
+		}`
+
+	var op BulkOperation
+	if err := c.queryPath(ctx, query, map[string]interface{}{"type": operationType}, []string{"currentBulkOperation"}, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// GetBulkOperation returns a bulk operation by ID
+func (c *Client) GetBulkOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	const query = `
+		query GetBulkOperation($id: ID!) {
+			node(id: $id) {
+				... on BulkOperation {` + bulkOperationFields + `
+				}
+			}
+		}`
+
+	var op BulkOperation
+	if err := c.queryPath(ctx, query, map[string]interface{}{"id": id}, []string{"node"}, &op); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("bulk operation %s: %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+	return &op, nil
+}
+
+// CancelBulkOperation asks Shopify to stop a running bulk operation
+func (c *Client) CancelBulkOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	const mutation = `
+		mutation BulkOperationCancel($id: ID!) {
+			bulkOperationCancel(id: $id) {
+				bulkOperation {` + bulkOperationFields + `
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	var result struct {
+		BulkOperation *BulkOperation `json:"bulkOperation"`
+	}
+	if err := c.queryPath(ctx, mutation, map[string]interface{}{"id": id}, []string{"bulkOperationCancel"}, &result); err != nil {
+		return nil, err
+	}
+	return result.BulkOperation, nil
+}
+
+// BulkWaitOptions controls how WaitBulkOperation learns that an operation finished
+type BulkWaitOptions struct {
+	// Interval between status polls. Zero means DefaultBulkPollInterval.
+	// With a Notifier, polling is a fallback for missed webhooks.
+	PollInterval time.Duration
+	// Receives bulk_operations/finish webhooks. Nil means poll only.
+	Notifier *BulkNotifier
+}
+
+// WaitBulkOperation blocks until the operation finished and returns its final state.
+// Operations that did not complete are reported as *BulkOperationError.
+func (c *Client) WaitBulkOperation(ctx context.Context, id string, opts BulkWaitOptions) (*BulkOperation, error) {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultBulkPollInterval
+	}
+
+	var finished <-chan struct{}
+	if opts.Notifier != nil {
+		var cancel func()
+		finished, cancel = opts.Notifier.subscribe(id)
+		defer cancel()
+	}
+
+	for {
+		op, err := c.GetBulkOperation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if op.Done() {
+			if op.Status != BulkOperationCompleted {
+				return op, &BulkOperationError{Operation: op}
+			}
+			return op, nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-finished:
+			timer.Stop()
+			finished = nil
+		case <-timer.C:
+		}
+	}
+}
+
+// RunBulkQuery submits a bulk query and waits for it to finish
+func (c *Client) RunBulkQuery(ctx context.Context, query string, opts BulkWaitOptions) (*BulkOperation, error) {
+	op, err := c.BulkOperationRunQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return c.WaitBulkOperation(ctx, op.ID, opts)
+}
+
+// BulkOperationWebhook is the payload of the bulk_operations/finish webhook
+type BulkOperationWebhook struct {
+	AdminGraphQLAPIID string `json:"admin_graphql_api_id"`
+	CompletedAt       string `json:"completed_at"`
+	CreatedAt         string `json:"created_at"`
+	ErrorCode         string `json:"error_code"`
+	Status            string `json:"status"`
+	Type              string `json:"type"`
+}
+
+// BulkNotifier wakes up WaitBulkOperation calls when a bulk_operations/finish webhook arrives.
+// Mount Handler on the webhook endpoint and pass the notifier in BulkWaitOptions.
+type BulkNotifier struct {
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// NewBulkNotifier creates a notifier with no waiters
+func NewBulkNotifier() *BulkNotifier {
+	return &BulkNotifier{waiters: make(map[string][]chan struct{})}
+}
+
+// subscribe returns a channel closed when the operation's webhook arrives, and a function to unsubscribe
+func (n *BulkNotifier) subscribe(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	n.mu.Lock()
+	n.waiters[id] = append(n.waiters[id], ch)
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		waiters := n.waiters[id]
+		for i, w := range waiters {
+			if w == ch {
+				n.waiters[id] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(n.waiters[id]) == 0 {
+			delete(n.waiters, id)
+		}
+	}
+}
+
+// Notify wakes up everyone waiting for the operation in the webhook payload
+func (n *BulkNotifier) Notify(payload BulkOperationWebhook) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ch := range n.waiters[payload.AdminGraphQLAPIID] {
+		close(ch)
+	}
+	delete(n.waiters, payload.AdminGraphQLAPIID)
+}
+
+// Handler returns an http.Handler for the bulk_operations/finish webhook.
+// Requests are verified with the app's API secret key (X-Shopify-Hmac-Sha256).
+func (n *BulkNotifier) Handler(apiSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "cannot read body", http.StatusBadRequest)
+			return
+		}
+		if !VerifyWebhookHMAC(body, r.Header.Get("X-Shopify-Hmac-Sha256"), apiSecret) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var payload BulkOperationWebhook
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		n.Notify(payload)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// VerifyWebhookHMAC reports whether signature (the base64 X-Shopify-Hmac-Sha256 header)
+// matches the HMAC-SHA256 of body with the app's API secret key
+func VerifyWebhookHMAC(body []byte, signature, apiSecret string) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || apiSecret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// BulkObject is one object of a bulk query result with its nested children.
+// Children are the lines whose __parentId is this object's id, in file order.
+type BulkObject struct {
+	ID       string
+	ParentID string
+	// The object's fields as returned by Shopify (including __parentId for children)
+	Data     json.RawMessage
+	Children []*BulkObject
+}
+
+// TypeName returns the resource type from the object's GID, e.g. "LineItem"
+func (o *BulkObject) TypeName() string {
+	return gidType(o.ID)
+}
+
+// ChildrenOfType returns the children whose GID has the given resource type
+func (o *BulkObject) ChildrenOfType(typeName string) []*BulkObject {
+	var children []*BulkObject
+	for _, child := range o.Children {
+		if child.TypeName() == typeName {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// BulkChildAdder is implemented by record types that collect nested bulk objects
+type BulkChildAdder interface {
+	AddBulkChild(child *BulkObject) error
+}
+
+// Decode unmarshals the object into v. If v implements BulkChildAdder,
+// every child is passed to AddBulkChild after the object's own fields are decoded.
+func (o *BulkObject) Decode(v interface{}) error {
+	if err := json.Unmarshal(o.Data, v); err != nil {
+		return fmt.Errorf("failed to parse bulk object %s: %w", o.ID, err)
+	}
+	if adder, ok := v.(BulkChildAdder); ok {
+		for _, child := range o.Children {
+			if err := adder.AddBulkChild(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gidType returns the resource type of a GID such as "gid://shopify/Order/123"
+func gidType(gid string) string {
+	rest, ok := strings.CutPrefix(gid, "gid://shopify/")
+	if !ok {
+		return ""
+	}
+	typeName, _, _ := strings.Cut(rest, "/")
+	return typeName
+}
+
+// ParseBulkJSONL streams a bulk query result and yields each top-level object once all
+// of its descendants were read. Shopify writes children after their parent, so only
+// the current top-level object is kept in memory.
+func ParseBulkJSONL(r io.Reader) iter.Seq2[*BulkObject, error] {
+	return func(yield func(*BulkObject, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+
+		var current *BulkObject
+		index := make(map[string]*BulkObject)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			var header struct {
+				ID       string `json:"id"`
+				ParentID string `json:"__parentId"`
+			}
+			if err := json.Unmarshal(line, &header); err != nil {
+				yield(nil, fmt.Errorf("bulk result line %d: %w", lineNumber, err))
+				return
+			}
+
+			obj := &BulkObject{
+				ID:       header.ID,
+				ParentID: header.ParentID,
+				Data:     json.RawMessage(bytes.Clone(line)),
+			}
+
+			if obj.ParentID == "" {
+				if current != nil && !yield(current, nil) {
+					return
+				}
+				current = obj
+				clear(index)
+			} else {
+				parent, ok := index[obj.ParentID]
+				if !ok {
+					yield(nil, fmt.Errorf("bulk result line %d: parent %s not found before child", lineNumber, obj.ParentID))
+					return
+				}
+				parent.Children = append(parent.Children, obj)
+			}
+			if obj.ID != "" {
+				index[obj.ID] = obj
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield(nil, fmt.Errorf("failed to read bulk result: %w", err))
+			return
+		}
+		if current != nil {
+			yield(current, nil)
+		}
+	}
+}
+
+// DecodeBulkObjects turns a stream of bulk objects into typed records (see BulkObject.Decode)
+func DecodeBulkObjects[T any](objects iter.Seq2[*BulkObject, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for obj, err := range objects {
+			var record T
+			if err == nil {
+				err = obj.Decode(&record)
+			}
+			if err != nil {
+				yield(record, err)
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// downloadBulkResult opens a result URL. The URL is pre-signed, so no Shopify
+// credentials are sent, and no client timeout applies: large files are bounded by ctx only.
+func (c *Client) downloadBulkResult(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpClient := &http.Client{Transport: c.httpClient().Transport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bulk result: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp.Body, nil
+}
+
+// BulkObjects downloads the result of a completed bulk query and streams its objects.
+// An operation without results (zero objects) yields nothing.
+func (c *Client) BulkObjects(ctx context.Context, op *BulkOperation) iter.Seq2[*BulkObject, error] {
+	return func(yield func(*BulkObject, error) bool) {
+		if op.URL == "" {
+			if op.Status != BulkOperationCompleted {
+				yield(nil, &BulkOperationError{Operation: op})
+			}
+			return
+		}
+
+		body, err := c.downloadBulkResult(ctx, op.URL)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer body.Close()
+
+		for obj, err := range ParseBulkJSONL(body) {
+			if !yield(obj, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// BulkRecords runs a bulk query, waits for it and streams the result decoded as T
+func BulkRecords[T any](ctx context.Context, c *Client, query string, opts BulkWaitOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		op, err := c.RunBulkQuery(ctx, query, opts)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		for record, err := range DecodeBulkObjects[T](c.BulkObjects(ctx, op)) {
+			if !yield(record, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StagedUploadTarget is where a file for a bulk mutation is uploaded
+type StagedUploadTarget struct {
+	URL         string `json:"url"`
+	ResourceURL string `json:"resourceUrl"`
+	Parameters  []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"parameters"`
+}
+
+// stagedUploadPath returns the "key" parameter, which bulkOperationRunMutation takes as stagedUploadPath
+func (t *StagedUploadTarget) stagedUploadPath() string {
+	for _, param := range t.Parameters {
+		if param.Name == "key" {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// StageBulkMutationVariables uploads a JSONL file of mutation variables (one JSON object
+// per line) and returns the staged upload path for bulkOperationRunMutation
+func (c *Client) StageBulkMutationVariables(ctx context.Context, jsonl []byte) (string, error) {
+	const mutation = `
+		mutation StagedUploadsCreate($input: [StagedUploadInput!]!) {
+			stagedUploadsCreate(input: $input) {
+				stagedTargets {
+					url
+					resourceUrl
+					parameters {
+						name
+						value
+					}
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	variables := map[string]interface{}{
+		"input": []map[string]interface{}{{
+			"resource":   "BULK_MUTATION_VARIABLES",
+			"filename":   "bulk_op_vars.jsonl",
+			"mimeType":   "text/jsonl",
+			"httpMethod": "POST",
+		}},
+	}
+
+	var result struct {
+		StagedTargets []StagedUploadTarget `json:"stagedTargets"`
+	}
+	if err := c.queryPath(ctx, mutation, variables, []string{"stagedUploadsCreate"}, &result); err != nil {
+		return "", err
+	}
+	if len(result.StagedTargets) == 0 {
+		return "", fmt.Errorf("stagedUploadsCreate returned no target")
+	}
+	target := result.StagedTargets[0]
+
+	// Form fields must come before the file
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for _, param := range target.Parameters {
+		if err := writer.WriteField(param.Name, param.Value); err != nil {
+			return "", fmt.Errorf("failed to build upload form: %w", err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", "bulk_op_vars.jsonl")
+	if err != nil {
+		return "", fmt.Errorf("failed to build upload form: %w", err)
+	}
+	if _, err := part.Write(jsonl); err != nil {
+		return "", fmt.Errorf("failed to build upload form: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to build upload form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, &form)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, body, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload bulk mutation variables: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to upload bulk mutation variables: %w",
+			&HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+
+	path := target.stagedUploadPath()
+	if path == "" {
+		return "", fmt.Errorf("staged upload target has no key parameter")
+	}
+	return path, nil
+}
+
+// EncodeBulkVariables encodes one variables object per line for a bulk mutation
+func EncodeBulkVariables(variables []map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i, vars := range variables {
+		if err := encoder.Encode(vars); err != nil {
+			return nil, fmt.Errorf("failed to encode bulk variables line %d: %w", i+1, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// BulkOperationRunMutation uploads the variables and runs mutation once per line.
+// mutation is a single GraphQL mutation whose variables come from each line.
+func (c *Client) BulkOperationRunMutation(ctx context.Context, mutation string, variables []map[string]interface{}) (*BulkOperation, error) {
+	if len(variables) == 0 {
+		return nil, errors.New("bulk mutation needs at least one line of variables")
+	}
+
+	jsonl, err := EncodeBulkVariables(variables)
+	if err != nil {
+		return nil, err
+	}
+	stagedUploadPath, err := c.StageBulkMutationVariables(ctx, jsonl)
+	if err != nil {
+		return nil, err
+	}
+
+	const runMutation = `
+		mutation BulkOperationRunMutation($mutation: String!, $stagedUploadPath: String!) {
+			bulkOperationRunMutation(mutation: $mutation, stagedUploadPath: $stagedUploadPath) {
+				bulkOperation {` + bulkOperationFields + `
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	var result struct {
+		BulkOperation *BulkOperation `json:"bulkOperation"`
+	}
+	runVars := map[string]interface{}{
+		"mutation":         mutation,
+		"stagedUploadPath": stagedUploadPath,
+	}
+	if err := c.queryPath(ctx, runMutation, runVars, []string{"bulkOperationRunMutation"}, &result); err != nil {
+		return nil, err
+	}
+	if result.BulkOperation == nil {
+		return nil, fmt.Errorf("bulkOperationRunMutation returned no bulk operation")
+	}
+	return result.BulkOperation, nil
+}
+
+// BulkMutationResult is one line of a bulk mutation result file
+type BulkMutationResult struct {
+	// 0-based line of the variables file this result belongs to
+	LineNumber int             `json:"__lineNumber"`
+	Data       json.RawMessage `json:"data"`
+	Errors     []GraphQLError  `json:"errors,omitempty"`
+}
+
+// BulkMutationResults downloads the result of a completed bulk mutation and streams its lines.
+// Per-line userErrors are inside Data; top-level errors are in Errors.
+func (c *Client) BulkMutationResults(ctx context.Context, op *BulkOperation) iter.Seq2[BulkMutationResult, error] {
+	return func(yield func(BulkMutationResult, error) bool) {
+		url := op.URL
+		if url == "" {
+			url = op.PartialDataURL
+		}
+		if url == "" {
+			return
+		}
+
+		body, err := c.downloadBulkResult(ctx, url)
+		if err != nil {
+			yield(BulkMutationResult{}, err)
+			return
+		}
+		defer body.Close()
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var result BulkMutationResult
+			if err := json.Unmarshal(line, &result); err != nil {
+				yield(BulkMutationResult{}, fmt.Errorf("failed to parse bulk mutation result: %w", err))
+				return
+			}
+			if !yield(result, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(BulkMutationResult{}, fmt.Errorf("failed to read bulk mutation result: %w", err))
+		}
+	}
+}
+
+// RunBulkMutation runs a bulk mutation and waits for it to finish
+func (c *Client) RunBulkMutation(ctx context.Context, mutation string, variables []map[string]interface{}, opts BulkWaitOptions) (*BulkOperation, error) {
+	op, err := c.BulkOperationRunMutation(ctx, mutation, variables)
+	if err != nil {
+		return nil, err
+	}
+	return c.WaitBulkOperation(ctx, op.ID, opts)
+}
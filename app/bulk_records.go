@@ -0,0 +1,226 @@
+package app
+
+import "fmt"
+
+// BulkOrdersQuery exports every order with its line items
+const BulkOrdersQuery = `
+{
+	orders {
+		edges {
+			node {
+				id
+				name
+				email
+				createdAt
+				displayFinancialStatus
+				displayFulfillmentStatus
+				tags
+				totalPriceSet {
+					shopMoney {
+						amount
+						currencyCode
+					}
+				}
+				totalTaxSet {
+					shopMoney {
+						amount
+						currencyCode
+					}
+				}
+				lineItems {
+					edges {
+						node {
+							id
+							sku
+							quantity
+							variant {
+								id
+							}
+							originalUnitPriceSet {
+								shopMoney {
+									amount
+									currencyCode
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+// BulkCustomersQuery exports every customer with their addresses
+const BulkCustomersQuery = `
+{
+	customers {
+		edges {
+			node {
+				id
+				email
+				firstName
+				lastName
+				phone
+				defaultAddress {
+					id
+				}
+				addressesV2 {
+					edges {
+						node {
+							id
+							address1
+							address2
+							city
+							province
+							country
+							zip
+							phone
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+// BulkVariantsQuery exports every product variant with its prices
+const BulkVariantsQuery = `
+{
+	productVariants {
+		edges {
+			node {
+				id
+				sku
+				title
+				price
+				compareAtPrice
+				product {
+					id
+				}
+			}
+		}
+	}
+}`
+
+// BulkMetafieldsSetMutation sets metafields in a bulk mutation.
+// Each variables line is {"metafields": [MetafieldsSetInput...]}.
+const BulkMetafieldsSetMutation = `
+	mutation BulkMetafieldsSet($metafields: [MetafieldsSetInput!]!) {
+		metafieldsSet(metafields: $metafields) {
+			metafields {
+				id
+				key
+				namespace
+			}
+			userErrors {
+				field
+				message
+			}
+		}
+	}`
+
+// BulkVariantPricesMutation updates variant prices in a bulk mutation.
+// Each variables line is {"productId": "...", "variants": [{"id": "...", "price": "...", "compareAtPrice": "..."}]}.
+const BulkVariantPricesMutation = `
+	mutation BulkVariantPrices($productId: ID!, $variants: [ProductVariantsBulkInput!]!) {
+		productVariantsBulkUpdate(productId: $productId, variants: $variants) {
+			productVariants {
+				id
+				price
+				compareAtPrice
+			}
+			userErrors {
+				field
+				message
+			}
+		}
+	}`
+
+// BulkOrder is an order exported with BulkOrdersQuery
+type BulkOrder struct {
+	ID                       string         `json:"id"`
+	Name                     string         `json:"name"`
+	Email                    string         `json:"email"`
+	CreatedAt                string         `json:"createdAt"`
+	DisplayFinancialStatus   string         `json:"displayFinancialStatus"`
+	DisplayFulfillmentStatus string         `json:"displayFulfillmentStatus"`
+	Tags                     []string       `json:"tags"`
+	TotalPriceSet            MoneyBag       `json:"totalPriceSet"`
+	TotalTaxSet              MoneyBag       `json:"totalTaxSet"`
+	LineItems                []BulkLineItem `json:"lineItems,omitempty"`
+}
+
+// BulkLineItem is a line item nested under a BulkOrder
+type BulkLineItem struct {
+	ID       string `json:"id"`
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+	Variant  *struct {
+		ID string `json:"id"`
+	} `json:"variant"`
+	OriginalUnitPriceSet MoneyBag `json:"originalUnitPriceSet"`
+}
+
+// AddBulkChild implements BulkChildAdder
+func (o *BulkOrder) AddBulkChild(child *BulkObject) error {
+	if child.TypeName() != "LineItem" {
+		return nil
+	}
+	var lineItem BulkLineItem
+	if err := child.Decode(&lineItem); err != nil {
+		return fmt.Errorf("order %s: %w", o.ID, err)
+	}
+	o.LineItems = append(o.LineItems, lineItem)
+	return nil
+}
+
+// BulkCustomer is a customer exported with BulkCustomersQuery
+type BulkCustomer struct {
+	ID             string `json:"id"`
+	Email          string `json:"email"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	Phone          string `json:"phone"`
+	DefaultAddress *struct {
+		ID string `json:"id"`
+	} `json:"defaultAddress"`
+	Addresses []BulkAddress `json:"addresses,omitempty"`
+}
+
+// BulkAddress is a mailing address nested under a BulkCustomer
+type BulkAddress struct {
+	ID       string `json:"id"`
+	Address1 string `json:"address1"`
+	Address2 string `json:"address2"`
+	City     string `json:"city"`
+	Province string `json:"province"`
+	Country  string `json:"country"`
+	Zip      string `json:"zip"`
+	Phone    string `json:"phone"`
+}
+
+// AddBulkChild implements BulkChildAdder
+func (cu *BulkCustomer) AddBulkChild(child *BulkObject) error {
+	if child.TypeName() != "MailingAddress" {
+		return nil
+	}
+	var address BulkAddress
+	if err := child.Decode(&address); err != nil {
+		return fmt.Errorf("customer %s: %w", cu.ID, err)
+	}
+	cu.Addresses = append(cu.Addresses, address)
+	return nil
+}
+
+// BulkVariant is a product variant exported with BulkVariantsQuery
+type BulkVariant struct {
+	ID    string `json:"id"`
+	SKU   string `json:"sku"`
+	Title string `json:"title"`
+	Price Money  `json:"price"`
+	// Nil when the variant has no compare-at price
+	CompareAtPrice *Money `json:"compareAtPrice"`
+	Product        struct {
+		ID string `json:"id"`
+	} `json:"product"`
+}
@@ -0,0 +1,362 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteMode selects whether a Cassette records real traffic or replays a recording
+type CassetteMode int
+
+const (
+	// CassetteReplay serves requests from the recorded interactions and never touches the network
+	CassetteReplay CassetteMode = iota
+	// CassetteRecord sends requests to Shopify and appends each request/response pair to the file
+	CassetteRecord
+)
+
+// ParseCassetteMode parses "record" or "replay" (the default for an empty string)
+func ParseCassetteMode(s string) (CassetteMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "replay":
+		return CassetteReplay, nil
+	case "record":
+		return CassetteRecord, nil
+	}
+	return 0, fmt.Errorf("invalid cassette mode %q (want record or replay)", s)
+}
+
+// ErrCassetteMiss is returned in replay mode when no recorded interaction matches a request
+var ErrCassetteMiss = errors.New("no matching interaction in cassette")
+
+// redactedHeaders are replaced with "[REDACTED]" before an interaction is written
+var redactedHeaders = []string{"X-Shopify-Access-Token", "Authorization", "Cookie", "Set-Cookie"}
+
+// CassetteRequest is a recorded request. URL is the path and query only, so a
+// cassette carries no shop domain and replays against any shop.
+type CassetteRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded response
+type CassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// CassetteInteraction is one request/response pair
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// cassetteFile is the JSON layout of a cassette file
+type cassetteFile struct {
+	RecordedAt   time.Time             `json:"recordedAt"`
+	Meta         map[string]string     `json:"meta,omitempty"`
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// Cassette is an http.RoundTripper that records Admin API traffic to a file or
+// replays it. Replay is deterministic: each request is answered by the first
+// interaction not yet played with the same method, path, query and body, so a
+// request sent several times (polling fulfillment orders, say) gets the recorded
+// responses in order. Use it with WithCassette.
+type Cassette struct {
+	mu        sync.Mutex
+	path      string
+	mode      CassetteMode
+	transport http.RoundTripper
+	file      cassetteFile
+	played    []bool
+}
+
+// NewCassetteRecorder starts an empty cassette that records to path through transport
+// (nil means http.DefaultTransport). The file is rewritten after every interaction,
+// so a run that stops halfway still leaves a usable cassette.
+func NewCassetteRecorder(path string, transport http.RoundTripper) *Cassette {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Cassette{
+		path:      path,
+		mode:      CassetteRecord,
+		transport: transport,
+		file:      cassetteFile{RecordedAt: time.Now().UTC(), Meta: map[string]string{}},
+	}
+}
+
+// LoadCassette opens the cassette at path for replay
+func LoadCassette(path string) (*Cassette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	c := &Cassette{path: path, mode: CassetteReplay}
+	if err := json.Unmarshal(content, &c.file); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	if c.file.Meta == nil {
+		c.file.Meta = map[string]string{}
+	}
+	c.played = make([]bool, len(c.file.Interactions))
+	return c, nil
+}
+
+// OpenCassette records to path or replays it depending on mode
+func OpenCassette(path string, mode CassetteMode) (*Cassette, error) {
+	if mode == CassetteRecord {
+		return NewCassetteRecorder(path, nil), nil
+	}
+	return LoadCassette(path)
+}
+
+// Mode returns whether the cassette records or replays
+func (c *Cassette) Mode() CassetteMode {
+	return c.mode
+}
+
+// SetMeta stores a value with the recording, such as the inputs of the scenario
+// that produced it. In replay mode it only changes the in-memory copy.
+func (c *Cassette) SetMeta(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.file.Meta[key] = value
+	if c.mode != CassetteRecord {
+		return nil
+	}
+	return c.saveLocked()
+}
+
+// Meta returns a value stored with SetMeta
+func (c *Cassette) Meta(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Meta[key]
+}
+
+// Unplayed returns the interactions a replay has not used yet. A regression run
+// that leaves interactions unplayed made fewer calls than the recorded one.
+func (c *Cassette) Unplayed() []CassetteInteraction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unplayed []CassetteInteraction
+	for i, interaction := range c.file.Interactions {
+		if !c.played[i] {
+			unplayed = append(unplayed, interaction)
+		}
+	}
+	return unplayed
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.mode == CassetteRecord {
+		return c.record(req, body)
+	}
+	return c.replay(req, body)
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: cannot read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: cannot read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := CassetteInteraction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+			Header: redactHeader(req.Header),
+			Body:   string(body),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       string(respBody),
+		},
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.file.Interactions = append(c.file.Interactions, interaction)
+	if err := c.saveLocked(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	key := cassetteKey(req.Method, req.URL.RequestURI(), body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.file.Interactions {
+		if c.played[i] || cassetteKey(interaction.Request.Method, interaction.Request.URL, []byte(interaction.Request.Body)) != key {
+			continue
+		}
+		c.played[i] = true
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	operation := req.Method + " " + req.URL.Path
+	if name := graphQLOperationName(body); name != "" {
+		operation += " (" + name + ")"
+	}
+	return nil, fmt.Errorf("cassette %s: %s: %w", c.path, operation, ErrCassetteMiss)
+}
+
+func (c *Cassette) saveLocked() error {
+	content, err := json.MarshalIndent(c.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := writeFileAtomic(c.path, content); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+func redactHeader(header http.Header) http.Header {
+	out := header.Clone()
+	for _, name := range redactedHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, "[REDACTED]")
+		}
+	}
+	return out
+}
+
+var graphQLWhitespace = regexp.MustCompile(`\s+`)
+
+// cassetteKey identifies a request for replay. JSON bodies are compared after
+// re-encoding (so key order does not matter) with GraphQL documents whitespace-normalized.
+func cassetteKey(method, url string, body []byte) string {
+	return method + " " + url + "\n" + canonicalBody(body)
+}
+
+func canonicalBody(body []byte) string {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return string(body)
+	}
+	if request, ok := v.(map[string]interface{}); ok {
+		if query, ok := request["query"].(string); ok {
+			request["query"] = strings.TrimSpace(graphQLWhitespace.ReplaceAllString(query, " "))
+		}
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(canonical)
+}
+
+// graphQLOperationName returns the operation name of a GraphQL request body, if any
+func graphQLOperationName(body []byte) string {
+	var request struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(body, &request) != nil || request.Query == "" {
+		return ""
+	}
+	return operationName(request.Query)
+}
+
+// WithCassette sends the client's requests through a recording or replaying cassette
+func WithCassette(cassette *Cassette) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = &http.Client{Transport: cassette, Timeout: 30 * time.Second}
+	}
+}
+
+var (
+	envCassetteMu sync.Mutex
+	envCassettes  = map[string]*Cassette{}
+)
+
+// envCassetteOption returns WithCassette for SHOPIFY_CASSETTE and SHOPIFY_CASSETTE_MODE,
+// or nil when no cassette is configured. The cassette is opened once per process so
+// every client built from the environment shares the recording or replay position.
+func envCassetteOption() ClientOption {
+	path := strings.TrimSpace(os.Getenv("SHOPIFY_CASSETTE"))
+	if path == "" {
+		return nil
+	}
+
+	envCassetteMu.Lock()
+	defer envCassetteMu.Unlock()
+	modeName := os.Getenv("SHOPIFY_CASSETTE_MODE")
+	key := modeName + " " + path
+	cassette, ok := envCassettes[key]
+	if !ok {
+		mode, err := ParseCassetteMode(modeName)
+		if err == nil {
+			cassette, err = OpenCassette(path, mode)
+		}
+		if err != nil {
+			// Reported on the first call, like missing credentials
+			return WithHTTPClient(&http.Client{Transport: failingTransport{err}})
+		}
+		envCassettes[key] = cassette
+	}
+	return WithCassette(cassette)
+}
+
+// failingTransport fails every request with err
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseCassetteMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    CassetteMode
+		wantErr bool
+	}{
+		{"", CassetteReplay, false},
+		{"replay", CassetteReplay, false},
+		{" Record ", CassetteRecord, false},
+		{"rewind", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCassetteMode(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseCassetteMode(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// recordShopQueries records n shop queries answered with increasing numbers
+// and returns the cassette path
+func recordShopQueries(t *testing.T, n int) string {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":{"shop":{"name":"Shop %d"}}}`, requests.Add(1))
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "shop.json")
+	cassette := NewCassetteRecorder(path, server.Client().Transport)
+	if err := cassette.SetMeta("scenario", "shop"); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(strings.TrimPrefix(server.URL, "https://"), "shpat_secret",
+		WithCassette(cassette), WithRateLimiter(NewRateLimiter()))
+	for range n {
+		if _, err := client.postGraphQL(context.Background(), "query { shop { name } }", nil); err != nil {
+			t.Fatalf("recording: %v", err)
+		}
+	}
+	return path
+}
+
+func TestCassetteRecordRedactsToken(t *testing.T) {
+	path := recordShopQueries(t, 1)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "shpat_secret") {
+		t.Error("cassette contains the access token")
+	}
+	if !strings.Contains(string(content), "[REDACTED]") {
+		t.Error("cassette has no redacted X-Shopify-Access-Token header")
+	}
+}
+
+func TestCassetteReplay(t *testing.T) {
+	path := recordShopQueries(t, 2)
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if cassette.Mode() != CassetteReplay || cassette.Meta("scenario") != "shop" {
+		t.Fatalf("mode, scenario = %v, %q, want replay, shop", cassette.Mode(), cassette.Meta("scenario"))
+	}
+	// Replay ignores the shop domain and token, and whitespace in the document
+	client := NewClient("cassette.myshopify.com", "replay",
+		WithCassette(cassette), WithRateLimiter(NewRateLimiter()), WithRetryPolicy(NoRetry))
+	query := "query {\n\tshop { name }\n}"
+
+	for _, want := range []string{"Shop 1", "Shop 2"} {
+		data, err := client.postGraphQL(context.Background(), query, nil)
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("replayed %s, want %s", data, want)
+		}
+	}
+	if unplayed := cassette.Unplayed(); len(unplayed) != 0 {
+		t.Errorf("%d interactions unplayed, want 0", len(unplayed))
+	}
+
+	// Every recorded response has been played
+	if _, err := client.postGraphQL(context.Background(), query, nil); !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("third replay = %v, want %v", err, ErrCassetteMiss)
+	}
+}
+
+func TestCassetteReplayMiss(t *testing.T) {
+	cassette, err := LoadCassette(recordShopQueries(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("cassette.myshopify.com", "replay",
+		WithCassette(cassette), WithRateLimiter(NewRateLimiter()), WithRetryPolicy(NoRetry))
+
+	_, err = client.postGraphQL(context.Background(), "query Other { shop { id } }", nil)
+	if !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("postGraphQL = %v, want %v", err, ErrCassetteMiss)
+	}
+	if !strings.Contains(err.Error(), "(Other)") {
+		t.Errorf("error %q does not name the operation", err)
+	}
+	if len(cassette.Unplayed()) != 1 {
+		t.Error("a miss marked the recorded interaction as played")
+	}
+}
+
+func TestCassetteKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"key order", `{"query":"q","variables":{"a":1,"b":2}}`, `{"variables":{"b":2,"a":1},"query":"q"}`, true},
+		{"query whitespace", `{"query":"query {\n  shop { id }\n}"}`, `{"query":"query { shop { id } }"}`, true},
+		{"number precision", `{"variables":{"rate":0.05}}`, `{"variables":{"rate":0.050}}`, false},
+		{"different variables", `{"query":"q","variables":{"id":"1"}}`, `{"query":"q","variables":{"id":"2"}}`, false},
+		{"not JSON", `a=1`, `a=1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := cassetteKey(http.MethodPost, "/graphql.json", []byte(tt.a))
+			b := cassetteKey(http.MethodPost, "/graphql.json", []byte(tt.b))
+			if (a == b) != tt.same {
+				t.Errorf("keys equal = %v, want %v:\n%s\n%s", a == b, tt.same, a, b)
+			}
+		})
+	}
+}
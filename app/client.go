@@ -0,0 +1,543 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultAPIVersion is the Admin API version used when neither the Client nor
+// SHOPIFY_API_VERSION sets one. Bump it here when upgrading to a new quarterly release.
+const DefaultAPIVersion = "2025-10"
+
+// ConfiguredAPIVersion returns SHOPIFY_API_VERSION, or DefaultAPIVersion when it is not set.
+// NewClient uses it as the version of every client that does not pass WithAPIVersion.
+func ConfiguredAPIVersion() string {
+	if version := strings.TrimSpace(os.Getenv("SHOPIFY_API_VERSION")); version != "" {
+		return version
+	}
+	return DefaultAPIVersion
+}
+
+// DefaultUserAgent is sent with every request unless the Client overrides it
+const DefaultUserAgent = "shopify-demo"
+
+// Client talks to the Admin API (GraphQL and REST) of a single Shopify shop.
+// Create one per shop with NewClient; a Client is safe for concurrent use.
+type Client struct {
+	// The shop's myshopify domain, e.g. "my-store.myshopify.com"
+	ShopDomain string
+	// Admin API access token sent as X-Shopify-Access-Token
+	AccessToken string
+	// Admin API version, e.g. "2025-10". Empty means ConfiguredAPIVersion().
+	APIVersion string
+	// HTTP client used for all requests. Nil means a client with a 30s timeout.
+	HTTPClient *http.Client
+	// User-Agent header. Empty means DefaultUserAgent.
+	UserAgent string
+	// Rate limiter for GraphQL cost and REST call budgets.
+	// Nil means the limiter shared by every client of the same shop.
+	RateLimiter *RateLimiter
+	// Retry policy for failed calls. Nil means DefaultRetryPolicy().
+	RetryPolicy RetryPolicy
+	// Store of idempotency keys used by the *Idempotent order creation methods.
+	// Nil means only Shopify is checked for an existing order.
+	IdempotencyStore IdempotencyStore
+	// Log of calls Shopify reported as deprecated (X-Shopify-API-Deprecated-Reason).
+	// Nil means the process-wide DefaultDeprecationLog().
+	Deprecations *DeprecationLog
+	// Structured logger for API calls. Nil means DefaultLogger().
+	Logger *slog.Logger
+	// Journal of every mutation sent and its result. Nil means DefaultAuditJournal(),
+	// which is only enabled when SHOPIFY_AUDIT_FILE is set.
+	AuditJournal *AuditJournal
+	// Prometheus metrics of API calls. Nil means DefaultMetrics().
+	Metrics *Metrics
+	// OpenTelemetry tracer provider for API call spans. Nil means the global provider,
+	// which records nothing unless the program installs one.
+	TracerProvider trace.TracerProvider
+}
+
+// ClientOption configures optional Client settings in NewClient
+type ClientOption func(*Client)
+
+// WithAPIVersion sets the Admin API version used by the client
+func WithAPIVersion(version string) ClientOption {
+	return func(c *Client) {
+		c.APIVersion = version
+	}
+}
+
+// WithHTTPClient sets the underlying http.Client (timeouts, transport, proxies)
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithRateLimiter gives the client its own rate limiter instead of the shared per-shop one
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.RateLimiter = limiter
+	}
+}
+
+// WithRetryPolicy sets how failed calls are retried. Use NoRetry to disable retries.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithIdempotencyStore sets where idempotency keys of created orders are recorded
+func WithIdempotencyStore(store IdempotencyStore) ClientOption {
+	return func(c *Client) {
+		c.IdempotencyStore = store
+	}
+}
+
+// WithDeprecationLog sets where calls reported as deprecated are recorded
+func WithDeprecationLog(log *DeprecationLog) ClientOption {
+	return func(c *Client) {
+		c.Deprecations = log
+	}
+}
+
+// WithLogger sets the structured logger used for API calls
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.Logger = logger
+	}
+}
+
+// WithAuditJournal records every mutation sent by the client and its result in journal
+func WithAuditJournal(journal *AuditJournal) ClientOption {
+	return func(c *Client) {
+		c.AuditJournal = journal
+	}
+}
+
+// NewClient creates a client for the given shop domain and access token
+func NewClient(shopDomain, accessToken string, opts ...ClientOption) *Client {
+	c := &Client{
+		ShopDomain:  shopDomain,
+		AccessToken: accessToken,
+		APIVersion:  ConfiguredAPIVersion(),
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+		UserAgent:   DefaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// With returns a copy of the client with opts applied, e.g. a registry client
+// with an idempotency store. c is not changed; the copy shares its rate limiter.
+func (c *Client) With(opts ...ClientOption) *Client {
+	clone := *c
+	for _, opt := range opts {
+		opt(&clone)
+	}
+	return &clone
+}
+
+// NewClientFromEnv creates a client from SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET.
+// When SHOPIFY_CASSETTE is set, requests are recorded to or replayed from that
+// cassette file (SHOPIFY_CASSETTE_MODE=record or replay), and spans are exported to
+// OTEL_EXPORTER_OTLP_ENDPOINT when it is set; opts are applied after these.
+// Missing variables are reported when the client makes its first call.
+func NewClientFromEnv(opts ...ClientOption) *Client {
+	opts = append(envClientOptions(), opts...)
+	return NewClient(os.Getenv("SHOPIFY_SHOP_DOMAIN"), os.Getenv("SHOPIFY_API_SECRET"), opts...)
+}
+
+// envClientOptions returns the options set by the environment: the tracer
+// provider of OTEL_EXPORTER_OTLP_ENDPOINT and the cassette of SHOPIFY_CASSETTE
+func envClientOptions() []ClientOption {
+	var opts []ClientOption
+	if telemetry := envTelemetryOption(); telemetry != nil {
+		opts = append(opts, telemetry)
+	}
+	if cassette := envCassetteOption(); cassette != nil {
+		opts = append(opts, cassette)
+	}
+	return opts
+}
+
+var (
+	defaultClientMu sync.RWMutex
+	defaultClient   *Client
+)
+
+// SetDefaultClient sets the client used by the package-level functions.
+// Passing nil restores the environment-based default.
+func SetDefaultClient(c *Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	defaultClient = c
+}
+
+// DefaultClient returns the client used by the package-level functions.
+// Unless SetDefaultClient was called, it is built from the environment on each call.
+func DefaultClient() *Client {
+	defaultClientMu.RLock()
+	c := defaultClient
+	defaultClientMu.RUnlock()
+	if c != nil {
+		return c
+	}
+	return NewClientFromEnv()
+}
+
+// checkCredentials returns an error naming what the client is missing, its shop
+// domain or its access token. NewClientFromEnv reads them from SHOPIFY_SHOP_DOMAIN
+// and SHOPIFY_API_SECRET, so the error mentions those too.
+func (c *Client) checkCredentials() error {
+	switch {
+	case c.ShopDomain == "" && c.AccessToken == "":
+		return errors.New("client has no shop domain and no access token (SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET when built from the environment)")
+	case c.ShopDomain == "":
+		return errors.New("client has no shop domain (SHOPIFY_SHOP_DOMAIN when built from the environment)")
+	case c.AccessToken == "":
+		return fmt.Errorf("client for %s has no access token (SHOPIFY_API_SECRET when built from the environment)", c.ShopDomain)
+	}
+	return nil
+}
+
+func (c *Client) apiVersion() string {
+	if c.APIVersion == "" {
+		return ConfiguredAPIVersion()
+	}
+	return c.APIVersion
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return &http.Client{Timeout: 30 * time.Second}
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) limiter() *RateLimiter {
+	if c.RateLimiter != nil {
+		return c.RateLimiter
+	}
+	return SharedRateLimiter(c.ShopDomain)
+}
+
+func (c *Client) retryPolicy() RetryPolicy {
+	if c.RetryPolicy != nil {
+		return c.RetryPolicy
+	}
+	return DefaultRetryPolicy()
+}
+
+// retry asks the retry policy whether a failed attempt is sent again and waits before returning true.
+// It returns false when the policy gives up and an error when ctx is done while waiting.
+func (c *Client) retry(ctx context.Context, attempt RetryAttempt) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	delay, ok := c.retryPolicy().Retry(attempt)
+	if !ok {
+		return false, nil
+	}
+	c.observeRetry(ctx, attempt, delay)
+	c.logger().LogAttrs(ctx, slog.LevelWarn, "retrying shopify call",
+		slog.String("operation", attempt.Operation),
+		slog.Int("attempt", attempt.Attempt),
+		slog.Duration("delay", delay),
+		slog.String("error", attempt.Err.Error()),
+	)
+	if err := sleepContext(ctx, delay); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// graphQLURL returns the Admin GraphQL endpoint of the shop
+func (c *Client) graphQLURL() string {
+	return fmt.Sprintf("https://%s/admin/api/%s/graphql.json", c.ShopDomain, c.apiVersion())
+}
+
+// restURL returns the Admin REST endpoint for a path such as "orders/123.json"
+func (c *Client) restURL(path string) string {
+	return fmt.Sprintf("https://%s/admin/api/%s/%s", c.ShopDomain, c.apiVersion(), strings.TrimPrefix(path, "/"))
+}
+
+// newRequest creates a request bound to ctx with the authentication and content headers set
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Shopify-Access-Token", c.AccessToken)
+	if key := IdempotencyKey(ctx); key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	} else {
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+
+	return req, nil
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+// It returns ctx.Err() when the wait was cut short.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do executes a request and returns the response (with its body closed) and the full body
+func (c *Client) do(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp, bodyBytes, nil
+}
+
+// doREST sends a REST Admin API request. payload is JSON-encoded when not nil.
+// The request waits for room in the shop's REST bucket, and 429, 5xx and network
+// failures are retried according to the client's RetryPolicy.
+// The status code is returned as-is; callers decide which codes count as success.
+func (c *Client) doREST(ctx context.Context, method, path string, payload interface{}) (status int, response []byte, err error) {
+	if err := c.checkCredentials(); err != nil {
+		return 0, nil, err
+	}
+
+	var data []byte
+	if payload != nil {
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	call := newRESTCall(method, path, data)
+	ctx = c.startCallSpan(ctx, call)
+	defer func() {
+		call.status, call.response, call.err = status, response, err
+		c.observe(ctx, call)
+	}()
+
+	limiter := c.limiter()
+	idempotent := restIdempotent(ctx, method)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+
+		waited, err := limiter.WaitREST(ctx)
+		c.observeThrottleWait(ctx, call, waited)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		attemptCtx, span := c.startAttemptSpan(ctx, call, method, path, attempt)
+		req, err := c.newRequest(attemptCtx, method, c.restURL(path), body)
+		if err != nil {
+			endSpan(span, err)
+			return 0, nil, err
+		}
+		call.attempts = attempt
+		resp, bodyBytes, err := c.do(req)
+		c.endAttempt(span, call, resp, err)
+
+		var retryAfter time.Duration
+		attemptErr := err
+		if err == nil {
+			c.recordDeprecation(call.operation, resp.Header)
+			retryAfter = limiter.UpdateREST(resp.StatusCode, resp.Header)
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+				attemptErr = &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+			}
+		}
+
+		if attemptErr != nil {
+			retry, waitErr := c.retry(ctx, RetryAttempt{
+				Operation:  call.operation,
+				Attempt:    attempt,
+				Elapsed:    time.Since(start),
+				Err:        attemptErr,
+				Idempotent: idempotent,
+				RetryAfter: retryAfter,
+			})
+			if waitErr != nil {
+				return 0, nil, waitErr
+			}
+			if retry {
+				continue
+			}
+		}
+
+		if err != nil {
+			return 0, nil, err
+		}
+		return resp.StatusCode, bodyBytes, nil
+	}
+}
+
+// graphQLEnvelope is the part of a GraphQL response needed for throttling decisions
+type graphQLEnvelope struct {
+	Errors     []GraphQLError `json:"errors,omitempty"`
+	Extensions struct {
+		Cost *QueryCost `json:"cost,omitempty"`
+	} `json:"extensions"`
+}
+
+// throttled reports whether the response was rejected with a THROTTLED error
+func (e *graphQLEnvelope) throttled() bool {
+	for _, gqlErr := range e.Errors {
+		if gqlErr.Extensions.Code == ErrorCodeThrottled {
+			return true
+		}
+	}
+	return false
+}
+
+// postGraphQL sends a GraphQL document and returns the raw response body of a 200 response.
+// The request waits for enough budget in the shop's cost bucket, and THROTTLED,
+// 429, 5xx and network failures are retried according to the client's RetryPolicy.
+func (c *Client) postGraphQL(ctx context.Context, query string, variables map[string]interface{}) (response []byte, err error) {
+	if err := c.checkCredentials(); err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	call := newGraphQLCall(query, variables)
+	ctx = c.startCallSpan(ctx, call)
+	defer func() {
+		call.response = response
+		if call.err == nil {
+			call.err = err
+		}
+		c.observe(ctx, call)
+	}()
+
+	limiter := c.limiter()
+	idempotent := graphQLIdempotent(ctx, query)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		waited, err := limiter.WaitGraphQL(ctx, query)
+		c.observeThrottleWait(ctx, call, waited)
+		if err != nil {
+			return nil, err
+		}
+
+		attemptCtx, span := c.startAttemptSpan(ctx, call, http.MethodPost, "graphql.json", attempt)
+		req, err := c.newRequest(attemptCtx, http.MethodPost, c.graphQLURL(), bytes.NewReader(body))
+		if err != nil {
+			endSpan(span, err)
+			return nil, err
+		}
+		call.attempts = attempt
+		resp, bodyBytes, err := c.do(req)
+		c.endAttempt(span, call, resp, err)
+
+		var retryAfter time.Duration
+		attemptErr := err
+		if err == nil {
+			call.status = resp.StatusCode
+			c.recordDeprecation(call.operation, resp.Header)
+			if resp.StatusCode != http.StatusOK {
+				retryAfter = limiter.UpdateREST(resp.StatusCode, resp.Header)
+				attemptErr = &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+			} else {
+				var envelope graphQLEnvelope
+				if json.Unmarshal(bodyBytes, &envelope) == nil {
+					limiter.UpdateGraphQL(query, envelope.Extensions.Cost)
+					call.cost = envelope.Extensions.Cost
+					if len(envelope.Errors) > 0 {
+						attemptErr = &GraphQLErrors{Errors: envelope.Errors}
+						if envelope.throttled() {
+							retryAfter = limiter.ThrottleDelay(query)
+						}
+					}
+				}
+			}
+		}
+
+		if attemptErr != nil {
+			retry, waitErr := c.retry(ctx, RetryAttempt{
+				Operation:  call.operation,
+				Attempt:    attempt,
+				Elapsed:    time.Since(start),
+				Err:        attemptErr,
+				Idempotent: idempotent,
+				RetryAfter: retryAfter,
+			})
+			if waitErr != nil {
+				return nil, waitErr
+			}
+			if retry {
+				continue
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		// Check HTTP status
+		if resp.StatusCode != http.StatusOK {
+			return nil, attemptErr
+		}
+		// GraphQL errors are left in the body for the caller to report,
+		// and logged as the call's error
+		call.err = attemptErr
+		return bodyBytes, nil
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a TLS test server running handler, with
+// its own rate limiter, fast retries and no logging
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	policy := &ExponentialBackoff{MaxAttempts: 3, InitialInterval: time.Millisecond}
+	return NewClient(strings.TrimPrefix(server.URL, "https://"), "token",
+		WithHTTPClient(server.Client()), WithRetryPolicy(policy), WithRateLimiter(NewRateLimiter()),
+		WithLogger(slog.New(slog.DiscardHandler)))
+}
+
+func TestClientWith(t *testing.T) {
+	limiter := NewRateLimiter()
+	c := NewClient("shop.myshopify.com", "token", WithRateLimiter(limiter))
+	store := NewMemoryIdempotencyStore()
+
+	clone := c.With(WithIdempotencyStore(store), WithAPIVersion("2025-01"))
+	if clone.IdempotencyStore != store || clone.APIVersion != "2025-01" {
+		t.Errorf("clone = %+v, want the options applied", clone)
+	}
+	if c.IdempotencyStore != nil || c.APIVersion == "2025-01" {
+		t.Errorf("original changed: %+v", c)
+	}
+	if clone.ShopDomain != c.ShopDomain || clone.AccessToken != c.AccessToken || clone.RateLimiter != limiter {
+		t.Errorf("clone = %+v, want the shop and rate limiter of the original", clone)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name              string
+		shopDomain, token string
+		want              string
+	}{
+		{"complete", "shop.myshopify.com", "token", ""},
+		{"no token", "shop.myshopify.com", "", "client for shop.myshopify.com has no access token"},
+		{"no shop domain", "", "token", "client has no shop domain"},
+		{"neither", "", "", "client has no shop domain and no access token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewClient(tt.shopDomain, tt.token).checkCredentials()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkCredentials = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("checkCredentials = %v, want %q...", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,16 @@
 package app
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // DraftOrderInput represents the input for creating a draft order
@@ -84,22 +83,31 @@ type DraftOrderInput struct {
 
 // DraftLineItemInput represents a line item in a draft order with discount support
 type DraftLineItemInput struct {
-	VariantID       string                `json:"variantId"`
-	Quantity        int                   `json:"quantity"`
-	OriginalUnitPrice float64             `json:"originalUnitPrice,omitempty"` // Custom price for the line item
-	Title           string                `json:"title,omitempty"`
-	AppliedDiscount *AppliedDiscountInput `json:"appliedDiscount,omitempty"`
-	Taxable         bool                  `json:"taxable,omitempty"`
+	VariantID         string                `json:"variantId"`
+	Quantity          int                   `json:"quantity"`
+	OriginalUnitPrice Money                 `json:"originalUnitPrice,omitzero"` // Custom price for the line item
+	Title             string                `json:"title,omitempty"`
+	AppliedDiscount   *AppliedDiscountInput `json:"appliedDiscount,omitempty"`
+	Taxable           bool                  `json:"taxable,omitempty"`
 	// Tax lines for this line item
 	TaxLines []TaxLineInput `json:"taxLines,omitempty"`
 }
 
 // AppliedDiscountInput represents a discount applied to a line item
 type AppliedDiscountInput struct {
-	Description string  `json:"description,omitempty"`
-	ValueType   string  `json:"valueType"` // PERCENTAGE or FIXED_AMOUNT
-	Value       float64 `json:"value"`
-	Title       string  `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	ValueType   string          `json:"valueType"` // PERCENTAGE or FIXED_AMOUNT
+	Value       decimal.Decimal `json:"value"`     // Percentage (e.g. 10 for 10%) or amount
+	Title       string          `json:"title,omitempty"`
+}
+
+// MarshalJSON writes Value as a JSON number, as Shopify types it Float
+func (d AppliedDiscountInput) MarshalJSON() ([]byte, error) {
+	type appliedDiscountInput AppliedDiscountInput
+	return json.Marshal(struct {
+		appliedDiscountInput
+		Value json.Number `json:"value"`
+	}{appliedDiscountInput(d), json.Number(d.Value.String())})
 }
 
 // TaxLineInput represents a tax line input for draft order line items
@@ -108,7 +116,7 @@ type TaxLineInput struct {
 	// The name of the tax (required)
 	Title string `json:"title"`
 	// The proportion of the line item price that the tax represents as a decimal
-	Rate float64 `json:"rate,omitempty"`
+	Rate decimal.Decimal `json:"rate,omitzero"`
 	// The amount of tax, in shop and presentment currencies
 	PriceSet *MoneyBagInput `json:"priceSet,omitempty"`
 	// Whether the channel that submitted the tax line is liable for remitting
@@ -117,13 +125,26 @@ type TaxLineInput struct {
 	Source string `json:"source,omitempty"`
 }
 
+// MarshalJSON writes Rate as a JSON number, as Shopify types it Float
+func (tl TaxLineInput) MarshalJSON() ([]byte, error) {
+	type taxLineInput TaxLineInput
+	var rate json.Number
+	if !tl.Rate.IsZero() {
+		rate = json.Number(tl.Rate.String())
+	}
+	return json.Marshal(struct {
+		taxLineInput
+		Rate json.Number `json:"rate,omitempty"`
+	}{taxLineInput(tl), rate})
+}
+
 // OrderCreateTaxLineInput represents tax line input for orderCreate mutation
 // Based on: https://shopify.dev/docs/api/admin-graphql/latest/input-objects/ordercreatetaxlineinput
 type OrderCreateTaxLineInput struct {
 	// The name of the tax line to create (required)
 	Title string `json:"title"`
 	// The proportion of the item price that the tax represents as a decimal (required)
-	Rate string `json:"rate"` // Using string for Decimal type
+	Rate decimal.Decimal `json:"rate"`
 	// The amount of tax to be charged on the item
 	PriceSet *MoneyBagInput `json:"priceSet,omitempty"`
 	// Whether the channel that submitted the tax line is liable for remitting (default: false)
@@ -137,54 +158,68 @@ type MoneyBagInput struct {
 
 // MoneyInput represents a monetary value
 type MoneyInput struct {
-	Amount       string `json:"amount"`
+	Amount       Money  `json:"amount"`
 	CurrencyCode string `json:"currencyCode"`
 }
 
+// NewMoneyBagInput returns a MoneyBagInput of an amount in the shop's currency
+func NewMoneyBagInput(amount Money) *MoneyBagInput {
+	return &MoneyBagInput{ShopMoney: &MoneyInput{Amount: amount, CurrencyCode: amount.Currency()}}
+}
+
 // OrderInput represents the input for creating an order (kept for backward compatibility)
 type OrderInput struct {
-	Email           string                   `json:"email,omitempty"`
-	LineItems       []LineItemInput          `json:"lineItems"`
-	ShippingAddress *MailingAddressInput     `json:"shippingAddress,omitempty"`
-	BillingAddress  *MailingAddressInput     `json:"billingAddress,omitempty"`
-	FinancialStatus string                   `json:"financialStatus,omitempty"` // PENDING, AUTHORIZED, PARTIALLY_PAID, PAID, PARTIALLY_REFUNDED, REFUNDED, VOIDED
-	Customer        *CustomerInput           `json:"customer,omitempty"`
-	Note            string                   `json:"note,omitempty"`
-	Tags            []string                 `json:"tags,omitempty"`
-	Metafields      []MetafieldInput         `json:"metafields,omitempty"`
-	TaxLines        []OrderCreateTaxLineInput `json:"taxLines,omitempty"` // Order-level tax lines
-	AppliedDiscount *AppliedDiscountInput    `json:"appliedDiscount,omitempty"` // Order-level discount (deprecated, use DiscountCode instead)
-	DiscountCode    *OrderCreateDiscountCodeInput `json:"discountCode,omitempty"` // Order-level discount via discountCode
+	Email           string                        `json:"email,omitempty"`
+	LineItems       []LineItemInput               `json:"lineItems"`
+	ShippingAddress *MailingAddressInput          `json:"shippingAddress,omitempty"`
+	BillingAddress  *MailingAddressInput          `json:"billingAddress,omitempty"`
+	FinancialStatus string                        `json:"financialStatus,omitempty"` // PENDING, AUTHORIZED, PARTIALLY_PAID, PAID, PARTIALLY_REFUNDED, REFUNDED, VOIDED
+	Customer        *CustomerInput                `json:"customer,omitempty"`
+	Note            string                        `json:"note,omitempty"`
+	Tags            []string                      `json:"tags,omitempty"`
+	Metafields      []MetafieldInput              `json:"metafields,omitempty"`
+	TaxLines        []OrderCreateTaxLineInput     `json:"taxLines,omitempty"`        // Order-level tax lines
+	AppliedDiscount *AppliedDiscountInput         `json:"appliedDiscount,omitempty"` // Order-level discount (deprecated, use DiscountCode instead)
+	DiscountCode    *OrderCreateDiscountCodeInput `json:"discountCode,omitempty"`    // Order-level discount via discountCode
 }
 
 // OrderCreateDiscountCodeInput represents discount code input for orderCreate mutation
 // Based on: https://shopify.dev/docs/api/admin-graphql/latest/input-objects/ordercreatediscountcodeinput
 type OrderCreateDiscountCodeInput struct {
-	ItemFixedDiscountCode *ItemFixedDiscountCodeInput `json:"itemFixedDiscountCode,omitempty"`
+	ItemFixedDiscountCode      *ItemFixedDiscountCodeInput      `json:"itemFixedDiscountCode,omitempty"`
 	ItemPercentageDiscountCode *ItemPercentageDiscountCodeInput `json:"itemPercentageDiscountCode,omitempty"`
 }
 
 // ItemFixedDiscountCodeInput represents a fixed amount discount code
 type ItemFixedDiscountCodeInput struct {
-	Code      string    `json:"code"`      // Description of the discount
+	Code      string         `json:"code"`      // Description of the discount
 	AmountSet *MoneyBagInput `json:"amountSet"` // Fixed discount amount
 }
 
 // ItemPercentageDiscountCodeInput represents a percentage discount code
 type ItemPercentageDiscountCodeInput struct {
-	Code      string  `json:"code"`      // Description of the discount
-	Percentage float64 `json:"percentage"` // Percentage discount (0-100)
+	Code       string          `json:"code"`       // Description of the discount
+	Percentage decimal.Decimal `json:"percentage"` // Percentage discount (0-100)
+}
+
+// MarshalJSON writes Percentage as a JSON number, as Shopify types it Float
+func (d ItemPercentageDiscountCodeInput) MarshalJSON() ([]byte, error) {
+	type itemPercentageDiscountCodeInput ItemPercentageDiscountCodeInput
+	return json.Marshal(struct {
+		itemPercentageDiscountCodeInput
+		Percentage json.Number `json:"percentage"`
+	}{itemPercentageDiscountCodeInput(d), json.Number(d.Percentage.String())})
 }
 
 // LineItemInput represents a line item in an order
 type LineItemInput struct {
-	VariantID          string                   `json:"variantId"`
-	Quantity           int                       `json:"quantity"`
-	Price              string                    `json:"price,omitempty"` // Deprecated: use priceSet instead
-	PriceSet           *MoneyBagInput            `json:"priceSet,omitempty"` // Custom price after discount
-	Title              string                    `json:"title,omitempty"`
-	Properties         []LineItemPropertyInput   `json:"properties,omitempty"` // For notes about discounts
-	TaxLines           []OrderCreateTaxLineInput `json:"taxLines,omitempty"`
+	VariantID  string                    `json:"variantId"`
+	Quantity   int                       `json:"quantity"`
+	Price      Money                     `json:"price,omitzero"`     // Deprecated: use priceSet instead
+	PriceSet   *MoneyBagInput            `json:"priceSet,omitempty"` // Custom price after discount
+	Title      string                    `json:"title,omitempty"`
+	Properties []LineItemPropertyInput   `json:"properties,omitempty"` // For notes about discounts
+	TaxLines   []OrderCreateTaxLineInput `json:"taxLines,omitempty"`
 }
 
 // LineItemPropertyInput represents a property/note on a line item
@@ -195,7 +230,7 @@ type LineItemPropertyInput struct {
 
 // DiscountAllocationInput represents discount allocation for a line item
 type DiscountAllocationInput struct {
-	Amount string `json:"amount"`
+	Amount Money  `json:"amount"`
 	Title  string `json:"title,omitempty"`
 }
 
@@ -249,8 +284,8 @@ type LocalizedFieldInput struct {
 // - Shipping line price
 // If you need to specify shipping tax explicitly, add it as a separate tax line after order completion
 type ShippingLineInput struct {
-	Title string  `json:"title,omitempty"` // Shipping method title (e.g., "Car", "Standard Shipping")
-	Price float64 `json:"price,omitempty"` // Shipping cost (can include tax if using totalShippingIncTax)
+	Title string `json:"title,omitempty"` // Shipping method title (e.g., "Car", "Standard Shipping")
+	Price Money  `json:"price,omitzero"`  // Shipping cost (can include tax if using totalShippingIncTax)
 	// Note: Tax fields are NOT supported in ShippingLineInput
 	// Shopify will automatically calculate shipping tax based on address and tax settings
 }
@@ -287,8 +322,12 @@ type CompleteDraftOrderResponse struct {
 	Data struct {
 		DraftOrderComplete struct {
 			DraftOrder struct {
-				ID   string `json:"id"`
-				Name string `json:"name"`
+				ID    string `json:"id"`
+				Name  string `json:"name"`
+				Order *struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"order"`
 			} `json:"draftOrder"`
 			UserErrors []UserError `json:"userErrors"`
 		} `json:"draftOrderComplete"`
@@ -296,6 +335,79 @@ type CompleteDraftOrderResponse struct {
 	Errors []GraphQLError `json:"errors,omitempty"`
 }
 
+// draftOrderPayload is the payload of draftOrderUpdate, shaped like DraftOrderResponse's draftOrderCreate
+type draftOrderPayload struct {
+	DraftOrder struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"draftOrder"`
+	UserErrors []UserError `json:"userErrors"`
+}
+
+// MoneyV2 is an amount with its currency as returned by the Admin API. The
+// Amount has CurrencyCode as its currency.
+type MoneyV2 struct {
+	Amount       Money  `json:"amount"`
+	CurrencyCode string `json:"currencyCode"`
+}
+
+func (m *MoneyV2) UnmarshalJSON(data []byte) error {
+	type moneyV2 MoneyV2
+	if err := json.Unmarshal(data, (*moneyV2)(m)); err != nil {
+		return err
+	}
+	m.Amount = m.Amount.WithCurrency(m.CurrencyCode)
+	return nil
+}
+
+// MoneyBag is a MoneyBag with only shopMoney selected
+type MoneyBag struct {
+	ShopMoney MoneyV2 `json:"shopMoney"`
+}
+
+// TaxLine is a tax line of a draft order, order or line item
+type TaxLine struct {
+	Title          string          `json:"title"`
+	PriceSet       MoneyBag        `json:"priceSet"`
+	Rate           decimal.Decimal `json:"rate"`
+	RatePercentage decimal.Decimal `json:"ratePercentage"`
+}
+
+// AppliedDiscount is the discount applied to a draft order line item
+type AppliedDiscount struct {
+	Title       string          `json:"title"`
+	ValueType   string          `json:"valueType"`
+	Value       decimal.Decimal `json:"value"`
+	Description string          `json:"description"`
+}
+
+// DraftOrderLineItem is a line item as selected by QueryDraftOrder
+type DraftOrderLineItem struct {
+	ID                   string           `json:"id"`
+	Title                string           `json:"title"`
+	Taxable              bool             `json:"taxable"`
+	OriginalUnitPriceSet MoneyBag         `json:"originalUnitPriceSet"`
+	AppliedDiscount      *AppliedDiscount `json:"appliedDiscount"`
+}
+
+// DraftOrderDetails is the draft order returned by QueryDraftOrder
+type DraftOrderDetails struct {
+	ID            string                         `json:"id"`
+	Name          string                         `json:"name"`
+	TotalTaxSet   MoneyBag                       `json:"totalTaxSet"`
+	TaxLines      []TaxLine                      `json:"taxLines"`
+	TaxesIncluded bool                           `json:"taxesIncluded"`
+	TaxExempt     bool                           `json:"taxExempt"`
+	LineItems     Connection[DraftOrderLineItem] `json:"lineItems"`
+}
+
+// CalculatedDraftOrder is the result of CalculateDraftOrder
+type CalculatedDraftOrder struct {
+	TotalTaxSet   MoneyBag  `json:"totalTaxSet"`
+	TaxLines      []TaxLine `json:"taxLines"`
+	TotalPriceSet MoneyBag  `json:"totalPriceSet"`
+}
+
 // OrderResponse represents the response from orderCreate mutation (kept for backward compatibility)
 type OrderResponse struct {
 	Data struct {
@@ -305,25 +417,16 @@ type OrderResponse struct {
 				Name          string `json:"name"`
 				Email         string `json:"email"`
 				TotalPriceSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currencyCode"`
-					} `json:"shopMoney"`
+					ShopMoney MoneyV2 `json:"shopMoney"`
 				} `json:"totalPriceSet"`
 				TotalTaxSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currencyCode"`
-					} `json:"shopMoney"`
+					ShopMoney MoneyV2 `json:"shopMoney"`
 				} `json:"totalTaxSet"`
 				TaxLines []struct {
-					Title string      `json:"title"`
-					Rate  interface{} `json:"rate"` // Can be number or string
+					Title    string          `json:"title"`
+					Rate     decimal.Decimal `json:"rate"` // Number or string
 					PriceSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currencyCode"`
-						} `json:"shopMoney"`
+						ShopMoney MoneyV2 `json:"shopMoney"`
 					} `json:"priceSet"`
 				} `json:"taxLines,omitempty"`
 				CreatedAt   string `json:"createdAt"`
@@ -342,6 +445,8 @@ type OrderInfo struct {
 	DraftID           string
 	DraftName         string
 	FulfillmentOrders []FulfillmentOrderInfo
+	// True when an order created earlier for the same idempotency key was returned
+	Replayed bool `json:"-"`
 }
 
 // FulfillmentOrderInfo represents fulfillment order information
@@ -368,7 +473,9 @@ type UserError struct {
 
 // GraphQLError represents a GraphQL error
 type GraphQLError struct {
-	Message    string `json:"message"`
+	Message string `json:"message"`
+	// Path to the field that failed, e.g. ["order", "lineItems"]
+	Path       []interface{} `json:"path,omitempty"`
 	Extensions struct {
 		Code string `json:"code,omitempty"`
 	} `json:"extensions,omitempty"`
@@ -376,59 +483,22 @@ type GraphQLError struct {
 
 // CallAdminGraphQL is a helper function to call Shopify Admin GraphQL API
 // Exported for use in other packages
-func CallAdminGraphQL(query string, variables map[string]interface{}) (map[string]interface{}, error) {
-	return callAdminGraphQL(query, variables)
+func (c *Client) CallAdminGraphQL(ctx context.Context, query string, variables map[string]interface{}) (map[string]interface{}, error) {
+	return c.callAdminGraphQL(ctx, query, variables)
 }
 
-// callAdminGraphQL is a helper function to call Shopify Admin GraphQL API
-func callAdminGraphQL(query string, variables map[string]interface{}) (map[string]interface{}, error) {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return nil, fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/graphql.json", shopDomain, apiVersion)
-
-	payload := map[string]interface{}{
-		"query":     query,
-		"variables": variables,
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
+// CallAdminREST is a helper function to call Shopify Admin REST API
+// path is relative to the versioned API root, e.g. "orders.json" or "orders/123/transactions.json"
+// Exported for use in other packages
+func (c *Client) CallAdminREST(ctx context.Context, method, path string, payload interface{}) (int, []byte, error) {
+	return c.doREST(ctx, method, path, payload)
+}
 
-	// Read response
-	bodyBytes, err := io.ReadAll(resp.Body)
+// callAdminGraphQL is a helper function to call Shopify Admin GraphQL API
+func (c *Client) callAdminGraphQL(ctx context.Context, query string, variables map[string]interface{}) (map[string]interface{}, error) {
+	bodyBytes, err := c.postGraphQL(ctx, query, variables)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, err
 	}
 
 	var respData map[string]interface{}
@@ -438,14 +508,14 @@ func callAdminGraphQL(query string, variables map[string]interface{}) (map[strin
 
 	// GraphQL có thể trả lỗi nhưng HTTP vẫn 200
 	if errs, ok := respData["errors"]; ok {
-		return nil, fmt.Errorf("GraphQL errors: %v", errs)
+		return nil, newGraphQLErrors(errs)
 	}
 
 	return respData, nil
 }
 
 // CreateDraftOrder creates a draft order in Shopify using GraphQL Admin API
-func CreateDraftOrder(input DraftOrderInput) (*DraftOrderResponse, error) {
+func (c *Client) CreateDraftOrder(ctx context.Context, input DraftOrderInput) (*DraftOrderResponse, error) {
 	const mutation = `
 		mutation CreateDraftOrder($input: DraftOrderInput!) {
 			draftOrderCreate(input: $input) {
@@ -464,36 +534,16 @@ func CreateDraftOrder(input DraftOrderInput) (*DraftOrderResponse, error) {
 		"input": input,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to structured response
-	jsonData, err := json.Marshal(resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response: %w", err)
-	}
-
 	var response DraftOrderResponse
-	if err := json.Unmarshal(jsonData, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for user errors
-	if len(response.Data.DraftOrderCreate.UserErrors) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range response.Data.DraftOrderCreate.UserErrors {
-			errorMsg += fmt.Sprintf("%v: %s; ", err.Field, err.Message)
-		}
-		return nil, errors.New(errorMsg)
+	if err := c.graphQL(ctx, mutation, variables, &response.Data); err != nil {
+		return nil, err
 	}
 
 	return &response, nil
 }
 
 // QueryDraftOrder queries a draft order to get tax information
-func QueryDraftOrder(draftID string) (map[string]interface{}, error) {
+func (c *Client) QueryDraftOrder(ctx context.Context, draftID string) (*DraftOrderDetails, error) {
 	const query = `
 		query GetDraftOrder($id: ID!) {
 			draftOrder(id: $id) {
@@ -546,24 +596,23 @@ func QueryDraftOrder(draftID string) (map[string]interface{}, error) {
 		"id": draftID,
 	}
 
-	resp, err := callAdminGraphQL(query, variables)
+	data, err := Do[struct {
+		DraftOrder *DraftOrderDetails `json:"draftOrder"`
+	}](ctx, c, query, variables)
 	if err != nil {
 		return nil, err
 	}
-
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if draftOrder, ok := data["draftOrder"].(map[string]interface{}); ok {
-			return draftOrder, nil
-		}
+	if data.DraftOrder == nil {
+		return nil, fmt.Errorf("draft order %s: %w", draftID, ErrNotFound)
 	}
 
-	return nil, fmt.Errorf("draft order not found")
+	return data.DraftOrder, nil
 }
 
 // CalculateDraftOrder calculates tax and totals for a draft order using the draft order input
 // This allows previewing tax before completing the draft order
 // Note: draftOrderCalculate takes DraftOrderInput, not a draft order ID
-func CalculateDraftOrder(input DraftOrderInput) (map[string]interface{}, error) {
+func (c *Client) CalculateDraftOrder(ctx context.Context, input DraftOrderInput) (*CalculatedDraftOrder, error) {
 	const mutation = `
 		mutation CalculateDraftOrder($input: DraftOrderInput!) {
 			draftOrderCalculate(input: $input) {
@@ -603,33 +652,24 @@ func CalculateDraftOrder(input DraftOrderInput) (map[string]interface{}, error)
 		"input": input,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		DraftOrderCalculate struct {
+			CalculatedDraftOrder *CalculatedDraftOrder `json:"calculatedDraftOrder"`
+		} `json:"draftOrderCalculate"`
+	}](ctx, c, mutation, variables)
 	if err != nil {
 		return nil, err
 	}
-
-	// Check for errors
-	if errors, ok := resp["errors"].([]interface{}); ok && len(errors) > 0 {
-		return nil, fmt.Errorf("GraphQL errors: %v", errors)
-	}
-
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if calculate, ok := data["draftOrderCalculate"].(map[string]interface{}); ok {
-			if userErrors, ok := calculate["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-				return nil, fmt.Errorf("user errors: %v", userErrors)
-			}
-			if calculated, ok := calculate["calculatedDraftOrder"].(map[string]interface{}); ok {
-				return calculated, nil
-			}
-		}
+	if data.DraftOrderCalculate.CalculatedDraftOrder == nil {
+		return nil, fmt.Errorf("failed to calculate draft order")
 	}
 
-	return nil, fmt.Errorf("failed to calculate draft order")
+	return data.DraftOrderCalculate.CalculatedDraftOrder, nil
 }
 
 // UpdateDraftOrder updates a draft order using draftOrderUpdate mutation
 // This allows updating draft order before completing it
-func UpdateDraftOrder(draftID string, input DraftOrderInput) (*DraftOrderResponse, error) {
+func (c *Client) UpdateDraftOrder(ctx context.Context, draftID string, input DraftOrderInput) (*DraftOrderResponse, error) {
 	const mutation = `
 		mutation UpdateDraftOrder($id: ID!, $input: DraftOrderInput!) {
 			draftOrderUpdate(id: $id, input: $input) {
@@ -649,43 +689,36 @@ func UpdateDraftOrder(draftID string, input DraftOrderInput) (*DraftOrderRespons
 		"input": input,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		DraftOrderUpdate draftOrderPayload `json:"draftOrderUpdate"`
+	}](ctx, c, mutation, variables)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to structured response
-	jsonData, err := json.Marshal(resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response: %w", err)
-	}
-
+	// DraftOrderResponse is shared with CreateDraftOrder, so the updated draft is
+	// returned in the DraftOrderCreate field
 	var response DraftOrderResponse
-	if err := json.Unmarshal(jsonData, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for user errors
-	if len(response.Data.DraftOrderCreate.UserErrors) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range response.Data.DraftOrderCreate.UserErrors {
-			errorMsg += fmt.Sprintf("%v: %s; ", err.Field, err.Message)
-		}
-		return nil, errors.New(errorMsg)
-	}
-
+	response.Data.DraftOrderCreate = data.DraftOrderUpdate
 	return &response, nil
 }
 
 // CompleteDraftOrder completes a draft order to create a real order
 // paymentPending: false means the order will be marked as paid
-func CompleteDraftOrder(draftID string, paymentPending bool) (*OrderInfo, error) {
+func (c *Client) CompleteDraftOrder(ctx context.Context, draftID string, paymentPending bool) (_ *OrderInfo, err error) {
+	ctx, span := c.startSpan(ctx, "CompleteDraftOrder", attribute.String("shopify.draft_order_id", draftID))
+	defer func() { endSpan(span, err) }()
+
 	const mutation = `
 		mutation CompleteDraftOrder($id: ID!, $paymentPending: Boolean!) {
 			draftOrderComplete(id: $id, paymentPending: $paymentPending) {
 				draftOrder {
 					id
 					name
+					order {
+						id
+						name
+					}
 				}
 				userErrors {
 					field
@@ -699,36 +732,21 @@ func CompleteDraftOrder(draftID string, paymentPending bool) (*OrderInfo, error)
 		"paymentPending": paymentPending,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to structured response
-	jsonData, err := json.Marshal(resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response: %w", err)
-	}
-
 	var response CompleteDraftOrderResponse
-	if err := json.Unmarshal(jsonData, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.graphQL(ctx, mutation, variables, &response.Data); err != nil {
+		return nil, err
 	}
 
-	// Check for user errors
-	if len(response.Data.DraftOrderComplete.UserErrors) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range response.Data.DraftOrderComplete.UserErrors {
-			errorMsg += fmt.Sprintf("%v: %s; ", err.Field, err.Message)
-		}
-		return nil, errors.New(errorMsg)
+	// The order is normally returned with the completed draft; otherwise look it up
+	var orderID, orderName string
+	if order := response.Data.DraftOrderComplete.DraftOrder.Order; order != nil {
+		orderID, orderName = order.ID, order.Name
+	} else {
+		orderID, orderName, err = c.getOrderFromDraft(ctx, draftID)
 	}
-
-	// Get order info from draft
-	orderID, orderName, err := getOrderFromDraft(draftID)
 	if err == nil && orderID != "" {
 		// Query fulfillment orders (automatically created by Shopify)
-		fulfillmentOrders, _ := GetFulfillmentOrders(orderID)
+		fulfillmentOrders, _ := c.GetFulfillmentOrders(ctx, orderID)
 
 		return &OrderInfo{
 			OrderID:           orderID,
@@ -747,7 +765,7 @@ func CompleteDraftOrder(draftID string, paymentPending bool) (*OrderInfo, error)
 }
 
 // getOrderFromDraft queries the draft order to get the created order after completion
-func getOrderFromDraft(draftID string) (string, string, error) {
+func (c *Client) getOrderFromDraft(ctx context.Context, draftID string) (string, string, error) {
 	const query = `
 		query GetDraftOrderOrder($id: ID!) {
 			node(id: $id) {
@@ -766,50 +784,57 @@ func getOrderFromDraft(draftID string) (string, string, error) {
 		"id": draftID,
 	}
 
-	resp, err := callAdminGraphQL(query, variables)
+	data, err := Do[struct {
+		Node *struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			Order *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"order"`
+		} `json:"node"`
+	}](ctx, c, query, variables)
 	if err != nil {
 		return "", "", err
 	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return "", "", fmt.Errorf("unexpected GraphQL response, missing data")
+	if data.Node == nil {
+		return "", "", fmt.Errorf("draft order %s: %w", draftID, ErrNotFound)
 	}
-
-	node, ok := data["node"].(map[string]interface{})
-	if !ok || node == nil {
-		return "", "", fmt.Errorf("no node found for draft id %s", draftID)
-	}
-
-	order, ok := node["order"].(map[string]interface{})
-	if !ok || order == nil {
+	if data.Node.Order == nil {
 		return "", "", fmt.Errorf("draft has no linked order yet")
 	}
 
-	orderID, _ := order["id"].(string)
-	orderName, _ := order["name"].(string)
-	return orderID, orderName, nil
+	return data.Node.Order.ID, data.Node.Order.Name, nil
 }
 
 // GetFulfillmentOrders queries fulfillment orders for a given order ID
 // Note: FulfillmentOrders are automatically created when draftOrderComplete is called
 // This function will retry up to maxRetries times with increasing delays
-func GetFulfillmentOrders(orderID string) ([]FulfillmentOrderInfo, error) {
-	return GetFulfillmentOrdersWithRetry(orderID, 5, 3*time.Second)
+func (c *Client) GetFulfillmentOrders(ctx context.Context, orderID string) ([]FulfillmentOrderInfo, error) {
+	return c.GetFulfillmentOrdersWithRetry(ctx, orderID, 5, 3*time.Second)
 }
 
 // GetFulfillmentOrdersWithRetry queries fulfillment orders with retry logic
 // maxRetries: maximum number of retry attempts
-// initialDelay: initial delay between retries (will increase exponentially)
-func GetFulfillmentOrdersWithRetry(orderID string, maxRetries int, initialDelay time.Duration) ([]FulfillmentOrderInfo, error) {
+// initialDelay: initial delay between retries (grows 1.5x per attempt, with jitter)
+func (c *Client) GetFulfillmentOrdersWithRetry(ctx context.Context, orderID string, maxRetries int, initialDelay time.Duration) (_ []FulfillmentOrderInfo, err error) {
+	ctx, span := c.startSpan(ctx, "GetFulfillmentOrdersWithRetry", attribute.String("shopify.order_id", orderID))
+	defer func() { endSpan(span, err) }()
+
 	var lastErr error
-	delay := initialDelay
+	backoff := &ExponentialBackoff{
+		InitialInterval:     initialDelay,
+		Multiplier:          1.5,
+		RandomizationFactor: DefaultRandomizationFactor,
+	}
 
 	for i := 0; i < maxRetries; i++ {
-		fulfillmentOrders, err := getFulfillmentOrdersOnce(orderID)
+		span.SetAttributes(attribute.Int("shopify.polls", i+1))
+		fulfillmentOrders, err := c.getFulfillmentOrdersOnce(ctx, orderID)
 		if err == nil {
 			// If we got results (even if empty), return them
 			// Empty might mean routing not complete, but no error
+			span.SetAttributes(attribute.Int("shopify.fulfillment_orders", len(fulfillmentOrders)))
 			return fulfillmentOrders, nil
 		}
 
@@ -817,22 +842,44 @@ func GetFulfillmentOrdersWithRetry(orderID string, maxRetries int, initialDelay
 
 		// If not the last retry, wait before retrying
 		if i < maxRetries-1 {
-			time.Sleep(delay)
-			delay = time.Duration(float64(delay) * 1.5) // Exponential backoff
+			if err := sleepContext(ctx, backoff.Delay(i+1)); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	return nil, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
-// getFulfillmentOrdersOnce performs a single query for fulfillment orders
-func getFulfillmentOrdersOnce(orderID string) ([]FulfillmentOrderInfo, error) {
+// fulfillmentOrderLineItemNode is a FulfillmentOrderLineItem node as returned by GraphQL
+type fulfillmentOrderLineItemNode struct {
+	ID                string `json:"id"`
+	RemainingQuantity int    `json:"remainingQuantity"`
+	TotalQuantity     int    `json:"totalQuantity"`
+	LineItem          struct {
+		ID string `json:"id"`
+	} `json:"lineItem"`
+}
+
+// fulfillmentOrderNode is a FulfillmentOrder node with the first page of its line items
+type fulfillmentOrderNode struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	RequestStatus    string `json:"requestStatus"`
+	AssignedLocation struct {
+		Location struct {
+			ID string `json:"id"`
+		} `json:"location"`
+	} `json:"assignedLocation"`
+	LineItems Connection[fulfillmentOrderLineItemNode] `json:"lineItems"`
+}
+
+// getFulfillmentOrdersOnce performs a single (paginated) query for fulfillment orders
+func (c *Client) getFulfillmentOrdersOnce(ctx context.Context, orderID string) ([]FulfillmentOrderInfo, error) {
 	const query = `
-		query GetFulfillmentOrders($id: ID!) {
+		query GetFulfillmentOrders($id: ID!, $first: Int!, $after: String) {
 			order(id: $id) {
-				id
-				name
-				fulfillmentOrders(first: 10) {
+				fulfillmentOrders(first: $first, after: $after) {
 					edges {
 						node {
 							id
@@ -851,120 +898,112 @@ func getFulfillmentOrdersOnce(orderID string) ([]FulfillmentOrderInfo, error) {
 										totalQuantity
 										lineItem {
 											id
-											title
 										}
 									}
 								}
+								pageInfo {
+									hasNextPage
+									endCursor
+								}
 							}
 						}
 					}
+					pageInfo {
+						hasNextPage
+						endCursor
+					}
 				}
 			}
 		}`
 
+	// A fulfillment order with its first 50 line items costs about 105 points,
+	// so a page of 5 stays well within the 1000-point limit of a single query
 	variables := map[string]interface{}{
-		"id": orderID,
-	}
-
-	resp, err := callAdminGraphQL(query, variables)
-	if err != nil {
-		return nil, err
-	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected GraphQL response, missing data")
-	}
-
-	order, ok := data["order"].(map[string]interface{})
-	if !ok || order == nil {
-		return nil, fmt.Errorf("order not found for id %s", orderID)
-	}
-
-	fulfillmentOrdersData, ok := order["fulfillmentOrders"].(map[string]interface{})
-	if !ok {
-		// Debug: Check if order exists but fulfillmentOrders field is missing
-		// This might indicate access scope issues
-		return []FulfillmentOrderInfo{}, nil
-	}
-
-	edges, ok := fulfillmentOrdersData["edges"].([]interface{})
-	if !ok {
-		return []FulfillmentOrderInfo{}, nil
+		"id":    orderID,
+		"first": 5,
 	}
 
-	var fulfillmentOrders []FulfillmentOrderInfo
-	for _, edge := range edges {
-		edgeMap, ok := edge.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		node, ok := edgeMap["node"].(map[string]interface{})
-		if !ok {
-			continue
+	fulfillmentOrders := []FulfillmentOrderInfo{}
+	for node, err := range Paginate[fulfillmentOrderNode](ctx, c, query, variables, "order", "fulfillmentOrders") {
+		if err != nil {
+			if IsNotFound(err) {
+				return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+			}
+			return nil, err
 		}
 
-		fo := FulfillmentOrderInfo{
-			ID:            getString(node, "id"),
-			Status:        getString(node, "status"),
-			RequestStatus: getString(node, "requestStatus"),
+		fo, err := c.fulfillmentOrderInfo(ctx, node)
+		if err != nil {
+			return nil, err
 		}
+		fulfillmentOrders = append(fulfillmentOrders, fo)
+	}
 
-		// Get assigned location
-		if assignedLocation, ok := node["assignedLocation"].(map[string]interface{}); ok {
-			if location, ok := assignedLocation["location"].(map[string]interface{}); ok {
-				fo.AssignedLocationID = getString(location, "id")
-			}
-		}
+	return fulfillmentOrders, nil
+}
 
-		// Get line items
-		if lineItemsData, ok := node["lineItems"].(map[string]interface{}); ok {
-			if lineItemEdges, ok := lineItemsData["edges"].([]interface{}); ok {
-				for _, liEdge := range lineItemEdges {
-					if liEdgeMap, ok := liEdge.(map[string]interface{}); ok {
-						if liNode, ok := liEdgeMap["node"].(map[string]interface{}); ok {
-							lineItem := FulfillmentOrderLineItem{
-								ID:       getString(liNode, "id"),
-								Quantity: getInt(liNode, "remainingQuantity"), // Use remainingQuantity instead of quantity
-							}
-							// Fallback to totalQuantity if remainingQuantity is 0
-							if lineItem.Quantity == 0 {
-								lineItem.Quantity = getInt(liNode, "totalQuantity")
-							}
-							if lineItemObj, ok := liNode["lineItem"].(map[string]interface{}); ok {
-								lineItem.LineItemID = getString(lineItemObj, "id")
-							}
-							fo.LineItems = append(fo.LineItems, lineItem)
+// fulfillmentOrderLineItemsQuery fetches the remaining line items of a fulfillment order with more than one page
+const fulfillmentOrderLineItemsQuery = `
+	query GetFulfillmentOrderLineItems($id: ID!, $first: Int!, $after: String) {
+		fulfillmentOrder(id: $id) {
+			lineItems(first: $first, after: $after) {
+				edges {
+					node {
+						id
+						remainingQuantity
+						totalQuantity
+						lineItem {
+							id
 						}
 					}
 				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
 			}
 		}
+	}`
 
-		fulfillmentOrders = append(fulfillmentOrders, fo)
+// fulfillmentOrderInfo converts a fulfillment order node, fetching the rest of its line items
+func (c *Client) fulfillmentOrderInfo(ctx context.Context, node fulfillmentOrderNode) (FulfillmentOrderInfo, error) {
+	fo := FulfillmentOrderInfo{
+		ID:                 node.ID,
+		Status:             node.Status,
+		RequestStatus:      node.RequestStatus,
+		AssignedLocationID: node.AssignedLocation.Location.ID,
 	}
 
-	return fulfillmentOrders, nil
-}
-
-// Helper functions for type conversion
-func getString(m map[string]interface{}, key string) string {
-	if val, ok := m[key].(string); ok {
-		return val
+	lineItems := node.LineItems.Items()
+	if node.LineItems.PageInfo.HasNextPage {
+		rest, err := CollectAll(Paginate[fulfillmentOrderLineItemNode](ctx, c, fulfillmentOrderLineItemsQuery, map[string]interface{}{
+			"id":    node.ID,
+			"first": 250,
+			"after": node.LineItems.PageInfo.EndCursor,
+		}, "fulfillmentOrder", "lineItems"))
+		if err != nil {
+			return FulfillmentOrderInfo{}, fmt.Errorf("failed to get line items of %s: %w", node.ID, err)
+		}
+		lineItems = append(lineItems, rest...)
 	}
-	return ""
-}
 
-func getInt(m map[string]interface{}, key string) int {
-	if val, ok := m[key].(float64); ok {
-		return int(val)
+	for _, liNode := range lineItems {
+		lineItem := FulfillmentOrderLineItem{
+			ID:         liNode.ID,
+			Quantity:   liNode.RemainingQuantity, // Use remainingQuantity instead of quantity
+			LineItemID: liNode.LineItem.ID,
+		}
+		// Fallback to totalQuantity if remainingQuantity is 0
+		if lineItem.Quantity == 0 {
+			lineItem.Quantity = liNode.TotalQuantity
+		}
+		fo.LineItems = append(fo.LineItems, lineItem)
 	}
-	return 0
+	return fo, nil
 }
 
 // CreateFulfillment creates a fulfillment for one or more fulfillment orders
-func CreateFulfillment(fulfillmentOrderIDs []string, trackingInfo *TrackingInfo) (string, error) {
+func (c *Client) CreateFulfillment(ctx context.Context, fulfillmentOrderIDs []string, trackingInfo *TrackingInfo) (string, error) {
 	const mutation = `
 		mutation CreateFulfillment($fulfillment: FulfillmentV2Input!) {
 			fulfillmentCreateV2(fulfillment: $fulfillment) {
@@ -1009,39 +1048,22 @@ func CreateFulfillment(fulfillmentOrderIDs []string, trackingInfo *TrackingInfo)
 		"fulfillment": fulfillmentInput,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		FulfillmentCreateV2 struct {
+			Fulfillment *struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"fulfillment"`
+		} `json:"fulfillmentCreateV2"`
+	}](ctx, c, mutation, variables)
 	if err != nil {
 		return "", err
 	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("unexpected GraphQL response, missing data")
-	}
-
-	createRes, ok := data["fulfillmentCreateV2"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("missing fulfillmentCreateV2 in response")
-	}
-
-	// Check for user errors
-	if ue, ok := createRes["userErrors"].([]interface{}); ok && len(ue) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range ue {
-			if errMap, ok := err.(map[string]interface{}); ok {
-				errorMsg += fmt.Sprintf("%v: %v; ", errMap["field"], errMap["message"])
-			}
-		}
-		return "", errors.New(errorMsg)
-	}
-
-	fulfillment, ok := createRes["fulfillment"].(map[string]interface{})
-	if !ok {
+	if data.FulfillmentCreateV2.Fulfillment == nil {
 		return "", fmt.Errorf("missing fulfillment in response")
 	}
 
-	fulfillmentID := getString(fulfillment, "id")
-	return fulfillmentID, nil
+	return data.FulfillmentCreateV2.Fulfillment.ID, nil
 }
 
 // TrackingInfo represents tracking information for fulfillment
@@ -1051,35 +1073,94 @@ type TrackingInfo struct {
 	TrackingURL     string
 }
 
+// restOrder is the part of a REST order response that the REST order calls read
+type restOrder struct {
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	Email       string         `json:"email"`
+	Currency    string         `json:"currency"`
+	TotalPrice  Money          `json:"total_price"`
+	OrderNumber int            `json:"order_number"`
+	TaxLines    []restTaxLine  `json:"tax_lines"`
+	LineItems   []restLineItem `json:"line_items"`
+}
+
+// restLineItem is a line item of a REST order
+type restLineItem struct {
+	ID       int64         `json:"id"`
+	Price    Money         `json:"price"`
+	Quantity int           `json:"quantity"`
+	TaxLines []restTaxLine `json:"tax_lines"`
+}
+
+// restTaxLine is a tax line of a REST order, line item or draft order
+type restTaxLine struct {
+	Title string          `json:"title"`
+	Rate  decimal.Decimal `json:"rate"`
+	Price Money           `json:"price"`
+}
+
+// restOrderResponse is the body of the REST order endpoints
+type restOrderResponse struct {
+	Order  *restOrder `json:"order"`
+	Errors any        `json:"errors"`
+}
+
+// decodeRESTOrder decodes a REST order response and sets the order's currency
+// on its amounts
+func decodeRESTOrder(body []byte) (*restOrder, error) {
+	var response restOrderResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse order response: %w", err)
+	}
+	if response.Errors != nil {
+		return nil, fmt.Errorf("REST API errors: %v", response.Errors)
+	}
+	order := response.Order
+	if order == nil {
+		return nil, fmt.Errorf("missing order in response")
+	}
+	order.TotalPrice = order.TotalPrice.WithCurrency(order.Currency)
+	setTaxLineCurrency(order.TaxLines, order.Currency)
+	for i := range order.LineItems {
+		order.LineItems[i].Price = order.LineItems[i].Price.WithCurrency(order.Currency)
+		setTaxLineCurrency(order.LineItems[i].TaxLines, order.Currency)
+	}
+	return order, nil
+}
+
+func setTaxLineCurrency(taxLines []restTaxLine, currency string) {
+	for i := range taxLines {
+		taxLines[i].Price = taxLines[i].Price.WithCurrency(currency)
+	}
+}
+
 // AddTaxToOrder adds tax lines to an order using REST API
 // Tries multiple approaches: order-level tax, then line-item level tax
-func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
+func (c *Client) AddTaxToOrder(ctx context.Context, orderID string, taxLines []TaxLineInput) (err error) {
+	ctx, span := c.startSpan(ctx, "AddTaxToOrder", attribute.String("shopify.order_id", orderID))
+	defer func() { endSpan(span, err) }()
 
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
+	if err := c.checkCredentials(); err != nil {
+		return err
 	}
 
+	log := c.logger().With("order_id", orderID)
+
 	// Extract numeric order ID from GID format
 	orderNum := orderID
 	if strings.HasPrefix(orderID, "gid://shopify/Order/") {
 		orderNum = strings.TrimPrefix(orderID, "gid://shopify/Order/")
 	}
 
-	apiVersion := "2025-10"
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	// Convert tax lines to REST API format
 	taxLinesRest := make([]map[string]interface{}, len(taxLines))
 	for i, tl := range taxLines {
 		taxLineMap := map[string]interface{}{
 			"title": tl.Title,
 		}
-		if tl.Rate > 0 {
-			taxLineMap["rate"] = tl.Rate
+		if tl.Rate.IsPositive() {
+			taxLineMap["rate"] = json.Number(tl.Rate.String())
 		}
 		if tl.PriceSet != nil && tl.PriceSet.ShopMoney != nil {
 			taxLineMap["price"] = tl.PriceSet.ShopMoney.Amount
@@ -1095,9 +1176,9 @@ func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
 
 	// Approach 1: Try adding tax_lines at order level
 	// IMPORTANT: Remove existing tax lines first, then add custom tax from input.json
-	fmt.Printf("Debug: Step 1 - Removing Shopify's auto-calculated tax...\n")
-	url := fmt.Sprintf("https://%s/admin/api/%s/orders/%s.json", shopDomain, apiVersion, orderNum)
-	
+	log.Debug("removing auto-calculated tax")
+	orderPath := fmt.Sprintf("orders/%s.json", orderNum)
+
 	// Step 1: Remove existing tax lines
 	removeTaxPayload := map[string]interface{}{
 		"order": map[string]interface{}{
@@ -1105,24 +1186,17 @@ func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
 			"tax_lines": []interface{}{}, // Clear existing tax
 		},
 	}
-	
-	removeBody, err := json.Marshal(removeTaxPayload)
-	if err == nil {
-		removeReq, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(removeBody))
-		if err == nil {
-			removeReq.Header.Set("Content-Type", "application/json")
-			removeReq.Header.Set("X-Shopify-Access-Token", accessToken)
-			removeResp, err := client.Do(removeReq)
-			if err == nil {
-				removeResp.Body.Close()
-				fmt.Printf("Debug: Existing tax removed, waiting 1 second before adding custom tax...\n")
-				time.Sleep(1 * time.Second) // Wait a bit for Shopify to process
-			}
+
+	if _, _, err := c.doREST(ctx, http.MethodPut, orderPath, removeTaxPayload); err == nil {
+		log.Debug("existing tax removed, waiting before adding custom tax")
+		// Wait a bit for Shopify to process
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return err
 		}
 	}
-	
+
 	// Step 2: Add custom tax lines from input.json
-	fmt.Printf("Debug: Step 2 - Adding custom tax lines from input.json (%d tax lines)...\n", len(taxLinesRest))
+	log.Debug("adding custom tax lines at order level", "tax_lines", len(taxLinesRest))
 	payload := map[string]interface{}{
 		"order": map[string]interface{}{
 			"id":        orderNum,
@@ -1130,176 +1204,95 @@ func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
 		},
 	}
 
-	body, err := json.Marshal(payload)
+	status, bodyBytes, err := c.doREST(ctx, http.MethodPut, orderPath, payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
 
 	// Check if order-level tax was successful
-	if resp.StatusCode == http.StatusOK {
-		var updateResponse map[string]interface{}
-		if err := json.Unmarshal(bodyBytes, &updateResponse); err == nil {
-			if order, ok := updateResponse["order"].(map[string]interface{}); ok {
-				if taxLines, ok := order["tax_lines"].([]interface{}); ok {
-					fmt.Printf("Debug: Response tax_lines count: %d (sent: %d)\n", len(taxLines), len(taxLinesRest))
-					for i, tl := range taxLines {
-						if tlMap, ok := tl.(map[string]interface{}); ok {
-							fmt.Printf("Debug: Tax line %d: title=%v, rate=%v, price=%v\n", 
-								i+1, 
-								tlMap["title"], 
-								tlMap["rate"], 
-								tlMap["price"])
-						}
-					}
-					if len(taxLines) > 0 {
-						if len(taxLines) < len(taxLinesRest) {
-							fmt.Printf("Debug: ⚠ Only %d tax lines added at order level (expected: %d). Trying line items to add all tax lines...\n", 
-								len(taxLines), len(taxLinesRest))
-							fmt.Printf("Debug: Sent tax lines: %v\n", taxLinesRest)
-							// Continue to try line items approach to add all tax lines
-						} else {
-							fmt.Printf("Debug: ✓ All tax lines successfully added at order level: %d\n", len(taxLines))
-							return nil
-						}
-					} else {
-						// No tax lines added, continue to line items approach
-						fmt.Printf("Debug: No tax lines were added at order level, trying line items...\n")
-					}
-				} else {
-					fmt.Printf("Debug: No tax_lines field in response\n")
-				}
-			}
-		} else {
-			fmt.Printf("Debug: Failed to parse response: %v\n", err)
+	if status == http.StatusOK {
+		order, err := decodeRESTOrder(bodyBytes)
+		if err != nil {
+			return err
+		}
+		applied := order.TaxLines
+		log.Debug("order-level tax lines applied", "applied", len(applied), "sent", len(taxLinesRest), "tax_lines", applied)
+		switch {
+		case len(applied) == 0:
+			// No tax lines added, continue to line items approach
+			log.Debug("no tax lines added at order level, trying line items")
+		case len(applied) < len(taxLinesRest):
+			// Continue to try line items approach to add all tax lines
+			log.Warn("only some tax lines added at order level, trying line items",
+				"applied", len(applied), "expected", len(taxLinesRest), "sent", taxLinesRest)
+		default:
+			log.Info("tax lines added at order level", "tax_lines", len(applied))
+			return nil
 		}
 	} else {
-		fmt.Printf("Debug: Response status: %d, body: %s\n", resp.StatusCode, string(bodyBytes))
+		log.Warn("order-level tax update failed", "status", status, "body", string(bodyBytes))
 	}
 
 	// Approach 2: If order-level failed, try adding tax to line items
-	fmt.Printf("Debug: Order-level tax failed, trying line-item level...\n")
-	
-	// First, fetch the order to get line items
-	getURL := fmt.Sprintf("https://%s/admin/api/%s/orders/%s.json", shopDomain, apiVersion, orderNum)
-	getReq, err := http.NewRequest(http.MethodGet, getURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create GET request: %w", err)
-	}
-	getReq.Header.Set("X-Shopify-Access-Token", accessToken)
+	log.Debug("trying line-item level tax")
 
-	getResp, err := client.Do(getReq)
+	// First, fetch the order to get line items
+	_, getBodyBytes, err := c.doREST(ctx, http.MethodGet, orderPath, nil)
 	if err != nil {
 		return fmt.Errorf("failed to fetch order: %w", err)
 	}
-	defer getResp.Body.Close()
 
-	getBodyBytes, err := io.ReadAll(getResp.Body)
+	order, err := decodeRESTOrder(getBodyBytes)
 	if err != nil {
-		return fmt.Errorf("failed to read order response: %w", err)
+		return err
 	}
-
-	var orderData map[string]interface{}
-	if err := json.Unmarshal(getBodyBytes, &orderData); err != nil {
-		return fmt.Errorf("failed to parse order: %w", err)
-	}
-
-	order, ok := orderData["order"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid order response")
-	}
-
-	// Get line items
-	lineItems, ok := order["line_items"].([]interface{})
-	if !ok {
+	if len(order.LineItems) == 0 {
 		return fmt.Errorf("no line items found in order")
 	}
 
 	// Calculate tax per line item (distribute total tax proportionally)
-	totalPrice := 0.0
-	for _, li := range lineItems {
-		if liMap, ok := li.(map[string]interface{}); ok {
-			if price, ok := liMap["price"].(string); ok {
-				if p, err := strconv.ParseFloat(price, 64); err == nil {
-					if qty, ok := liMap["quantity"].(float64); ok {
-						totalPrice += p * qty
-					}
-				}
-			}
-		}
+	totalPrice := NewMoney(decimal.Zero, order.Currency)
+	for _, li := range order.LineItems {
+		totalPrice = totalPrice.Add(li.Price.MulInt(li.Quantity))
 	}
 
 	// Update each line item with tax
-	updatedLineItems := make([]map[string]interface{}, len(lineItems))
-	for i, li := range lineItems {
-		liMap, ok := li.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Calculate tax for this line item
-		lineItemPrice := 0.0
-		if price, ok := liMap["price"].(string); ok {
-			if p, err := strconv.ParseFloat(price, 64); err == nil {
-				if qty, ok := liMap["quantity"].(float64); ok {
-					lineItemPrice = p * qty
-				}
-			}
-		}
+	updatedLineItems := make([]map[string]interface{}, len(order.LineItems))
+	for i, li := range order.LineItems {
+		lineItemPrice := li.Price.MulInt(li.Quantity)
 
 		// Distribute ALL tax lines proportionally to this line item
 		lineItemTaxLines := []map[string]interface{}{}
-		if totalPrice > 0 && len(taxLinesRest) > 0 {
-			for _, taxLine := range taxLinesRest {
-				// Calculate tax amount for this line item based on proportion
-				if taxPrice, ok := taxLine["price"].(string); ok {
-					if totalTax, err := strconv.ParseFloat(taxPrice, 64); err == nil {
-						lineItemTax := (lineItemPrice / totalPrice) * totalTax
-						if lineItemTax > 0 {
-							lineTaxLine := map[string]interface{}{
-								"title": taxLine["title"],
-								"rate":  taxLine["rate"],
-								"price": fmt.Sprintf("%.2f", math.Round(lineItemTax*100)/100),
-							}
-							if source, ok := taxLine["source"].(string); ok && source != "" {
-								lineTaxLine["source"] = source
-							} else {
-								// Set source to MANUAL for custom tax
-								lineTaxLine["source"] = "manual"
-							}
-							lineItemTaxLines = append(lineItemTaxLines, lineTaxLine)
-							fmt.Printf("Debug: Adding tax line to line item %d: %s (rate: %v, amount: %.2f)\n", 
-								i+1, taxLine["title"], taxLine["rate"], lineItemTax)
-						}
-					}
-				}
+		for _, tl := range taxLines {
+			if !totalPrice.IsPositive() || tl.PriceSet == nil || tl.PriceSet.ShopMoney == nil {
+				continue
 			}
+			// Calculate tax amount for this line item based on proportion
+			lineItemTax := tl.PriceSet.ShopMoney.Amount.Mul(lineItemPrice.Decimal()).Div(totalPrice.Decimal()).Round(RoundHalfUp)
+			if !lineItemTax.IsPositive() {
+				continue
+			}
+			lineTaxLine := map[string]interface{}{
+				"title":  tl.Title,
+				"price":  lineItemTax,
+				"source": tl.Source,
+			}
+			if tl.Rate.IsPositive() {
+				lineTaxLine["rate"] = json.Number(tl.Rate.String())
+			}
+			if tl.Source == "" {
+				// Set source to MANUAL for custom tax
+				lineTaxLine["source"] = "manual"
+			}
+			lineItemTaxLines = append(lineItemTaxLines, lineTaxLine)
+			log.Debug("adding tax line to line item", "line_item", i+1,
+				"title", tl.Title, "rate", tl.Rate, "amount", lineItemTax)
 		}
 
-		// Update line item
-		updatedLineItem := map[string]interface{}{
-			"id":        liMap["id"],
+		updatedLineItems[i] = map[string]interface{}{
+			"id":        li.ID,
 			"tax_lines": lineItemTaxLines,
 		}
-		updatedLineItems[i] = updatedLineItem
 	}
 
 	// Update order with line items that have tax
@@ -1310,72 +1303,35 @@ func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
 		},
 	}
 
-	updateBody, err := json.Marshal(updatePayload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal update request: %w", err)
-	}
-
-	updateReq, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(updateBody))
+	updateStatus, updateBodyBytes, err := c.doREST(ctx, http.MethodPut, orderPath, updatePayload)
 	if err != nil {
-		return fmt.Errorf("failed to create update request: %w", err)
+		return fmt.Errorf("failed to execute update request: %w", err)
 	}
 
-	updateReq.Header.Set("Content-Type", "application/json")
-	updateReq.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	updateResp, err := client.Do(updateReq)
-	if err != nil {
-		return fmt.Errorf("failed to execute update request: %w", err)
+	if updateStatus != http.StatusOK {
+		return &HTTPStatusError{StatusCode: updateStatus, Body: string(updateBodyBytes)}
 	}
-	defer updateResp.Body.Close()
 
-	updateBodyBytes, err := io.ReadAll(updateResp.Body)
+	// Check if tax was added
+	finalOrder, err := decodeRESTOrder(updateBodyBytes)
 	if err != nil {
-		return fmt.Errorf("failed to read update response: %w", err)
+		return err
 	}
-
-	if updateResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status %d: %s", updateResp.StatusCode, string(updateBodyBytes))
+	if len(finalOrder.TaxLines) > 0 {
+		log.Info("tax lines added at order level", "tax_lines", len(finalOrder.TaxLines))
+		return nil
 	}
-
-	// Check if tax was added
-	var finalResponse map[string]interface{}
-	if err := json.Unmarshal(updateBodyBytes, &finalResponse); err == nil {
-		if finalOrder, ok := finalResponse["order"].(map[string]interface{}); ok {
-			// Check order-level tax
-			if taxLines, ok := finalOrder["tax_lines"].([]interface{}); ok {
-				if len(taxLines) > 0 {
-					fmt.Printf("Debug: ✓ Tax lines successfully added: %d\n", len(taxLines))
-					return nil
-				}
-			}
-			// Check line-item tax
-			if lineItems, ok := finalOrder["line_items"].([]interface{}); ok {
-				totalLineItemTax := 0
-				fmt.Printf("Debug: Checking tax lines in line items...\n")
-				for i, li := range lineItems {
-					if liMap, ok := li.(map[string]interface{}); ok {
-						if taxLines, ok := liMap["tax_lines"].([]interface{}); ok {
-							if len(taxLines) > 0 {
-								totalLineItemTax += len(taxLines)
-								fmt.Printf("Debug: Line item %d has %d tax line(s):\n", i+1, len(taxLines))
-								for j, tl := range taxLines {
-									if tlMap, ok := tl.(map[string]interface{}); ok {
-										fmt.Printf("Debug:   Tax %d: title=%v, rate=%v, price=%v\n", 
-											j+1, tlMap["title"], tlMap["rate"], tlMap["price"])
-									}
-								}
-							}
-						}
-					}
-				}
-				if totalLineItemTax > 0 {
-					fmt.Printf("Debug: ✓ Tax lines successfully added to line items: %d total\n", totalLineItemTax)
-					return nil
-				}
-			}
+	totalLineItemTax := 0
+	for i, li := range finalOrder.LineItems {
+		if len(li.TaxLines) > 0 {
+			totalLineItemTax += len(li.TaxLines)
+			log.Debug("line item tax lines", "line_item", i+1, "tax_lines", li.TaxLines)
 		}
 	}
+	if totalLineItemTax > 0 {
+		log.Info("tax lines added to line items", "tax_lines", totalLineItemTax)
+		return nil
+	}
 
 	return fmt.Errorf("failed to add tax lines (tried both order-level and line-item level)")
 }
@@ -1383,34 +1339,31 @@ func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
 // TaxLineRestInput represents a simple tax line input for REST API
 type TaxLineRestInput struct {
 	Title string
-	Rate  float64
-	Price float64
+	Rate  decimal.Decimal
+	Price Money
 }
 
 // UpdateOrderTaxLinesREST updates order tax lines using REST API
 // This is used to restore custom tax lines after Order Edit API recalculates them
-func UpdateOrderTaxLinesREST(orderID string, taxLines []TaxLineRestInput) error {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
+func (c *Client) UpdateOrderTaxLinesREST(ctx context.Context, orderID string, taxLines []TaxLineRestInput) error {
+	if err := c.checkCredentials(); err != nil {
+		return err
 	}
 
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/orders/%s.json", shopDomain, apiVersion, orderID)
-
 	// Build tax_lines array for REST API
 	restTaxLines := []map[string]interface{}{}
-	var totalTax float64
+	var totalTax Money
 	for _, tl := range taxLines {
+		if err := totalTax.CheckCurrency(tl.Price); err != nil {
+			return fmt.Errorf("tax line %s of %s: %w", tl.Title, orderID, err)
+		}
 		restTaxLines = append(restTaxLines, map[string]interface{}{
 			"title": tl.Title,
-			"rate":  tl.Rate,
-			"price": fmt.Sprintf("%.2f", tl.Price),
+			"rate":  json.Number(tl.Rate.String()),
+			"price": tl.Price.Round(RoundHalfUp),
 		})
-		totalTax += tl.Price
-		fmt.Printf("  → Restoring tax line: %s (rate: %.4f, amount: $%.2f)\n", tl.Title, tl.Rate, tl.Price)
+		totalTax = totalTax.Add(tl.Price.Round(RoundHalfUp))
+		c.logger().Debug("restoring tax line", "order_id", orderID, "title", tl.Title, "rate", tl.Rate, "amount", tl.Price)
 	}
 
 	// Update order with custom tax lines
@@ -1418,199 +1371,56 @@ func UpdateOrderTaxLinesREST(orderID string, taxLines []TaxLineRestInput) error
 		"order": map[string]interface{}{
 			"id":        orderID,
 			"tax_lines": restTaxLines,
-			"total_tax": fmt.Sprintf("%.2f", totalTax),
+			"total_tax": totalTax,
 		},
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	status, bodyBytes, err := c.doREST(ctx, http.MethodPut, fmt.Sprintf("orders/%s.json", orderID), payload)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if status != http.StatusOK {
+		return &HTTPStatusError{StatusCode: status, Body: string(bodyBytes)}
 	}
 
 	// Verify tax lines were updated
-	var respData map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &respData); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if order, ok := respData["order"].(map[string]interface{}); ok {
-		if updatedTaxLines, ok := order["tax_lines"].([]interface{}); ok {
-			fmt.Printf("  → Updated order with %d tax line(s)\n", len(updatedTaxLines))
-		}
+	order, err := decodeRESTOrder(bodyBytes)
+	if err != nil {
+		return err
 	}
-
+	c.logger().Info("order tax lines restored", "order_id", orderID, "tax_lines", len(order.TaxLines))
 	return nil
 }
 
-// UpdateOrderTaxGraphQL attempts to update order tax using GraphQL orderUpdate mutation
-// Note: orderUpdate may not support tax lines directly, but we'll try
-func UpdateOrderTaxGraphQL(orderID string, taxLines []TaxLineInput) error {
-	const mutation = `
-		mutation UpdateOrderTax($id: ID!, $input: OrderUpdateInput!) {
-			orderUpdate(id: $id, input: $input) {
-				order {
-					id
-					totalTaxSet {
-						shopMoney {
-							amount
-							currencyCode
-						}
-					}
-					taxLines {
-						title
-						priceSet {
-							shopMoney {
-								amount
-								currencyCode
-							}
-						}
-						rate
-					}
-				}
-				userErrors {
-					field
-					message
-				}
-			}
-		}`
-
-	// Note: OrderUpdateInput may not support taxLines directly
-	// This is an attempt - may need to use orderEditBegin instead
-	input := map[string]interface{}{
-		// Try to add tax via custom attributes or other supported fields
-		// If taxLines is not supported, this will fail gracefully
-	}
-
-	variables := map[string]interface{}{
-		"id":    orderID,
-		"input": input,
-	}
-
-	resp, err := callAdminGraphQL(mutation, variables)
-	if err != nil {
-		return fmt.Errorf("failed to call GraphQL: %w", err)
-	}
-
-	// Check for errors
-	if errors, ok := resp["errors"].([]interface{}); ok && len(errors) > 0 {
-		return fmt.Errorf("GraphQL errors: %v", errors)
-	}
-
-	// Check user errors
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if orderUpdate, ok := data["orderUpdate"].(map[string]interface{}); ok {
-			if userErrors, ok := orderUpdate["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-				return fmt.Errorf("user errors: %v", userErrors)
-			}
-			// Check if tax was updated
-			if order, ok := orderUpdate["order"].(map[string]interface{}); ok {
-				if taxLines, ok := order["taxLines"].([]interface{}); ok && len(taxLines) > 0 {
-					fmt.Printf("Debug: ✓ Tax updated via GraphQL: %d tax lines\n", len(taxLines))
-					return nil
-				}
-			}
+// UpdateOrderTaxViaEdit sets an order's tax lines through an order edit. Order
+// Edit has no mutation for tax lines, so the edit is committed as is and the tax
+// lines are written with CommitPreservingTax, which verifies them afterwards.
+func (c *Client) UpdateOrderTaxViaEdit(ctx context.Context, orderID string, taxLines []TaxLineInput) error {
+	expected := make([]TaxLineRestInput, 0, len(taxLines))
+	for _, tl := range taxLines {
+		taxLine := TaxLineRestInput{Title: tl.Title, Rate: tl.Rate}
+		if tl.PriceSet != nil && tl.PriceSet.ShopMoney != nil {
+			taxLine.Price = tl.PriceSet.ShopMoney.Amount
 		}
+		expected = append(expected, taxLine)
 	}
 
-	return fmt.Errorf("orderUpdate mutation does not support tax lines directly")
-}
-
-// UpdateOrderTaxViaEdit attempts to update order tax using orderEditBegin flow
-// This is the recommended way to make significant changes to an order
-func UpdateOrderTaxViaEdit(orderID string, taxLines []TaxLineInput) error {
-	// Step 1: Begin order edit
-	const beginMutation = `
-		mutation BeginOrderEdit($id: ID!) {
-			orderEditBegin(id: $id) {
-				calculatedOrder {
-					id
-				}
-				userErrors {
-					field
-					message
-				}
-			}
-		}`
-
-	variables := map[string]interface{}{
-		"id": orderID,
-	}
-
-	resp, err := callAdminGraphQL(beginMutation, variables)
+	session, err := c.EditOrder(ctx, orderID)
 	if err != nil {
 		return fmt.Errorf("failed to begin order edit: %w", err)
 	}
-
-	// Check for errors
-	if errors, ok := resp["errors"].([]interface{}); ok && len(errors) > 0 {
-		return fmt.Errorf("GraphQL errors: %v", errors)
-	}
-
-	// Get calculated order ID
-	var calculatedOrderID string
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if orderEditBegin, ok := data["orderEditBegin"].(map[string]interface{}); ok {
-			if userErrors, ok := orderEditBegin["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-				return fmt.Errorf("user errors: %v", userErrors)
-			}
-			if calculatedOrder, ok := orderEditBegin["calculatedOrder"].(map[string]interface{}); ok {
-				if id, ok := calculatedOrder["id"].(string); ok {
-					calculatedOrderID = id
-				}
-			}
-		}
+	result, err := session.CommitPreservingTax(ctx, PreserveTaxOptions{TaxLines: expected})
+	if err != nil {
+		return err
 	}
-
-	if calculatedOrderID == "" {
-		return fmt.Errorf("failed to get calculated order ID")
+	if result.RestoreError != nil {
+		return fmt.Errorf("failed to set tax lines of %s: %w", orderID, result.RestoreError)
 	}
-
-	// Step 2: Add tax to calculated order
-	// Note: This may require using orderEditAddTaxLine mutation if available
-	// For now, we'll try to complete the edit and see if we can add tax
-	// This is a simplified version - full implementation may require more steps
-
-	fmt.Printf("Debug: Order edit begun, calculated order ID: %s\n", calculatedOrderID)
-	fmt.Println("Note: Adding tax via order edit requires additional mutations")
-	fmt.Println("This is a placeholder - full implementation needed")
-
-	return fmt.Errorf("orderEditAddTaxLine mutation not yet implemented")
-}
-
-// Helper function to get keys from map
-func getKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+	if !result.Preserved {
+		return fmt.Errorf("tax lines of %s do not match after the edit", orderID)
 	}
-	return keys
+	return nil
 }
 
 // CreateOrderFromDraft is a convenience function that creates a draft order and completes it
@@ -1619,9 +1429,12 @@ func getKeys(m map[string]interface{}) []string {
 // - Store has tax rates configured in Settings → Taxes
 // - Shipping address is provided
 // - Line items have taxable=true
-func CreateOrderFromDraft(input DraftOrderInput, paymentPending bool) (*OrderInfo, error) {
+func (c *Client) CreateOrderFromDraft(ctx context.Context, input DraftOrderInput, paymentPending bool) (_ *OrderInfo, err error) {
+	ctx, span := c.startSpan(ctx, "CreateOrderFromDraft")
+	defer func() { endSpan(span, err) }()
+
 	// Step 1: Create draft order
-	draftResp, err := CreateDraftOrder(input)
+	draftResp, err := c.CreateDraftOrder(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create draft order: %w", err)
 	}
@@ -1631,34 +1444,26 @@ func CreateOrderFromDraft(input DraftOrderInput, paymentPending bool) (*OrderInf
 	// Step 2: Calculate tax before completing (optional - to preview tax)
 	// This helps ensure tax will be calculated when completing
 	// We use the same input to calculate tax
-	fmt.Println("Calculating tax for draft order...")
-	calculated, err := CalculateDraftOrder(input)
+	log := c.logger().With("draft_id", draftID)
+	calculated, err := c.CalculateDraftOrder(ctx, input)
 	if err != nil {
-		fmt.Printf("Warning: Could not calculate tax preview: %v\n", err)
-		fmt.Println("Tax will still be calculated when completing draft order if store has tax configured")
+		// Tax is still calculated on completion if the store has tax configured
+		log.Warn("could not calculate tax preview", "error", err)
 	} else {
 		// Check if tax was calculated
-		if taxLines, ok := calculated["taxLines"].([]interface{}); ok && len(taxLines) > 0 {
-			fmt.Printf("✓ Tax preview calculated: %d tax line(s)\n", len(taxLines))
-			if totalTax, ok := calculated["totalTaxSet"].(map[string]interface{}); ok {
-				if shopMoney, ok := totalTax["shopMoney"].(map[string]interface{}); ok {
-					if amount, ok := shopMoney["amount"].(string); ok {
-						fmt.Printf("  Total Tax: %s %s\n", amount, shopMoney["currencyCode"])
-					}
-				}
-			}
+		if len(calculated.TaxLines) > 0 {
+			totalTax := calculated.TotalTaxSet.ShopMoney
+			log.Debug("tax preview calculated", "tax_lines", len(calculated.TaxLines),
+				"total_tax", totalTax.Amount, "currency", totalTax.CurrencyCode)
 		} else {
-			fmt.Println("⚠️  No tax calculated in preview")
-			fmt.Println("  This may mean:")
-			fmt.Println("    - Store tax rates are not configured in Settings → Taxes")
-			fmt.Println("    - Shipping address is missing or incomplete")
-			fmt.Println("    - Line items are marked as taxExempt")
+			// Store tax rates not configured, shipping address missing or line items tax exempt
+			log.Warn("no tax calculated in preview")
 		}
 	}
 
 	// Step 3: Complete draft order
 	// Shopify will automatically calculate tax when completing if conditions are met
-	orderInfo, err := CompleteDraftOrder(draftID, paymentPending)
+	orderInfo, err := c.CompleteDraftOrder(ctx, draftID, paymentPending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete draft order: %w", err)
 	}
@@ -1668,9 +1473,12 @@ func CreateOrderFromDraft(input DraftOrderInput, paymentPending bool) (*OrderInf
 
 // CreateOrderFromDraftWithTaxAttempt creates a draft order, attempts to add tax, then completes it
 // This tries to add tax to draft order before completing (may not work if DraftOrderInput doesn't support tax)
-func CreateOrderFromDraftWithTaxAttempt(input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (*OrderInfo, error) {
+func (c *Client) CreateOrderFromDraftWithTaxAttempt(ctx context.Context, input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (_ *OrderInfo, err error) {
+	ctx, span := c.startSpan(ctx, "CreateOrderFromDraftWithTaxAttempt")
+	defer func() { endSpan(span, err) }()
+
 	// Step 1: Create draft order
-	draftResp, err := CreateDraftOrder(input)
+	draftResp, err := c.CreateDraftOrder(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create draft order: %w", err)
 	}
@@ -1680,24 +1488,25 @@ func CreateOrderFromDraftWithTaxAttempt(input DraftOrderInput, taxLines []TaxLin
 	// Step 2: Try to update draft order with tax lines (if supported)
 	// Note: This may not work as DraftOrderInput may not support taxLines
 	if len(taxLines) > 0 {
-		fmt.Printf("Attempting to add tax to draft order %s before completing...\n", draftID)
+		log := c.logger().With("draft_id", draftID)
+		log.Debug("adding tax to draft order before completing")
 		// Try to add tax to line items in draft order
 		updateInput := input
 		for i := range updateInput.LineItems {
 			updateInput.LineItems[i].TaxLines = taxLines
 		}
-		
-		_, updateErr := UpdateDraftOrder(draftID, updateInput)
+
+		_, updateErr := c.UpdateDraftOrder(ctx, draftID, updateInput)
 		if updateErr != nil {
-			fmt.Printf("Warning: Failed to update draft order with tax: %v\n", updateErr)
-			fmt.Println("Note: DraftOrderInput may not support taxLines. Will complete without tax.")
+			// DraftOrderInput may not support taxLines; the draft is completed without tax
+			log.Warn("failed to update draft order with tax", "error", updateErr)
 		} else {
-			fmt.Println("✓ Draft order updated with tax lines")
+			log.Debug("draft order updated with tax lines")
 		}
 	}
 
 	// Step 3: Complete draft order
-	orderInfo, err := CompleteDraftOrder(draftID, paymentPending)
+	orderInfo, err := c.CompleteDraftOrder(ctx, draftID, paymentPending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete draft order: %w", err)
 	}
@@ -1707,9 +1516,12 @@ func CreateOrderFromDraftWithTaxAttempt(input DraftOrderInput, taxLines []TaxLin
 
 // CreateOrderFromDraftWithTax creates a draft order, completes it, and adds tax lines
 // If tax needs to be added, it completes with paymentPending=true first, adds tax, then marks as paid
-func CreateOrderFromDraftWithTax(input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (*OrderInfo, error) {
+func (c *Client) CreateOrderFromDraftWithTax(ctx context.Context, input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (_ *OrderInfo, err error) {
+	ctx, span := c.startSpan(ctx, "CreateOrderFromDraftWithTax")
+	defer func() { endSpan(span, err) }()
+
 	// Step 1: Create draft order
-	draftResp, err := CreateDraftOrder(input)
+	draftResp, err := c.CreateDraftOrder(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create draft order: %w", err)
 	}
@@ -1722,118 +1534,49 @@ func CreateOrderFromDraftWithTax(input DraftOrderInput, taxLines []TaxLineInput,
 	shouldAddTax := len(taxLines) > 0
 	completeAsPending := shouldAddTax && !paymentPending
 
-	orderInfo, err := CompleteDraftOrder(draftID, completeAsPending)
+	orderInfo, err := c.CompleteDraftOrder(ctx, draftID, completeAsPending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete draft order: %w", err)
 	}
 
 	// Step 3: Add tax lines to order if provided
 	if shouldAddTax && orderInfo.OrderID != "" {
-		fmt.Printf("Attempting to add tax to order %s (status: pending)...\n", orderInfo.OrderID)
-		if err := AddTaxToOrder(orderInfo.OrderID, taxLines); err != nil {
+		log := c.logger().With("order_id", orderInfo.OrderID)
+		log.Debug("adding tax to pending order")
+		if err := c.AddTaxToOrder(ctx, orderInfo.OrderID, taxLines); err != nil {
 			// Log error but don't fail - order is already created
-			fmt.Printf("Warning: Failed to add tax to order: %v\n", err)
-			// Try to continue anyway
-		} else {
-			fmt.Println("✓ Tax lines added successfully")
-		}
-
-		// If we completed as pending to add tax, now mark as paid
-		if completeAsPending && paymentPending == false {
-			fmt.Println("Marking order as paid...")
-			// Mark order as paid using REST API
-			if err := MarkOrderAsPaid(orderInfo.OrderID); err != nil {
-				return orderInfo, fmt.Errorf("failed to mark order as paid: %w", err)
-			}
-			fmt.Println("✓ Order marked as paid")
-		}
-	}
-
-	return orderInfo, nil
-}
-
-// MarkOrderAsPaid marks an order as paid using REST API
-func MarkOrderAsPaid(orderID string) error {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
-	// Extract numeric order ID from GID format
-	orderNum := orderID
-	if strings.HasPrefix(orderID, "gid://shopify/Order/") {
-		orderNum = strings.TrimPrefix(orderID, "gid://shopify/Order/")
-	}
-
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/orders/%s.json", shopDomain, apiVersion, orderNum)
-
-	payload := map[string]interface{}{
-		"order": map[string]interface{}{
-			"id":              orderNum,
-			"financial_status": "paid",
-		},
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
+			log.Warn("failed to add tax to order", "error", err)
+			// Try to continue anyway
+		} else {
+			log.Debug("tax lines added")
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		// If we completed as pending to add tax, now mark as paid
+		if completeAsPending && paymentPending == false {
+			log.Debug("marking order as paid")
+			// Record the outstanding amount as a manual payment
+			if err := c.MarkOrderAsPaid(ctx, orderInfo.OrderID); err != nil {
+				return orderInfo, fmt.Errorf("failed to mark order as paid: %w", err)
+			}
+			log.Debug("order marked as paid")
+		}
 	}
 
-	return nil
+	return orderInfo, nil
 }
 
 // CreateOrderWithTax creates a new order with tax lines using REST API
 // This allows adding tax lines directly when creating the order
-func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return nil, fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
+func (c *Client) CreateOrderWithTax(ctx context.Context, input OrderInput) (*OrderResponse, error) {
 	// Use REST API to create order with tax lines
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/orders.json", shopDomain, apiVersion)
-
 	// Convert input to REST API format
 	orderPayload := map[string]interface{}{
-		"email":           input.Email,
-		"line_items":      []map[string]interface{}{},
+		"email":            input.Email,
+		"line_items":       []map[string]interface{}{},
 		"financial_status": strings.ToLower(input.FinancialStatus),
-		"note":            input.Note,
+		"note":             input.Note,
 	}
-	
+
 	// Add tags only if not empty
 	if len(input.Tags) > 0 {
 		orderPayload["tags"] = strings.Join(input.Tags, ",")
@@ -1846,12 +1589,12 @@ func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
 		if strings.HasPrefix(variantID, "gid://shopify/ProductVariant/") {
 			variantID = strings.TrimPrefix(variantID, "gid://shopify/ProductVariant/")
 		}
-		
+
 		lineItem := map[string]interface{}{
 			"variant_id": variantID,
 			"quantity":   item.Quantity,
 		}
-		if item.Price != "" {
+		if !item.Price.IsZero() {
 			lineItem["price"] = item.Price
 		}
 		if item.Title != "" {
@@ -1914,83 +1657,26 @@ func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
 		"order": orderPayload,
 	}
 
-	jsonData, err := json.Marshal(requestPayload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	status, body, err := c.doREST(ctx, http.MethodPost, "orders.json", requestPayload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	// Check HTTP status
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse REST API response
-	var restResponse map[string]interface{}
-	if err := json.Unmarshal(body, &restResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: status, Body: string(body)}
 	}
 
-	// Check for errors
-	if errors, ok := restResponse["errors"]; ok {
-		return nil, fmt.Errorf("REST API errors: %v", errors)
-	}
-
-	// Convert REST API response to OrderResponse format
-	order, ok := restResponse["order"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("missing order in response")
-	}
-
-	// Extract order ID (convert from numeric to GID format)
-	orderID := ""
-	if id, ok := order["id"].(float64); ok {
-		orderID = fmt.Sprintf("gid://shopify/Order/%.0f", id)
-	} else if idStr, ok := order["id"].(string); ok {
-		orderID = fmt.Sprintf("gid://shopify/Order/%s", idStr)
+	order, err := decodeRESTOrder(body)
+	if err != nil {
+		return nil, err
 	}
 
 	// Extract total tax
-	totalTax := "0.00"
-	if taxLines, ok := order["tax_lines"].([]interface{}); ok {
-		total := 0.0
-		for _, tl := range taxLines {
-			if tlMap, ok := tl.(map[string]interface{}); ok {
-				if price, ok := tlMap["price"].(string); ok {
-					if p, err := strconv.ParseFloat(price, 64); err == nil {
-						total += p
-					}
-				}
-			}
-		}
-		totalTax = fmt.Sprintf("%.2f", total)
+	totalTax := NewMoney(decimal.Zero, order.Currency)
+	for _, tl := range order.TaxLines {
+		totalTax = totalTax.Add(tl.Price)
 	}
 
 	// Build response
@@ -2002,25 +1688,16 @@ func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
 					Name          string `json:"name"`
 					Email         string `json:"email"`
 					TotalPriceSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currencyCode"`
-						} `json:"shopMoney"`
+						ShopMoney MoneyV2 `json:"shopMoney"`
 					} `json:"totalPriceSet"`
 					TotalTaxSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currencyCode"`
-						} `json:"shopMoney"`
+						ShopMoney MoneyV2 `json:"shopMoney"`
 					} `json:"totalTaxSet"`
 					TaxLines []struct {
-						Title string      `json:"title"`
-						Rate  interface{} `json:"rate"` // Can be number or string
+						Title    string          `json:"title"`
+						Rate     decimal.Decimal `json:"rate"` // Number or string
 						PriceSet struct {
-							ShopMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currencyCode"`
-							} `json:"shopMoney"`
+							ShopMoney MoneyV2 `json:"shopMoney"`
 						} `json:"priceSet"`
 					} `json:"taxLines,omitempty"`
 					CreatedAt   string `json:"createdAt"`
@@ -2031,36 +1708,19 @@ func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
 		}{},
 	}
 
-	response.Data.OrderCreate.Order.ID = orderID
-	if name, ok := order["name"].(string); ok {
-		response.Data.OrderCreate.Order.Name = name
-	}
-	if email, ok := order["email"].(string); ok {
-		response.Data.OrderCreate.Order.Email = email
-	}
-	if totalPrice, ok := order["total_price"].(string); ok {
-		response.Data.OrderCreate.Order.TotalPriceSet.ShopMoney.Amount = totalPrice
-		response.Data.OrderCreate.Order.TotalPriceSet.ShopMoney.CurrencyCode = "USD"
-	}
-	response.Data.OrderCreate.Order.TotalTaxSet.ShopMoney.Amount = totalTax
-	response.Data.OrderCreate.Order.TotalTaxSet.ShopMoney.CurrencyCode = "USD"
-	if orderNum, ok := order["order_number"].(float64); ok {
-		response.Data.OrderCreate.Order.OrderNumber = int(orderNum)
-	}
+	created := &response.Data.OrderCreate.Order
+	created.ID = resourceGID("Order", strconv.FormatInt(order.ID, 10))
+	created.Name = order.Name
+	created.Email = order.Email
+	created.TotalPriceSet.ShopMoney = MoneyV2{Amount: order.TotalPrice, CurrencyCode: order.Currency}
+	created.TotalTaxSet.ShopMoney = MoneyV2{Amount: totalTax, CurrencyCode: order.Currency}
+	created.OrderNumber = order.OrderNumber
 
 	return response, nil
 }
 
 // CreateOrder creates a new order in Shopify using GraphQL Admin API (legacy method, kept for backward compatibility)
-func CreateOrder(input OrderInput) (*OrderResponse, error) {
-	// Get environment variables
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return nil, fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
+func (c *Client) CreateOrder(ctx context.Context, input OrderInput) (*OrderResponse, error) {
 	// Construct GraphQL mutation with tax lines support
 	mutation := `
 		mutation orderCreate($input: OrderCreateInput!) {
@@ -2102,78 +1762,13 @@ func CreateOrder(input OrderInput) (*OrderResponse, error) {
 		}
 	`
 
-	// Prepare GraphQL request
-	requestBody := map[string]interface{}{
-		"query": mutation,
-		"variables": map[string]interface{}{
-			"input": input,
-		},
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Construct API endpoint
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/graphql.json", shopDomain, apiVersion)
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
+	// Execute GraphQL request
 	var response OrderResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for GraphQL errors
-	if len(response.Errors) > 0 {
-		errorMsg := "GraphQL errors: "
-		for _, err := range response.Errors {
-			errorMsg += err.Message + "; "
-		}
-		return nil, errors.New(errorMsg)
-	}
-
-	// Check for user errors
-	if len(response.Data.OrderCreate.UserErrors) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range response.Data.OrderCreate.UserErrors {
-			errorMsg += fmt.Sprintf("%v: %s; ", err.Field, err.Message)
-		}
-		return nil, errors.New(errorMsg)
+	err := c.graphQL(ctx, mutation, map[string]interface{}{
+		"input": input,
+	}, &response.Data)
+	if err != nil {
+		return nil, err
 	}
 
 	return &response, nil
@@ -2182,17 +1777,17 @@ func CreateOrder(input OrderInput) (*OrderResponse, error) {
 // EnsureShippingNoteMetafieldDefinition ensures that the Shipping Note metafield definition exists
 // This function should be called when the app starts to automatically create the definition
 // if it doesn't already exist. This makes the metafield structured and visible in Shopify Admin UI.
-func EnsureShippingNoteMetafieldDefinition() error {
+func (c *Client) EnsureShippingNoteMetafieldDefinition(ctx context.Context) error {
 	const namespace = "connectpos"
 	const key = "shipping_note"
 	const name = "Shipping Note"
 	const description = "Ghi chú vận chuyển cho đơn hàng từ ConnectPOS"
 	const typeName = "multi_line_text_field"
 
-	// First, check if definition already exists
-	checkQuery := `
-		query {
-			metafieldDefinitions(first: 250, ownerType: ORDER, namespace: "` + namespace + `") {
+	// First, check if definition already exists (all pages)
+	const checkQuery = `
+		query MetafieldDefinitions($first: Int!, $after: String, $namespace: String!) {
+			metafieldDefinitions(first: $first, after: $after, ownerType: ORDER, namespace: $namespace) {
 				edges {
 					node {
 						name
@@ -2203,31 +1798,26 @@ func EnsureShippingNoteMetafieldDefinition() error {
 						}
 					}
 				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
 			}
 		}
 	`
 
-	resp, err := callAdminGraphQL(checkQuery, nil)
-	if err != nil {
-		return fmt.Errorf("failed to check existing metafield definitions: %w", err)
-	}
-
-	// Check if definition already exists
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if definitions, ok := data["metafieldDefinitions"].(map[string]interface{}); ok {
-			if edges, ok := definitions["edges"].([]interface{}); ok {
-				for _, edge := range edges {
-					if edgeMap, ok := edge.(map[string]interface{}); ok {
-						if node, ok := edgeMap["node"].(map[string]interface{}); ok {
-							if nodeKey, ok := node["key"].(string); ok && nodeKey == key {
-								// Definition already exists, skip creation
-								fmt.Printf("✓ Metafield definition '%s.%s' already exists, skipping creation\n", namespace, key)
-								return nil
-							}
-						}
-					}
-				}
-			}
+	checkVars := map[string]interface{}{
+		"first":     250,
+		"namespace": namespace,
+	}
+	for node, err := range Paginate[metafieldDefinitionNode](ctx, c, checkQuery, checkVars, "metafieldDefinitions") {
+		if err != nil {
+			return fmt.Errorf("failed to check existing metafield definitions: %w", err)
+		}
+		if node.Key == key {
+			// Definition already exists, skip creation
+			c.logger().Debug("metafield definition already exists", "namespace", namespace, "key", key)
+			return nil
 		}
 	}
 
@@ -2264,26 +1854,32 @@ func EnsureShippingNoteMetafieldDefinition() error {
 		}
 	`
 
-	resp, err = callAdminGraphQL(createMutation, nil)
+	created, err := Do[struct {
+		MetafieldDefinitionCreate struct {
+			CreatedDefinition *metafieldDefinitionNode `json:"createdDefinition"`
+		} `json:"metafieldDefinitionCreate"`
+	}](ctx, c, createMutation, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create metafield definition: %w", err)
 	}
 
-	// Check for user errors
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if createResult, ok := data["metafieldDefinitionCreate"].(map[string]interface{}); ok {
-			if userErrors, ok := createResult["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-				return fmt.Errorf("failed to create metafield definition: %v", userErrors)
-			}
-			if createdDef, ok := createResult["createdDefinition"].(map[string]interface{}); ok {
-				fmt.Printf("✓ Successfully created metafield definition: %s.%s\n", namespace, key)
-				fmt.Printf("  Name: %v\n", createdDef["name"])
-				return nil
-			}
-		}
+	createdDef := created.MetafieldDefinitionCreate.CreatedDefinition
+	if createdDef == nil {
+		return fmt.Errorf("unexpected response when creating metafield definition")
 	}
 
-	return fmt.Errorf("unexpected response when creating metafield definition")
+	c.logger().Info("created metafield definition", "namespace", namespace, "key", key, "name", createdDef.Name)
+	return nil
+}
+
+// metafieldDefinitionNode is a MetafieldDefinition as selected by EnsureShippingNoteMetafieldDefinition
+type metafieldDefinitionNode struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Type      struct {
+		Name string `json:"name"`
+	} `json:"type"`
 }
 
 // CreateOrderGraphQL creates a new order using GraphQL orderCreate mutation
@@ -2291,15 +1887,7 @@ func EnsureShippingNoteMetafieldDefinition() error {
 // - Custom tax lines: supported via taxLines field
 // - Order-level discount: via discountCode field
 // - Line-item discount: calculate discounted price and set in priceSet, add note in properties
-func CreateOrderGraphQL(input OrderInput) (*OrderResponse, error) {
-	// Get environment variables
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return nil, fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
+func (c *Client) CreateOrderGraphQL(ctx context.Context, input OrderInput) (*OrderResponse, error) {
 	// Construct GraphQL mutation with tax lines and discount support
 	// According to Shopify GraphQL schema, orderCreate takes 'order' argument with type OrderCreateOrderInput!
 	mutation := `
@@ -2341,95 +1929,19 @@ func CreateOrderGraphQL(input OrderInput) (*OrderResponse, error) {
 		}
 	`
 
-	// Prepare GraphQL request
-	requestBody := map[string]interface{}{
-		"query": mutation,
-		"variables": map[string]interface{}{
-			"order": input,
-		},
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Construct API endpoint
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/graphql.json", shopDomain, apiVersion)
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
+	// Execute GraphQL request
 	var response OrderResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for GraphQL errors
-	if len(response.Errors) > 0 {
-		errorMsg := "GraphQL errors: "
-		for _, err := range response.Errors {
-			errorMsg += err.Message + "; "
-		}
-		return nil, errors.New(errorMsg)
+	err := c.graphQL(ctx, mutation, map[string]interface{}{
+		"order": input,
+	}, &response.Data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check for user errors
-	if len(response.Data.OrderCreate.UserErrors) > 0 {
-		errorMsg := "User errors: "
-		for _, err := range response.Data.OrderCreate.UserErrors {
-			errorMsg += fmt.Sprintf("%v: %s; ", err.Field, err.Message)
-		}
-		return nil, errors.New(errorMsg)
-	}
-
-	// Print tax lines if present
-	if len(response.Data.OrderCreate.Order.TaxLines) > 0 {
-		fmt.Printf("✓ Custom tax lines applied: %d tax line(s)\n", len(response.Data.OrderCreate.Order.TaxLines))
-		for i, tl := range response.Data.OrderCreate.Order.TaxLines {
-			rateStr := ""
-			if rateFloat, ok := tl.Rate.(float64); ok {
-				rateStr = fmt.Sprintf("%.3f", rateFloat)
-			} else if rateStrVal, ok := tl.Rate.(string); ok {
-				rateStr = rateStrVal
-			} else {
-				rateStr = fmt.Sprintf("%v", tl.Rate)
-			}
-			fmt.Printf("  Tax Line %d: %s (rate: %s, amount: %s)\n", 
-				i+1, tl.Title, rateStr, tl.PriceSet.ShopMoney.Amount)
-		}
+	// Log tax lines if present
+	for _, tl := range response.Data.OrderCreate.Order.TaxLines {
+		c.logger().Debug("custom tax line applied", "order_id", response.Data.OrderCreate.Order.ID,
+			"title", tl.Title, "rate", tl.Rate, "amount", tl.PriceSet.ShopMoney.Amount)
 	}
 
 	return &response, nil
@@ -2440,54 +1952,25 @@ func CreateOrderGraphQL(input OrderInput) (*OrderResponse, error) {
 // - compare_at_price = original price (will show strikethrough)
 // - price = discounted price (will show as current price)
 // IMPORTANT: Don't set priceSet in orderCreate, let Shopify use variant prices
-func UpdateVariantPriceAndCompareAt(variantID string, price string, compareAtPrice string) error {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
+func (c *Client) UpdateVariantPriceAndCompareAt(ctx context.Context, variantID string, price, compareAtPrice Money) error {
 	// Extract variant number from GID (e.g., "gid://shopify/ProductVariant/48360774271216" -> "48360774271216")
 	variantNum := strings.TrimPrefix(variantID, "gid://shopify/ProductVariant/")
 
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/variants/%s.json", shopDomain, apiVersion, variantNum)
-
 	payload := map[string]interface{}{
 		"variant": map[string]interface{}{
-			"id":              variantNum,
-			"price":           price,           // Discounted price (current price)
+			"id":               variantNum,
+			"price":            price,          // Discounted price (current price)
 			"compare_at_price": compareAtPrice, // Original price (will show strikethrough)
 		},
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
+	status, bodyBytes, err := c.doREST(ctx, http.MethodPut, fmt.Sprintf("variants/%s.json", variantNum), payload)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if status != http.StatusOK {
+		return &HTTPStatusError{StatusCode: status, Body: string(bodyBytes)}
 	}
 
 	return nil
@@ -2506,7 +1989,7 @@ func ExampleCreateOrderFromDraft() {
 				AppliedDiscount: &AppliedDiscountInput{
 					Description: "20% off",
 					ValueType:   "PERCENTAGE",
-					Value:       20.0,
+					Value:       decimal.NewFromInt(20),
 					Title:       "20PERCENT",
 				},
 			},
@@ -2516,7 +1999,7 @@ func ExampleCreateOrderFromDraft() {
 				AppliedDiscount: &AppliedDiscountInput{
 					Description: "15% off",
 					ValueType:   "PERCENTAGE",
-					Value:       15.0,
+					Value:       decimal.NewFromInt(15),
 					Title:       "15PERCENT",
 				},
 			},
@@ -2575,7 +2058,7 @@ func ExampleCreateDraftOrderStepByStep() {
 				AppliedDiscount: &AppliedDiscountInput{
 					Description: "17% off",
 					ValueType:   "PERCENTAGE",
-					Value:       17.0,
+					Value:       decimal.NewFromInt(17),
 					Title:       "17PERCENT",
 				},
 			},
@@ -2662,20 +2145,42 @@ func ExampleCreateOrderLegacy() {
 	fmt.Printf("Created At: %s\n", order.CreatedAt)
 }
 
-// CreateDraftOrderREST creates a draft order using REST API with discount and tax support
-// Returns draft order ID and order ID after completion
-func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
+// restDraftOrder is the part of a REST draft order response that
+// CreateDraftOrderREST reads
+type restDraftOrder struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	OrderID     *int64        `json:"order_id"`
+	OrderNumber int           `json:"order_number"`
+	Currency    string        `json:"currency"`
+	TaxLines    []restTaxLine `json:"tax_lines"`
+}
 
-	if shopDomain == "" || accessToken == "" {
-		return nil, fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
+// decodeRESTDraftOrder decodes a REST draft order response
+func decodeRESTDraftOrder(body []byte) (*restDraftOrder, error) {
+	var response struct {
+		DraftOrder *restDraftOrder `json:"draft_order"`
+		Errors     any             `json:"errors"`
 	}
-
-	apiVersion := "2025-10"
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse draft order response: %w", err)
 	}
+	if response.Errors != nil {
+		return nil, fmt.Errorf("REST API errors: %v", response.Errors)
+	}
+	draftOrder := response.DraftOrder
+	if draftOrder == nil {
+		return nil, fmt.Errorf("missing draft_order in response")
+	}
+	setTaxLineCurrency(draftOrder.TaxLines, draftOrder.Currency)
+	return draftOrder, nil
+}
+
+// CreateDraftOrderREST creates a draft order using REST API with discount and tax support
+// Returns draft order ID and order ID after completion
+func (c *Client) CreateDraftOrderREST(ctx context.Context, input OrderInput) (_ map[string]string, err error) {
+	ctx, span := c.startSpan(ctx, "CreateDraftOrderREST")
+	defer func() { endSpan(span, err) }()
 
 	// Build draft order payload
 	draftOrderPayload := map[string]interface{}{
@@ -2716,7 +2221,7 @@ func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
 		}
 
 		// Add original price if available
-		if item.Price != "" {
+		if !item.Price.IsZero() {
 			lineItem["price"] = item.Price
 		}
 
@@ -2735,12 +2240,8 @@ func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
 				taxLine := map[string]interface{}{
 					"title": tl.Title,
 				}
-				if tl.Rate != "" {
-					if rate, err := strconv.ParseFloat(tl.Rate, 64); err == nil && rate > 0 {
-						taxLine["rate"] = fmt.Sprintf("%.4f", rate)
-					} else {
-						taxLine["rate"] = tl.Rate
-					}
+				if tl.Rate.IsPositive() {
+					taxLine["rate"] = tl.Rate.StringFixed(4)
 				}
 				if tl.PriceSet != nil && tl.PriceSet.ShopMoney != nil {
 					taxLine["price"] = tl.PriceSet.ShopMoney.Amount
@@ -2757,14 +2258,14 @@ func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
 	// Add order-level discount if available
 	if input.AppliedDiscount != nil {
 		orderDiscount := map[string]interface{}{
-			"amount": fmt.Sprintf("%.2f", input.AppliedDiscount.Value),
+			"amount": input.AppliedDiscount.Value.StringFixed(2),
 		}
 		if input.AppliedDiscount.Title != "" {
 			orderDiscount["title"] = input.AppliedDiscount.Title
 		}
 		if input.AppliedDiscount.ValueType == "PERCENTAGE" {
 			orderDiscount["value_type"] = "percentage"
-			orderDiscount["value"] = fmt.Sprintf("%.2f", input.AppliedDiscount.Value)
+			orderDiscount["value"] = input.AppliedDiscount.Value.StringFixed(2)
 		} else {
 			orderDiscount["value_type"] = "fixed_amount"
 		}
@@ -2811,26 +2312,16 @@ func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
 				"title": tl.Title,
 			}
 			// Rate should be float/decimal, not string
-			if tl.Rate != "" {
-				if rate, err := strconv.ParseFloat(tl.Rate, 64); err == nil && rate > 0 {
-					taxLine["rate"] = rate // Use float
-				}
+			if tl.Rate.IsPositive() {
+				taxLine["rate"] = json.Number(tl.Rate.String()) // Use a number
 			}
-			// Price should also be float/decimal, not string
+			// Price should also be a number, not string
 			if tl.PriceSet != nil && tl.PriceSet.ShopMoney != nil {
-				if price, err := strconv.ParseFloat(tl.PriceSet.ShopMoney.Amount, 64); err == nil {
-					taxLine["price"] = price // Use float, not string
-				} else {
-					// Fallback to string if parsing fails
-					taxLine["price"] = tl.PriceSet.ShopMoney.Amount
-				}
+				taxLine["price"] = json.Number(tl.PriceSet.ShopMoney.Amount.String())
 			}
 			taxLines[i] = taxLine
 		}
-		fmt.Printf("Debug: Adding %d tax lines at order level with format: rate as float, price as float\n", len(taxLines))
-		for i, tl := range taxLines {
-			fmt.Printf("Debug: Tax line %d: %v\n", i+1, tl)
-		}
+		c.logger().Debug("adding tax lines at order level", "tax_lines", taxLines)
 		draftOrderPayload["tax_lines"] = taxLines
 	}
 
@@ -2839,166 +2330,77 @@ func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
 		"draft_order": draftOrderPayload,
 	}
 
-	jsonData, err := json.Marshal(requestPayload)
+	// Create draft order; the payload and response are logged by doREST
+	status, bodyBytes, err := c.doREST(ctx, http.MethodPost, "draft_orders.json", requestPayload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	fmt.Printf("Debug: Creating draft order via REST API with discount and tax...\n")
-	fmt.Printf("Debug: Request payload:\n%s\n\n", string(jsonData))
-
-	// Create draft order
-	url := fmt.Sprintf("https://%s/admin/api/%s/draft_orders.json", shopDomain, apiVersion)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, fmt.Errorf("failed to create draft order: %w", &HTTPStatusError{StatusCode: status, Body: string(bodyBytes)})
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	draftOrder, err := decodeRESTDraftOrder(bodyBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	fmt.Printf("Debug: Draft order creation response status: %d\n", resp.StatusCode)
-	fmt.Printf("Debug: Response body:\n%s\n\n", string(bodyBytes))
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to create draft order: status %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var restResponse map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &restResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for errors
-	if errors, ok := restResponse["errors"]; ok {
-		return nil, fmt.Errorf("API errors: %v", errors)
-	}
-
-	draftOrder, ok := restResponse["draft_order"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("missing draft_order in response")
-	}
-
-	draftOrderID, ok := draftOrder["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("could not extract draft order ID")
+		return nil, err
 	}
-
-	fmt.Printf("✓ Draft order created successfully (ID: %.0f)\n", draftOrderID)
-
-	// Check tax lines in draft order
-	if taxLines, ok := draftOrder["tax_lines"].([]interface{}); ok {
-		fmt.Printf("Debug: Tax lines in draft order after creation: %d\n", len(taxLines))
-		for i, tl := range taxLines {
-			if tlMap, ok := tl.(map[string]interface{}); ok {
-				fmt.Printf("  Tax Line %d: %v\n", i+1, tlMap)
+	draftID := strconv.FormatInt(draftOrder.ID, 10)
+
+	log := c.logger().With("draft_id", draftID)
+	log.Debug("draft order created")
+
+	// Check if custom tax_lines were applied or ignored
+	log.Debug("draft order tax lines", "tax_lines", draftOrder.TaxLines)
+	if len(draftOrder.TaxLines) > 0 {
+		title := draftOrder.TaxLines[0].Title
+		// Check if it's our custom tax or Shopify's auto tax
+		customTaxFound := false
+		for _, inputTL := range input.TaxLines {
+			if title == inputTL.Title {
+				customTaxFound = true
+				break
 			}
 		}
-		// Check if custom tax_lines were applied or ignored
-		if len(taxLines) > 0 {
-			firstTaxLine, ok := taxLines[0].(map[string]interface{})
-			if ok {
-				if title, ok := firstTaxLine["title"].(string); ok {
-					// Check if it's our custom tax or Shopify's auto tax
-					customTaxFound := false
-					for _, inputTL := range input.TaxLines {
-						if title == inputTL.Title {
-							customTaxFound = true
-							break
-						}
-					}
-					if !customTaxFound {
-						fmt.Printf("⚠️  Custom tax_lines were ignored. Shopify used auto-calculated tax: %s\n", title)
-					}
-				}
-			}
+		if !customTaxFound {
+			log.Warn("custom tax_lines were ignored, Shopify used auto-calculated tax", "title", title)
 		}
 	}
 
 	// Complete the draft order
-	fmt.Printf("\nCompleting draft order...\n")
-	completeURL := fmt.Sprintf("https://%s/admin/api/%s/draft_orders/%.0f/complete.json?payment_pending=true",
-		shopDomain, apiVersion, draftOrderID)
-
-	completeReq, err := http.NewRequest("PUT", completeURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create complete request: %w", err)
-	}
-
-	completeReq.Header.Set("Content-Type", "application/json")
-	completeReq.Header.Set("X-Shopify-Access-Token", accessToken)
+	completePath := fmt.Sprintf("draft_orders/%s/complete.json?payment_pending=true", draftID)
 
-	completeResp, err := client.Do(completeReq)
+	completeStatus, completeBody, err := c.doREST(ctx, http.MethodPut, completePath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete draft order: %w", err)
 	}
-	defer completeResp.Body.Close()
-
-	completeBody, err := io.ReadAll(completeResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read complete response: %w", err)
-	}
-
-	fmt.Printf("Debug: Complete response status: %d\n", completeResp.StatusCode)
-	fmt.Printf("Debug: Complete response body:\n%s\n\n", string(completeBody))
-
-	if completeResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to complete draft order: status %d, body: %s", completeResp.StatusCode, string(completeBody))
-	}
-
-	var completedResponse map[string]interface{}
-	if err := json.Unmarshal(completeBody, &completedResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse completed response: %w", err)
-	}
 
-	if errors, ok := completedResponse["errors"]; ok {
-		return nil, fmt.Errorf("complete API errors: %v", errors)
+	if completeStatus != http.StatusOK {
+		return nil, fmt.Errorf("failed to complete draft order: %w", &HTTPStatusError{StatusCode: completeStatus, Body: string(completeBody)})
 	}
 
-	completedDraftOrder, ok := completedResponse["draft_order"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("missing draft_order in completed response")
+	completed, err := decodeRESTDraftOrder(completeBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read completed draft order: %w", err)
 	}
 
 	result := map[string]string{
-		"draft_id": fmt.Sprintf("%.0f", draftOrderID),
-	}
-
-	if draftName, ok := completedDraftOrder["name"].(string); ok {
-		result["draft_name"] = draftName
+		"draft_id":   draftID,
+		"draft_name": completed.Name,
 	}
 
 	// Check if order was created
-	if orderID, ok := completedDraftOrder["order_id"]; ok && orderID != nil {
-		result["order_id"] = fmt.Sprintf("gid://shopify/Order/%.0f", orderID.(float64))
-		if orderNumber, ok := completedDraftOrder["order_number"].(float64); ok {
-			result["order_number"] = fmt.Sprintf("%.0f", orderNumber)
+	if completed.OrderID != nil {
+		result["order_id"] = resourceGID("Order", strconv.FormatInt(*completed.OrderID, 10))
+		if completed.OrderNumber != 0 {
+			result["order_number"] = strconv.Itoa(completed.OrderNumber)
 		}
-		fmt.Printf("✓ Order created successfully!\n")
+		log.Debug("order created from draft", "order_id", result["order_id"])
 	} else {
-		fmt.Printf("⚠ Order ID not found (may be pending payment)\n")
+		log.Warn("order ID not found after completing draft (may be pending payment)")
 	}
 
 	// Check tax lines in completed order
-	if taxLines, ok := completedDraftOrder["tax_lines"].([]interface{}); ok {
-		fmt.Printf("Debug: Tax lines in completed order: %d\n", len(taxLines))
-		for i, tl := range taxLines {
-			if tlMap, ok := tl.(map[string]interface{}); ok {
-				fmt.Printf("  Tax Line %d: %v\n", i+1, tlMap)
-			}
-		}
-	}
+	log.Debug("completed order tax lines", "tax_lines", completed.TaxLines)
 
 	return result, nil
 }
@@ -3011,16 +2413,26 @@ type OrderEditBeginResponse struct {
 
 // CalculatedLineItem represents a line item in a calculated order edit
 type CalculatedLineItem struct {
-	ID                   string
-	Title                string
-	Quantity             int
-	DiscountedUnitPrice  string
-	OriginalUnitPrice    string
+	ID                  string
+	Title               string
+	Quantity            int
+	DiscountedUnitPrice Money
+	OriginalUnitPrice   Money
+	// The fields below are filled by OrderEditSession
+	VariantID string
+	SKU       string
+	// Units that can still be changed, i.e. not yet fulfilled
+	EditableQuantity int
+	// Whether removed units can be returned to inventory
+	Restockable bool
+	// Discounted total of the line
+	EditableSubtotal Money
+	Discounts        []CalculatedDiscount
 }
 
 // OrderEditBegin starts an order edit session
 // Returns the calculated order ID and line items that can be edited
-func OrderEditBegin(orderID string) (*OrderEditBeginResponse, error) {
+func (c *Client) OrderEditBegin(ctx context.Context, orderID string) (*OrderEditBeginResponse, error) {
 	const mutation = `
 		mutation OrderEditBegin($id: ID!) {
 			orderEditBegin(id: $id) {
@@ -3040,6 +2452,10 @@ func OrderEditBegin(orderID string) (*OrderEditBeginResponse, error) {
 								}
 							}
 						}
+						pageInfo {
+							hasNextPage
+							endCursor
+						}
 					}
 				}
 				userErrors {
@@ -3053,76 +2469,136 @@ func OrderEditBegin(orderID string) (*OrderEditBeginResponse, error) {
 		"id": orderID,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		OrderEditBegin struct {
+			CalculatedOrder *struct {
+				ID        string                             `json:"id"`
+				LineItems Connection[calculatedLineItemNode] `json:"lineItems"`
+			} `json:"calculatedOrder"`
+		} `json:"orderEditBegin"`
+	}](ctx, c, mutation, variables)
+	if IsUserError(err) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin order edit: %w", err)
 	}
 
-	// Parse response
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+	calculatedOrder := data.OrderEditBegin.CalculatedOrder
+	if calculatedOrder == nil {
+		return nil, fmt.Errorf("missing calculatedOrder in response")
 	}
 
-	orderEditBegin, ok := data["orderEditBegin"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("missing orderEditBegin in response")
+	result := &OrderEditBeginResponse{
+		CalculatedOrderID: calculatedOrder.ID,
 	}
 
-	// Check for user errors
-	if userErrors, ok := orderEditBegin["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-		return nil, fmt.Errorf("user errors: %v", userErrors)
+	// Parse line items (first page from the mutation, the rest from the calculated order)
+	firstPage := calculatedOrder.LineItems
+	nodes := firstPage.Items()
+	if firstPage.PageInfo.HasNextPage {
+		rest, err := CollectAll(Paginate[calculatedLineItemNode](ctx, c, calculatedLineItemsQuery, map[string]interface{}{
+			"id":    result.CalculatedOrderID,
+			"first": calculatedLineItemsPage,
+			"after": firstPage.PageInfo.EndCursor,
+		}, "node", "lineItems"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get calculated line items: %w", err)
+		}
+		nodes = append(nodes, rest...)
 	}
 
-	calculatedOrder, ok := orderEditBegin["calculatedOrder"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("missing calculatedOrder in response")
+	for _, node := range nodes {
+		result.LineItems = append(result.LineItems, node.calculatedLineItem())
 	}
 
-	result := &OrderEditBeginResponse{
-		CalculatedOrderID: calculatedOrder["id"].(string),
-	}
-
-	// Parse line items
-	if lineItems, ok := calculatedOrder["lineItems"].(map[string]interface{}); ok {
-		if edges, ok := lineItems["edges"].([]interface{}); ok {
-			for _, edge := range edges {
-				edgeMap := edge.(map[string]interface{})
-				node := edgeMap["node"].(map[string]interface{})
-				
-				lineItem := CalculatedLineItem{
-					ID:       node["id"].(string),
-					Title:    node["title"].(string),
-					Quantity: int(node["quantity"].(float64)),
-				}
-				
-				if priceSet, ok := node["discountedUnitPriceSet"].(map[string]interface{}); ok {
-					if shopMoney, ok := priceSet["shopMoney"].(map[string]interface{}); ok {
-						lineItem.DiscountedUnitPrice = shopMoney["amount"].(string)
+	return result, nil
+}
+
+// calculatedLineItemNode is a CalculatedLineItem node as returned by GraphQL.
+// orderEditBegin selects only id, title, quantity and discountedUnitPriceSet;
+// the other fields are those of calculatedLineItemFields.
+type calculatedLineItemNode struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+	Variant  *struct {
+		ID string `json:"id"`
+	} `json:"variant"`
+	EditableQuantity              int      `json:"editableQuantity"`
+	Restockable                   bool     `json:"restockable"`
+	OriginalUnitPriceSet          MoneyBag `json:"originalUnitPriceSet"`
+	DiscountedUnitPriceSet        MoneyBag `json:"discountedUnitPriceSet"`
+	EditableSubtotalSet           MoneyBag `json:"editableSubtotalSet"`
+	CalculatedDiscountAllocations []struct {
+		AllocatedAmountSet  MoneyBag `json:"allocatedAmountSet"`
+		DiscountApplication struct {
+			ID          string `json:"id"`
+			Description string `json:"description"`
+		} `json:"discountApplication"`
+	} `json:"calculatedDiscountAllocations"`
+}
+
+func (node calculatedLineItemNode) calculatedLineItem() CalculatedLineItem {
+	item := CalculatedLineItem{
+		ID:                  node.ID,
+		Title:               node.Title,
+		Quantity:            node.Quantity,
+		DiscountedUnitPrice: node.DiscountedUnitPriceSet.ShopMoney.Amount,
+		OriginalUnitPrice:   node.OriginalUnitPriceSet.ShopMoney.Amount,
+		SKU:                 node.SKU,
+		EditableQuantity:    node.EditableQuantity,
+		Restockable:         node.Restockable,
+		EditableSubtotal:    node.EditableSubtotalSet.ShopMoney.Amount,
+	}
+	if node.Variant != nil {
+		item.VariantID = node.Variant.ID
+	}
+	for _, allocation := range node.CalculatedDiscountAllocations {
+		item.Discounts = append(item.Discounts, CalculatedDiscount{
+			ID:          allocation.DiscountApplication.ID,
+			Description: allocation.DiscountApplication.Description,
+			Amount:      allocation.AllocatedAmountSet.ShopMoney.Amount,
+		})
+	}
+	return item
+}
+
+// calculatedLineItemsPage is the number of calculated line items requested at a
+// time. A line item with calculatedLineItemFields costs about 12 points, so a
+// page of 250 would exceed the 1000-point limit of a single query.
+const calculatedLineItemsPage = 50
+
+// calculatedLineItemsQuery pages through the line items of a calculated order
+const calculatedLineItemsQuery = `
+	query CalculatedOrderLineItems($id: ID!, $first: Int!, $after: String) {
+		node(id: $id) {
+			... on CalculatedOrder {
+				lineItems(first: $first, after: $after) {
+					nodes { ` + calculatedLineItemFields + ` }
+					pageInfo {
+						hasNextPage
+						endCursor
 					}
 				}
-				
-				result.LineItems = append(result.LineItems, lineItem)
 			}
 		}
-	}
-
-	return result, nil
-}
+	}`
 
 // OrderEditAddLineItemDiscountInput represents input for adding discount to a line item
 type OrderEditAddLineItemDiscountInput struct {
 	CalculatedOrderID string
 	LineItemID        string
 	DiscountTitle     string
-	PercentValue      float64  // Use for percentage discount (0-100)
-	FixedValue        float64  // Use for fixed amount discount
-	IsPercentage      bool     // true = percentage, false = fixed amount
+	PercentValue      decimal.Decimal // Use for percentage discount (0-100)
+	FixedValue        Money           // Use for fixed amount discount
+	IsPercentage      bool            // true = percentage, false = fixed amount
 }
 
 // OrderEditAddLineItemDiscount adds a discount to a line item in an order edit session
 // This will show the original price with strikethrough in Shopify Admin!
-func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error {
+func (c *Client) OrderEditAddLineItemDiscount(ctx context.Context, input OrderEditAddLineItemDiscountInput) error {
 	var mutation string
 	var variables map[string]interface{}
 
@@ -3147,13 +2623,13 @@ func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error
 					}
 				}
 			}`
-		
+
 		variables = map[string]interface{}{
 			"id":         input.CalculatedOrderID,
 			"lineItemId": input.LineItemID,
 			"discount": map[string]interface{}{
 				"description":  input.DiscountTitle,
-				"percentValue": input.PercentValue,
+				"percentValue": json.Number(input.PercentValue.String()),
 			},
 		}
 	} else {
@@ -3177,7 +2653,7 @@ func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error
 					}
 				}
 			}`
-		
+
 		variables = map[string]interface{}{
 			"id":         input.CalculatedOrderID,
 			"lineItemId": input.LineItemID,
@@ -3188,34 +2664,28 @@ func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error
 		}
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		OrderEditAddLineItemDiscount struct {
+			CalculatedOrder *struct {
+				ID string `json:"id"`
+			} `json:"calculatedOrder"`
+			CalculatedLineItem *struct {
+				ID                     string   `json:"id"`
+				DiscountedUnitPriceSet MoneyBag `json:"discountedUnitPriceSet"`
+			} `json:"calculatedLineItem"`
+		} `json:"orderEditAddLineItemDiscount"`
+	}](ctx, c, mutation, variables)
+	if IsUserError(err) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("failed to add line item discount: %w", err)
 	}
 
-	// Parse response
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("unexpected response format")
-	}
-
-	orderEditResult, ok := data["orderEditAddLineItemDiscount"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("missing orderEditAddLineItemDiscount in response")
-	}
-
-	// Check for user errors
-	if userErrors, ok := orderEditResult["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-		return fmt.Errorf("user errors: %v", userErrors)
-	}
-
 	// Log the discounted price
-	if calculatedLineItem, ok := orderEditResult["calculatedLineItem"].(map[string]interface{}); ok {
-		if priceSet, ok := calculatedLineItem["discountedUnitPriceSet"].(map[string]interface{}); ok {
-			if shopMoney, ok := priceSet["shopMoney"].(map[string]interface{}); ok {
-				fmt.Printf("  → Line item discounted price: $%s\n", shopMoney["amount"])
-			}
-		}
+	if lineItem := data.OrderEditAddLineItemDiscount.CalculatedLineItem; lineItem != nil {
+		c.logger().Debug("line item discount added", "line_item_id", lineItem.ID,
+			"discounted_unit_price", lineItem.DiscountedUnitPriceSet.ShopMoney.Amount)
 	}
 
 	return nil
@@ -3223,7 +2693,7 @@ func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error
 
 // OrderEditCommit commits the order edit changes
 // This finalizes all discounts added and they will show with strikethrough in Shopify Admin
-func OrderEditCommit(calculatedOrderID string, notifyCustomer bool) error {
+func (c *Client) OrderEditCommit(ctx context.Context, calculatedOrderID string, notifyCustomer bool) error {
 	const mutation = `
 		mutation OrderEditCommit($id: ID!, $notifyCustomer: Boolean!) {
 			orderEditCommit(id: $id, notifyCustomer: $notifyCustomer) {
@@ -3243,30 +2713,24 @@ func OrderEditCommit(calculatedOrderID string, notifyCustomer bool) error {
 		"notifyCustomer": notifyCustomer,
 	}
 
-	resp, err := callAdminGraphQL(mutation, variables)
+	data, err := Do[struct {
+		OrderEditCommit struct {
+			Order *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"order"`
+		} `json:"orderEditCommit"`
+	}](ctx, c, mutation, variables)
+	if IsUserError(err) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("failed to commit order edit: %w", err)
 	}
 
-	// Parse response
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("unexpected response format")
-	}
-
-	orderEditCommit, ok := data["orderEditCommit"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("missing orderEditCommit in response")
-	}
-
-	// Check for user errors
-	if userErrors, ok := orderEditCommit["userErrors"].([]interface{}); ok && len(userErrors) > 0 {
-		return fmt.Errorf("user errors: %v", userErrors)
-	}
-
 	// Log success
-	if order, ok := orderEditCommit["order"].(map[string]interface{}); ok {
-		fmt.Printf("  → Order edit committed: %s\n", order["name"])
+	if order := data.OrderEditCommit.Order; order != nil {
+		c.logger().Info("order edit committed", "order_id", order.ID, "order_name", order.Name)
 	}
 
 	return nil
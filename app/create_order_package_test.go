@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// Shopify types these fields Float, which rejects the quoted strings
+// decimal.Decimal marshals to
+func TestInputsMarshalFloatsAsNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{
+			"discount value",
+			AppliedDiscountInput{ValueType: "PERCENTAGE", Value: decimal.RequireFromString("12.50")},
+			`{"valueType":"PERCENTAGE","value":12.5}`,
+		},
+		{
+			"tax line rate",
+			TaxLineInput{Title: "GST", Rate: decimal.RequireFromString("0.05")},
+			`{"title":"GST","rate":0.05}`,
+		},
+		{
+			"tax line without a rate",
+			TaxLineInput{Title: "GST"},
+			`{"title":"GST"}`,
+		},
+		{
+			"discount in a draft line item",
+			DraftLineItemInput{Quantity: 1, AppliedDiscount: &AppliedDiscountInput{ValueType: "FIXED_AMOUNT", Value: decimal.NewFromInt(5)}},
+			`{"variantId":"","quantity":1,"appliedDiscount":{"valueType":"FIXED_AMOUNT","value":5}}`,
+		},
+		{
+			"percentage discount code",
+			ItemPercentageDiscountCodeInput{Code: "SAVE", Percentage: decimal.RequireFromString("12.5")},
+			`{"code":"SAVE","percentage":12.5}`,
+		},
+		{
+			"order edit percentage discount",
+			(&OrderEditSession{}).discountInput(OrderEditDiscount{Description: "Loyalty", PercentValue: decimal.NewFromInt(20), IsPercentage: true}),
+			`{"description":"Loyalty","percentValue":20}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRESTOrder(t *testing.T) {
+	order, err := decodeRESTOrder([]byte(`{"order":{"id":1006,"currency":"EUR","total_price":"52.50",
+		"tax_lines":[{"title":"GST","rate":0.05,"price":"2.50"}],
+		"line_items":[{"id":7,"price":"25.00","quantity":2,"tax_lines":[]}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 1006 || order.TotalPrice.String() != "52.5" || order.TotalPrice.Currency() != "EUR" {
+		t.Errorf("order = %d %v %q", order.ID, order.TotalPrice, order.TotalPrice.Currency())
+	}
+	if tl := order.TaxLines[0]; tl.Rate.String() != "0.05" || tl.Price.Currency() != "EUR" {
+		t.Errorf("tax line = %s %v %q", tl.Rate, tl.Price, tl.Price.Currency())
+	}
+	if li := order.LineItems[0]; li.ID != 7 || li.Quantity != 2 || li.Price.Currency() != "EUR" {
+		t.Errorf("line item = %+v", li)
+	}
+
+	for name, body := range map[string]string{
+		"errors":        `{"errors":{"order":["is invalid"]}}`,
+		"no order":      `{}`,
+		"id not number": `{"order":{"id":"1006"}}`,
+	} {
+		if _, err := decodeRESTOrder([]byte(body)); err == nil {
+			t.Errorf("%s: decodeRESTOrder(%s) succeeded", name, body)
+		}
+	}
+}
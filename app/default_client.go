@@ -0,0 +1,226 @@
+package app
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// Package-level functions kept for backward compatibility.
+// Each one forwards to the same method on DefaultClient() with context.Background();
+// call the Client methods directly to pass a context for cancellation and deadlines.
+
+// CallAdminGraphQL calls DefaultClient().CallAdminGraphQL
+func CallAdminGraphQL(query string, variables map[string]interface{}) (map[string]interface{}, error) {
+	return DefaultClient().CallAdminGraphQL(context.Background(), query, variables)
+}
+
+// CallAdminREST calls DefaultClient().CallAdminREST
+func CallAdminREST(method, path string, payload interface{}) (int, []byte, error) {
+	return DefaultClient().CallAdminREST(context.Background(), method, path, payload)
+}
+
+// CreateDraftOrder calls DefaultClient().CreateDraftOrder
+func CreateDraftOrder(input DraftOrderInput) (*DraftOrderResponse, error) {
+	return DefaultClient().CreateDraftOrder(context.Background(), input)
+}
+
+// QueryDraftOrder calls DefaultClient().QueryDraftOrder
+func QueryDraftOrder(draftID string) (*DraftOrderDetails, error) {
+	return DefaultClient().QueryDraftOrder(context.Background(), draftID)
+}
+
+// CalculateDraftOrder calls DefaultClient().CalculateDraftOrder
+func CalculateDraftOrder(input DraftOrderInput) (*CalculatedDraftOrder, error) {
+	return DefaultClient().CalculateDraftOrder(context.Background(), input)
+}
+
+// UpdateDraftOrder calls DefaultClient().UpdateDraftOrder
+func UpdateDraftOrder(draftID string, input DraftOrderInput) (*DraftOrderResponse, error) {
+	return DefaultClient().UpdateDraftOrder(context.Background(), draftID, input)
+}
+
+// CompleteDraftOrder calls DefaultClient().CompleteDraftOrder
+func CompleteDraftOrder(draftID string, paymentPending bool) (*OrderInfo, error) {
+	return DefaultClient().CompleteDraftOrder(context.Background(), draftID, paymentPending)
+}
+
+// GetFulfillmentOrders calls DefaultClient().GetFulfillmentOrders
+func GetFulfillmentOrders(orderID string) ([]FulfillmentOrderInfo, error) {
+	return DefaultClient().GetFulfillmentOrders(context.Background(), orderID)
+}
+
+// GetFulfillmentOrdersWithRetry calls DefaultClient().GetFulfillmentOrdersWithRetry
+func GetFulfillmentOrdersWithRetry(orderID string, maxRetries int, initialDelay time.Duration) ([]FulfillmentOrderInfo, error) {
+	return DefaultClient().GetFulfillmentOrdersWithRetry(context.Background(), orderID, maxRetries, initialDelay)
+}
+
+// CreateFulfillment calls DefaultClient().CreateFulfillment
+func CreateFulfillment(fulfillmentOrderIDs []string, trackingInfo *TrackingInfo) (string, error) {
+	return DefaultClient().CreateFulfillment(context.Background(), fulfillmentOrderIDs, trackingInfo)
+}
+
+// AddTaxToOrder calls DefaultClient().AddTaxToOrder
+func AddTaxToOrder(orderID string, taxLines []TaxLineInput) error {
+	return DefaultClient().AddTaxToOrder(context.Background(), orderID, taxLines)
+}
+
+// UpdateOrderTaxLinesREST calls DefaultClient().UpdateOrderTaxLinesREST
+func UpdateOrderTaxLinesREST(orderID string, taxLines []TaxLineRestInput) error {
+	return DefaultClient().UpdateOrderTaxLinesREST(context.Background(), orderID, taxLines)
+}
+
+// UpdateOrderTaxViaEdit calls DefaultClient().UpdateOrderTaxViaEdit
+func UpdateOrderTaxViaEdit(orderID string, taxLines []TaxLineInput) error {
+	return DefaultClient().UpdateOrderTaxViaEdit(context.Background(), orderID, taxLines)
+}
+
+// CreateOrderFromDraft calls DefaultClient().CreateOrderFromDraft
+func CreateOrderFromDraft(input DraftOrderInput, paymentPending bool) (*OrderInfo, error) {
+	return DefaultClient().CreateOrderFromDraft(context.Background(), input, paymentPending)
+}
+
+// CreateOrderFromDraftWithTaxAttempt calls DefaultClient().CreateOrderFromDraftWithTaxAttempt
+func CreateOrderFromDraftWithTaxAttempt(input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (*OrderInfo, error) {
+	return DefaultClient().CreateOrderFromDraftWithTaxAttempt(context.Background(), input, taxLines, paymentPending)
+}
+
+// CreateOrderFromDraftWithTax calls DefaultClient().CreateOrderFromDraftWithTax
+func CreateOrderFromDraftWithTax(input DraftOrderInput, taxLines []TaxLineInput, paymentPending bool) (*OrderInfo, error) {
+	return DefaultClient().CreateOrderFromDraftWithTax(context.Background(), input, taxLines, paymentPending)
+}
+
+// MarkOrderAsPaid calls DefaultClient().MarkOrderAsPaid
+func MarkOrderAsPaid(orderID string) error {
+	return DefaultClient().MarkOrderAsPaid(context.Background(), orderID)
+}
+
+// CreateOrderWithTax calls DefaultClient().CreateOrderWithTax
+func CreateOrderWithTax(input OrderInput) (*OrderResponse, error) {
+	return DefaultClient().CreateOrderWithTax(context.Background(), input)
+}
+
+// CreateOrder calls DefaultClient().CreateOrder
+func CreateOrder(input OrderInput) (*OrderResponse, error) {
+	return DefaultClient().CreateOrder(context.Background(), input)
+}
+
+// EnsureShippingNoteMetafieldDefinition calls DefaultClient().EnsureShippingNoteMetafieldDefinition
+func EnsureShippingNoteMetafieldDefinition() error {
+	return DefaultClient().EnsureShippingNoteMetafieldDefinition(context.Background())
+}
+
+// CreateOrderGraphQL calls DefaultClient().CreateOrderGraphQL
+func CreateOrderGraphQL(input OrderInput) (*OrderResponse, error) {
+	return DefaultClient().CreateOrderGraphQL(context.Background(), input)
+}
+
+// UpdateVariantPriceAndCompareAt calls DefaultClient().UpdateVariantPriceAndCompareAt
+func UpdateVariantPriceAndCompareAt(variantID string, price, compareAtPrice Money) error {
+	return DefaultClient().UpdateVariantPriceAndCompareAt(context.Background(), variantID, price, compareAtPrice)
+}
+
+// CreateDraftOrderREST calls DefaultClient().CreateDraftOrderREST
+func CreateDraftOrderREST(input OrderInput) (map[string]string, error) {
+	return DefaultClient().CreateDraftOrderREST(context.Background(), input)
+}
+
+// OrderEditBegin calls DefaultClient().OrderEditBegin
+func OrderEditBegin(orderID string) (*OrderEditBeginResponse, error) {
+	return DefaultClient().OrderEditBegin(context.Background(), orderID)
+}
+
+// OrderEditAddLineItemDiscount calls DefaultClient().OrderEditAddLineItemDiscount
+func OrderEditAddLineItemDiscount(input OrderEditAddLineItemDiscountInput) error {
+	return DefaultClient().OrderEditAddLineItemDiscount(context.Background(), input)
+}
+
+// OrderEditCommit calls DefaultClient().OrderEditCommit
+func OrderEditCommit(calculatedOrderID string, notifyCustomer bool) error {
+	return DefaultClient().OrderEditCommit(context.Background(), calculatedOrderID, notifyCustomer)
+}
+
+// CreateOrderGraphQLIdempotent calls DefaultClient().CreateOrderGraphQLIdempotent
+func CreateOrderGraphQLIdempotent(key string, input OrderInput) (*OrderInfo, error) {
+	return DefaultClient().CreateOrderGraphQLIdempotent(context.Background(), key, input)
+}
+
+// CreateOrderFromDraftIdempotent calls DefaultClient().CreateOrderFromDraftIdempotent
+func CreateOrderFromDraftIdempotent(key string, input DraftOrderInput, paymentPending bool) (*OrderInfo, error) {
+	return DefaultClient().CreateOrderFromDraftIdempotent(context.Background(), key, input, paymentPending)
+}
+
+// GetOrder calls DefaultClient().GetOrder
+func GetOrder(id string, opts ...OrderOption) (*Order, error) {
+	return DefaultClient().GetOrder(context.Background(), id, opts...)
+}
+
+// SearchOrders calls DefaultClient().SearchOrders
+func SearchOrders(q *OrderQuery, opts ...OrderOption) iter.Seq2[*Order, error] {
+	return DefaultClient().SearchOrders(context.Background(), q, opts...)
+}
+
+// CancelOrder calls DefaultClient().CancelOrder
+func CancelOrder(orderID string, opts CancelOptions) (*CancelResult, error) {
+	return DefaultClient().CancelOrder(context.Background(), orderID, opts)
+}
+
+// SuggestRefund calls DefaultClient().SuggestRefund
+func SuggestRefund(orderID string, input SuggestRefundInput) (*SuggestedRefund, error) {
+	return DefaultClient().SuggestRefund(context.Background(), orderID, input)
+}
+
+// CreateRefund calls DefaultClient().CreateRefund
+func CreateRefund(input RefundInput) (*RefundResult, error) {
+	return DefaultClient().CreateRefund(context.Background(), input)
+}
+
+// CreateReturn calls DefaultClient().CreateReturn
+func CreateReturn(input ReturnInput) (*Return, error) {
+	return DefaultClient().CreateReturn(context.Background(), input)
+}
+
+// RefundReturn calls DefaultClient().RefundReturn
+func RefundReturn(input RefundReturnInput) (*RefundResult, error) {
+	return DefaultClient().RefundReturn(context.Background(), input)
+}
+
+// ProcessReturn calls DefaultClient().ProcessReturn
+func ProcessReturn(data ReturnData) (*ReturnResult, error) {
+	return DefaultClient().ProcessReturn(context.Background(), data)
+}
+
+// ListTransactions calls DefaultClient().ListTransactions
+func ListTransactions(orderID string) ([]OrderTransaction, error) {
+	return DefaultClient().ListTransactions(context.Background(), orderID)
+}
+
+// CreateTransaction calls DefaultClient().CreateTransaction
+func CreateTransaction(orderID string, input TransactionInput) (*OrderTransaction, error) {
+	return DefaultClient().CreateTransaction(context.Background(), orderID, input)
+}
+
+// RecordPayments calls DefaultClient().RecordPayments
+func RecordPayments(orderID string, payments []PaymentData) ([]OrderTransaction, error) {
+	return DefaultClient().RecordPayments(context.Background(), orderID, payments)
+}
+
+// CaptureTransaction calls DefaultClient().CaptureTransaction
+func CaptureTransaction(orderID, authorizationID string, amount Money) (*OrderTransaction, error) {
+	return DefaultClient().CaptureTransaction(context.Background(), orderID, authorizationID, amount)
+}
+
+// VoidTransaction calls DefaultClient().VoidTransaction
+func VoidTransaction(authorizationID string) (*OrderTransaction, error) {
+	return DefaultClient().VoidTransaction(context.Background(), authorizationID)
+}
+
+// EditOrder calls DefaultClient().EditOrder
+func EditOrder(orderID string) (*OrderEditSession, error) {
+	return DefaultClient().EditOrder(context.Background(), orderID)
+}
+
+// ReconcileOrder calls DefaultClient().ReconcileOrder
+func ReconcileOrder(orderID string, cart []CartLine, opts ReconcileOptions) (*ReconcileResult, error) {
+	return DefaultClient().ReconcileOrder(context.Background(), orderID, cart, opts)
+}
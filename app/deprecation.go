@@ -0,0 +1,218 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecatedReasonHeader is set by Shopify on responses to calls that use a
+// deprecated endpoint, field or argument in the requested API version
+const DeprecatedReasonHeader = "X-Shopify-API-Deprecated-Reason"
+
+// DeprecatedCall is an operation Shopify reported as deprecated
+type DeprecatedCall struct {
+	// GraphQL operation name (e.g. "CreateDraftOrder") or REST method and path
+	// with IDs replaced by ":id" (e.g. "POST orders/:id/transactions.json")
+	Operation string `json:"operation"`
+	// API version the call was made with
+	APIVersion string `json:"apiVersion"`
+	// Value of the X-Shopify-API-Deprecated-Reason header, usually a link to the changelog
+	Reason    string    `json:"reason"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// DeprecationLog collects deprecated calls, one entry per operation and API version.
+// A log opened with OpenDeprecationLog is saved to its file after each new entry,
+// so reports can cover calls made by earlier runs.
+type DeprecationLog struct {
+	mu    sync.Mutex
+	path  string
+	calls map[string]*DeprecatedCall
+}
+
+// NewDeprecationLog creates an empty in-memory log
+func NewDeprecationLog() *DeprecationLog {
+	return &DeprecationLog{calls: make(map[string]*DeprecatedCall)}
+}
+
+// OpenDeprecationLog loads the log at path, creating an empty one if the file does not exist
+func OpenDeprecationLog(path string) (*DeprecationLog, error) {
+	l := &DeprecationLog{path: path, calls: make(map[string]*DeprecatedCall)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read deprecation log: %w", err)
+	}
+	if len(content) > 0 {
+		var calls []DeprecatedCall
+		if err := json.Unmarshal(content, &calls); err != nil {
+			return nil, fmt.Errorf("invalid deprecation log %s: %w", path, err)
+		}
+		for i := range calls {
+			l.calls[deprecationKey(calls[i].Operation, calls[i].APIVersion)] = &calls[i]
+		}
+	}
+	return l, nil
+}
+
+func deprecationKey(operation, apiVersion string) string {
+	return apiVersion + " " + operation
+}
+
+// Record adds one deprecated call. It reports whether the operation is new to the log
+// for that API version. Saving errors are returned after the call has been recorded in memory.
+func (l *DeprecationLog) Record(operation, apiVersion, reason string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UTC()
+	key := deprecationKey(operation, apiVersion)
+	call, ok := l.calls[key]
+	if !ok {
+		call = &DeprecatedCall{Operation: operation, APIVersion: apiVersion, FirstSeen: now}
+		l.calls[key] = call
+	}
+	call.Reason = reason
+	call.Count++
+	call.LastSeen = now
+
+	// Only new entries are written so the file is not rewritten on every call
+	if ok || l.path == "" {
+		return !ok, nil
+	}
+	content, err := json.MarshalIndent(l.sortedLocked(), "", "  ")
+	if err != nil {
+		return true, fmt.Errorf("failed to encode deprecation log: %w", err)
+	}
+	if err := writeFileAtomic(l.path, content); err != nil {
+		return true, fmt.Errorf("failed to write deprecation log: %w", err)
+	}
+	return true, nil
+}
+
+// Calls returns the recorded calls sorted by API version and operation
+func (l *DeprecationLog) Calls() []DeprecatedCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sortedLocked()
+}
+
+func (l *DeprecationLog) sortedLocked() []DeprecatedCall {
+	calls := make([]DeprecatedCall, 0, len(l.calls))
+	for _, call := range l.calls {
+		calls = append(calls, *call)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].APIVersion != calls[j].APIVersion {
+			return calls[i].APIVersion < calls[j].APIVersion
+		}
+		return calls[i].Operation < calls[j].Operation
+	})
+	return calls
+}
+
+var (
+	defaultDeprecationLogOnce sync.Once
+	defaultDeprecationLog     *DeprecationLog
+)
+
+// DefaultDeprecationLog returns the log used by clients without WithDeprecationLog.
+// It is saved to SHOPIFY_DEPRECATION_FILE when that variable is set, otherwise kept in memory.
+func DefaultDeprecationLog() *DeprecationLog {
+	defaultDeprecationLogOnce.Do(func() {
+		if path := os.Getenv("SHOPIFY_DEPRECATION_FILE"); path != "" {
+			l, err := OpenDeprecationLog(path)
+			if err == nil {
+				defaultDeprecationLog = l
+				return
+			}
+			DefaultLogger().Warn("deprecated calls are only kept in memory", "error", err)
+		}
+		defaultDeprecationLog = NewDeprecationLog()
+	})
+	return defaultDeprecationLog
+}
+
+func (c *Client) deprecations() *DeprecationLog {
+	if c.Deprecations != nil {
+		return c.Deprecations
+	}
+	return DefaultDeprecationLog()
+}
+
+// recordDeprecation records the operation if the response carries X-Shopify-API-Deprecated-Reason.
+// A warning is logged the first time an operation is seen.
+func (c *Client) recordDeprecation(operation string, header http.Header) {
+	reason := header.Get(DeprecatedReasonHeader)
+	if reason == "" {
+		return
+	}
+	first, err := c.deprecations().Record(operation, c.apiVersion(), reason)
+	if first {
+		c.logger().Warn("deprecated API call", "operation", operation, "api_version", c.apiVersion(), "reason", reason)
+	}
+	if err != nil {
+		c.logger().Warn("cannot save deprecation log", "error", err)
+	}
+}
+
+// restIDSegment matches path segments that are resource IDs, e.g. "123" or "123.json"
+var restIDSegment = regexp.MustCompile(`^\d+`)
+
+// restOperation names a REST call by method and path, with IDs and the query string removed,
+// so calls to the same endpoint for different resources are recorded once
+func restOperation(method, path string) string {
+	path, _, _ = strings.Cut(strings.TrimPrefix(path, "/"), "?")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = restIDSegment.ReplaceAllString(segment, ":id")
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+// ShopifyDeprecatedAPICall is an entry of Shopify's report of deprecated calls made by the app
+type ShopifyDeprecatedAPICall struct {
+	APIType           string `json:"api_type"`
+	Description       string `json:"description"`
+	DocumentationURL  string `json:"documentation_url"`
+	Endpoint          string `json:"endpoint"`
+	LastCallAt        string `json:"last_call_at"`
+	MigrationDeadline string `json:"migration_deadline"`
+	GraphQLSchemaName string `json:"graphql_schema_name"`
+	Version           string `json:"version"`
+}
+
+// DeprecatedAPICalls fetches Shopify's own report of deprecated calls the app made
+// in the past 30 days (REST deprecated_api_calls.json). Shopify only serves it to
+// custom apps; for other apps the call fails with an *HTTPStatusError.
+func (c *Client) DeprecatedAPICalls(ctx context.Context) ([]ShopifyDeprecatedAPICall, error) {
+	status, body, err := c.doREST(ctx, http.MethodGet, "deprecated_api_calls.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: status, Body: string(body)}
+	}
+
+	var response struct {
+		DeprecatedAPICalls []ShopifyDeprecatedAPICall `json:"deprecated_api_calls"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return response.DeprecatedAPICalls, nil
+}
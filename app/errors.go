@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GraphQL error codes returned by Shopify in errors[].extensions.code
+const (
+	ErrorCodeThrottled       = "THROTTLED"
+	ErrorCodeAccessDenied    = "ACCESS_DENIED"
+	ErrorCodeShopInactive    = "SHOP_INACTIVE"
+	ErrorCodeInternalError   = "INTERNAL_SERVER_ERROR"
+	ErrorCodeMaxCostExceeded = "MAX_COST_EXCEEDED"
+)
+
+// ErrNotFound is wrapped by errors returned when a requested resource does not exist
+var ErrNotFound = errors.New("not found")
+
+// GraphQLErrors is returned when a GraphQL response carries top-level errors
+type GraphQLErrors struct {
+	Errors []GraphQLError
+}
+
+func (e *GraphQLErrors) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, gqlErr := range e.Errors {
+		if gqlErr.Extensions.Code != "" {
+			messages = append(messages, fmt.Sprintf("%s (%s)", gqlErr.Message, gqlErr.Extensions.Code))
+		} else {
+			messages = append(messages, gqlErr.Message)
+		}
+	}
+	return "GraphQL errors: " + strings.Join(messages, "; ")
+}
+
+// Code returns the first non-empty extensions.code, or "" if none is set
+func (e *GraphQLErrors) Code() string {
+	for _, gqlErr := range e.Errors {
+		if gqlErr.Extensions.Code != "" {
+			return gqlErr.Extensions.Code
+		}
+	}
+	return ""
+}
+
+// HasCode reports whether any of the errors has the given extensions.code
+func (e *GraphQLErrors) HasCode(code string) bool {
+	for _, gqlErr := range e.Errors {
+		if gqlErr.Extensions.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// UserErrors is returned when a mutation reports userErrors (validation failures)
+type UserErrors struct {
+	// The mutation that reported the errors, e.g. "draftOrderCreate"
+	Operation string
+	Errors    []UserError
+}
+
+func (e *UserErrors) Error() string {
+	var sb strings.Builder
+	if e.Operation != "" {
+		sb.WriteString(e.Operation)
+		sb.WriteString(": ")
+	}
+	sb.WriteString("User errors: ")
+	for _, userErr := range e.Errors {
+		fmt.Fprintf(&sb, "%v: %s; ", userErr.Field, userErr.Message)
+	}
+	return sb.String()
+}
+
+// FieldErrors groups the messages by dotted field path (e.g. "input.lineItems.0.quantity")
+// so callers can show them next to the matching input
+func (e *UserErrors) FieldErrors() map[string][]string {
+	fields := make(map[string][]string)
+	for _, userErr := range e.Errors {
+		path := strings.Join(userErr.Field, ".")
+		fields[path] = append(fields[path], userErr.Message)
+	}
+	return fields
+}
+
+// HTTPStatusError is returned when the Admin API answers with an unexpected HTTP status
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// newUserErrors converts a decoded userErrors value into *UserErrors.
+// It returns nil when there are no user errors.
+func newUserErrors(operation string, userErrors interface{}) error {
+	var parsed []UserError
+	switch v := userErrors.(type) {
+	case nil:
+		return nil
+	case []UserError:
+		parsed = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("%s: user errors: %v", operation, v)
+		}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("%s: user errors: %v", operation, v)
+		}
+	}
+
+	if len(parsed) == 0 {
+		return nil
+	}
+	return &UserErrors{Operation: operation, Errors: parsed}
+}
+
+// newGraphQLErrors converts a decoded errors value into *GraphQLErrors
+func newGraphQLErrors(errs interface{}) error {
+	var parsed []GraphQLError
+	data, err := json.Marshal(errs)
+	if err == nil {
+		err = json.Unmarshal(data, &parsed)
+	}
+	if err != nil || len(parsed) == 0 {
+		return fmt.Errorf("GraphQL errors: %v", errs)
+	}
+	return &GraphQLErrors{Errors: parsed}
+}
+
+// IsThrottled reports whether err is a THROTTLED GraphQL error or an HTTP 429
+func IsThrottled(err error) bool {
+	var gqlErrs *GraphQLErrors
+	if errors.As(err, &gqlErrs) && gqlErrs.HasCode(ErrorCodeThrottled) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsAccessDenied reports whether err is caused by missing access scopes or permissions
+func IsAccessDenied(err error) bool {
+	var gqlErrs *GraphQLErrors
+	if errors.As(err, &gqlErrs) && gqlErrs.HasCode(ErrorCodeAccessDenied) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden)
+}
+
+// IsNotFound reports whether err means the requested resource does not exist
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// IsUserError reports whether err carries mutation userErrors (validation failures)
+func IsUserError(err error) bool {
+	var userErrs *UserErrors
+	return errors.As(err, &userErrs)
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Do sends a GraphQL document with c and decodes the response's data into T.
+//
+// Top-level errors are returned as *GraphQLErrors. If any root field of data
+// (e.g. draftOrderCreate) reports userErrors, the decoded T is returned together
+// with a *UserErrors naming that field, so callers never have to check userErrors
+// themselves. A response whose shape does not match T is a decode error.
+func Do[T any](ctx context.Context, c *Client, query string, variables map[string]interface{}) (T, error) {
+	var result T
+	err := c.graphQL(ctx, query, variables, &result)
+	return result, err
+}
+
+// graphQL is the non-generic core of Do: it decodes data into out
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	bodyBytes, err := c.postGraphQL(ctx, query, variables)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors,omitempty"`
+	}
+	if err := json.Unmarshal(bodyBytes, &envelope); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(envelope.Errors) > 0 {
+		return &GraphQLErrors{Errors: envelope.Errors}
+	}
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return fmt.Errorf("unexpected GraphQL response, missing data")
+	}
+
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operationName(query), err)
+	}
+
+	return rootUserErrors(envelope.Data)
+}
+
+// rootUserErrors returns the userErrors of the first root field (in name order) that has any
+func rootUserErrors(data json.RawMessage) error {
+	var roots map[string]json.RawMessage
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(roots))
+	for name := range roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var payload struct {
+			UserErrors []UserError `json:"userErrors"`
+		}
+		// Query roots such as order or orders[] do not decode into the payload; skip them
+		if json.Unmarshal(roots[name], &payload) != nil {
+			continue
+		}
+		if err := newUserErrors(name, payload.UserErrors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// operationName returns the name of a GraphQL document's operation, e.g. "CreateDraftOrder"
+// for "mutation CreateDraftOrder(...)", or "query"/"mutation" for an anonymous operation
+func operationName(query string) string {
+	isName := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	doc := strings.TrimSpace(query)
+	kind := doc[:len(doc)-len(strings.TrimLeftFunc(doc, isName))]
+	if kind == "" {
+		return "query"
+	}
+	rest := strings.TrimSpace(doc[len(kind):])
+	name := rest[:len(rest)-len(strings.TrimLeftFunc(rest, isName))]
+	if name == "" {
+		return kind
+	}
+	return name
+}
@@ -0,0 +1,336 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdempotencyTagPrefix prefixes the order tag that records the idempotency key.
+// With a UUID hashId the tag is exactly 40 characters, Shopify's tag length limit.
+const IdempotencyTagPrefix = "pos:"
+
+// maxTagLength is the longest tag Shopify stores
+const maxTagLength = 40
+
+// IdempotencyKeyFor returns the idempotency key of a POS order: the cartId when the
+// POS sends one, otherwise the order hashId. It returns "" when both are empty.
+func IdempotencyKeyFor(cartID, hashID string) string {
+	if cartID = strings.TrimSpace(cartID); cartID != "" {
+		return cartID
+	}
+	return strings.TrimSpace(hashID)
+}
+
+// IdempotencyTag returns the order tag used to find an order created for key.
+// Keys too long for Shopify's 40-character tags are replaced by the start of
+// their SHA-256 in hex, so two long keys still get different tags.
+func IdempotencyTag(key string) string {
+	if len(IdempotencyTagPrefix)+len(key) <= maxTagLength {
+		return IdempotencyTagPrefix + key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return IdempotencyTagPrefix + hex.EncodeToString(sum[:])[:maxTagLength-len(IdempotencyTagPrefix)]
+}
+
+// IdempotencyStore remembers which order was created for an idempotency key.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the order recorded for key, or ok=false if there is none
+	Get(key string) (info *OrderInfo, ok bool, err error)
+	// Put records the order created for key
+	Put(key string, info *OrderInfo) error
+}
+
+// idempotencyRecord is one entry of a FileIdempotencyStore
+type idempotencyRecord struct {
+	Order     OrderInfo `json:"order"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MemoryIdempotencyStore keeps idempotency keys in memory, for a single long-running process
+type MemoryIdempotencyStore struct {
+	mu      sync.RWMutex
+	records map[string]OrderInfo
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]OrderInfo)}
+}
+
+// Get implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Get(key string) (*OrderInfo, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.records[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return &info, true, nil
+}
+
+// Put implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Put(key string, info *OrderInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = *info
+	return nil
+}
+
+// FileIdempotencyStore keeps idempotency keys in a JSON file so replays are detected
+// across restarts. Every Put rewrites the file atomically (write to a temp file, then rename).
+type FileIdempotencyStore struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]idempotencyRecord
+}
+
+// OpenFileIdempotencyStore loads the store at path, creating an empty one if the file does not exist
+func OpenFileIdempotencyStore(path string) (*FileIdempotencyStore, error) {
+	s := &FileIdempotencyStore{path: path, records: make(map[string]idempotencyRecord)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read idempotency store: %w", err)
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &s.records); err != nil {
+			return nil, fmt.Errorf("invalid idempotency store %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// Get implements IdempotencyStore
+func (s *FileIdempotencyStore) Get(key string) (*OrderInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil, false, nil
+	}
+	info := record.Order
+	return &info, true, nil
+}
+
+// Put implements IdempotencyStore
+func (s *FileIdempotencyStore) Put(key string, info *OrderInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = idempotencyRecord{Order: *info, CreatedAt: time.Now().UTC()}
+
+	content, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency store: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return fmt.Errorf("failed to write idempotency store: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with content by writing a temp file next to it and renaming it
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// keyLock is the lock of one idempotency key and the number of callers holding
+// or waiting for it
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// idempotencyLocks serializes creations that share a key within this process.
+// A key's entry is deleted when its last caller unlocks it.
+var (
+	idempotencyLocksMu sync.Mutex
+	idempotencyLocks   = map[string]*keyLock{}
+)
+
+func lockIdempotencyKey(key string) func() {
+	idempotencyLocksMu.Lock()
+	lock := idempotencyLocks[key]
+	if lock == nil {
+		lock = &keyLock{}
+		idempotencyLocks[key] = lock
+	}
+	lock.refs++
+	idempotencyLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		idempotencyLocksMu.Lock()
+		defer idempotencyLocksMu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(idempotencyLocks, key)
+		}
+	}
+}
+
+// FindOrderByIdempotencyKey looks up an order tagged with IdempotencyTag(key).
+// It returns ok=false when no such order exists. Shopify indexes tags asynchronously,
+// so an order created a few seconds ago may not be found yet.
+func (c *Client) FindOrderByIdempotencyKey(ctx context.Context, key string) (*OrderInfo, bool, error) {
+	const query = `
+		query FindOrderByIdempotencyKey($query: String!) {
+			orders(first: 1, query: $query) {
+				edges {
+					node {
+						id
+						name
+					}
+				}
+			}
+		}`
+
+	data, err := Do[struct {
+		Orders Connection[struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}] `json:"orders"`
+	}](ctx, c, query, map[string]interface{}{
+		"query": NewOrderQuery().Tag(IdempotencyTag(key)).String(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	orders := data.Orders.Items()
+	if len(orders) == 0 || orders[0].ID == "" {
+		return nil, false, nil
+	}
+
+	return &OrderInfo{
+		OrderID:   orders[0].ID,
+		OrderName: orders[0].Name,
+	}, true, nil
+}
+
+// createIdempotent runs create at most once per key. An earlier order is returned
+// (with Replayed set) when the key is in the client's IdempotencyStore or an order
+// carries the key's tag. If create fails, Shopify is checked once more in case the
+// order was created but the response was lost.
+func (c *Client) createIdempotent(ctx context.Context, key string, create func(ctx context.Context) (*OrderInfo, error)) (*OrderInfo, error) {
+	if key == "" {
+		return create(ctx)
+	}
+
+	unlock := lockIdempotencyKey(key)
+	defer unlock()
+
+	store := c.IdempotencyStore
+	if store != nil {
+		info, ok, err := store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("idempotency store lookup for %s: %w", key, err)
+		}
+		if ok {
+			info.Replayed = true
+			return info, nil
+		}
+	}
+
+	if info, ok, err := c.FindOrderByIdempotencyKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("idempotency lookup for %s: %w", key, err)
+	} else if ok {
+		info.Replayed = true
+		if store != nil {
+			if err := store.Put(key, info); err != nil {
+				return nil, fmt.Errorf("idempotency store update for %s: %w", key, err)
+			}
+		}
+		return info, nil
+	}
+
+	info, createErr := create(ctx)
+	if createErr != nil {
+		if IsUserError(createErr) {
+			return nil, createErr
+		}
+		found, ok, err := c.FindOrderByIdempotencyKey(ctx, key)
+		if err != nil || !ok {
+			return nil, createErr
+		}
+		info = found
+	}
+
+	if store != nil {
+		if err := store.Put(key, info); err != nil {
+			return info, fmt.Errorf("order %s created but idempotency key %s not recorded: %w", info.OrderName, key, err)
+		}
+	}
+	return info, nil
+}
+
+// withIdempotencyTag returns tags with the tag for key added once
+func withIdempotencyTag(tags []string, key string) []string {
+	tag := IdempotencyTag(key)
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(append([]string{}, tags...), tag)
+}
+
+// CreateOrderGraphQLIdempotent creates an order with orderCreate unless one was already
+// created for key (see IdempotencyKeyFor). The order is tagged with IdempotencyTag(key)
+// so the key is found again even without a local store. An empty key disables the check.
+func (c *Client) CreateOrderGraphQLIdempotent(ctx context.Context, key string, input OrderInput) (*OrderInfo, error) {
+	if key != "" {
+		input.Tags = withIdempotencyTag(input.Tags, key)
+	}
+	return c.createIdempotent(ctx, key, func(ctx context.Context) (*OrderInfo, error) {
+		resp, err := c.CreateOrderGraphQL(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return &OrderInfo{
+			OrderID:   resp.Data.OrderCreate.Order.ID,
+			OrderName: resp.Data.OrderCreate.Order.Name,
+		}, nil
+	})
+}
+
+// CreateOrderFromDraftIdempotent is CreateOrderFromDraft with the same replay protection
+// as CreateOrderGraphQLIdempotent. Draft order tags are copied to the completed order.
+func (c *Client) CreateOrderFromDraftIdempotent(ctx context.Context, key string, input DraftOrderInput, paymentPending bool) (*OrderInfo, error) {
+	if key != "" {
+		input.Tags = withIdempotencyTag(input.Tags, key)
+	}
+	return c.createIdempotent(ctx, key, func(ctx context.Context) (*OrderInfo, error) {
+		return c.CreateOrderFromDraft(ctx, input, paymentPending)
+	})
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIdempotencyTag(t *testing.T) {
+	uuid := "0b4e7f2a-5c1d-4e8b-9a3f-6d2c1b0a9e8f"
+	long := "cart-" + strings.Repeat("x", 60)
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"cart-1", "pos:cart-1"},
+		{uuid, "pos:" + uuid},
+		{long, "pos:"},
+	}
+	for _, tt := range tests {
+		got := IdempotencyTag(tt.key)
+		if len(got) > maxTagLength {
+			t.Errorf("IdempotencyTag(%q) = %q, longer than %d characters", tt.key, got, maxTagLength)
+		}
+		if !strings.HasPrefix(got, tt.want) || (len(tt.key) <= 36 && got != tt.want) {
+			t.Errorf("IdempotencyTag(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	// Long keys sharing the first 36 characters still get different tags
+	if IdempotencyTag(long+"a") == IdempotencyTag(long+"b") {
+		t.Error("two long keys have the same tag")
+	}
+	if IdempotencyTag(long) != IdempotencyTag(long) {
+		t.Error("the tag of a long key is not stable")
+	}
+}
+
+func TestLockIdempotencyKeyReleasesEntries(t *testing.T) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockIdempotencyKey("cart-lock")
+			mu.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			mu.Unlock()
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if maxHolders != 1 {
+		t.Errorf("%d callers held the key at once, want 1", maxHolders)
+	}
+
+	idempotencyLocksMu.Lock()
+	defer idempotencyLocksMu.Unlock()
+	if len(idempotencyLocks) != 0 {
+		t.Errorf("%d key locks left after unlocking, want 0", len(idempotencyLocks))
+	}
+}
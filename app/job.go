@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultJobPollInterval is how often WaitJob polls when no interval is given
+const DefaultJobPollInterval = time.Second
+
+// Job is an asynchronous job started by a mutation such as orderCancel
+type Job struct {
+	ID   string `json:"id"`
+	Done bool   `json:"done"`
+}
+
+// WaitJob polls a job until it is done. interval zero means DefaultJobPollInterval;
+// use a context deadline to bound the wait.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) error {
+	const query = `
+		query GetJob($id: ID!) {
+			job(id: $id) {
+				id
+				done
+			}
+		}`
+
+	if interval <= 0 {
+		interval = DefaultJobPollInterval
+	}
+
+	for {
+		var job Job
+		if err := c.queryPath(ctx, query, map[string]interface{}{"id": id}, []string{"job"}, &job); err != nil {
+			if IsNotFound(err) {
+				return fmt.Errorf("job %s: %w", id, ErrNotFound)
+			}
+			return err
+		}
+		if job.Done {
+			return nil
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
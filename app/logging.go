@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	envLoggerOnce sync.Once
+	envLogger     *slog.Logger
+)
+
+// DefaultLogger returns the logger used by clients without WithLogger.
+// SHOPIFY_LOG_LEVEL (debug, info, warn, error) and SHOPIFY_LOG_FORMAT (text or json)
+// configure a stderr logger; when neither is set it is slog.Default().
+// Every API call is logged at debug level, failures and userErrors at warn or error.
+func DefaultLogger() *slog.Logger {
+	level := strings.TrimSpace(os.Getenv("SHOPIFY_LOG_LEVEL"))
+	format := strings.TrimSpace(os.Getenv("SHOPIFY_LOG_FORMAT"))
+	if level == "" && format == "" {
+		return slog.Default()
+	}
+
+	envLoggerOnce.Do(func() {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			lvl = slog.LevelInfo
+		}
+		opts := &slog.HandlerOptions{Level: lvl}
+		if strings.EqualFold(format, "json") {
+			envLogger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+		} else {
+			envLogger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+		}
+	})
+	return envLogger
+}
+
+func (c *Client) logger() *slog.Logger {
+	logger := c.Logger
+	if logger == nil {
+		logger = DefaultLogger()
+	}
+	return logger.With("shop", c.ShopDomain)
+}
+
+// redactedKeys are variable and payload fields whose values are not logged:
+// credentials and customer personal data
+var redactedKeys = map[string]bool{
+	"accesstoken": true,
+	"token":       true,
+	"password":    true,
+	"secret":      true,
+	"email":       true,
+	"phone":       true,
+	"firstname":   true,
+	"first_name":  true,
+	"lastname":    true,
+	"last_name":   true,
+	"address1":    true,
+	"address2":    true,
+	"zip":         true,
+	"note":        true,
+}
+
+// redactValue returns a copy of a decoded JSON value with redactedKeys replaced
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			if redactedKeys[strings.ToLower(key)] && field != nil && field != "" {
+				out[key] = "[REDACTED]"
+				continue
+			}
+			out[key] = redactValue(field)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// redactJSON redacts a JSON document for logging; invalid JSON is dropped
+func redactJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal(data, &v) != nil {
+		return nil
+	}
+	return redactValue(v)
+}
+
+// apiCall describes one Admin API call (all attempts) for logging and the audit journal
+type apiCall struct {
+	kind      string // "graphql" or "rest"
+	operation string
+	query     string // GraphQL document
+	method    string // REST method
+	path      string // REST path
+	request   []byte // GraphQL variables or REST payload as JSON
+	start     time.Time
+	attempts  int
+	status    int
+	cost      *QueryCost
+	response  []byte
+	err       error
+	span      trace.Span
+}
+
+func newGraphQLCall(query string, variables map[string]interface{}) *apiCall {
+	request, _ := json.Marshal(variables)
+	return &apiCall{kind: "graphql", operation: operationName(query), query: query, request: request, start: time.Now()}
+}
+
+func newRESTCall(method, path string, payload []byte) *apiCall {
+	return &apiCall{kind: "rest", operation: restOperation(method, path), method: method, path: path, request: payload, start: time.Now()}
+}
+
+// mutation reports whether the call may have changed data in the shop
+func (call *apiCall) mutation() bool {
+	if call.kind == "rest" {
+		return call.method != "GET" && call.method != "HEAD"
+	}
+	return strings.HasPrefix(strings.TrimSpace(call.query), "mutation")
+}
+
+// userErrors returns the userErrors reported by a successful GraphQL call
+func (call *apiCall) userErrors() *UserErrors {
+	if call.kind != "graphql" || call.err != nil || len(call.response) == 0 {
+		return nil
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(call.response, &envelope) != nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	var userErrs *UserErrors
+	if errors.As(rootUserErrors(envelope.Data), &userErrs) {
+		return userErrs
+	}
+	return nil
+}
+
+// observe logs a finished call, records its metrics and span, and records
+// mutations in the audit journal
+func (c *Client) observe(ctx context.Context, call *apiCall) {
+	duration := time.Since(call.start)
+	userErrs := call.userErrors()
+
+	attrs := []slog.Attr{
+		slog.String("operation", call.operation),
+		slog.String("kind", call.kind),
+		slog.Int("status", call.status),
+		slog.Duration("duration", duration),
+		slog.Int("attempts", call.attempts),
+	}
+	if call.kind == "rest" {
+		attrs = append(attrs, slog.String("method", call.method), slog.String("path", call.path))
+	}
+	if call.cost != nil {
+		attrs = append(attrs, slog.Group("cost",
+			slog.Float64("requested", call.cost.RequestedQueryCost),
+			slog.Float64("actual", call.cost.ActualQueryCost),
+			slog.Float64("available", call.cost.ThrottleStatus.CurrentlyAvailable),
+		))
+	}
+	if key := IdempotencyKey(ctx); key != "" {
+		attrs = append(attrs, slog.String("idempotency_key", key))
+	}
+	if request := redactJSON(call.request); request != nil {
+		attrs = append(attrs, slog.Any("variables", request))
+	}
+
+	c.observeMetrics(call, duration, userErrs)
+
+	logger := c.logger()
+	switch {
+	case call.err != nil:
+		attrs = append(attrs, slog.String("error", call.err.Error()))
+		logger.LogAttrs(ctx, slog.LevelError, "shopify call failed", attrs...)
+	case userErrs != nil:
+		attrs = append(attrs, slog.String("user_errors", userErrs.Error()))
+		logger.LogAttrs(ctx, slog.LevelWarn, "shopify user errors", attrs...)
+	default:
+		logger.LogAttrs(ctx, slog.LevelDebug, "shopify call", attrs...)
+	}
+
+	if !call.mutation() {
+		return
+	}
+	journal := c.auditJournal()
+	if journal == nil {
+		return
+	}
+	if err := journal.Record(c.auditEntry(ctx, call, duration, userErrs)); err != nil {
+		logger.Warn("cannot write audit journal", "error", err)
+	}
+}
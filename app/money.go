@@ -0,0 +1,252 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// RoundingMode says how Money is rounded to its currency's minor unit
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero: 2.345 -> 2.35, -2.345 -> -2.35
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the even cent (banker's rounding): 2.345 -> 2.34
+	RoundHalfEven
+	// RoundDown truncates toward zero: 2.349 -> 2.34
+	RoundDown
+	// RoundUp rounds away from zero: 2.341 -> 2.35
+	RoundUp
+)
+
+// Money is an exact amount of a currency. Arithmetic never rounds; Round does,
+// to the currency's minor unit. In JSON it is a string amount like Shopify's
+// Money and Decimal scalars ("12.50"), with the currency in a sibling
+// currencyCode field (see MoneyV2). The zero value is 0 in no currency, and an
+// amount without a currency takes the currency of the one it is added to.
+type Money struct {
+	amount   decimal.Decimal
+	currency string
+}
+
+// ErrCurrencyMismatch is returned by CheckCurrency for amounts of two different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// NewMoney returns an amount of a currency, e.g. an ISO 4217 code like "USD"
+func NewMoney(amount decimal.Decimal, currency string) Money {
+	return Money{amount: amount, currency: currency}
+}
+
+// ParseMoney parses an amount such as "12.50" or "-3"
+func ParseMoney(amount, currency string) (Money, error) {
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	return Money{amount: d, currency: currency}, nil
+}
+
+// MustParseMoney is ParseMoney for amounts known to be valid; it panics otherwise
+func MustParseMoney(amount, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Decimal returns the amount
+func (m Money) Decimal() decimal.Decimal { return m.amount }
+
+// Currency returns the currency code, "" when unknown
+func (m Money) Currency() string { return m.currency }
+
+// WithCurrency returns the amount in another currency, without conversion
+func (m Money) WithCurrency(currency string) Money {
+	m.currency = currency
+	return m
+}
+
+// IsZero reports whether the amount is 0, whatever the currency. It makes
+// `json:",omitzero"` leave out zero amounts.
+func (m Money) IsZero() bool { return m.amount.IsZero() }
+
+// IsPositive reports whether the amount is greater than 0
+func (m Money) IsPositive() bool { return m.amount.IsPositive() }
+
+// IsNegative reports whether the amount is less than 0
+func (m Money) IsNegative() bool { return m.amount.IsNegative() }
+
+// CheckCurrency returns an error wrapping ErrCurrencyMismatch when m and other
+// have different currencies. Add, Sub and Cmp panic on such amounts, so check
+// amounts from Shopify or a POS before combining them.
+func (m Money) CheckCurrency(other Money) error {
+	if m.currency != "" && other.currency != "" && m.currency != other.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, other.currency)
+	}
+	return nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than other.
+// Amounts of two different currencies cannot be compared.
+func (m Money) Cmp(other Money) int {
+	m.sameCurrency(other, "compare")
+	return m.amount.Cmp(other.amount)
+}
+
+// Equal reports whether m and other are the same amount
+func (m Money) Equal(other Money) bool { return m.Cmp(other) == 0 }
+
+// Add returns m + other. Amounts of two different currencies cannot be added.
+func (m Money) Add(other Money) Money {
+	return Money{amount: m.amount.Add(other.amount), currency: m.sameCurrency(other, "add")}
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	return Money{amount: m.amount.Sub(other.amount), currency: m.sameCurrency(other, "subtract")}
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{amount: m.amount.Neg(), currency: m.currency}
+}
+
+// Abs returns the absolute value of m
+func (m Money) Abs() Money {
+	return Money{amount: m.amount.Abs(), currency: m.currency}
+}
+
+// Mul returns m * factor, e.g. a tax rate
+func (m Money) Mul(factor decimal.Decimal) Money {
+	return Money{amount: m.amount.Mul(factor), currency: m.currency}
+}
+
+// MulInt returns m * n, e.g. a unit price times a quantity
+func (m Money) MulInt(n int) Money {
+	return m.Mul(decimal.NewFromInt(int64(n)))
+}
+
+// Div returns m / divisor with decimal.DivisionPrecision places; round the
+// result to get an amount of the currency
+func (m Money) Div(divisor decimal.Decimal) Money {
+	return Money{amount: m.amount.Div(divisor), currency: m.currency}
+}
+
+// Percent returns percent % of m, e.g. Percent(decimal.NewFromInt(15)) for 15%
+func (m Money) Percent(percent decimal.Decimal) Money {
+	return Money{amount: m.amount.Mul(percent).Div(decimal.NewFromInt(100)), currency: m.currency}
+}
+
+// Round rounds m to the currency's minor unit, e.g. cents
+func (m Money) Round(mode RoundingMode) Money {
+	return m.RoundTo(m.Places(), mode)
+}
+
+// RoundTo rounds m to a number of decimal places
+func (m Money) RoundTo(places int32, mode RoundingMode) Money {
+	var d decimal.Decimal
+	switch mode {
+	case RoundHalfEven:
+		d = m.amount.RoundBank(places)
+	case RoundDown:
+		d = m.amount.Truncate(places)
+	case RoundUp:
+		d = m.amount.Truncate(places)
+		if !d.Equal(m.amount) {
+			d = d.Add(decimal.New(int64(m.amount.Sign()), -places))
+		}
+	default:
+		d = m.amount.Round(places)
+	}
+	return Money{amount: d, currency: m.currency}
+}
+
+// Places returns the number of decimal places of the currency's minor unit:
+// 2 for most currencies, 0 for JPY, 3 for KWD. Unknown currencies have 2.
+func (m Money) Places() int32 {
+	if places, ok := currencyPlaces[m.currency]; ok {
+		return places
+	}
+	return 2
+}
+
+// currencyPlaces lists ISO 4217 currencies Shopify supports whose minor unit
+// is not a hundredth
+var currencyPlaces = map[string]int32{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// String returns the exact amount without trailing zeros, like the amounts
+// Shopify returns: "52.5"
+func (m Money) String() string {
+	return m.amount.String()
+}
+
+// StringFixed returns the amount rounded half up to the currency's minor
+// unit, with all its places: "52.50"
+func (m Money) StringFixed() string {
+	return m.amount.StringFixed(m.Places())
+}
+
+// MarshalJSON writes the amount as a string with at least the currency's
+// places, "12.50". Amounts with more places are written exactly, not rounded.
+func (m Money) MarshalJSON() ([]byte, error) {
+	text := m.amount.String()
+	if m.amount.Exponent() >= -m.Places() {
+		text = m.StringFixed()
+	}
+	return json.Marshal(text)
+}
+
+// UnmarshalJSON reads an amount sent as a string or a number; null and "" are 0.
+// The currency is left as is.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte(`""`)) {
+		m.amount = decimal.Decimal{}
+		return nil
+	}
+	text := string(bytes.Trim(data, `"`))
+	d, err := decimal.NewFromString(text)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	m.amount = d
+	return nil
+}
+
+// sameCurrency returns the currency of an operation on m and other, and
+// panics when they have different ones. Callers check amounts that may be in
+// different currencies with CheckCurrency first.
+func (m Money) sameCurrency(other Money, operation string) string {
+	if err := m.CheckCurrency(other); err != nil {
+		panic(fmt.Sprintf("app: cannot %s amounts: %v", operation, err))
+	}
+	if m.currency == "" {
+		return other.currency
+	}
+	return m.currency
+}
+
+// MinMoney returns the smaller of two amounts
+func MinMoney(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// MaxMoney returns the larger of two amounts
+func MaxMoney(a, b Money) Money {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
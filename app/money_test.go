@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRoundTo(t *testing.T) {
+	tests := []struct {
+		amount string
+		mode   RoundingMode
+		want   string
+	}{
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.344", RoundHalfUp, "2.34"},
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"-2.345", RoundHalfEven, "-2.34"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+		{"2.341", RoundUp, "2.35"},
+		{"-2.341", RoundUp, "-2.35"},
+		{"2.34", RoundUp, "2.34"},
+		{"-2.34", RoundUp, "-2.34"},
+	}
+	for _, tt := range tests {
+		got := MustParseMoney(tt.amount, "USD").RoundTo(2, tt.mode)
+		if got.String() != tt.want {
+			t.Errorf("RoundTo(%s, 2, mode %d) = %s, want %s", tt.amount, tt.mode, got, tt.want)
+		}
+		if got.Currency() != "USD" {
+			t.Errorf("RoundTo(%s) currency = %q, want USD", tt.amount, got.Currency())
+		}
+	}
+}
+
+func TestPlaces(t *testing.T) {
+	tests := []struct {
+		currency    string
+		amount      string
+		wantPlaces  int32
+		wantRounded string
+		wantFixed   string
+	}{
+		{"USD", "1234.565", 2, "1234.57", "1234.57"},
+		{"JPY", "1234.5", 0, "1235", "1235"},
+		{"KWD", "1.2345", 3, "1.235", "1.235"},
+		{"KWD", "1.5", 3, "1.5", "1.500"},
+		{"", "1.005", 2, "1.01", "1.01"},
+		{"XYZ", "1.5", 2, "1.5", "1.50"},
+	}
+	for _, tt := range tests {
+		m := MustParseMoney(tt.amount, tt.currency)
+		if got := m.Places(); got != tt.wantPlaces {
+			t.Errorf("%q Places = %d, want %d", tt.currency, got, tt.wantPlaces)
+		}
+		if got := m.Round(RoundHalfUp).String(); got != tt.wantRounded {
+			t.Errorf("%s %s Round = %s, want %s", tt.amount, tt.currency, got, tt.wantRounded)
+		}
+		if got := m.StringFixed(); got != tt.wantFixed {
+			t.Errorf("%s %s StringFixed = %s, want %s", tt.amount, tt.currency, got, tt.wantFixed)
+		}
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             string
+	}{
+		{"12.5", "USD", `"12.50"`},
+		{"12", "USD", `"12.00"`},
+		{"-3.1", "USD", `"-3.10"`},
+		// Extra places are kept exactly, not rounded to cents
+		{"12.345", "USD", `"12.345"`},
+		{"0.125", "USD", `"0.125"`},
+		{"1000", "JPY", `"1000"`},
+		{"12.5", "JPY", `"12.5"`},
+		{"1.5", "KWD", `"1.500"`},
+		{"1.2345", "KWD", `"1.2345"`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(MustParseMoney(tt.amount, tt.currency))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%s %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"string", `"12.50"`, "12.5", false},
+		{"number", `12.5`, "12.5", false},
+		{"negative number", `-0.01`, "-0.01", false},
+		{"null", `null`, "0", false},
+		{"empty string", `""`, "0", false},
+		{"not a number", `"abc"`, "", true},
+		{"object", `{}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := MustParseMoney("99", "EUR")
+			err := json.Unmarshal([]byte(tt.data), &m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if m.String() != tt.want {
+				t.Errorf("Unmarshal(%s) = %s, want %s", tt.data, m, tt.want)
+			}
+			if m.Currency() != "EUR" {
+				t.Errorf("Unmarshal(%s) currency = %q, want EUR kept", tt.data, m.Currency())
+			}
+		})
+	}
+}
+
+func TestSameCurrency(t *testing.T) {
+	usd := MustParseMoney("1", "USD")
+	eur := MustParseMoney("1", "EUR")
+	none := MustParseMoney("1", "")
+	tests := []struct {
+		name         string
+		a, b         Money
+		wantCurrency string
+		wantErr      bool
+	}{
+		{"same currency", usd, usd, "USD", false},
+		{"no currency adopts the other", none, usd, "USD", false},
+		{"other without currency", usd, none, "USD", false},
+		{"neither has a currency", none, none, "", false},
+		{"different currencies", usd, eur, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.a.CheckCurrency(tt.b)
+			if tt.wantErr != errors.Is(err, ErrCurrencyMismatch) {
+				t.Fatalf("CheckCurrency = %v, want ErrCurrencyMismatch %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				for name, op := range map[string]func(){
+					"Add": func() { tt.a.Add(tt.b) },
+					"Sub": func() { tt.a.Sub(tt.b) },
+					"Cmp": func() { tt.a.Cmp(tt.b) },
+				} {
+					if !panics(op) {
+						t.Errorf("%s of USD and EUR did not panic", name)
+					}
+				}
+				return
+			}
+			if got := tt.a.Add(tt.b); got.Currency() != tt.wantCurrency || got.String() != "2" {
+				t.Errorf("Add = %s %q, want 2 %q", got, got.Currency(), tt.wantCurrency)
+			}
+			if got := tt.a.Sub(tt.b); got.Currency() != tt.wantCurrency || !got.IsZero() {
+				t.Errorf("Sub = %s %q, want 0 %q", got, got.Currency(), tt.wantCurrency)
+			}
+			if !tt.a.Equal(tt.b) {
+				t.Errorf("%v and %v are not equal", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func panics(f func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	f()
+	return false
+}
@@ -0,0 +1,670 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrderSection selects an optional part of the order fetched by GetOrder.
+// The order's own fields, totals and order-level tax lines are always fetched.
+type OrderSection uint
+
+const (
+	// OrderSectionLineItems fetches line items with their discount allocations and tax lines
+	OrderSectionLineItems OrderSection = 1 << iota
+	// OrderSectionShippingLines fetches shipping lines with their tax lines
+	OrderSectionShippingLines
+	// OrderSectionTransactions fetches payment transactions
+	OrderSectionTransactions
+	// OrderSectionFulfillments fetches fulfillments with their tracking information and line items
+	OrderSectionFulfillments
+	// OrderSectionFulfillmentOrders fetches fulfillment orders with their line items
+	OrderSectionFulfillmentOrders
+	// OrderSectionMetafields fetches metafields, see WithOrderMetafieldNamespace
+	OrderSectionMetafields
+	// OrderSectionCustomer fetches the customer and their default address
+	OrderSectionCustomer
+	// OrderSectionAddresses fetches the shipping and billing addresses
+	OrderSectionAddresses
+
+	// OrderSectionsAll fetches every section; it is the default of GetOrder
+	OrderSectionsAll = OrderSectionLineItems | OrderSectionShippingLines | OrderSectionTransactions |
+		OrderSectionFulfillments | OrderSectionFulfillmentOrders | OrderSectionMetafields |
+		OrderSectionCustomer | OrderSectionAddresses
+)
+
+// OrderOption configures GetOrder
+type OrderOption func(*orderOptions)
+
+type orderOptions struct {
+	sections           OrderSection
+	metafieldNamespace string
+}
+
+// WithOrderSections fetches only the given sections instead of all of them
+func WithOrderSections(sections ...OrderSection) OrderOption {
+	return func(o *orderOptions) {
+		o.sections = 0
+		for _, section := range sections {
+			o.sections |= section
+		}
+	}
+}
+
+// WithOrderMetafieldNamespace fetches only the metafields of one namespace, e.g. "connectpos"
+func WithOrderMetafieldNamespace(namespace string) OrderOption {
+	return func(o *orderOptions) {
+		o.metafieldNamespace = namespace
+	}
+}
+
+// Order is an order as returned by GetOrder. Slices of sections that were not
+// requested are nil.
+type Order struct {
+	ID                       string     `json:"id"`
+	LegacyResourceID         string     `json:"legacyResourceId"`
+	Name                     string     `json:"name"`
+	Email                    string     `json:"email"`
+	Phone                    string     `json:"phone"`
+	Note                     string     `json:"note"`
+	Tags                     []string   `json:"tags"`
+	SourceName               string     `json:"sourceName"`
+	CreatedAt                time.Time  `json:"createdAt"`
+	ProcessedAt              time.Time  `json:"processedAt"`
+	CancelledAt              *time.Time `json:"cancelledAt"`
+	CancelReason             string     `json:"cancelReason"`
+	Closed                   bool       `json:"closed"`
+	CurrencyCode             string     `json:"currencyCode"`
+	DisplayFinancialStatus   string     `json:"displayFinancialStatus"`
+	DisplayFulfillmentStatus string     `json:"displayFulfillmentStatus"`
+	TaxesIncluded            bool       `json:"taxesIncluded"`
+
+	SubtotalPriceSet      MoneyBag  `json:"subtotalPriceSet"`
+	TotalShippingPriceSet MoneyBag  `json:"totalShippingPriceSet"`
+	TotalDiscountsSet     MoneyBag  `json:"totalDiscountsSet"`
+	TotalTaxSet           MoneyBag  `json:"totalTaxSet"`
+	TotalPriceSet         MoneyBag  `json:"totalPriceSet"`
+	CurrentTotalPriceSet  MoneyBag  `json:"currentTotalPriceSet"`
+	TotalOutstandingSet   MoneyBag  `json:"totalOutstandingSet"`
+	TaxLines              []TaxLine `json:"taxLines"`
+
+	LineItems         []OrderLineItem        `json:"lineItems"`
+	ShippingLines     []OrderShippingLine    `json:"shippingLines"`
+	Transactions      []OrderTransaction     `json:"transactions"`
+	Fulfillments      []Fulfillment          `json:"fulfillments"`
+	FulfillmentOrders []FulfillmentOrderInfo `json:"fulfillmentOrders"`
+	Metafields        []Metafield            `json:"metafields"`
+	Customer          *OrderCustomer         `json:"customer"`
+	ShippingAddress   *MailingAddress        `json:"shippingAddress"`
+	BillingAddress    *MailingAddress        `json:"billingAddress"`
+}
+
+// Metafield returns the value of the metafield namespace.key, or "" when the order has none
+func (o *Order) Metafield(namespace, key string) string {
+	for _, m := range o.Metafields {
+		if m.Namespace == namespace && m.Key == key {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// OrderLineItem is a line item of an order
+type OrderLineItem struct {
+	ID              string `json:"id"`
+	Title           string `json:"title"`
+	SKU             string `json:"sku"`
+	Quantity        int    `json:"quantity"`
+	CurrentQuantity int    `json:"currentQuantity"`
+	Taxable         bool   `json:"taxable"`
+	Variant         *struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"variant"`
+	OriginalUnitPriceSet   MoneyBag             `json:"originalUnitPriceSet"`
+	DiscountedUnitPriceSet MoneyBag             `json:"discountedUnitPriceSet"`
+	DiscountAllocations    []DiscountAllocation `json:"discountAllocations"`
+	TaxLines               []TaxLine            `json:"taxLines"`
+}
+
+// DiscountAllocation is the part of a discount allocated to a line item
+type DiscountAllocation struct {
+	AllocatedAmountSet  MoneyBag            `json:"allocatedAmountSet"`
+	DiscountApplication DiscountApplication `json:"discountApplication"`
+}
+
+// DiscountApplication describes the discount behind a DiscountAllocation.
+// Type is the GraphQL type: DiscountCodeApplication, ManualDiscountApplication,
+// AutomaticDiscountApplication or ScriptDiscountApplication.
+type DiscountApplication struct {
+	Type  string `json:"__typename"`
+	Title string `json:"title"`
+	Code  string `json:"code"`
+	// Description of a manual discount, e.g. one added by an order edit
+	Description      string `json:"description"`
+	AllocationMethod string `json:"allocationMethod"`
+	TargetSelection  string `json:"targetSelection"`
+	TargetType       string `json:"targetType"`
+	// Value holds either Amount and CurrencyCode or Percentage
+	Value struct {
+		Amount       Money   `json:"amount"`
+		CurrencyCode string  `json:"currencyCode"`
+		Percentage   float64 `json:"percentage"`
+	} `json:"value"`
+}
+
+// Name returns the discount code, or the title of discounts without a code
+func (d DiscountApplication) Name() string {
+	if d.Code != "" {
+		return d.Code
+	}
+	return d.Title
+}
+
+// OrderShippingLine is a shipping line of an order
+type OrderShippingLine struct {
+	Title              string    `json:"title"`
+	Code               string    `json:"code"`
+	OriginalPriceSet   MoneyBag  `json:"originalPriceSet"`
+	DiscountedPriceSet MoneyBag  `json:"discountedPriceSet"`
+	TaxLines           []TaxLine `json:"taxLines"`
+}
+
+// OrderTransaction is a payment transaction of an order
+type OrderTransaction struct {
+	ID                string    `json:"id"`
+	Kind              string    `json:"kind"`
+	Status            string    `json:"status"`
+	Gateway           string    `json:"gateway"`
+	Test              bool      `json:"test"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ProcessedAt       time.Time `json:"processedAt"`
+	AmountSet         MoneyBag  `json:"amountSet"`
+	ParentTransaction *struct {
+		ID string `json:"id"`
+	} `json:"parentTransaction"`
+}
+
+// Fulfillment is a fulfillment of an order
+type Fulfillment struct {
+	ID           string    `json:"id"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"createdAt"`
+	TrackingInfo []struct {
+		Number  string `json:"number"`
+		Company string `json:"company"`
+		URL     string `json:"url"`
+	} `json:"trackingInfo"`
+	FulfillmentLineItems []FulfillmentLineItem `json:"fulfillmentLineItems"`
+}
+
+// FulfillmentLineItem is a quantity of a line item shipped by a fulfillment
+type FulfillmentLineItem struct {
+	ID         string `json:"id"`
+	LineItemID string `json:"lineItemId"`
+	Quantity   int    `json:"quantity"`
+}
+
+// fulfillmentLineItemNode is a FulfillmentLineItem as selected by fulfillmentLineItemFields
+type fulfillmentLineItemNode struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+	LineItem struct {
+		ID string `json:"id"`
+	} `json:"lineItem"`
+}
+
+// fulfillmentNode is a Fulfillment as selected by fulfillmentFields
+type fulfillmentNode struct {
+	Fulfillment
+	FulfillmentLineItems Connection[fulfillmentLineItemNode] `json:"fulfillmentLineItems"`
+}
+
+// fulfillment returns the fulfillment of node with the line items past the first page
+func (c *Client) fulfillment(ctx context.Context, node fulfillmentNode) (Fulfillment, error) {
+	items, err := allPages(ctx, c, node.FulfillmentLineItems, fulfillmentLineItemsPage, fulfillmentLineItemsQuery,
+		map[string]interface{}{"id": node.ID}, "node", "fulfillmentLineItems")
+	if err != nil {
+		return Fulfillment{}, fmt.Errorf("failed to get line items of fulfillment %s: %w", node.ID, err)
+	}
+
+	fulfillment := node.Fulfillment
+	fulfillment.FulfillmentLineItems = []FulfillmentLineItem{}
+	for _, item := range items {
+		fulfillment.FulfillmentLineItems = append(fulfillment.FulfillmentLineItems, FulfillmentLineItem{
+			ID:         item.ID,
+			LineItemID: item.LineItem.ID,
+			Quantity:   item.Quantity,
+		})
+	}
+	return fulfillment, nil
+}
+
+// Metafield is a metafield of an order
+type Metafield struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+}
+
+// OrderCustomer is the customer of an order
+type OrderCustomer struct {
+	ID             string          `json:"id"`
+	FirstName      string          `json:"firstName"`
+	LastName       string          `json:"lastName"`
+	DisplayName    string          `json:"displayName"`
+	Email          string          `json:"email"`
+	Phone          string          `json:"phone"`
+	DefaultAddress *MailingAddress `json:"defaultAddress"`
+}
+
+// MailingAddress is a shipping, billing or customer address
+type MailingAddress struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Name      string `json:"name"`
+	Company   string `json:"company"`
+	Address1  string `json:"address1"`
+	Address2  string `json:"address2"`
+	City      string `json:"city"`
+	Province  string `json:"province"`
+	Country   string `json:"country"`
+	Zip       string `json:"zip"`
+	Phone     string `json:"phone"`
+}
+
+// orderNode is an order as returned by GetOrderQuery, with the first page of its connections.
+// Its connection fields shadow the slices of the embedded Order when decoding.
+type orderNode struct {
+	Order
+	LineItems         Connection[OrderLineItem]        `json:"lineItems"`
+	ShippingLines     Connection[OrderShippingLine]    `json:"shippingLines"`
+	Transactions      []OrderTransaction               `json:"transactions"`
+	Fulfillments      []fulfillmentNode                `json:"fulfillments"`
+	FulfillmentOrders Connection[fulfillmentOrderNode] `json:"fulfillmentOrders"`
+	Metafields        Connection[Metafield]            `json:"metafields"`
+}
+
+// Selections shared by GetOrderQuery and the queries of later connection pages
+const (
+	moneyBagFields = `shopMoney { amount currencyCode }`
+
+	taxLineFields = `title rate ratePercentage priceSet { ` + moneyBagFields + ` }`
+
+	mailingAddressFields = `firstName lastName name company address1 address2 city province country zip phone`
+
+	orderLineItemFields = `
+		id
+		title
+		sku
+		quantity
+		currentQuantity
+		taxable
+		variant { id title }
+		originalUnitPriceSet { ` + moneyBagFields + ` }
+		discountedUnitPriceSet { ` + moneyBagFields + ` }
+		discountAllocations {
+			allocatedAmountSet { ` + moneyBagFields + ` }
+			discountApplication {
+				__typename
+				allocationMethod
+				targetSelection
+				targetType
+				value {
+					... on MoneyV2 { amount currencyCode }
+					... on PricingPercentageValue { percentage }
+				}
+				... on DiscountCodeApplication { code }
+				... on ManualDiscountApplication { title description }
+				... on AutomaticDiscountApplication { title }
+				... on ScriptDiscountApplication { title }
+			}
+		}
+		taxLines { ` + taxLineFields + ` }`
+
+	orderShippingLineFields = `
+		title
+		code
+		originalPriceSet { ` + moneyBagFields + ` }
+		discountedPriceSet { ` + moneyBagFields + ` }
+		taxLines { ` + taxLineFields + ` }`
+
+	orderFulfillmentOrderFields = `
+		id
+		status
+		requestStatus
+		assignedLocation { location { id } }
+		lineItems(first: 10) {
+			nodes {
+				id
+				remainingQuantity
+				totalQuantity
+				lineItem { id }
+			}
+			pageInfo { hasNextPage endCursor }
+		}`
+
+	fulfillmentLineItemFields = `id quantity lineItem { id }`
+
+	fulfillmentFields = `
+		id
+		status
+		createdAt
+		trackingInfo { number company url }
+		fulfillmentLineItems(first: 5) {
+			nodes { ` + fulfillmentLineItemFields + ` }
+			pageInfo { hasNextPage endCursor }
+		}`
+
+	metafieldFields = `id namespace key type value`
+
+	orderTransactionFields = `
+		id
+		kind
+		status
+		gateway
+		test
+		createdAt
+		processedAt
+		amountSet { ` + moneyBagFields + ` }
+		parentTransaction { id }`
+
+	// orderFields are the fields of an order that do not belong to a section
+	orderFields = `
+		id
+		legacyResourceId
+		name
+		email
+		phone
+		note
+		tags
+		sourceName
+		createdAt
+		processedAt
+		cancelledAt
+		cancelReason
+		closed
+		currencyCode
+		displayFinancialStatus
+		displayFulfillmentStatus
+		taxesIncluded
+		subtotalPriceSet { ` + moneyBagFields + ` }
+		totalShippingPriceSet { ` + moneyBagFields + ` }
+		totalDiscountsSet { ` + moneyBagFields + ` }
+		totalTaxSet { ` + moneyBagFields + ` }
+		totalPriceSet { ` + moneyBagFields + ` }
+		currentTotalPriceSet { ` + moneyBagFields + ` }
+		totalOutstandingSet { ` + moneyBagFields + ` }
+		taxLines { ` + taxLineFields + ` }`
+)
+
+// Sizes of the pages of an order's connections. A query may request at most
+// 1000 points, so GetOrderQuery fetches small first pages, which keep an order
+// with every section at about 600 points, and GetOrder fetches the rest in pages
+// of these sizes. The lists of transactions and fulfillments cannot be paged:
+// when their first page is full, GetOrder fetches them again up to the listed size.
+const (
+	orderLineItemsPage         = 50  // 14 points a line item
+	orderShippingLinesPage     = 100 // 8 points a shipping line
+	orderFulfillmentOrdersPage = 25  // 25 points a fulfillment order with its first line items
+	orderMetafieldsPage        = 250 // 1 point a metafield
+	fulfillmentLineItemsPage   = 250 // 2 points a fulfillment line item
+
+	orderTransactionsFirst = 20 // 4 points a transaction, up to 100 with ListTransactions
+	orderFulfillmentsFirst = 10 // 14 points a fulfillment with its first line items, up to 50
+)
+
+// GetOrderQuery fetches an order; each optional section is included when its Boolean variable is true
+const GetOrderQuery = `
+	query GetOrder($id: ID!, $lineItems: Boolean!, $shippingLines: Boolean!, $transactions: Boolean!,
+		$fulfillments: Boolean!, $fulfillmentOrders: Boolean!, $metafields: Boolean!, $metafieldNamespace: String,
+		$customer: Boolean!, $addresses: Boolean!) {
+		order(id: $id) {
+			` + orderFields + `
+			lineItems(first: 10) @include(if: $lineItems) {
+				nodes { ` + orderLineItemFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+			shippingLines(first: 5) @include(if: $shippingLines) {
+				nodes { ` + orderShippingLineFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+			transactions(first: 20) @include(if: $transactions) { ` + orderTransactionFields + ` }
+			fulfillments(first: 10) @include(if: $fulfillments) { ` + fulfillmentFields + ` }
+			fulfillmentOrders(first: 5) @include(if: $fulfillmentOrders) {
+				nodes { ` + orderFulfillmentOrderFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+			metafields(first: 20, namespace: $metafieldNamespace) @include(if: $metafields) {
+				nodes { ` + metafieldFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+			customer @include(if: $customer) {
+				id
+				firstName
+				lastName
+				displayName
+				email
+				phone
+				defaultAddress { ` + mailingAddressFields + ` }
+			}
+			shippingAddress @include(if: $addresses) { ` + mailingAddressFields + ` }
+			billingAddress @include(if: $addresses) { ` + mailingAddressFields + ` }
+		}
+	}`
+
+// Queries for the pages after the first of an order's connections
+const (
+	orderLineItemsQuery = `
+		query GetOrderLineItems($id: ID!, $first: Int!, $after: String) {
+			order(id: $id) {
+				lineItems(first: $first, after: $after) {
+					nodes { ` + orderLineItemFields + ` }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`
+
+	orderShippingLinesQuery = `
+		query GetOrderShippingLines($id: ID!, $first: Int!, $after: String) {
+			order(id: $id) {
+				shippingLines(first: $first, after: $after) {
+					nodes { ` + orderShippingLineFields + ` }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`
+
+	orderFulfillmentOrdersQuery = `
+		query GetOrderFulfillmentOrders($id: ID!, $first: Int!, $after: String) {
+			order(id: $id) {
+				fulfillmentOrders(first: $first, after: $after) {
+					nodes { ` + orderFulfillmentOrderFields + ` }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`
+
+	orderMetafieldsQuery = `
+		query GetOrderMetafields($id: ID!, $first: Int!, $after: String, $namespace: String) {
+			order(id: $id) {
+				metafields(first: $first, after: $after, namespace: $namespace) {
+					nodes { ` + metafieldFields + ` }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`
+
+	orderFulfillmentsQuery = `
+		query GetOrderFulfillments($id: ID!) {
+			order(id: $id) {
+				fulfillments(first: 50) { ` + fulfillmentFields + ` }
+			}
+		}`
+
+	fulfillmentLineItemsQuery = `
+		query GetFulfillmentLineItems($id: ID!, $first: Int!, $after: String) {
+			node(id: $id) {
+				... on Fulfillment {
+					fulfillmentLineItems(first: $first, after: $after) {
+						nodes { ` + fulfillmentLineItemFields + ` }
+						pageInfo { hasNextPage endCursor }
+					}
+				}
+			}
+		}`
+)
+
+var numericID = regexp.MustCompile(`^\d+$`)
+
+// OrderGID returns the GID of an order given its GID or numeric ID
+func OrderGID(id string) string {
+	return resourceGID("Order", id)
+}
+
+// resourceGID returns the GID of a resource of the type given its GID or numeric ID
+func resourceGID(typeName, id string) string {
+	if numericID.MatchString(id) {
+		return "gid://shopify/" + typeName + "/" + id
+	}
+	return id
+}
+
+// GetOrder fetches an order by GID or numeric ID, with every section unless
+// WithOrderSections selects some. Connections with more than one page are
+// fetched completely. A missing order is reported as an error wrapping ErrNotFound.
+func (c *Client) GetOrder(ctx context.Context, id string, opts ...OrderOption) (_ *Order, err error) {
+	options := orderOptions{sections: OrderSectionsAll}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	id = OrderGID(id)
+
+	ctx, span := c.startSpan(ctx, "GetOrder", attribute.String("shopify.order_id", id))
+	defer func() { endSpan(span, err) }()
+
+	has := func(section OrderSection) bool { return options.sections&section != 0 }
+	variables := map[string]interface{}{
+		"id":                 id,
+		"lineItems":          has(OrderSectionLineItems),
+		"shippingLines":      has(OrderSectionShippingLines),
+		"transactions":       has(OrderSectionTransactions),
+		"fulfillments":       has(OrderSectionFulfillments),
+		"fulfillmentOrders":  has(OrderSectionFulfillmentOrders),
+		"metafields":         has(OrderSectionMetafields),
+		"metafieldNamespace": nil,
+		"customer":           has(OrderSectionCustomer),
+		"addresses":          has(OrderSectionAddresses),
+	}
+	if options.metafieldNamespace != "" {
+		variables["metafieldNamespace"] = options.metafieldNamespace
+	}
+
+	var node orderNode
+	if err := c.queryPath(ctx, GetOrderQuery, variables, []string{"order"}, &node); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("order %s: %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+
+	order := node.Order
+	if has(OrderSectionLineItems) {
+		if order.LineItems, err = allPages(ctx, c, node.LineItems, orderLineItemsPage, orderLineItemsQuery, map[string]interface{}{"id": id}, "order", "lineItems"); err != nil {
+			return nil, fmt.Errorf("failed to get line items of %s: %w", id, err)
+		}
+	}
+	if has(OrderSectionShippingLines) {
+		if order.ShippingLines, err = allPages(ctx, c, node.ShippingLines, orderShippingLinesPage, orderShippingLinesQuery, map[string]interface{}{"id": id}, "order", "shippingLines"); err != nil {
+			return nil, fmt.Errorf("failed to get shipping lines of %s: %w", id, err)
+		}
+	}
+	if has(OrderSectionFulfillmentOrders) {
+		nodes, err := allPages(ctx, c, node.FulfillmentOrders, orderFulfillmentOrdersPage, orderFulfillmentOrdersQuery, map[string]interface{}{"id": id}, "order", "fulfillmentOrders")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get fulfillment orders of %s: %w", id, err)
+		}
+		order.FulfillmentOrders = []FulfillmentOrderInfo{}
+		for _, foNode := range nodes {
+			fo, err := c.fulfillmentOrderInfo(ctx, foNode)
+			if err != nil {
+				return nil, err
+			}
+			order.FulfillmentOrders = append(order.FulfillmentOrders, fo)
+		}
+	}
+	if has(OrderSectionMetafields) {
+		metafields, err := allPages(ctx, c, node.Metafields, orderMetafieldsPage, orderMetafieldsQuery, map[string]interface{}{
+			"id":        id,
+			"namespace": variables["metafieldNamespace"],
+		}, "order", "metafields")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metafields of %s: %w", id, err)
+		}
+		order.Metafields = []Metafield{}
+		for _, m := range metafields {
+			if options.metafieldNamespace == "" || m.Namespace == options.metafieldNamespace {
+				order.Metafields = append(order.Metafields, m)
+			}
+		}
+	}
+	if has(OrderSectionTransactions) {
+		order.Transactions = node.Transactions
+		if len(order.Transactions) == orderTransactionsFirst {
+			if order.Transactions, err = c.ListTransactions(ctx, id); err != nil {
+				return nil, fmt.Errorf("failed to get transactions of %s: %w", id, err)
+			}
+		}
+		if order.Transactions == nil {
+			order.Transactions = []OrderTransaction{}
+		}
+	}
+	if has(OrderSectionFulfillments) {
+		fulfillments := node.Fulfillments
+		if len(fulfillments) == orderFulfillmentsFirst {
+			var all struct {
+				Fulfillments []fulfillmentNode `json:"fulfillments"`
+			}
+			if err := c.queryPath(ctx, orderFulfillmentsQuery, map[string]interface{}{"id": id}, []string{"order"}, &all); err != nil {
+				return nil, fmt.Errorf("failed to get fulfillments of %s: %w", id, err)
+			}
+			fulfillments = all.Fulfillments
+		}
+		order.Fulfillments = []Fulfillment{}
+		for _, f := range fulfillments {
+			fulfillment, err := c.fulfillment(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			order.Fulfillments = append(order.Fulfillments, fulfillment)
+		}
+	}
+
+	span.SetAttributes(attribute.String("shopify.order_name", order.Name))
+	return &order, nil
+}
+
+// allPages returns the items of the first page of a connection followed by those of
+// its later pages, which query fetches size items at a time with variables and the
+// connection at path
+func allPages[T any](ctx context.Context, c *Client, first Connection[T], size int, query string, variables map[string]interface{}, path ...string) ([]T, error) {
+	items := first.Items()
+	if !first.PageInfo.HasNextPage {
+		return items, nil
+	}
+	vars := map[string]interface{}{"first": size, "after": first.PageInfo.EndCursor}
+	for k, v := range variables {
+		vars[k] = v
+	}
+	rest, err := CollectAll(Paginate[T](ctx, c, query, vars, path...))
+	if err != nil {
+		return nil, err
+	}
+	return append(items, rest...), nil
+}
@@ -0,0 +1,361 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Order cancellation reasons (OrderCancelReason)
+const (
+	CancelReasonCustomer  = "CUSTOMER"
+	CancelReasonDeclined  = "DECLINED"
+	CancelReasonFraud     = "FRAUD"
+	CancelReasonInventory = "INVENTORY"
+	CancelReasonStaff     = "STAFF"
+	CancelReasonOther     = "OTHER"
+)
+
+// RefundMethod says how a cancelled order's payments are returned
+type RefundMethod int
+
+const (
+	// RefundNone keeps the payments; uncaptured authorizations are still voided
+	RefundNone RefundMethod = iota
+	// RefundOriginalPayment refunds to the payment methods the customer used
+	RefundOriginalPayment
+	// RefundStoreCredit refunds as store credit on the customer's account
+	RefundStoreCredit
+)
+
+// CancelOptions configures CancelOrder. The zero value cancels with reason
+// OTHER without restocking, refunding or notifying the customer.
+type CancelOptions struct {
+	// One of the CancelReason constants. Empty means CancelReasonOther.
+	Reason string
+	// Return the unfulfilled items to inventory
+	Restock bool
+	Refund  RefundMethod
+	// Expiry of the store credit issued with RefundStoreCredit. Nil means it does not expire.
+	StoreCreditExpiresAt *time.Time
+	NotifyCustomer       bool
+	// Note shown to staff on the order timeline
+	StaffNote string
+	// Interval between job polls. Zero means DefaultJobPollInterval.
+	PollInterval time.Duration
+}
+
+// Refund is a refund of an order
+type Refund struct {
+	ID               string             `json:"id"`
+	Note             string             `json:"note"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	TotalRefundedSet MoneyBag           `json:"totalRefundedSet"`
+	Transactions     []OrderTransaction `json:"transactions"`
+	RefundLineItems  []RefundLineItem   `json:"refundLineItems"`
+}
+
+// RefundLineItem is a quantity of a line item covered by a refund
+type RefundLineItem struct {
+	LineItemID  string   `json:"lineItemId"`
+	Quantity    int      `json:"quantity"`
+	RestockType string   `json:"restockType"`
+	SubtotalSet MoneyBag `json:"subtotalSet"`
+	TotalTaxSet MoneyBag `json:"totalTaxSet"`
+}
+
+// refundLineItemNode is a RefundLineItem as selected by refundLineItemFields
+type refundLineItemNode struct {
+	LineItem struct {
+		ID string `json:"id"`
+	} `json:"lineItem"`
+	Quantity    int      `json:"quantity"`
+	RestockType string   `json:"restockType"`
+	SubtotalSet MoneyBag `json:"subtotalSet"`
+	TotalTaxSet MoneyBag `json:"totalTaxSet"`
+}
+
+func (node refundLineItemNode) refundLineItem() RefundLineItem {
+	return RefundLineItem{
+		LineItemID:  node.LineItem.ID,
+		Quantity:    node.Quantity,
+		RestockType: node.RestockType,
+		SubtotalSet: node.SubtotalSet,
+		TotalTaxSet: node.TotalTaxSet,
+	}
+}
+
+// refundNode is a Refund as selected by refundFields
+type refundNode struct {
+	Refund
+	Transactions    Connection[OrderTransaction]   `json:"transactions"`
+	RefundLineItems Connection[refundLineItemNode] `json:"refundLineItems"`
+}
+
+// refund returns the refund of node with the transactions and line items past
+// the first pages selected by refundFields. If those cannot be fetched, the
+// refund is returned with the first pages and the error.
+func (c *Client) refund(ctx context.Context, node refundNode) (Refund, error) {
+	refund := node.Refund
+	refund.Transactions = node.Transactions.Items()
+	lineItems := node.RefundLineItems.Items()
+
+	transactions, err := allPages(ctx, c, node.Transactions, 100, refundTransactionsQuery,
+		map[string]interface{}{"id": refund.ID}, "node", "transactions")
+	if err == nil {
+		refund.Transactions = transactions
+		var all []refundLineItemNode
+		all, err = allPages(ctx, c, node.RefundLineItems, 100, refundLineItemsQuery,
+			map[string]interface{}{"id": refund.ID}, "node", "refundLineItems")
+		if err == nil {
+			lineItems = all
+		}
+	}
+
+	refund.RefundLineItems = []RefundLineItem{}
+	for _, item := range lineItems {
+		refund.RefundLineItems = append(refund.RefundLineItems, item.refundLineItem())
+	}
+	if err != nil {
+		return refund, fmt.Errorf("failed to get all transactions and line items of refund %s: %w", refund.ID, err)
+	}
+	return refund, nil
+}
+
+// getRefund returns a refund with all its transactions and line items
+func (c *Client) getRefund(ctx context.Context, id string) (Refund, error) {
+	const query = `
+		query GetRefund($id: ID!) {
+			node(id: $id) {
+				... on Refund { ` + refundFields + ` }
+			}
+		}`
+
+	var node refundNode
+	if err := c.queryPath(ctx, query, map[string]interface{}{"id": id}, []string{"node"}, &node); err != nil {
+		if IsNotFound(err) {
+			return Refund{}, fmt.Errorf("refund %s: %w", id, ErrNotFound)
+		}
+		return Refund{}, err
+	}
+	return c.refund(ctx, node)
+}
+
+// Selections of a Refund decoded by refundNode and of its line items. The first
+// pages of transactions and line items are kept small so that a refund costs
+// about 200 points; refund fetches the rest.
+const (
+	refundLineItemFields = `
+		lineItem { id }
+		quantity
+		restockType
+		subtotalSet { ` + moneyBagFields + ` }
+		totalTaxSet { ` + moneyBagFields + ` }`
+
+	refundFields = `
+		id
+		note
+		createdAt
+		totalRefundedSet { ` + moneyBagFields + ` }
+		transactions(first: 10) {
+			nodes { ` + orderTransactionFields + ` }
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
+		}
+		refundLineItems(first: 25) {
+			nodes { ` + refundLineItemFields + ` }
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
+		}`
+
+	refundTransactionsQuery = `
+		query RefundTransactions($id: ID!, $first: Int!, $after: String) {
+			node(id: $id) {
+				... on Refund {
+					transactions(first: $first, after: $after) {
+						nodes { ` + orderTransactionFields + ` }
+						pageInfo {
+							hasNextPage
+							endCursor
+						}
+					}
+				}
+			}
+		}`
+
+	refundLineItemsQuery = `
+		query RefundLineItems($id: ID!, $first: Int!, $after: String) {
+			node(id: $id) {
+				... on Refund {
+					refundLineItems(first: $first, after: $after) {
+						nodes { ` + refundLineItemFields + ` }
+						pageInfo {
+							hasNextPage
+							endCursor
+						}
+					}
+				}
+			}
+		}`
+)
+
+// CancelResult is the state of an order after CancelOrder
+type CancelResult struct {
+	OrderID         string
+	OrderName       string
+	CancelledAt     time.Time
+	CancelReason    string
+	FinancialStatus string
+	// Total refunded on the order, including refunds made before the cancellation
+	TotalRefundedSet MoneyBag
+	Refunds          []Refund
+}
+
+// CancelOrder cancels an order with orderCancel, waits for the cancellation job
+// and returns the cancelled order with its refunds. Cancelling an order twice
+// is reported as *UserErrors.
+func (c *Client) CancelOrder(ctx context.Context, orderID string, opts CancelOptions) (_ *CancelResult, err error) {
+	const mutation = `
+		mutation OrderCancel($orderId: ID!, $reason: OrderCancelReason!, $restock: Boolean!,
+			$notifyCustomer: Boolean, $staffNote: String, $refundMethod: OrderCancelRefundMethodInput) {
+			orderCancel(orderId: $orderId, reason: $reason, restock: $restock,
+				notifyCustomer: $notifyCustomer, staffNote: $staffNote, refundMethod: $refundMethod) {
+				job {
+					id
+					done
+				}
+				orderCancelUserErrors {
+					field
+					message
+					code
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	orderID = OrderGID(orderID)
+	reason := opts.Reason
+	if reason == "" {
+		reason = CancelReasonOther
+	}
+
+	ctx, span := c.startSpan(ctx, "CancelOrder",
+		attribute.String("shopify.order_id", orderID),
+		attribute.String("shopify.cancel_reason", reason))
+	defer func() { endSpan(span, err) }()
+
+	refundMethod := map[string]interface{}{"originalPaymentMethodsRefund": opts.Refund == RefundOriginalPayment}
+	if opts.Refund == RefundStoreCredit {
+		storeCredit := map[string]interface{}{}
+		if opts.StoreCreditExpiresAt != nil {
+			storeCredit["expiresAt"] = opts.StoreCreditExpiresAt.UTC().Format(time.RFC3339)
+		}
+		refundMethod["storeCreditRefund"] = storeCredit
+	}
+	variables := map[string]interface{}{
+		"orderId":        orderID,
+		"reason":         reason,
+		"restock":        opts.Restock,
+		"notifyCustomer": opts.NotifyCustomer,
+		"staffNote":      opts.StaffNote,
+		"refundMethod":   refundMethod,
+	}
+
+	data, err := Do[struct {
+		OrderCancel struct {
+			Job                   *Job        `json:"job"`
+			OrderCancelUserErrors []UserError `json:"orderCancelUserErrors"`
+		} `json:"orderCancel"`
+	}](ctx, c, mutation, variables)
+	if err != nil {
+		return nil, err
+	}
+	if err := newUserErrors("orderCancel", data.OrderCancel.OrderCancelUserErrors); err != nil {
+		return nil, err
+	}
+	job := data.OrderCancel.Job
+	if job == nil {
+		return nil, fmt.Errorf("orderCancel returned no job for %s", orderID)
+	}
+	span.SetAttributes(attribute.String("shopify.job_id", job.ID))
+
+	if !job.Done {
+		if err := c.WaitJob(ctx, job.ID, opts.PollInterval); err != nil {
+			return nil, fmt.Errorf("cancellation of %s: %w", orderID, err)
+		}
+	}
+
+	result, err := c.getCancelResult(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if result.CancelledAt.IsZero() {
+		return nil, fmt.Errorf("order %s is not cancelled after job %s finished", orderID, job.ID)
+	}
+	return result, nil
+}
+
+// getCancelResult fetches the cancellation state and refunds of an order
+func (c *Client) getCancelResult(ctx context.Context, orderID string) (*CancelResult, error) {
+	const query = `
+		query GetOrderCancellation($id: ID!) {
+			order(id: $id) {
+				id
+				name
+				cancelledAt
+				cancelReason
+				displayFinancialStatus
+				totalRefundedSet { ` + moneyBagFields + ` }
+				refunds(first: 50) { id }
+			}
+		}`
+
+	var order struct {
+		ID                     string     `json:"id"`
+		Name                   string     `json:"name"`
+		CancelledAt            *time.Time `json:"cancelledAt"`
+		CancelReason           string     `json:"cancelReason"`
+		DisplayFinancialStatus string     `json:"displayFinancialStatus"`
+		TotalRefundedSet       MoneyBag   `json:"totalRefundedSet"`
+		Refunds                []struct {
+			ID string `json:"id"`
+		} `json:"refunds"`
+	}
+	if err := c.queryPath(ctx, query, map[string]interface{}{"id": orderID}, []string{"order"}, &order); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+		}
+		return nil, err
+	}
+
+	result := &CancelResult{
+		OrderID:          order.ID,
+		OrderName:        order.Name,
+		CancelReason:     order.CancelReason,
+		FinancialStatus:  order.DisplayFinancialStatus,
+		TotalRefundedSet: order.TotalRefundedSet,
+		Refunds:          []Refund{},
+	}
+	if order.CancelledAt != nil {
+		result.CancelledAt = *order.CancelledAt
+	}
+	// Refunds are fetched one by one: with their transactions and line items,
+	// 50 refunds in one query would exceed the query cost limit
+	for _, node := range order.Refunds {
+		refund, err := c.getRefund(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Refunds = append(result.Refunds, refund)
+	}
+	return result, nil
+}
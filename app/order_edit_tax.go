@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TaxDriftPolicy says what CommitPreservingTax does when Shopify's recalculation
+// on commit changes the order's tax lines
+type TaxDriftPolicy int
+
+const (
+	// RestoreTaxDrift writes the expected tax lines back with UpdateOrderTaxLinesREST
+	RestoreTaxDrift TaxDriftPolicy = iota
+	// ReportTaxDrift keeps Shopify's tax and only reports the drift
+	ReportTaxDrift
+)
+
+// PreserveTaxOptions configures CommitPreservingTax
+type PreserveTaxOptions struct {
+	// The tax the order must keep, e.g. the tax lines computed by the POS for
+	// the edited cart. Nil means the order's tax lines from before the commit.
+	TaxLines       []TaxLineRestInput
+	Policy         TaxDriftPolicy
+	NotifyCustomer bool
+}
+
+// TaxLineDrift is a tax line whose amount differs from the expected one
+type TaxLineDrift struct {
+	Title string
+	// Zero when the line is missing on that side
+	Expected Money
+	Actual   Money
+}
+
+// TaxPreservation is the outcome of CommitPreservingTax
+type TaxPreservation struct {
+	OrderID string
+	// The tax lines the order had to keep
+	Expected []TaxLineRestInput
+	// Differences left by the commit, before any restore
+	Drift []TaxLineDrift
+	// Whether the expected tax lines were written back
+	Restored bool
+	// Why the restore failed, when it did. The edit itself is committed.
+	RestoreError error
+	// Tax lines of the order once CommitPreservingTax returned
+	TaxLines    []TaxLine
+	TotalTaxSet MoneyBag
+	// True when the order ends with the expected tax: the commit did not
+	// change it or it was restored and verified
+	Preserved bool
+}
+
+// CommitPreservingTax commits the edit and makes sure the order keeps its
+// tax. Committing an order edit makes Shopify recalculate tax with the shop's
+// rates, which replaces custom (POS) tax lines. The expected tax lines are
+// compared with the order's after the commit; drift is restored or reported
+// depending on the policy, and the restore is verified by fetching the order
+// again.
+//
+// An error is returned when the edit could not be committed or its outcome
+// could not be read. A failed restore is reported in the result, since the
+// edit is committed by then.
+func (s *OrderEditSession) CommitPreservingTax(ctx context.Context, opts PreserveTaxOptions) (_ *TaxPreservation, err error) {
+	ctx, span, err := s.startSpan(ctx, "CommitPreservingTax")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { endSpan(span, err) }()
+
+	expected := opts.TaxLines
+	if expected == nil {
+		order, err := s.client.GetOrder(ctx, s.OrderID, WithOrderSections())
+		if err != nil {
+			return nil, err
+		}
+		expected = taxLinesForREST(order.TaxLines)
+	}
+
+	if err := s.client.OrderEditCommit(ctx, s.CalculatedOrderID, opts.NotifyCustomer); err != nil {
+		return nil, err
+	}
+	s.committed = true
+
+	result := &TaxPreservation{OrderID: s.OrderID, Expected: expected}
+	order, err := s.client.GetOrder(ctx, s.OrderID, WithOrderSections())
+	if err != nil {
+		return nil, fmt.Errorf("edit of %s committed, but its tax could not be checked: %w", s.OrderID, err)
+	}
+	if result.Drift, err = taxLineDrift(expected, order.TaxLines); err != nil {
+		return nil, fmt.Errorf("edit of %s committed, but its tax could not be checked: %w", s.OrderID, err)
+	}
+	span.SetAttributes(attribute.Int("shopify.tax_drift", len(result.Drift)))
+
+	if len(result.Drift) > 0 && opts.Policy == RestoreTaxDrift {
+		orderNum := strings.TrimPrefix(s.OrderID, "gid://shopify/Order/")
+		if result.RestoreError = s.client.UpdateOrderTaxLinesREST(ctx, orderNum, expected); result.RestoreError == nil {
+			result.Restored = true
+			if order, err = s.client.GetOrder(ctx, s.OrderID, WithOrderSections()); err != nil {
+				return nil, fmt.Errorf("tax of %s restored, but could not be verified: %w", s.OrderID, err)
+			}
+		}
+	}
+
+	result.TaxLines = order.TaxLines
+	result.TotalTaxSet = order.TotalTaxSet
+	drift, err := taxLineDrift(expected, order.TaxLines)
+	if err != nil {
+		return nil, fmt.Errorf("edit of %s committed, but its tax could not be checked: %w", s.OrderID, err)
+	}
+	result.Preserved = len(drift) == 0
+	if !result.Preserved {
+		s.client.logger().Warn("order tax changed by edit", "order_id", s.OrderID,
+			"drift", len(result.Drift), "restored", result.Restored, "restore_error", result.RestoreError)
+	}
+	return result, nil
+}
+
+// taxLinesForREST converts tax lines read from GraphQL for UpdateOrderTaxLinesREST
+func taxLinesForREST(taxLines []TaxLine) []TaxLineRestInput {
+	out := make([]TaxLineRestInput, 0, len(taxLines))
+	for _, tl := range taxLines {
+		out = append(out, TaxLineRestInput{Title: tl.Title, Rate: tl.Rate, Price: tl.PriceSet.ShopMoney.Amount})
+	}
+	return out
+}
+
+// taxLineDrift compares tax lines by title and returns those whose amounts
+// differ by a cent or more, in the order of expected then of the new titles.
+// Expected amounts in another currency than the order's are an error.
+func taxLineDrift(expected []TaxLineRestInput, actual []TaxLine) ([]TaxLineDrift, error) {
+	amounts := map[string]Money{}
+	var titles []string
+	for _, tl := range actual {
+		if _, ok := amounts[tl.Title]; !ok {
+			titles = append(titles, tl.Title)
+		}
+		amount := tl.PriceSet.ShopMoney.Amount
+		if err := amounts[tl.Title].CheckCurrency(amount); err != nil {
+			return nil, fmt.Errorf("tax line %s: %w", tl.Title, err)
+		}
+		amounts[tl.Title] = amounts[tl.Title].Add(amount)
+	}
+
+	var drift []TaxLineDrift
+	seen := map[string]bool{}
+	want := map[string]Money{}
+	for _, tl := range expected {
+		if err := want[tl.Title].CheckCurrency(tl.Price); err != nil {
+			return nil, fmt.Errorf("expected tax line %s: %w", tl.Title, err)
+		}
+		want[tl.Title] = want[tl.Title].Add(tl.Price)
+	}
+	for _, tl := range expected {
+		if seen[tl.Title] {
+			continue
+		}
+		seen[tl.Title] = true
+		if err := want[tl.Title].CheckCurrency(amounts[tl.Title]); err != nil {
+			return nil, fmt.Errorf("expected tax line %s: %w", tl.Title, err)
+		}
+		if !want[tl.Title].Sub(amounts[tl.Title]).Round(RoundHalfUp).IsZero() {
+			drift = append(drift, TaxLineDrift{Title: tl.Title, Expected: want[tl.Title], Actual: amounts[tl.Title]})
+		}
+	}
+	for _, title := range titles {
+		if !seen[title] && !amounts[title].Round(RoundHalfUp).IsZero() {
+			drift = append(drift, TaxLineDrift{Title: title, Actual: amounts[title]})
+		}
+	}
+	return drift, nil
+}
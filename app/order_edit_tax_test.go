@@ -0,0 +1,49 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTaxLineDrift(t *testing.T) {
+	taxLine := func(title, amount, currency string) TaxLine {
+		return TaxLine{Title: title, PriceSet: MoneyBag{ShopMoney: MoneyV2{Amount: MustParseMoney(amount, currency), CurrencyCode: currency}}}
+	}
+	gst := TaxLineRestInput{Title: "GST", Price: MustParseMoney("2.50", "USD")}
+
+	tests := []struct {
+		name     string
+		expected []TaxLineRestInput
+		actual   []TaxLine
+		// "Title expected->actual" per drifted line
+		want    []string
+		wantErr error
+	}{
+		{"kept", []TaxLineRestInput{gst}, []TaxLine{taxLine("GST", "2.5", "USD")}, nil, nil},
+		{"under a cent", []TaxLineRestInput{gst}, []TaxLine{taxLine("GST", "2.504", "USD")}, nil, nil},
+		{"recalculated", []TaxLineRestInput{gst}, []TaxLine{taxLine("Tax", "4.5", "USD")},
+			[]string{"GST 2.5->0", "Tax 0->4.5"}, nil},
+		{"split lines of a title add up", []TaxLineRestInput{gst},
+			[]TaxLine{taxLine("GST", "1.25", "USD"), taxLine("GST", "1.25", "USD")}, nil, nil},
+		{"order in another currency", []TaxLineRestInput{gst}, []TaxLine{taxLine("GST", "2.5", "EUR")},
+			nil, ErrCurrencyMismatch},
+		{"lines in different currencies", nil, []TaxLine{taxLine("GST", "1", "USD"), taxLine("GST", "1", "CAD")},
+			nil, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift, err := taxLineDrift(tt.expected, tt.actual)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("taxLineDrift error = %v, want %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, d := range drift {
+				got = append(got, fmt.Sprintf("%s %s->%s", d.Title, d.Expected.Decimal(), d.Actual.Decimal()))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("drift = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
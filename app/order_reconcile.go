@@ -0,0 +1,436 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Kinds of ReconcileChange
+const (
+	ReconcileAddItem        = "ADD_ITEM"
+	ReconcileRemoveItem     = "REMOVE_ITEM"
+	ReconcileSetQuantity    = "SET_QUANTITY"
+	ReconcileAddDiscount    = "ADD_DISCOUNT"
+	ReconcileUpdateDiscount = "UPDATE_DISCOUNT"
+	ReconcileRemoveDiscount = "REMOVE_DISCOUNT"
+)
+
+// ErrDiscountNotEditable is returned by ReconcileOrder when the cart changes or
+// drops a discount the order already has. orderEditUpdateDiscount and
+// orderEditRemoveDiscount only take discounts staged in the same edit.
+var ErrDiscountNotEditable = errors.New("an order edit cannot change a discount the order already has")
+
+// CartLine is a line of the cart an order is reconciled to. A line is either a
+// product variant or, without VariantID, a custom item with Title and Price.
+type CartLine struct {
+	VariantID string `json:"variantId,omitempty"`
+	// Title and unit price of a custom item
+	Title    string `json:"title,omitempty"`
+	Price    Money  `json:"price,omitzero"`
+	Quantity int    `json:"quantity"`
+	// Whether a custom item is taxed
+	Taxable bool `json:"taxable,omitempty"`
+	// Nil means the line has no discount
+	Discount *OrderEditDiscount `json:"discount,omitempty"`
+}
+
+// ReconcileOptions configures ReconcileOrder
+type ReconcileOptions struct {
+	// Return removed units of variants to inventory
+	Restock        bool
+	NotifyCustomer bool
+	// Plan and preview the edit without committing it
+	DryRun bool
+	// Tax the order must keep after the edit, e.g. the POS tax of the cart.
+	// When set the edit is committed with CommitPreservingTax.
+	TaxLines []TaxLineRestInput
+}
+
+// ReconcileChange is an order edit operation applied by ReconcileOrder
+type ReconcileChange struct {
+	// One of the Reconcile constants
+	Kind string
+	// The CalculatedLineItem changed, or added
+	LineItemID   string
+	VariantID    string
+	Title        string
+	FromQuantity int
+	ToQuantity   int
+	Discount     *OrderEditDiscount
+}
+
+// ReconcileResult reports what ReconcileOrder changed and what is owed
+type ReconcileResult struct {
+	OrderID           string
+	CalculatedOrderID string
+	Changes           []ReconcileChange
+	// False for a dry run or when the order already matched the cart
+	Committed bool
+	// Order total before and after the edit, as calculated by Shopify or, when
+	// the tax was restored, with the restored tax
+	TotalBefore Money
+	TotalAfter  Money
+	// What the customer owes after the edit, or what they are owed back.
+	// At most one of the two is non-zero.
+	BalanceDue Money
+	RefundOwed Money
+	// The order as it is after the edit; nil when no order edit was begun
+	// because the order already matched the cart
+	Preview *CalculatedOrder
+	// Set when ReconcileOptions.TaxLines is
+	Tax *TaxPreservation
+}
+
+// ReconcileOrder edits an order so its line items match a cart, e.g. the POS
+// cart after an exchange. Lines are matched by variant, custom items by title
+// and price; the differences become quantity changes, added and removed items
+// and discount changes, staged in one order edit and committed together. If
+// any change is rejected nothing is committed. A cart that changes or drops a
+// discount the order already has fails with ErrDiscountNotEditable before an
+// edit is begun.
+func (c *Client) ReconcileOrder(ctx context.Context, orderID string, cart []CartLine, opts ReconcileOptions) (_ *ReconcileResult, err error) {
+	orderID = OrderGID(orderID)
+	ctx, span := c.startSpan(ctx, "ReconcileOrder",
+		attribute.String("shopify.order_id", orderID),
+		attribute.Bool("shopify.dry_run", opts.DryRun))
+	defer func() { endSpan(span, err) }()
+
+	if err := validateCart(cart); err != nil {
+		return nil, err
+	}
+
+	// An order that already matches the cart is not edited at all
+	order, err := c.GetOrder(ctx, orderID, WithOrderSections(OrderSectionLineItems))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCartCurrency(cart, order.CurrencyCode); err != nil {
+		return nil, err
+	}
+	planned := planReconcile(order.reconcileLineItems(), cart)
+	if err := checkDiscountChanges(planned); err != nil {
+		return nil, fmt.Errorf("reconcile %s: %w", orderID, err)
+	}
+	if len(planned) == 0 {
+		span.SetAttributes(attribute.Int("shopify.changes", 0))
+		result := &ReconcileResult{
+			OrderID:     orderID,
+			Changes:     []ReconcileChange{},
+			TotalBefore: order.CurrentTotalPriceSet.ShopMoney.Amount,
+		}
+		result.setTotals(order.CurrentTotalPriceSet, order.TotalOutstandingSet)
+		return result, nil
+	}
+
+	session, err := c.EditOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := session.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{
+		OrderID:           orderID,
+		CalculatedOrderID: session.CalculatedOrderID,
+		Changes:           []ReconcileChange{},
+		TotalBefore:       before.TotalPriceSet.ShopMoney.Amount,
+	}
+	steps := planReconcile(before.LineItems, cart)
+	span.SetAttributes(attribute.Int("shopify.changes", len(steps)))
+
+	for _, step := range steps {
+		if err := step.apply(ctx, session, opts.Restock); err != nil {
+			item := step.change.Title
+			if item == "" {
+				item = step.change.VariantID
+			}
+			return nil, fmt.Errorf("reconcile %s: %s %s: %w", orderID, step.change.Kind, item, err)
+		}
+		result.Changes = append(result.Changes, step.change)
+	}
+
+	after := before
+	if len(result.Changes) > 0 {
+		if after, err = session.Preview(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result.Preview = after
+	result.setTotals(after.TotalPriceSet, after.TotalOutstandingSet)
+
+	if opts.DryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+	if opts.TaxLines != nil {
+		result.Tax, err = session.CommitPreservingTax(ctx, PreserveTaxOptions{TaxLines: opts.TaxLines, NotifyCustomer: opts.NotifyCustomer})
+	} else {
+		err = session.Commit(ctx, opts.NotifyCustomer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Committed = true
+	if result.Tax != nil && result.Tax.Restored {
+		// The restored tax changes the totals Shopify calculated for the edit
+		order, err := c.GetOrder(ctx, orderID, WithOrderSections())
+		if err != nil {
+			return nil, err
+		}
+		result.setTotals(order.TotalPriceSet, order.TotalOutstandingSet)
+	}
+	c.logger().Info("order reconciled", "order_id", orderID, "changes", len(result.Changes),
+		"balance_due", result.BalanceDue, "refund_owed", result.RefundOwed)
+	return result, nil
+}
+
+// setTotals sets the total after the edit and splits the outstanding amount
+// into balance due and refund owed
+func (r *ReconcileResult) setTotals(total, outstanding MoneyBag) {
+	r.TotalAfter = total.ShopMoney.Amount
+	amount := outstanding.ShopMoney.Amount
+	zero := NewMoney(decimal.Zero, amount.Currency())
+	r.BalanceDue = MaxMoney(amount, zero)
+	r.RefundOwed = MaxMoney(amount.Neg(), zero)
+}
+
+// validateCart rejects lines ReconcileOrder cannot match unambiguously
+func validateCart(cart []CartLine) error {
+	seen := map[string]bool{}
+	for i, line := range cart {
+		if line.Quantity <= 0 {
+			return fmt.Errorf("cart line %d: quantity must be greater than 0", i)
+		}
+		if line.VariantID == "" && line.Title == "" {
+			return fmt.Errorf("cart line %d: a custom item needs a title", i)
+		}
+		key := cartLineKey(line)
+		if seen[key] {
+			return fmt.Errorf("cart line %d: %s is already in the cart; combine the lines", i, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// checkCartCurrency rejects cart prices and fixed discounts in another currency
+// than the order's, which cannot be compared with its line items
+func checkCartCurrency(cart []CartLine, currency string) error {
+	order := NewMoney(decimal.Zero, currency)
+	for i, line := range cart {
+		if err := order.CheckCurrency(line.Price); err != nil {
+			return fmt.Errorf("cart line %d: price: %w", i, err)
+		}
+		if line.Discount == nil {
+			continue
+		}
+		if err := order.CheckCurrency(line.Discount.FixedValue); err != nil {
+			return fmt.Errorf("cart line %d: discount: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkDiscountChanges rejects steps that update or remove a discount. Every
+// discount on the order when ReconcileOrder begins its edit was applied before,
+// so neither can be staged.
+func checkDiscountChanges(steps []reconcileStep) error {
+	for _, step := range steps {
+		if step.change.Kind == ReconcileUpdateDiscount || step.change.Kind == ReconcileRemoveDiscount {
+			return fmt.Errorf("%s %s: %w", step.change.Kind, step.change.Title, ErrDiscountNotEditable)
+		}
+	}
+	return nil
+}
+
+// cartLineKey identifies a variant, or a custom item by title and unit price
+func cartLineKey(line CartLine) string {
+	if line.VariantID != "" {
+		return resourceGID("ProductVariant", line.VariantID)
+	}
+	return fmt.Sprintf("%s @ %s", line.Title, line.Price.StringFixed())
+}
+
+// calculatedLineKey is the cartLineKey of a line item of the order
+func calculatedLineKey(item CalculatedLineItem) string {
+	return cartLineKey(CartLine{VariantID: item.VariantID, Title: item.Title, Price: item.OriginalUnitPrice})
+}
+
+// reconcileLineItems returns the order's line items as planReconcile compares
+// them, so the order can be checked against the cart before an edit is begun.
+// IDs are the order's line item IDs, not calculated line item IDs.
+func (o *Order) reconcileLineItems() []CalculatedLineItem {
+	items := make([]CalculatedLineItem, 0, len(o.LineItems))
+	for _, li := range o.LineItems {
+		item := CalculatedLineItem{
+			ID:                  li.ID,
+			Title:               li.Title,
+			SKU:                 li.SKU,
+			Quantity:            li.CurrentQuantity,
+			OriginalUnitPrice:   li.OriginalUnitPriceSet.ShopMoney.Amount,
+			DiscountedUnitPrice: li.DiscountedUnitPriceSet.ShopMoney.Amount,
+		}
+		if li.Variant != nil {
+			item.VariantID = li.Variant.ID
+		}
+		for _, allocation := range li.DiscountAllocations {
+			description := allocation.DiscountApplication.Description
+			if description == "" {
+				description = allocation.DiscountApplication.Name()
+			}
+			item.Discounts = append(item.Discounts, CalculatedDiscount{
+				Description: description,
+				Amount:      allocation.AllocatedAmountSet.ShopMoney.Amount,
+			})
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// reconcileStep is a planned change with what is needed to stage it
+type reconcileStep struct {
+	change ReconcileChange
+	// The cart line of an added item
+	line CartLine
+}
+
+// planReconcile returns the steps that turn the line items into the cart
+func planReconcile(items []CalculatedLineItem, cart []CartLine) []reconcileStep {
+	matched := map[string]CalculatedLineItem{}
+	for _, item := range items {
+		key := calculatedLineKey(item)
+		if _, ok := matched[key]; !ok && item.Quantity > 0 {
+			matched[key] = item
+		}
+	}
+
+	var steps []reconcileStep
+	kept := map[string]bool{}
+	for _, line := range cart {
+		item, ok := matched[cartLineKey(line)]
+		if !ok {
+			steps = append(steps, reconcileStep{
+				change: ReconcileChange{
+					Kind:       ReconcileAddItem,
+					VariantID:  line.VariantID,
+					Title:      line.Title,
+					ToQuantity: line.Quantity,
+					Discount:   line.Discount,
+				},
+				line: line,
+			})
+			continue
+		}
+		kept[item.ID] = true
+
+		change := ReconcileChange{
+			LineItemID:   item.ID,
+			VariantID:    item.VariantID,
+			Title:        item.Title,
+			FromQuantity: item.Quantity,
+			ToQuantity:   line.Quantity,
+		}
+		if item.Quantity != line.Quantity {
+			change.Kind = ReconcileSetQuantity
+			steps = append(steps, reconcileStep{change: change})
+		}
+		if kind := discountChange(item, line.Discount); kind != "" {
+			change.Kind = kind
+			change.Discount = line.Discount
+			steps = append(steps, reconcileStep{change: change})
+		}
+	}
+
+	for _, item := range items {
+		if item.Quantity == 0 || kept[item.ID] {
+			continue
+		}
+		steps = append(steps, reconcileStep{change: ReconcileChange{
+			Kind:         ReconcileRemoveItem,
+			LineItemID:   item.ID,
+			VariantID:    item.VariantID,
+			Title:        item.Title,
+			FromQuantity: item.Quantity,
+		}})
+	}
+	return steps
+}
+
+// discountChange returns the kind of change that gives a line item the
+// discount, or "" when it already has it. A line item with several discounts
+// never matches the single discount of a cart line.
+func discountChange(item CalculatedLineItem, discount *OrderEditDiscount) string {
+	switch {
+	case discount == nil && len(item.Discounts) == 0:
+		return ""
+	case discount == nil:
+		return ReconcileRemoveDiscount
+	case len(item.Discounts) == 0:
+		return ReconcileAddDiscount
+	case len(item.Discounts) > 1:
+		return ReconcileUpdateDiscount
+	}
+
+	unitDiscount := item.OriginalUnitPrice.Sub(item.DiscountedUnitPrice)
+	if !unitDiscount.Sub(discount.unitAmount(item.OriginalUnitPrice)).Round(RoundHalfUp).IsZero() ||
+		item.Discounts[0].Description != discount.Description {
+		return ReconcileUpdateDiscount
+	}
+	return ""
+}
+
+// unitAmount returns the discount per unit for a unit price, rounded to cents
+func (d OrderEditDiscount) unitAmount(price Money) Money {
+	if d.IsPercentage {
+		return price.Percent(d.PercentValue).Round(RoundHalfUp)
+	}
+	return MinMoney(d.FixedValue, price)
+}
+
+// apply stages the step in the session and records the line item it added
+func (step *reconcileStep) apply(ctx context.Context, session *OrderEditSession, restock bool) error {
+	change := &step.change
+	switch change.Kind {
+	case ReconcileAddItem:
+		var item *CalculatedLineItem
+		var err error
+		if step.line.VariantID != "" {
+			item, err = session.AddVariant(ctx, step.line.VariantID, step.line.Quantity)
+		} else {
+			item, err = session.AddCustomItem(ctx, OrderEditCustomItem{
+				Title:            step.line.Title,
+				Price:            step.line.Price,
+				Quantity:         step.line.Quantity,
+				Taxable:          step.line.Taxable,
+				RequiresShipping: true,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		change.LineItemID = item.ID
+		change.VariantID = item.VariantID
+		change.Title = item.Title
+		if change.Discount != nil {
+			_, err = session.AddDiscount(ctx, item.ID, *change.Discount)
+		}
+		return err
+	case ReconcileRemoveItem:
+		return session.RemoveLineItem(ctx, change.LineItemID, restock)
+	case ReconcileSetQuantity:
+		_, err := session.SetQuantity(ctx, change.LineItemID, change.ToQuantity, restock)
+		return err
+	case ReconcileAddDiscount:
+		_, err := session.AddDiscount(ctx, change.LineItemID, *change.Discount)
+		return err
+	case ReconcileUpdateDiscount, ReconcileRemoveDiscount:
+		return ErrDiscountNotEditable
+	}
+	return fmt.Errorf("unknown change %s", change.Kind)
+}
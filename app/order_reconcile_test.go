@@ -0,0 +1,185 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPlanReconcile(t *testing.T) {
+	shirt := CalculatedLineItem{
+		ID:                  "gid://shopify/CalculatedLineItem/1",
+		VariantID:           "gid://shopify/ProductVariant/10",
+		Title:               "T-Shirt",
+		Quantity:            2,
+		OriginalUnitPrice:   MustParseMoney("25.00", "USD"),
+		DiscountedUnitPrice: MustParseMoney("25.00", "USD"),
+	}
+	mug := CalculatedLineItem{
+		ID:                  "gid://shopify/CalculatedLineItem/2",
+		VariantID:           "gid://shopify/ProductVariant/20",
+		Title:               "Mug",
+		Quantity:            1,
+		OriginalUnitPrice:   MustParseMoney("12.50", "USD"),
+		DiscountedUnitPrice: MustParseMoney("12.50", "USD"),
+	}
+	removed := mug
+	removed.Quantity = 0
+	discounted := shirt
+	discounted.DiscountedUnitPrice = MustParseMoney("20.00", "USD")
+	discounted.Discounts = []CalculatedDiscount{{ID: "gid://shopify/CalculatedManualDiscountApplication/1", Description: "Loyalty"}}
+	stacked := discounted
+	stacked.Discounts = append(stacked.Discounts, CalculatedDiscount{ID: "gid://shopify/CalculatedDiscountCodeApplication/2", Description: "SUMMER"})
+	second := shirt
+	second.ID = "gid://shopify/CalculatedLineItem/3"
+	second.Quantity = 1
+	loyalty := &OrderEditDiscount{Description: "Loyalty", PercentValue: decimal.NewFromInt(20), IsPercentage: true}
+
+	tests := []struct {
+		name  string
+		items []CalculatedLineItem
+		cart  []CartLine
+		// "KIND line from->to" per step
+		want []string
+	}{
+		{
+			name:  "no change",
+			items: []CalculatedLineItem{shirt, mug},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2}, {VariantID: "20", Quantity: 1}},
+			want:  nil,
+		},
+		{
+			name:  "changed quantity",
+			items: []CalculatedLineItem{shirt, mug},
+			cart:  []CartLine{{VariantID: "10", Quantity: 3}, {VariantID: "20", Quantity: 1}},
+			want:  []string{"SET_QUANTITY T-Shirt 2->3"},
+		},
+		{
+			name:  "added line",
+			items: []CalculatedLineItem{shirt},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2}, {VariantID: "20", Quantity: 4}},
+			want:  []string{"ADD_ITEM gid://shopify/ProductVariant/20 0->4"},
+		},
+		{
+			name:  "added custom item",
+			items: []CalculatedLineItem{shirt},
+			cart: []CartLine{{VariantID: "10", Quantity: 2},
+				{Title: "Gift wrap", Price: MustParseMoney("5", "USD"), Quantity: 1}},
+			want: []string{"ADD_ITEM Gift wrap 0->1"},
+		},
+		{
+			name:  "removed line",
+			items: []CalculatedLineItem{shirt, mug},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2}},
+			want:  []string{"REMOVE_ITEM Mug 1->0"},
+		},
+		{
+			name:  "line already removed by an earlier edit",
+			items: []CalculatedLineItem{shirt, removed},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2}},
+			want:  nil,
+		},
+		{
+			name:  "added discount",
+			items: []CalculatedLineItem{shirt},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2, Discount: loyalty}},
+			want:  []string{"ADD_DISCOUNT T-Shirt 2->2"},
+		},
+		{
+			name:  "same discount",
+			items: []CalculatedLineItem{discounted},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2, Discount: loyalty}},
+			want:  nil,
+		},
+		{
+			name:  "removed discount",
+			items: []CalculatedLineItem{discounted},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2}},
+			want:  []string{"REMOVE_DISCOUNT T-Shirt 2->2"},
+		},
+		{
+			name:  "changed discount value",
+			items: []CalculatedLineItem{discounted},
+			cart: []CartLine{{VariantID: "10", Quantity: 2,
+				Discount: &OrderEditDiscount{Description: "Loyalty", PercentValue: decimal.NewFromInt(10), IsPercentage: true}}},
+			want: []string{"UPDATE_DISCOUNT T-Shirt 2->2"},
+		},
+		{
+			name:  "changed discount description",
+			items: []CalculatedLineItem{discounted},
+			cart: []CartLine{{VariantID: "10", Quantity: 2,
+				Discount: &OrderEditDiscount{Description: "Staff", PercentValue: decimal.NewFromInt(20), IsPercentage: true}}},
+			want: []string{"UPDATE_DISCOUNT T-Shirt 2->2"},
+		},
+		{
+			name:  "line with more discounts than the cart",
+			items: []CalculatedLineItem{stacked},
+			cart:  []CartLine{{VariantID: "10", Quantity: 2, Discount: loyalty}},
+			want:  []string{"UPDATE_DISCOUNT T-Shirt 2->2"},
+		},
+		{
+			name:  "variant on two lines",
+			items: []CalculatedLineItem{shirt, second},
+			cart:  []CartLine{{VariantID: "10", Quantity: 3}},
+			want:  []string{"SET_QUANTITY T-Shirt 2->3", "REMOVE_ITEM T-Shirt 1->0"},
+		},
+		{
+			name:  "quantity and line changes together",
+			items: []CalculatedLineItem{shirt, mug},
+			cart:  []CartLine{{VariantID: "10", Quantity: 1}, {VariantID: "30", Quantity: 1}},
+			want: []string{
+				"SET_QUANTITY T-Shirt 2->1",
+				"ADD_ITEM gid://shopify/ProductVariant/30 0->1",
+				"REMOVE_ITEM Mug 1->0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, step := range planReconcile(tt.items, tt.cart) {
+				item := step.change.Title
+				if item == "" {
+					item = resourceGID("ProductVariant", step.change.VariantID)
+				}
+				got = append(got, fmt.Sprintf("%s %s %d->%d", step.change.Kind, item, step.change.FromQuantity, step.change.ToQuantity))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("planReconcile =\n  %v\nwant\n  %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDiscountChanges(t *testing.T) {
+	discounted := CalculatedLineItem{
+		ID:                  "gid://shopify/CalculatedLineItem/1",
+		VariantID:           "gid://shopify/ProductVariant/10",
+		Title:               "T-Shirt",
+		Quantity:            2,
+		OriginalUnitPrice:   MustParseMoney("25.00", "USD"),
+		DiscountedUnitPrice: MustParseMoney("20.00", "USD"),
+		Discounts:           []CalculatedDiscount{{ID: "gid://shopify/CalculatedManualDiscountApplication/1", Description: "Loyalty"}},
+	}
+	loyalty := &OrderEditDiscount{Description: "Loyalty", PercentValue: decimal.NewFromInt(20), IsPercentage: true}
+	tests := []struct {
+		name    string
+		cart    []CartLine
+		wantErr error
+	}{
+		{"kept discount", []CartLine{{VariantID: "10", Quantity: 3, Discount: loyalty}}, nil},
+		{"removed discount", []CartLine{{VariantID: "10", Quantity: 2}}, ErrDiscountNotEditable},
+		{"updated discount", []CartLine{{VariantID: "10", Quantity: 2,
+			Discount: &OrderEditDiscount{Description: "Loyalty", FixedValue: MustParseMoney("1", "USD")}}}, ErrDiscountNotEditable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDiscountChanges(planReconcile([]CalculatedLineItem{discounted}, tt.cart))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkDiscountChanges = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+)
+
+// OrderQuery builds the Shopify search syntax used by SearchOrders, quoting and
+// escaping values so tags like "pos:outlet 3" or names like "#2291" are matched
+// literally. Terms are combined with AND:
+//
+//	app.NewOrderQuery().Tag("connectpos").Metafield("connectpos", "shift_id", "S-104").CreatedAfter(start)
+type OrderQuery struct {
+	terms   []string
+	sortKey string
+	reverse bool
+}
+
+// Order sort keys accepted by OrderQuery.Sort
+const (
+	OrderSortCreatedAt   = "CREATED_AT"
+	OrderSortProcessedAt = "PROCESSED_AT"
+	OrderSortUpdatedAt   = "UPDATED_AT"
+	OrderSortOrderNumber = "ORDER_NUMBER"
+	OrderSortTotalPrice  = "TOTAL_PRICE"
+)
+
+// NewOrderQuery returns an empty query, which matches every order
+func NewOrderQuery() *OrderQuery {
+	return &OrderQuery{}
+}
+
+// Name matches an order name such as "#2291"; a bare number gets the "#" prefix
+func (q *OrderQuery) Name(name string) *OrderQuery {
+	if numericID.MatchString(name) {
+		name = "#" + name
+	}
+	return q.Field("name", name)
+}
+
+// Tag matches orders with the tag
+func (q *OrderQuery) Tag(tag string) *OrderQuery {
+	return q.Field("tag", tag)
+}
+
+// NotTag matches orders without the tag
+func (q *OrderQuery) NotTag(tag string) *OrderQuery {
+	return q.Raw("-tag:" + searchValue(tag))
+}
+
+// FinancialStatus matches a financial status such as "paid", "pending" or "partially_refunded"
+func (q *OrderQuery) FinancialStatus(status string) *OrderQuery {
+	return q.Field("financial_status", strings.ToLower(status))
+}
+
+// FulfillmentStatus matches a fulfillment status such as "unfulfilled", "partial" or "shipped"
+func (q *OrderQuery) FulfillmentStatus(status string) *OrderQuery {
+	return q.Field("fulfillment_status", strings.ToLower(status))
+}
+
+// Status matches "open", "closed" or "cancelled" orders
+func (q *OrderQuery) Status(status string) *OrderQuery {
+	return q.Field("status", strings.ToLower(status))
+}
+
+// Email matches the customer email of the order
+func (q *OrderQuery) Email(email string) *OrderQuery {
+	return q.Field("email", email)
+}
+
+// CreatedAfter matches orders created at or after t
+func (q *OrderQuery) CreatedAfter(t time.Time) *OrderQuery {
+	return q.Raw("created_at:>=" + searchValue(t.UTC().Format(time.RFC3339)))
+}
+
+// CreatedBefore matches orders created before t
+func (q *OrderQuery) CreatedBefore(t time.Time) *OrderQuery {
+	return q.Raw("created_at:<" + searchValue(t.UTC().Format(time.RFC3339)))
+}
+
+// CreatedBetween matches orders created in [from, to), e.g. during a POS shift
+func (q *OrderQuery) CreatedBetween(from, to time.Time) *OrderQuery {
+	return q.CreatedAfter(from).CreatedBefore(to)
+}
+
+// Metafield matches orders whose metafield namespace.key has the value. Shopify
+// only filters on metafields whose definition has filtering enabled.
+func (q *OrderQuery) Metafield(namespace, key, value string) *OrderQuery {
+	return q.Field("metafields."+namespace+"."+key, value)
+}
+
+// Field matches any search field, e.g. Field("source_name", "pos")
+func (q *OrderQuery) Field(name, value string) *OrderQuery {
+	return q.Raw(name + ":" + searchValue(value))
+}
+
+// Raw adds a term as is, for syntax the builder does not cover
+func (q *OrderQuery) Raw(term string) *OrderQuery {
+	q.terms = append(q.terms, term)
+	return q
+}
+
+// Sort orders the results by one of the OrderSort keys, newest or largest first when reverse is true
+func (q *OrderQuery) Sort(key string, reverse bool) *OrderQuery {
+	q.sortKey = key
+	q.reverse = reverse
+	return q
+}
+
+// String returns the search string passed as the query argument
+func (q *OrderQuery) String() string {
+	if q == nil {
+		return ""
+	}
+	return strings.Join(q.terms, " AND ")
+}
+
+// searchValue returns value as is when it only has characters that need no
+// quoting, otherwise single-quoted with backslashes and quotes escaped
+func searchValue(value string) string {
+	plain := value != "" && !strings.HasPrefix(value, "-")
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_.@#/+-", r)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// SearchOrdersQuery pages through the orders matching a search string
+const SearchOrdersQuery = `
+	query SearchOrders($first: Int!, $after: String, $query: String, $sortKey: OrderSortKeys, $reverse: Boolean) {
+		orders(first: $first, after: $after, query: $query, sortKey: $sortKey, reverse: $reverse) {
+			nodes { ` + orderFields + ` }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+
+// SearchOrders yields the orders matching q page by page. A nil q matches every order.
+//
+// Orders carry their own fields, totals and tax lines. Sections are not fetched
+// unless WithOrderSections is given; each order is then fetched with GetOrder,
+// one extra call per order, which keeps every call well under the query cost limit.
+// Iteration stops after the first error.
+func (c *Client) SearchOrders(ctx context.Context, q *OrderQuery, opts ...OrderOption) iter.Seq2[*Order, error] {
+	options := orderOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	variables := map[string]interface{}{
+		"query":   q.String(),
+		"sortKey": nil,
+		"reverse": false,
+	}
+	if q != nil && q.sortKey != "" {
+		variables["sortKey"] = q.sortKey
+		variables["reverse"] = q.reverse
+	}
+
+	return func(yield func(*Order, error) bool) {
+		for order, err := range Paginate[Order](ctx, c, SearchOrdersQuery, variables, "orders") {
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to search orders %q: %w", q.String(), err))
+				return
+			}
+			if options.sections == 0 {
+				if !yield(&order, nil) {
+					return
+				}
+				continue
+			}
+			full, err := c.GetOrder(ctx, order.ID, opts...)
+			if !yield(full, err) || err != nil {
+				return
+			}
+		}
+	}
+}
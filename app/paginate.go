@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// DefaultPageSize is the page size Paginate uses when the variables do not set "first"
+const DefaultPageSize = 50
+
+// PageInfo is the pageInfo block of a GraphQL connection
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Edge is one edge of a GraphQL connection
+type Edge[T any] struct {
+	Cursor string `json:"cursor,omitempty"`
+	Node   T      `json:"node"`
+}
+
+// Connection is one page of a GraphQL connection. Queries may select either
+// edges { node } or nodes; both are decoded.
+type Connection[T any] struct {
+	Edges    []Edge[T] `json:"edges"`
+	Nodes    []T       `json:"nodes"`
+	PageInfo PageInfo  `json:"pageInfo"`
+}
+
+// Items returns the nodes of the page, whichever of edges or nodes was selected
+func (conn *Connection[T]) Items() []T {
+	if len(conn.Nodes) > 0 {
+		return conn.Nodes
+	}
+	items := make([]T, 0, len(conn.Edges))
+	for _, edge := range conn.Edges {
+		items = append(items, edge.Node)
+	}
+	return items
+}
+
+// Paginate runs a connection query page by page and yields every node.
+//
+// The query must declare $first: Int and $after: String, pass them to the connection
+// and select pageInfo { hasNextPage endCursor }. path is the field path from data to
+// the connection, e.g. "order", "fulfillmentOrders". "first" defaults to DefaultPageSize
+// and a non-empty "after" in variables starts after that cursor.
+//
+// Iteration stops after the first error, which is yielded with the zero value of T.
+// A null object on the path is reported as an error wrapping ErrNotFound.
+func Paginate[T any](ctx context.Context, c *Client, query string, variables map[string]interface{}, path ...string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		vars := make(map[string]interface{}, len(variables)+2)
+		for k, v := range variables {
+			vars[k] = v
+		}
+		if _, ok := vars["first"]; !ok {
+			vars["first"] = DefaultPageSize
+		}
+		if after, ok := vars["after"].(string); ok && after == "" {
+			delete(vars, "after")
+		}
+
+		for {
+			var page Connection[T]
+			if err := c.queryPath(ctx, query, vars, path, &page); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range page.Items() {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+				return
+			}
+			vars["after"] = page.PageInfo.EndCursor
+		}
+	}
+}
+
+// CollectAll drains a Paginate iterator into a slice
+func CollectAll[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// queryPath runs a GraphQL query and decodes the value at path (under data) into out.
+// userErrors are reported the same way as by Do.
+func (c *Client) queryPath(ctx context.Context, query string, variables map[string]interface{}, path []string, out interface{}) error {
+	var data json.RawMessage
+	if err := c.graphQL(ctx, query, variables, &data); err != nil {
+		return err
+	}
+
+	raw := data
+	for i, field := range path {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+			return fmt.Errorf("%s: %w", strings.Join(append([]string{"data"}, path[:i]...), "."), ErrNotFound)
+		}
+		raw = object[field]
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return fmt.Errorf("%s: %w", strings.Join(append([]string{"data"}, path...), "."), ErrNotFound)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", strings.Join(path, "."), err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const itemsQuery = `query Items($first: Int, $after: String) {
+	shop { items(first: $first, after: $after) { nodes { id } pageInfo { hasNextPage endCursor } } }
+}`
+
+type testItem struct {
+	ID string `json:"id"`
+}
+
+// pagedServer serves pages of a shop.items connection. Cursor "cN" starts at
+// page N and the last page has no next page.
+type pagedServer struct {
+	pages [][]string
+
+	mu        sync.Mutex
+	variables []map[string]interface{}
+}
+
+func (s *pagedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.variables = append(s.variables, body.Variables)
+	s.mu.Unlock()
+
+	page := 0
+	if after, ok := body.Variables["after"].(string); ok {
+		page, _ = strconv.Atoi(after[1:])
+	}
+	nodes := []testItem{}
+	for _, id := range s.pages[page] {
+		nodes = append(nodes, testItem{ID: id})
+	}
+	hasNext := page+1 < len(s.pages)
+	cursor := ""
+	if hasNext {
+		cursor = fmt.Sprintf("c%d", page+1)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"shop": map[string]interface{}{"items": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": PageInfo{HasNextPage: hasNext, EndCursor: cursor},
+		}}},
+	})
+}
+
+func (s *pagedServer) requests() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.variables
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name string
+		// Pages of item IDs served
+		pages     [][]string
+		variables map[string]interface{}
+		// Stop iterating after this many items; 0 drains the iterator
+		breakAfter   int
+		wantIDs      []string
+		wantRequests int
+	}{
+		{
+			name:         "single page without next page",
+			pages:        [][]string{{"1", "2"}},
+			wantIDs:      []string{"1", "2"},
+			wantRequests: 1,
+		},
+		{
+			name:         "follows cursors until hasNextPage is false",
+			pages:        [][]string{{"1", "2"}, {"3"}, {"4", "5"}},
+			wantIDs:      []string{"1", "2", "3", "4", "5"},
+			wantRequests: 3,
+		},
+		{
+			name:         "empty connection",
+			pages:        [][]string{{}},
+			wantIDs:      nil,
+			wantRequests: 1,
+		},
+		{
+			name:         "break on the first page",
+			pages:        [][]string{{"1", "2"}, {"3"}},
+			breakAfter:   1,
+			wantIDs:      []string{"1"},
+			wantRequests: 1,
+		},
+		{
+			name:         "break at the end of a page",
+			pages:        [][]string{{"1", "2"}, {"3", "4"}, {"5"}},
+			breakAfter:   2,
+			wantIDs:      []string{"1", "2"},
+			wantRequests: 1,
+		},
+		{
+			name:         "break on a later page",
+			pages:        [][]string{{"1", "2"}, {"3", "4"}, {"5"}},
+			breakAfter:   3,
+			wantIDs:      []string{"1", "2", "3"},
+			wantRequests: 2,
+		},
+		{
+			name:         "starts after the given cursor",
+			pages:        [][]string{{"1"}, {"2"}, {"3"}},
+			variables:    map[string]interface{}{"after": "c1", "first": 1},
+			wantIDs:      []string{"2", "3"},
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &pagedServer{pages: tt.pages}
+			client := newTestClient(t, server)
+
+			var ids []string
+			for item, err := range Paginate[testItem](context.Background(), client, itemsQuery, tt.variables, "shop", "items") {
+				if err != nil {
+					t.Fatalf("Paginate: %v", err)
+				}
+				ids = append(ids, item.ID)
+				if len(ids) == tt.breakAfter {
+					break
+				}
+			}
+
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("items = %v, want %v", ids, tt.wantIDs)
+			}
+			requests := server.requests()
+			if len(requests) != tt.wantRequests {
+				t.Fatalf("sent %d requests, want %d", len(requests), tt.wantRequests)
+			}
+			wantFirst := float64(DefaultPageSize)
+			if first, ok := tt.variables["first"].(int); ok {
+				wantFirst = float64(first)
+			}
+			for i, vars := range requests {
+				if vars["first"] != wantFirst {
+					t.Errorf("request %d: first = %v, want %v", i, vars["first"], wantFirst)
+				}
+			}
+		})
+	}
+}
+
+func TestPaginateStopsWithoutCursor(t *testing.T) {
+	// hasNextPage without an endCursor cannot be followed
+	requests := 0
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"data":{"shop":{"items":{"nodes":[{"id":"1"}],"pageInfo":{"hasNextPage":true,"endCursor":""}}}}}`))
+	}))
+
+	items, err := CollectAll(Paginate[testItem](context.Background(), client, itemsQuery, nil, "shop", "items"))
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if len(items) != 1 || requests != 1 {
+		t.Errorf("got %d items in %d requests, want 1 in 1", len(items), requests)
+	}
+}
+
+func TestPaginateNotFound(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"shop":null}}`))
+	}))
+
+	var errs []error
+	for _, err := range Paginate[testItem](context.Background(), client, itemsQuery, nil, "shop", "items") {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNotFound) {
+		t.Fatalf("yielded %v, want one error wrapping ErrNotFound", errs)
+	}
+}
+
+func TestConnectionItems(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"nodes", `{"nodes":[{"id":"1"},{"id":"2"}]}`, []string{"1", "2"}},
+		{"edges", `{"edges":[{"cursor":"a","node":{"id":"1"}},{"cursor":"b","node":{"id":"2"}}]}`, []string{"1", "2"}},
+		{"empty", `{"edges":[]}`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conn Connection[testItem]
+			if err := json.Unmarshal([]byte(tt.json), &conn); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, item := range conn.Items() {
+				ids = append(ids, item.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("Items = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package app
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultQueryCost is the cost reserved for a GraphQL document before its real cost is known
+const defaultQueryCost = 50
+
+// QueryCost is the extensions.cost block Shopify returns with every GraphQL response
+type QueryCost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ActualQueryCost    float64        `json:"actualQueryCost"`
+	ThrottleStatus     ThrottleStatus `json:"throttleStatus"`
+}
+
+// ThrottleStatus is the state of the shop's GraphQL cost bucket
+type ThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"`
+}
+
+// RateLimiter is a leaky-bucket limiter for one shop. It tracks the GraphQL cost
+// bucket from extensions.cost and the REST bucket from X-Shopify-Shop-Api-Call-Limit,
+// and blocks callers until the bucket has room. Clients of the same shop share one
+// RateLimiter unless one is set explicitly.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	// GraphQL cost bucket (zero maximum means no response seen yet)
+	gqlMaximum     float64
+	gqlAvailable   float64
+	gqlRestoreRate float64
+	gqlUpdated     time.Time
+	// Last requested cost per GraphQL document, used to reserve budget before sending
+	queryCosts map[string]float64
+
+	// REST call bucket (zero maximum means no response seen yet)
+	restMaximum float64
+	restUsed    float64
+	restUpdated time.Time
+	// No REST request is sent before this time (set from Retry-After)
+	restBlockedUntil time.Time
+}
+
+// NewRateLimiter creates an empty limiter. Limits are learned from the first responses.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{queryCosts: make(map[string]float64)}
+}
+
+var (
+	sharedLimitersMu sync.Mutex
+	sharedLimiters   = make(map[string]*RateLimiter)
+)
+
+// SharedRateLimiter returns the limiter shared by all clients of a shop domain
+func SharedRateLimiter(shopDomain string) *RateLimiter {
+	sharedLimitersMu.Lock()
+	defer sharedLimitersMu.Unlock()
+	key := strings.ToLower(shopDomain)
+	l, ok := sharedLimiters[key]
+	if !ok {
+		l = NewRateLimiter()
+		sharedLimiters[key] = l
+	}
+	return l
+}
+
+// refillGraphQL restores the GraphQL bucket for the time elapsed since the last update
+func (l *RateLimiter) refillGraphQL(now time.Time) {
+	if l.gqlMaximum == 0 {
+		return
+	}
+	elapsed := now.Sub(l.gqlUpdated).Seconds()
+	l.gqlAvailable = math.Min(l.gqlMaximum, l.gqlAvailable+elapsed*l.gqlRestoreRate)
+	l.gqlUpdated = now
+}
+
+// WaitGraphQL blocks until the bucket can afford the query and reserves its estimated cost.
+// It returns how long the caller waited, or ctx.Err() if ctx is done first.
+func (l *RateLimiter) WaitGraphQL(ctx context.Context, query string) (time.Duration, error) {
+	var waited time.Duration
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refillGraphQL(now)
+
+		cost, ok := l.queryCosts[query]
+		if !ok {
+			cost = defaultQueryCost
+		}
+		if l.gqlMaximum > 0 && cost > l.gqlMaximum {
+			cost = l.gqlMaximum
+		}
+
+		if l.gqlMaximum == 0 || l.gqlRestoreRate <= 0 || l.gqlAvailable >= cost {
+			if l.gqlMaximum > 0 {
+				l.gqlAvailable -= cost
+			}
+			l.mu.Unlock()
+			return waited, nil
+		}
+
+		wait := time.Duration((cost - l.gqlAvailable) / l.gqlRestoreRate * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return waited, err
+		}
+		waited += wait
+	}
+}
+
+// UpdateGraphQL records the cost block of a GraphQL response
+func (l *RateLimiter) UpdateGraphQL(query string, cost *QueryCost) {
+	if cost == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cost.RequestedQueryCost > 0 {
+		l.queryCosts[query] = cost.RequestedQueryCost
+	}
+	if cost.ThrottleStatus.MaximumAvailable > 0 {
+		l.gqlMaximum = cost.ThrottleStatus.MaximumAvailable
+		l.gqlAvailable = cost.ThrottleStatus.CurrentlyAvailable
+		l.gqlRestoreRate = cost.ThrottleStatus.RestoreRate
+		l.gqlUpdated = time.Now()
+	}
+}
+
+// ThrottleDelay returns how long to back off after a THROTTLED response
+// before the bucket can afford the query again.
+func (l *RateLimiter) ThrottleDelay(query string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillGraphQL(time.Now())
+
+	cost, ok := l.queryCosts[query]
+	if !ok {
+		cost = defaultQueryCost
+	}
+	if l.gqlRestoreRate <= 0 {
+		return time.Second
+	}
+	if l.gqlAvailable >= cost {
+		return 0
+	}
+	return time.Duration((cost - l.gqlAvailable) / l.gqlRestoreRate * float64(time.Second))
+}
+
+// restLeakRate is the REST bucket leak rate in requests per second.
+// Shopify leaks 2/s for a 40-request bucket and 4/s for an 80-request (Plus) bucket.
+func (l *RateLimiter) restLeakRate() float64 {
+	return l.restMaximum / 20
+}
+
+// WaitREST blocks until the REST bucket has room for one more request.
+// It returns how long the caller waited, or ctx.Err() if ctx is done first.
+func (l *RateLimiter) WaitREST(ctx context.Context) (time.Duration, error) {
+	var waited time.Duration
+	for {
+		l.mu.Lock()
+		now := time.Now()
+
+		if now.Before(l.restBlockedUntil) {
+			wait := l.restBlockedUntil.Sub(now)
+			l.mu.Unlock()
+			if err := sleepContext(ctx, wait); err != nil {
+				return waited, err
+			}
+			waited += wait
+			continue
+		}
+
+		if l.restMaximum == 0 {
+			l.mu.Unlock()
+			return waited, nil
+		}
+
+		elapsed := now.Sub(l.restUpdated).Seconds()
+		l.restUsed = math.Max(0, l.restUsed-elapsed*l.restLeakRate())
+		l.restUpdated = now
+
+		if l.restUsed+1 <= l.restMaximum {
+			l.restUsed++
+			l.mu.Unlock()
+			return waited, nil
+		}
+
+		wait := time.Duration((l.restUsed + 1 - l.restMaximum) / l.restLeakRate() * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return waited, err
+		}
+		waited += wait
+	}
+}
+
+// UpdateREST records the X-Shopify-Shop-Api-Call-Limit header ("32/40") of a REST response
+// and, for 429 responses, the Retry-After delay. It returns the delay to wait before retrying.
+func (l *RateLimiter) UpdateREST(statusCode int, header http.Header) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if used, maximum, ok := parseCallLimit(header.Get("X-Shopify-Shop-Api-Call-Limit")); ok {
+		l.restUsed = used
+		l.restMaximum = maximum
+		l.restUpdated = time.Now()
+	}
+
+	if statusCode != http.StatusTooManyRequests {
+		return 0
+	}
+
+	delay := time.Second
+	if seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+		delay = time.Duration(seconds * float64(time.Second))
+	}
+	if until := time.Now().Add(delay); until.After(l.restBlockedUntil) {
+		l.restBlockedUntil = until
+	}
+	return delay
+}
+
+// parseCallLimit parses an X-Shopify-Shop-Api-Call-Limit value such as "32/40"
+func parseCallLimit(value string) (float64, float64, bool) {
+	usedStr, maxStr, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	used, err := strconv.ParseFloat(strings.TrimSpace(usedStr), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	maximum, err := strconv.ParseFloat(strings.TrimSpace(maxStr), 64)
+	if err != nil || maximum <= 0 {
+		return 0, 0, false
+	}
+	return used, maximum, true
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRefillGraphQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		maximum   float64
+		available float64
+		restore   float64
+		elapsed   time.Duration
+		want      float64
+	}{
+		{"restores for elapsed time", 1000, 100, 50, 2 * time.Second, 200},
+		{"capped at maximum", 1000, 990, 50, 2 * time.Second, 1000},
+		{"no time elapsed", 1000, 100, 50, 0, 100},
+		{"unknown bucket stays empty", 0, 0, 0, time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			l := NewRateLimiter()
+			l.gqlMaximum, l.gqlAvailable, l.gqlRestoreRate = tt.maximum, tt.available, tt.restore
+			l.gqlUpdated = now.Add(-tt.elapsed)
+			l.refillGraphQL(now)
+			if l.gqlAvailable != tt.want {
+				t.Errorf("available = %v, want %v", l.gqlAvailable, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitGraphQL(t *testing.T) {
+	const query = "query { shop { name } }"
+	tests := []struct {
+		name string
+		// Cost block of the previous response; nil means none seen yet
+		cost          *QueryCost
+		wantWait      bool
+		wantAvailable float64
+	}{
+		{
+			name:     "no response seen yet",
+			wantWait: false,
+		},
+		{
+			name: "reserves the requested cost",
+			cost: &QueryCost{RequestedQueryCost: 30, ThrottleStatus: ThrottleStatus{
+				MaximumAvailable: 1000, CurrentlyAvailable: 500, RestoreRate: 50}},
+			wantAvailable: 470,
+		},
+		{
+			name: "waits for the bucket to refill",
+			cost: &QueryCost{RequestedQueryCost: 50, ThrottleStatus: ThrottleStatus{
+				MaximumAvailable: 1000, CurrentlyAvailable: 0, RestoreRate: 2000}},
+			wantWait: true,
+		},
+		{
+			name: "cost above the maximum is capped",
+			cost: &QueryCost{RequestedQueryCost: 5000, ThrottleStatus: ThrottleStatus{
+				MaximumAvailable: 1000, CurrentlyAvailable: 1000, RestoreRate: 50}},
+			wantAvailable: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter()
+			l.UpdateGraphQL(query, tt.cost)
+			waited, err := l.WaitGraphQL(context.Background(), query)
+			if err != nil {
+				t.Fatalf("WaitGraphQL: %v", err)
+			}
+			if (waited > 0) != tt.wantWait {
+				t.Errorf("waited %v, want wait %v", waited, tt.wantWait)
+			}
+			if waited > time.Second {
+				t.Errorf("waited %v, want about 25ms", waited)
+			}
+			// The bucket refills a little between UpdateGraphQL and WaitGraphQL
+			if !tt.wantWait && tt.cost != nil && (l.gqlAvailable < tt.wantAvailable || l.gqlAvailable > tt.wantAvailable+1) {
+				t.Errorf("available = %v, want %v", l.gqlAvailable, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestWaitGraphQLContextDone(t *testing.T) {
+	const query = "query { shop { name } }"
+	l := NewRateLimiter()
+	l.UpdateGraphQL(query, &QueryCost{RequestedQueryCost: 100, ThrottleStatus: ThrottleStatus{
+		MaximumAvailable: 1000, CurrentlyAvailable: 0, RestoreRate: 1}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.WaitGraphQL(ctx, query); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitGraphQL = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestThrottleDelay(t *testing.T) {
+	const query = "query { shop { name } }"
+	tests := []struct {
+		name      string
+		cost      *QueryCost
+		wantDelay time.Duration
+	}{
+		{"unknown restore rate", nil, time.Second},
+		{"bucket can afford the query", &QueryCost{RequestedQueryCost: 50, ThrottleStatus: ThrottleStatus{
+			MaximumAvailable: 1000, CurrentlyAvailable: 500, RestoreRate: 50}}, 0},
+		{"until the cost is restored", &QueryCost{RequestedQueryCost: 150, ThrottleStatus: ThrottleStatus{
+			MaximumAvailable: 1000, CurrentlyAvailable: 50, RestoreRate: 50}}, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter()
+			l.UpdateGraphQL(query, tt.cost)
+			delay := l.ThrottleDelay(query)
+			// The bucket refills a little between UpdateGraphQL and ThrottleDelay
+			if delay > tt.wantDelay || delay < tt.wantDelay-50*time.Millisecond {
+				t.Errorf("ThrottleDelay = %v, want %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestWaitREST(t *testing.T) {
+	tests := []struct {
+		name     string
+		maximum  float64
+		used     float64
+		elapsed  time.Duration
+		wantWait bool
+		wantUsed float64
+	}{
+		{"no response seen yet", 0, 0, 0, false, 0},
+		{"room in the bucket", 40, 10, 0, false, 11},
+		{"leaks while idle", 40, 40, 5 * time.Second, false, 31},
+		// 2000 requests leak at 100/s, so the next request waits 10ms
+		{"full bucket waits for a leak", 2000, 2000, 0, true, 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter()
+			l.restMaximum, l.restUsed = tt.maximum, tt.used
+			l.restUpdated = time.Now().Add(-tt.elapsed)
+			waited, err := l.WaitREST(context.Background())
+			if err != nil {
+				t.Fatalf("WaitREST: %v", err)
+			}
+			if (waited > 0) != tt.wantWait {
+				t.Errorf("waited %v, want wait %v", waited, tt.wantWait)
+			}
+			if waited > time.Second {
+				t.Errorf("waited %v, want about 10ms", waited)
+			}
+			if !tt.wantWait && (l.restUsed < tt.wantUsed-0.1 || l.restUsed > tt.wantUsed) {
+				t.Errorf("used = %v, want %v", l.restUsed, tt.wantUsed)
+			}
+		})
+	}
+}
+
+func TestUpdateRESTRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		want       time.Duration
+	}{
+		{"success", http.StatusOK, "", 0},
+		{"throttled with Retry-After", http.StatusTooManyRequests, "0.02", 20 * time.Millisecond},
+		{"throttled without Retry-After", http.StatusTooManyRequests, "", time.Second},
+		{"throttled with invalid Retry-After", http.StatusTooManyRequests, "soon", time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Shopify-Shop-Api-Call-Limit", "40/40")
+			if tt.retryAfter != "" {
+				header.Set("Retry-After", tt.retryAfter)
+			}
+			l := NewRateLimiter()
+			if delay := l.UpdateREST(tt.status, header); delay != tt.want {
+				t.Errorf("UpdateREST = %v, want %v", delay, tt.want)
+			}
+			if l.restMaximum != 40 || l.restUsed != 40 {
+				t.Errorf("bucket = %v/%v, want 40/40", l.restUsed, l.restMaximum)
+			}
+		})
+	}
+}
+
+func TestWaitRESTRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "0.02")
+	l := NewRateLimiter()
+	l.UpdateREST(http.StatusTooManyRequests, header)
+
+	waited, err := l.WaitREST(context.Background())
+	if err != nil {
+		t.Fatalf("WaitREST: %v", err)
+	}
+	if waited <= 0 || waited > 20*time.Millisecond {
+		t.Errorf("waited %v, want up to 20ms", waited)
+	}
+}
+
+func TestParseCallLimit(t *testing.T) {
+	tests := []struct {
+		value     string
+		used, max float64
+		ok        bool
+	}{
+		{"32/40", 32, 40, true},
+		{" 1 / 80 ", 1, 80, true},
+		{"", 0, 0, false},
+		{"32", 0, 0, false},
+		{"x/40", 0, 0, false},
+		{"32/0", 0, 0, false},
+	}
+	for _, tt := range tests {
+		used, maximum, ok := parseCallLimit(tt.value)
+		if used != tt.used || maximum != tt.max || ok != tt.ok {
+			t.Errorf("parseCallLimit(%q) = %v, %v, %v, want %v, %v, %v", tt.value, used, maximum, ok, tt.used, tt.max, tt.ok)
+		}
+	}
+}
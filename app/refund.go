@@ -0,0 +1,798 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ReturnData is a return posted by ConnectPOS, read from JSON the same way as
+// the order payload of cmd/create_order
+type ReturnData struct {
+	Return ReturnOrderData `json:"return"`
+}
+
+// ReturnOrderData describes what is returned from an order and how the money goes back
+type ReturnOrderData struct {
+	// Order GID or numeric ID. When empty the order is looked up by OrderName.
+	OrderID   string           `json:"orderId"`
+	OrderName string           `json:"orderName"`
+	Note      string           `json:"note"`
+	Items     []ReturnItemData `json:"items"`
+	// Refund all the shipping still refundable, or ShippingAmount of it
+	RefundShipping bool  `json:"refundShipping"`
+	ShippingAmount Money `json:"shippingAmount,omitzero"`
+	// Tenders the money goes back to. When empty the refund follows Shopify's suggested transactions.
+	Payments []ReturnPaymentData `json:"payments,omitempty"`
+	// Put the items back in stock, at LocationID when given
+	Restock    bool   `json:"restock"`
+	LocationID string `json:"locationId,omitempty"`
+	// Record a Shopify return (returnCreate) before refunding it. Returned items
+	// must be fulfilled and are restocked by the return's disposition, not by Restock.
+	CreateReturn bool `json:"createReturn"`
+	Notify       bool `json:"notify"`
+}
+
+// ReturnItemData is a returned quantity of a line item. The line item is
+// identified by LineItemID, or else by ProductID (a variant ID, as in the order
+// payload) or SKU.
+type ReturnItemData struct {
+	LineItemID string `json:"lineItemId,omitempty"`
+	ProductID  string `json:"productId,omitempty"`
+	SKU        string `json:"sku,omitempty"`
+	Quantity   int    `json:"quantity"`
+	// One of the ReturnReason constants, used with CreateReturn
+	Reason string `json:"reason,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ReturnPaymentData is an amount refunded to a tender, like an entry of the
+// order payload's payments. PaymentCode is the gateway, e.g. "cash". ParentID
+// is the transaction refunded; when empty it is the payment made with the gateway.
+type ReturnPaymentData struct {
+	PaymentCode string `json:"paymentCode"`
+	PaymentName string `json:"paymentName,omitempty"`
+	Amount      Money  `json:"amount"`
+	ParentID    string `json:"parentId,omitempty"`
+}
+
+// Restock types of a refunded line item (RefundLineItemRestockType)
+const (
+	RestockNone   = "NO_RESTOCK"
+	RestockCancel = "CANCEL"
+	RestockReturn = "RETURN"
+)
+
+// Return reasons of a returned line item (ReturnReason)
+const (
+	ReturnReasonColor          = "COLOR"
+	ReturnReasonDefective      = "DEFECTIVE"
+	ReturnReasonNotAsDescribed = "NOT_AS_DESCRIBED"
+	ReturnReasonSizeTooLarge   = "SIZE_TOO_LARGE"
+	ReturnReasonSizeTooSmall   = "SIZE_TOO_SMALL"
+	ReturnReasonStyle          = "STYLE"
+	ReturnReasonUnwanted       = "UNWANTED"
+	ReturnReasonWrongItem      = "WRONG_ITEM"
+	ReturnReasonOther          = "OTHER"
+	ReturnReasonUnknown        = "UNKNOWN"
+)
+
+// RefundLineItemInput represents a line item to refund (RefundLineItemInput)
+type RefundLineItemInput struct {
+	LineItemID string `json:"lineItemId"`
+	Quantity   int    `json:"quantity"`
+	// One of the Restock constants. Empty means RestockNone.
+	RestockType string `json:"restockType,omitempty"`
+	LocationID  string `json:"locationId,omitempty"`
+}
+
+// RefundTransactionInput represents a refund transaction (OrderTransactionInput)
+type RefundTransactionInput struct {
+	OrderID  string `json:"orderId"`
+	Gateway  string `json:"gateway"`
+	Kind     string `json:"kind"`
+	Amount   Money  `json:"amount"`
+	ParentID string `json:"parentId,omitempty"`
+}
+
+// ShippingRefundInput represents the shipping refunded (ShippingRefundInput)
+type ShippingRefundInput struct {
+	Amount     Money `json:"amount,omitzero"`
+	FullRefund bool  `json:"fullRefund,omitempty"`
+}
+
+// RefundInput represents the input of refundCreate (RefundInput)
+type RefundInput struct {
+	OrderID         string                   `json:"orderId"`
+	Note            string                   `json:"note,omitempty"`
+	Notify          bool                     `json:"notify"`
+	Shipping        *ShippingRefundInput     `json:"shipping,omitempty"`
+	RefundLineItems []RefundLineItemInput    `json:"refundLineItems"`
+	Transactions    []RefundTransactionInput `json:"transactions"`
+}
+
+// SuggestRefundInput selects what SuggestRefund prices
+type SuggestRefundInput struct {
+	RefundLineItems []RefundLineItemInput
+	// Shipping to refund; zero refunds none unless RefundShipping is set
+	ShippingAmount Money
+	// Refund all the shipping still refundable
+	RefundShipping bool
+	// Suggest refunding everything still refundable, ignoring the fields above
+	SuggestFullRefund bool
+}
+
+// SuggestedRefund is Shopify's calculation of a refund, with the transactions
+// that would pay it back to the original payments
+type SuggestedRefund struct {
+	AmountSet            MoneyBag `json:"amountSet"`
+	SubtotalSet          MoneyBag `json:"subtotalSet"`
+	TotalTaxSet          MoneyBag `json:"totalTaxSet"`
+	MaximumRefundableSet MoneyBag `json:"maximumRefundableSet"`
+	Shipping             struct {
+		AmountSet            MoneyBag `json:"amountSet"`
+		MaximumRefundableSet MoneyBag `json:"maximumRefundableSet"`
+	} `json:"shipping"`
+	RefundLineItems       []RefundLineItem       `json:"refundLineItems"`
+	SuggestedTransactions []SuggestedTransaction `json:"suggestedTransactions"`
+}
+
+// SuggestedTransaction is a refund of part of a payment suggested by SuggestRefund
+type SuggestedTransaction struct {
+	Gateway              string   `json:"gateway"`
+	Kind                 string   `json:"kind"`
+	AmountSet            MoneyBag `json:"amountSet"`
+	MaximumRefundableSet MoneyBag `json:"maximumRefundableSet"`
+	ParentTransactionID  string   `json:"parentTransactionId"`
+}
+
+// RefundResult is a refund with the state of its order after it
+type RefundResult struct {
+	Refund           Refund
+	FinancialStatus  string
+	TotalRefundedSet MoneyBag
+}
+
+// ReturnInput represents the input of returnCreate (ReturnInput)
+type ReturnInput struct {
+	OrderID         string                `json:"orderId"`
+	ReturnLineItems []ReturnLineItemInput `json:"returnLineItems"`
+	NotifyCustomer  bool                  `json:"notifyCustomer"`
+}
+
+// ReturnLineItemInput represents a fulfilled quantity to return (ReturnLineItemInput)
+type ReturnLineItemInput struct {
+	FulfillmentLineItemID string `json:"fulfillmentLineItemId"`
+	Quantity              int    `json:"quantity"`
+	// One of the ReturnReason constants
+	ReturnReason     string `json:"returnReason"`
+	ReturnReasonNote string `json:"returnReasonNote,omitempty"`
+	CustomerNote     string `json:"customerNote,omitempty"`
+}
+
+// Return is a return of fulfilled items
+type Return struct {
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	Status          string           `json:"status"`
+	ReturnLineItems []ReturnLineItem `json:"returnLineItems"`
+}
+
+// ReturnLineItem is a quantity of a fulfillment line item being returned
+type ReturnLineItem struct {
+	ID                    string `json:"id"`
+	FulfillmentLineItemID string `json:"fulfillmentLineItemId"`
+	LineItemID            string `json:"lineItemId"`
+	Quantity              int    `json:"quantity"`
+	RefundableQuantity    int    `json:"refundableQuantity"`
+	ReturnReason          string `json:"returnReason"`
+}
+
+// returnNode is a Return as selected by returnFields
+type returnNode struct {
+	Return
+	ReturnLineItems Connection[struct {
+		ID                  string `json:"id"`
+		Quantity            int    `json:"quantity"`
+		RefundableQuantity  int    `json:"refundableQuantity"`
+		ReturnReason        string `json:"returnReason"`
+		FulfillmentLineItem *struct {
+			ID       string `json:"id"`
+			LineItem struct {
+				ID string `json:"id"`
+			} `json:"lineItem"`
+		} `json:"fulfillmentLineItem"`
+	}] `json:"returnLineItems"`
+}
+
+func (node returnNode) ret() Return {
+	ret := node.Return
+	ret.ReturnLineItems = []ReturnLineItem{}
+	for _, item := range node.ReturnLineItems.Items() {
+		line := ReturnLineItem{
+			ID:                 item.ID,
+			Quantity:           item.Quantity,
+			RefundableQuantity: item.RefundableQuantity,
+			ReturnReason:       item.ReturnReason,
+		}
+		if item.FulfillmentLineItem != nil {
+			line.FulfillmentLineItemID = item.FulfillmentLineItem.ID
+			line.LineItemID = item.FulfillmentLineItem.LineItem.ID
+		}
+		ret.ReturnLineItems = append(ret.ReturnLineItems, line)
+	}
+	return ret
+}
+
+// returnFields is the selection of a Return decoded by returnNode
+const returnFields = `
+	id
+	name
+	status
+	returnLineItems(first: 100) {
+		nodes {
+			id
+			quantity
+			refundableQuantity
+			returnReason
+			... on ReturnLineItem {
+				fulfillmentLineItem {
+					id
+					lineItem { id }
+				}
+			}
+		}
+	}`
+
+// RefundReturnInput represents the input of returnRefund (ReturnRefundInput)
+type RefundReturnInput struct {
+	ReturnID              string                         `json:"returnId"`
+	ReturnRefundLineItems []ReturnRefundLineItemInput    `json:"returnRefundLineItems"`
+	RefundShipping        *RefundShippingInput           `json:"refundShipping,omitempty"`
+	OrderTransactions     []ReturnRefundTransactionInput `json:"orderTransactions,omitempty"`
+	NotifyCustomer        bool                           `json:"notifyCustomer"`
+}
+
+// ReturnRefundLineItemInput represents a returned quantity to refund (ReturnRefundLineItemInput)
+type ReturnRefundLineItemInput struct {
+	ReturnLineItemID string `json:"returnLineItemId"`
+	Quantity         int    `json:"quantity"`
+}
+
+// RefundShippingInput represents the shipping refunded with a return (RefundShippingInput)
+type RefundShippingInput struct {
+	ShippingRefundAmount *MoneyInput `json:"shippingRefundAmount,omitempty"`
+	FullShippingRefund   bool        `json:"fullShippingRefund,omitempty"`
+}
+
+// ReturnRefundTransactionInput represents a refund of a payment with a return (ReturnRefundOrderTransactionInput)
+type ReturnRefundTransactionInput struct {
+	TransactionAmount MoneyInput `json:"transactionAmount"`
+	ParentID          string     `json:"parentId"`
+}
+
+// ReturnResult is the outcome of ProcessReturn
+type ReturnResult struct {
+	OrderID   string
+	OrderName string
+	// Set when the return was recorded with CreateReturn
+	Return *Return
+	// Shopify's calculation the refund was based on
+	Suggested *SuggestedRefund
+	// The refund, whose transactions are the new refund transactions
+	Refund           Refund
+	FinancialStatus  string
+	TotalRefundedSet MoneyBag
+}
+
+// SuggestRefund asks Shopify what a refund of line items and shipping comes to,
+// and how it would be split over the order's payments
+func (c *Client) SuggestRefund(ctx context.Context, orderID string, input SuggestRefundInput) (_ *SuggestedRefund, err error) {
+	const query = `
+		query SuggestRefund($id: ID!, $refundLineItems: [RefundLineItemInput!], $shippingAmount: Money,
+			$refundShipping: Boolean, $suggestFullRefund: Boolean) {
+			order(id: $id) {
+				suggestedRefund(refundLineItems: $refundLineItems, shippingAmount: $shippingAmount,
+					refundShipping: $refundShipping, suggestFullRefund: $suggestFullRefund) {
+					amountSet { ` + moneyBagFields + ` }
+					subtotalSet { ` + moneyBagFields + ` }
+					totalTaxSet { ` + moneyBagFields + ` }
+					maximumRefundableSet { ` + moneyBagFields + ` }
+					shipping {
+						amountSet { ` + moneyBagFields + ` }
+						maximumRefundableSet { ` + moneyBagFields + ` }
+					}
+					refundLineItems { ` + refundLineItemFields + ` }
+					suggestedTransactions {
+						gateway
+						kind
+						amountSet { ` + moneyBagFields + ` }
+						maximumRefundableSet { ` + moneyBagFields + ` }
+						parentTransaction { id }
+					}
+				}
+			}
+		}`
+
+	orderID = OrderGID(orderID)
+	ctx, span := c.startSpan(ctx, "SuggestRefund", attribute.String("shopify.order_id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	variables := map[string]interface{}{
+		"id":                orderID,
+		"refundLineItems":   input.RefundLineItems,
+		"shippingAmount":    nil,
+		"refundShipping":    input.RefundShipping,
+		"suggestFullRefund": input.SuggestFullRefund,
+	}
+	if !input.ShippingAmount.IsZero() {
+		variables["shippingAmount"] = input.ShippingAmount
+	}
+
+	var suggested struct {
+		SuggestedRefund
+		RefundLineItems       []refundLineItemNode `json:"refundLineItems"`
+		SuggestedTransactions []struct {
+			SuggestedTransaction
+			ParentTransaction *struct {
+				ID string `json:"id"`
+			} `json:"parentTransaction"`
+		} `json:"suggestedTransactions"`
+	}
+	if err := c.queryPath(ctx, query, variables, []string{"order", "suggestedRefund"}, &suggested); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to suggest refund for %s: %w", orderID, err)
+	}
+
+	result := suggested.SuggestedRefund
+	result.RefundLineItems = []RefundLineItem{}
+	for _, item := range suggested.RefundLineItems {
+		result.RefundLineItems = append(result.RefundLineItems, item.refundLineItem())
+	}
+	result.SuggestedTransactions = []SuggestedTransaction{}
+	for _, t := range suggested.SuggestedTransactions {
+		transaction := t.SuggestedTransaction
+		if t.ParentTransaction != nil {
+			transaction.ParentTransactionID = t.ParentTransaction.ID
+		}
+		result.SuggestedTransactions = append(result.SuggestedTransactions, transaction)
+	}
+	return &result, nil
+}
+
+// refundResultFields is the selection of a refund and its order decoded by refundResultNode
+const refundResultFields = refundFields + `
+	order {
+		displayFinancialStatus
+		totalRefundedSet { ` + moneyBagFields + ` }
+	}`
+
+// refundResultNode is a refund as selected by refundResultFields
+type refundResultNode struct {
+	refundNode
+	Order struct {
+		DisplayFinancialStatus string   `json:"displayFinancialStatus"`
+		TotalRefundedSet       MoneyBag `json:"totalRefundedSet"`
+	} `json:"order"`
+}
+
+// refundResult returns the result of a created refund. The result is returned
+// even with an error, which only means that some of the refund's transactions
+// or line items could not be fetched.
+func (c *Client) refundResult(ctx context.Context, node *refundResultNode) (*RefundResult, error) {
+	refund, err := c.refund(ctx, node.refundNode)
+	return &RefundResult{
+		Refund:           refund,
+		FinancialStatus:  node.Order.DisplayFinancialStatus,
+		TotalRefundedSet: node.Order.TotalRefundedSet,
+	}, err
+}
+
+// CreateRefund refunds line items, shipping and payments of an order with refundCreate.
+// Transactions without an OrderID get the input's order and without a Kind are refunds.
+// If the refund is created but not all of it can be read back, it is returned with the error.
+func (c *Client) CreateRefund(ctx context.Context, input RefundInput) (_ *RefundResult, err error) {
+	const mutation = `
+		mutation RefundCreate($input: RefundInput!) {
+			refundCreate(input: $input) {
+				refund { ` + refundResultFields + ` }
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	input.OrderID = OrderGID(input.OrderID)
+	ctx, span := c.startSpan(ctx, "CreateRefund", attribute.String("shopify.order_id", input.OrderID))
+	defer func() { endSpan(span, err) }()
+
+	if input.RefundLineItems == nil {
+		input.RefundLineItems = []RefundLineItemInput{}
+	}
+	if input.Transactions == nil {
+		input.Transactions = []RefundTransactionInput{}
+	}
+	for i := range input.Transactions {
+		if input.Transactions[i].OrderID == "" {
+			input.Transactions[i].OrderID = input.OrderID
+		}
+		if input.Transactions[i].Kind == "" {
+			input.Transactions[i].Kind = TransactionKindRefund
+		}
+	}
+
+	data, err := Do[struct {
+		RefundCreate struct {
+			Refund *refundResultNode `json:"refund"`
+		} `json:"refundCreate"`
+	}](ctx, c, mutation, map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	if data.RefundCreate.Refund == nil {
+		return nil, fmt.Errorf("refundCreate returned no refund for %s", input.OrderID)
+	}
+	result, err := c.refundResult(ctx, data.RefundCreate.Refund)
+	span.SetAttributes(attribute.String("shopify.refund_id", result.Refund.ID))
+	return result, err
+}
+
+// CreateReturn opens a return of fulfilled items with returnCreate
+func (c *Client) CreateReturn(ctx context.Context, input ReturnInput) (_ *Return, err error) {
+	const mutation = `
+		mutation ReturnCreate($returnInput: ReturnInput!) {
+			returnCreate(returnInput: $returnInput) {
+				return { ` + returnFields + ` }
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	input.OrderID = OrderGID(input.OrderID)
+	ctx, span := c.startSpan(ctx, "CreateReturn", attribute.String("shopify.order_id", input.OrderID))
+	defer func() { endSpan(span, err) }()
+
+	data, err := Do[struct {
+		ReturnCreate struct {
+			Return *returnNode `json:"return"`
+		} `json:"returnCreate"`
+	}](ctx, c, mutation, map[string]interface{}{"returnInput": input})
+	if err != nil {
+		return nil, err
+	}
+	if data.ReturnCreate.Return == nil {
+		return nil, fmt.Errorf("returnCreate returned no return for %s", input.OrderID)
+	}
+	ret := data.ReturnCreate.Return.ret()
+	span.SetAttributes(attribute.String("shopify.return_id", ret.ID))
+	return &ret, nil
+}
+
+// RefundReturn refunds returned items, shipping and payments of a return with returnRefund.
+// If the refund is created but not all of it can be read back, it is returned with the error.
+func (c *Client) RefundReturn(ctx context.Context, input RefundReturnInput) (_ *RefundResult, err error) {
+	const mutation = `
+		mutation ReturnRefund($returnRefundInput: ReturnRefundInput!) {
+			returnRefund(returnRefundInput: $returnRefundInput) {
+				refund { ` + refundResultFields + ` }
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	ctx, span := c.startSpan(ctx, "RefundReturn", attribute.String("shopify.return_id", input.ReturnID))
+	defer func() { endSpan(span, err) }()
+
+	data, err := Do[struct {
+		ReturnRefund struct {
+			Refund *refundResultNode `json:"refund"`
+		} `json:"returnRefund"`
+	}](ctx, c, mutation, map[string]interface{}{"returnRefundInput": input})
+	if err != nil {
+		return nil, err
+	}
+	if data.ReturnRefund.Refund == nil {
+		return nil, fmt.Errorf("returnRefund returned no refund for %s", input.ReturnID)
+	}
+	return c.refundResult(ctx, data.ReturnRefund.Refund)
+}
+
+// ProcessReturn refunds a POS return. The returned items are matched to the
+// order's line items and priced with SuggestRefund; the money goes back to the
+// payload's payments, or as Shopify suggests when it has none. The refund is
+// made with refundCreate, or with returnCreate and returnRefund when
+// CreateReturn is set. When the refund fails after the return was created, or
+// the refund was made but could not be read back in full, the partial result
+// is returned with the error so that a retry does not repeat them.
+func (c *Client) ProcessReturn(ctx context.Context, data ReturnData) (_ *ReturnResult, err error) {
+	in := data.Return
+	ctx, span := c.startSpan(ctx, "ProcessReturn",
+		attribute.String("shopify.order_id", in.OrderID),
+		attribute.String("shopify.order_name", in.OrderName))
+	defer func() { endSpan(span, err) }()
+
+	order, err := c.returnOrder(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("shopify.order_id", order.ID))
+
+	lines, items, err := returnLines(order, in)
+	if err != nil {
+		return nil, err
+	}
+	suggested, err := c.SuggestRefund(ctx, order.ID, SuggestRefundInput{
+		RefundLineItems: lines,
+		ShippingAmount:  in.ShippingAmount,
+		RefundShipping:  in.RefundShipping,
+	})
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := returnTransactions(order, in, suggested)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReturnResult{OrderID: order.ID, OrderName: order.Name, Suggested: suggested}
+	var refund *RefundResult
+	if in.CreateReturn {
+		result.Return, refund, err = c.refundAsReturn(ctx, order, in, lines, items, suggested, transactions)
+	} else {
+		var shipping *ShippingRefundInput
+		if amount := suggested.Shipping.AmountSet.ShopMoney.Amount; amount.IsPositive() {
+			shipping = &ShippingRefundInput{Amount: amount}
+		}
+		refund, err = c.CreateRefund(ctx, RefundInput{
+			OrderID:         order.ID,
+			Note:            in.Note,
+			Notify:          in.Notify,
+			Shipping:        shipping,
+			RefundLineItems: lines,
+			Transactions:    transactions,
+		})
+	}
+	if refund != nil {
+		result.Refund = refund.Refund
+		result.FinancialStatus = refund.FinancialStatus
+		result.TotalRefundedSet = refund.TotalRefundedSet
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to refund return of %s: %w", order.Name, err)
+		if result.Return == nil && refund == nil {
+			return nil, err
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// returnOrder fetches the order of a return with its line items, transactions and fulfillments
+func (c *Client) returnOrder(ctx context.Context, in ReturnOrderData) (*Order, error) {
+	opts := []OrderOption{WithOrderSections(OrderSectionLineItems, OrderSectionTransactions, OrderSectionFulfillments)}
+	if in.OrderID != "" {
+		return c.GetOrder(ctx, in.OrderID, opts...)
+	}
+	if in.OrderName == "" {
+		return nil, fmt.Errorf("return has neither orderId nor orderName")
+	}
+	// The name search is not exact, so "#1001" may also find "#10010"
+	name := in.OrderName
+	if numericID.MatchString(name) {
+		name = "#" + name
+	}
+	for order, err := range c.SearchOrders(ctx, NewOrderQuery().Name(name)) {
+		if err != nil {
+			return nil, err
+		}
+		if order.Name == name {
+			return c.GetOrder(ctx, order.ID, opts...)
+		}
+	}
+	return nil, fmt.Errorf("order %s: %w", name, ErrNotFound)
+}
+
+// returnLines matches the returned items to line items, returning the refund
+// lines with the item each came from. A quantity larger than a line's spills
+// over to the next line with the same variant or SKU.
+func returnLines(order *Order, in ReturnOrderData) ([]RefundLineItemInput, []ReturnItemData, error) {
+	if len(in.Items) == 0 {
+		return nil, nil, fmt.Errorf("return of %s has no items", order.Name)
+	}
+
+	fulfilled := map[string]int{}
+	for _, f := range order.Fulfillments {
+		for _, item := range f.FulfillmentLineItems {
+			fulfilled[item.LineItemID] += item.Quantity
+		}
+	}
+
+	var lines []RefundLineItemInput
+	var items []ReturnItemData
+	taken := map[string]int{}
+	for i, item := range in.Items {
+		left := item.Quantity
+		if left <= 0 {
+			return nil, nil, fmt.Errorf("return item %d of %s has quantity %d", i, order.Name, item.Quantity)
+		}
+		for _, li := range order.LineItems {
+			if left == 0 {
+				break
+			}
+			switch {
+			case item.LineItemID != "":
+				if li.ID != resourceGID("LineItem", item.LineItemID) {
+					continue
+				}
+			case item.ProductID != "":
+				if li.Variant == nil || li.Variant.ID != resourceGID("ProductVariant", item.ProductID) {
+					continue
+				}
+			case item.SKU != "":
+				if li.SKU != item.SKU {
+					continue
+				}
+			default:
+				return nil, nil, fmt.Errorf("return item %d of %s has no lineItemId, productId or sku", i, order.Name)
+			}
+			n := min(left, li.CurrentQuantity-taken[li.ID])
+			if n <= 0 {
+				continue
+			}
+			taken[li.ID] += n
+			left -= n
+
+			line := RefundLineItemInput{LineItemID: li.ID, Quantity: n, RestockType: RestockNone}
+			if in.Restock && !in.CreateReturn {
+				line.RestockType = RestockReturn
+				if taken[li.ID] > fulfilled[li.ID] {
+					line.RestockType = RestockCancel
+				}
+				line.LocationID = resourceGID("Location", in.LocationID)
+			}
+			lines = append(lines, line)
+			items = append(items, item)
+		}
+		if left > 0 {
+			return nil, nil, fmt.Errorf("return item %d of %s: %d more than the order has left to refund", i, order.Name, left)
+		}
+	}
+	return lines, items, nil
+}
+
+// returnTransactions returns the refund transactions of a return: one per
+// payment of the payload, or Shopify's suggested transactions
+func returnTransactions(order *Order, in ReturnOrderData, suggested *SuggestedRefund) ([]RefundTransactionInput, error) {
+	transactions := []RefundTransactionInput{}
+	if len(in.Payments) == 0 {
+		for _, t := range suggested.SuggestedTransactions {
+			if !t.AmountSet.ShopMoney.Amount.IsPositive() {
+				continue
+			}
+			transactions = append(transactions, RefundTransactionInput{
+				OrderID:  order.ID,
+				Gateway:  t.Gateway,
+				Kind:     TransactionKindRefund,
+				Amount:   t.AmountSet.ShopMoney.Amount,
+				ParentID: t.ParentTransactionID,
+			})
+		}
+		return transactions, nil
+	}
+
+	var total Money
+	for i, payment := range in.Payments {
+		parentID := payment.ParentID
+		if parentID == "" {
+			parentID = refundParent(order, payment.PaymentCode)
+		}
+		if parentID == "" {
+			return nil, fmt.Errorf("payment %d of the return of %s: no %s payment to refund", i, order.Name, payment.PaymentCode)
+		}
+		if err := total.CheckCurrency(payment.Amount); err != nil {
+			return nil, fmt.Errorf("payment %d of the return of %s: %w", i, order.Name, err)
+		}
+		total = total.Add(payment.Amount)
+		transactions = append(transactions, RefundTransactionInput{
+			OrderID:  order.ID,
+			Gateway:  payment.PaymentCode,
+			Kind:     TransactionKindRefund,
+			Amount:   payment.Amount,
+			ParentID: resourceGID("OrderTransaction", parentID),
+		})
+	}
+	maximum := suggested.MaximumRefundableSet.ShopMoney.Amount
+	if err := total.CheckCurrency(maximum); err != nil {
+		return nil, fmt.Errorf("return of %s: payments and refundable amount: %w", order.Name, err)
+	}
+	if total.Cmp(maximum) > 0 {
+		return nil, fmt.Errorf("return of %s refunds %s but only %s is refundable", order.Name, total.StringFixed(), maximum.StringFixed())
+	}
+	return transactions, nil
+}
+
+// refundParent returns the successful sale or capture made with the gateway, or ""
+func refundParent(order *Order, gateway string) string {
+	for _, t := range order.Transactions {
+		kind := strings.ToUpper(t.Kind)
+		if strings.EqualFold(t.Status, "SUCCESS") && (kind == TransactionKindSale || kind == TransactionKindCapture) && t.Gateway == gateway {
+			return t.ID
+		}
+	}
+	return ""
+}
+
+// refundAsReturn records the return of fulfilled items and refunds it
+func (c *Client) refundAsReturn(ctx context.Context, order *Order, in ReturnOrderData, lines []RefundLineItemInput,
+	items []ReturnItemData, suggested *SuggestedRefund, transactions []RefundTransactionInput) (*Return, *RefundResult, error) {
+	input := ReturnInput{OrderID: order.ID, NotifyCustomer: in.Notify}
+	returned := map[string]int{}
+	for i, line := range lines {
+		item := items[i]
+		reason := item.Reason
+		if reason == "" {
+			reason = ReturnReasonUnknown
+		}
+		left := line.Quantity
+		for _, f := range order.Fulfillments {
+			for _, fli := range f.FulfillmentLineItems {
+				if fli.LineItemID != line.LineItemID || left == 0 {
+					continue
+				}
+				n := min(left, fli.Quantity-returned[fli.ID])
+				if n <= 0 {
+					continue
+				}
+				returned[fli.ID] += n
+				left -= n
+				input.ReturnLineItems = append(input.ReturnLineItems, ReturnLineItemInput{
+					FulfillmentLineItemID: fli.ID,
+					Quantity:              n,
+					ReturnReason:          reason,
+					CustomerNote:          item.Note,
+				})
+			}
+		}
+		if left > 0 {
+			return nil, nil, fmt.Errorf("%d of line item %s of %s are not fulfilled and cannot be returned", left, line.LineItemID, order.Name)
+		}
+	}
+
+	ret, err := c.CreateReturn(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refundInput := RefundReturnInput{ReturnID: ret.ID, NotifyCustomer: in.Notify}
+	for _, item := range ret.ReturnLineItems {
+		refundInput.ReturnRefundLineItems = append(refundInput.ReturnRefundLineItems, ReturnRefundLineItemInput{
+			ReturnLineItemID: item.ID,
+			Quantity:         item.Quantity,
+		})
+	}
+	shipping := suggested.Shipping.AmountSet.ShopMoney
+	if shipping.Amount.IsPositive() {
+		refundInput.RefundShipping = &RefundShippingInput{
+			ShippingRefundAmount: &MoneyInput{Amount: shipping.Amount, CurrencyCode: shipping.CurrencyCode},
+		}
+	}
+	currency := suggested.AmountSet.ShopMoney.CurrencyCode
+	for _, t := range transactions {
+		refundInput.OrderTransactions = append(refundInput.OrderTransactions, ReturnRefundTransactionInput{
+			TransactionAmount: MoneyInput{Amount: t.Amount, CurrencyCode: currency},
+			ParentID:          t.ParentID,
+		})
+	}
+
+	refund, err := c.RefundReturn(ctx, refundInput)
+	return ret, refund, err
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownStore is returned when a POS storeId has no shop in the registry
+var ErrUnknownStore = errors.New("unknown POS store")
+
+// ShopConfig maps a ConnectPOS storeId to the Admin API credentials of a shop
+type ShopConfig struct {
+	// The POS store ID (storeId in ConnectPOS payloads)
+	StoreID string `json:"storeId" yaml:"storeId"`
+	// The shop's myshopify domain
+	ShopDomain string `json:"shopDomain" yaml:"shopDomain"`
+	// Admin API access token. Leave empty and set AccessTokenEnv to keep tokens out of the file.
+	AccessToken string `json:"accessToken,omitempty" yaml:"accessToken,omitempty"`
+	// Name of an environment variable holding the access token
+	AccessTokenEnv string `json:"accessTokenEnv,omitempty" yaml:"accessTokenEnv,omitempty"`
+	// Admin API version. Empty means ConfiguredAPIVersion().
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+}
+
+// shopsFile is the on-disk layout of a registry file (YAML or JSON)
+type shopsFile struct {
+	Shops []ShopConfig `json:"shops" yaml:"shops"`
+}
+
+// ShopRegistry resolves POS store IDs to per-shop clients.
+// Clients are created on first use and reused afterwards.
+type ShopRegistry struct {
+	mu      sync.RWMutex
+	shops   map[string]ShopConfig
+	clients map[string]*Client
+	opts    []ClientOption
+}
+
+// NewShopRegistry creates a registry from shop configs.
+// opts are applied to every client the registry creates.
+func NewShopRegistry(shops []ShopConfig, opts ...ClientOption) (*ShopRegistry, error) {
+	r := &ShopRegistry{
+		shops:   make(map[string]ShopConfig),
+		clients: make(map[string]*Client),
+		opts:    opts,
+	}
+	for _, shop := range shops {
+		if err := r.Register(shop); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadShopRegistry reads a registry file. Files ending in .json are parsed as JSON,
+// everything else as YAML. Both use a top-level "shops" list.
+func LoadShopRegistry(path string, opts ...ClientOption) (*ShopRegistry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read shop registry: %w", err)
+	}
+
+	var file shopsFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &file)
+	} else {
+		err = yaml.Unmarshal(content, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid shop registry %s: %w", path, err)
+	}
+
+	return NewShopRegistry(file.Shops, opts...)
+}
+
+// Register adds or replaces the shop for cfg.StoreID
+func (r *ShopRegistry) Register(cfg ShopConfig) error {
+	if cfg.StoreID == "" {
+		return fmt.Errorf("shop registry entry for %q has no storeId", cfg.ShopDomain)
+	}
+	if cfg.ShopDomain == "" {
+		return fmt.Errorf("shop registry entry for store %s has no shopDomain", cfg.StoreID)
+	}
+	if cfg.AccessToken == "" && cfg.AccessTokenEnv != "" {
+		cfg.AccessToken = os.Getenv(cfg.AccessTokenEnv)
+	}
+	if cfg.AccessToken == "" {
+		return fmt.Errorf("shop registry entry for store %s has no access token", cfg.StoreID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shops[cfg.StoreID] = cfg
+	delete(r.clients, cfg.StoreID)
+	return nil
+}
+
+// Shop returns the config registered for a store
+func (r *ShopRegistry) Shop(storeID string) (ShopConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cfg, ok := r.shops[storeID]
+	return cfg, ok
+}
+
+// StoreIDs returns the registered store IDs in sorted order
+func (r *ShopRegistry) StoreIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.shops))
+	for id := range r.shops {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Client returns the client for a POS store, creating it on first use
+func (r *ShopRegistry) Client(storeID string) (*Client, error) {
+	r.mu.RLock()
+	c, ok := r.clients[storeID]
+	r.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.clients[storeID]; ok {
+		return c, nil
+	}
+
+	cfg, ok := r.shops[storeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStore, storeID)
+	}
+
+	opts := append([]ClientOption{}, r.opts...)
+	if cfg.APIVersion != "" {
+		opts = append(opts, WithAPIVersion(cfg.APIVersion))
+	}
+	c = NewClient(cfg.ShopDomain, cfg.AccessToken, opts...)
+	r.clients[storeID] = c
+	return c, nil
+}
+
+var (
+	defaultRegistryMu sync.Mutex
+	defaultRegistry   *ShopRegistry
+)
+
+// SetDefaultRegistry sets the registry used by ClientForStore.
+// Passing nil makes ClientForStore load SHOPIFY_SHOPS_FILE again.
+func SetDefaultRegistry(r *ShopRegistry) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	defaultRegistry = r
+}
+
+// DefaultRegistry returns the registry set with SetDefaultRegistry, or loads the file
+// named by SHOPIFY_SHOPS_FILE. It returns nil, nil when neither is configured.
+// Its clients get the same cassette and telemetry options as NewClientFromEnv.
+func DefaultRegistry() (*ShopRegistry, error) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	if defaultRegistry != nil {
+		return defaultRegistry, nil
+	}
+
+	path := os.Getenv("SHOPIFY_SHOPS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	r, err := LoadShopRegistry(path, envClientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	defaultRegistry = r
+	return r, nil
+}
+
+// ClientForStore resolves the client for a POS storeId using the default registry.
+// When no registry is configured, the default client is returned so single-shop
+// setups that only use SHOPIFY_SHOP_DOMAIN/SHOPIFY_API_SECRET keep working.
+func ClientForStore(storeID string) (*Client, error) {
+	r, err := DefaultRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return DefaultClient(), nil
+	}
+	return r.Client(storeID)
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultRegistryUsesEnvOptions(t *testing.T) {
+	dir := t.TempDir()
+	shops := filepath.Join(dir, "shops.yaml")
+	content := "shops:\n  - storeId: \"1\"\n    shopDomain: first.myshopify.com\n    accessToken: token\n"
+	if err := os.WriteFile(shops, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHOPIFY_SHOPS_FILE", shops)
+	t.Setenv("SHOPIFY_CASSETTE", filepath.Join(dir, "cassette.json"))
+	t.Setenv("SHOPIFY_CASSETTE_MODE", "record")
+	SetDefaultRegistry(nil)
+	t.Cleanup(func() { SetDefaultRegistry(nil) })
+
+	c, err := ClientForStore("1")
+	if err != nil {
+		t.Fatalf("ClientForStore: %v", err)
+	}
+	if c.ShopDomain != "first.myshopify.com" {
+		t.Errorf("shop domain = %q, want first.myshopify.com", c.ShopDomain)
+	}
+	if _, ok := c.HTTPClient.Transport.(*Cassette); !ok {
+		t.Errorf("transport = %T, want the SHOPIFY_CASSETTE cassette", c.HTTPClient.Transport)
+	}
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults used by DefaultRetryPolicy
+const (
+	DefaultMaxAttempts         = 6
+	DefaultInitialInterval     = 500 * time.Millisecond
+	DefaultMaxInterval         = 30 * time.Second
+	DefaultMultiplier          = 2.0
+	DefaultRandomizationFactor = 0.5
+	DefaultMaxElapsedTime      = 2 * time.Minute
+)
+
+// RetryAttempt describes a failed attempt passed to a RetryPolicy
+type RetryAttempt struct {
+	// GraphQL operation name or REST "METHOD path" of the call
+	Operation string
+	// 1-based number of the attempt that failed
+	Attempt int
+	// Time since the first attempt was sent
+	Elapsed time.Duration
+	// Why the attempt failed: a network error, *HTTPStatusError or *GraphQLErrors
+	Err error
+	// Whether the call can be repeated without side effects: reads, PUT/DELETE,
+	// and mutations sent with an idempotency key
+	Idempotent bool
+	// Minimum wait suggested by Shopify (Retry-After or the GraphQL cost bucket), or 0
+	RetryAfter time.Duration
+}
+
+// RetryPolicy decides whether a failed Admin API call is sent again and how long to wait first
+type RetryPolicy interface {
+	// Retry returns the delay before the next attempt, or false to give up and return the error
+	Retry(attempt RetryAttempt) (time.Duration, bool)
+}
+
+// ExponentialBackoff retries retryable failures with exponentially growing, jittered delays.
+//
+// Throttled calls (HTTP 429, THROTTLED) are always retried because Shopify rejected them
+// before running them. 5xx responses and network errors are retried only for idempotent
+// calls, since a mutation may have been applied even though the response was lost.
+type ExponentialBackoff struct {
+	// Maximum number of attempts including the first one. Zero means DefaultMaxAttempts.
+	MaxAttempts int
+	// Delay after the first failure. Zero means DefaultInitialInterval.
+	InitialInterval time.Duration
+	// Upper bound for a single delay (before jitter). Zero means DefaultMaxInterval.
+	MaxInterval time.Duration
+	// Growth factor between delays. Zero means DefaultMultiplier.
+	Multiplier float64
+	// Each delay is picked at random within ±RandomizationFactor of its nominal value
+	RandomizationFactor float64
+	// Give up when the next attempt would start later than this after the first one.
+	// Zero means no limit.
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by clients that do not set one
+func DefaultRetryPolicy() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxAttempts:         DefaultMaxAttempts,
+		InitialInterval:     DefaultInitialInterval,
+		MaxInterval:         DefaultMaxInterval,
+		Multiplier:          DefaultMultiplier,
+		RandomizationFactor: DefaultRandomizationFactor,
+		MaxElapsedTime:      DefaultMaxElapsedTime,
+	}
+}
+
+// Retry implements RetryPolicy
+func (b *ExponentialBackoff) Retry(attempt RetryAttempt) (time.Duration, bool) {
+	if !IsRetryable(attempt.Err) {
+		return 0, false
+	}
+	if !attempt.Idempotent && !IsThrottled(attempt.Err) {
+		return 0, false
+	}
+
+	maxAttempts := b.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if attempt.Attempt >= maxAttempts {
+		return 0, false
+	}
+
+	delay := max(b.Delay(attempt.Attempt), attempt.RetryAfter)
+	if b.MaxElapsedTime > 0 && attempt.Elapsed+delay > b.MaxElapsedTime {
+		return 0, false
+	}
+	return delay, true
+}
+
+// Delay returns the jittered delay after the n-th failed attempt (1-based)
+func (b *ExponentialBackoff) Delay(n int) time.Duration {
+	initial := b.InitialInterval
+	if initial == 0 {
+		initial = DefaultInitialInterval
+	}
+	maxInterval := b.MaxInterval
+	if maxInterval == 0 {
+		maxInterval = DefaultMaxInterval
+	}
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = DefaultMultiplier
+	}
+
+	nominal := float64(initial) * math.Pow(multiplier, float64(max(n-1, 0)))
+	nominal = math.Min(nominal, float64(maxInterval))
+	if b.RandomizationFactor > 0 {
+		delta := b.RandomizationFactor * nominal
+		nominal = nominal - delta + rand.Float64()*2*delta
+	}
+	return time.Duration(nominal)
+}
+
+// noRetry is the policy behind NoRetry
+type noRetry struct{}
+
+func (noRetry) Retry(RetryAttempt) (time.Duration, bool) { return 0, false }
+
+// NoRetry is a RetryPolicy that never retries
+var NoRetry RetryPolicy = noRetry{}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// HTTP 429 and 5xx, THROTTLED and INTERNAL_SERVER_ERROR GraphQL errors, timeouts
+// and connection errors. Cancelled contexts are never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsThrottled(err) {
+		return true
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var gqlErrs *GraphQLErrors
+	if errors.As(err, &gqlErrs) {
+		return gqlErrs.HasCode(ErrorCodeInternalError)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// idempotencyKeyCtxKey is the context key of the idempotency key
+type idempotencyKeyCtxKey struct{}
+
+// WithIdempotencyKey returns a context whose Admin API calls carry key in the
+// Idempotency-Key header. Calls made with a key are retried like reads.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtxKey{}, key)
+}
+
+// IdempotencyKey returns the key set with WithIdempotencyKey, or ""
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtxKey{}).(string)
+	return key
+}
+
+// restIdempotent reports whether a REST call can be retried after a 5xx or network error
+func restIdempotent(ctx context.Context, method string) bool {
+	if IdempotencyKey(ctx) != "" {
+		return true
+	}
+	return method != http.MethodPost && method != http.MethodPatch
+}
+
+// graphQLIdempotent reports whether a GraphQL document can be retried after a 5xx or
+// network error: queries always, mutations only with an idempotency key
+// (from the context or an @idempotent directive in the document)
+func graphQLIdempotent(ctx context.Context, query string) bool {
+	if IdempotencyKey(ctx) != "" || strings.Contains(query, "@idempotent") {
+		return true
+	}
+	return !strings.HasPrefix(strings.TrimSpace(query), "mutation")
+}
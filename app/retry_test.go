@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffDelay(t *testing.T) {
+	b := &ExponentialBackoff{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+	}
+	want := []time.Duration{
+		500 * time.Millisecond,
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	}
+	for i, w := range want {
+		if got := b.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	b := &ExponentialBackoff{InitialInterval: time.Second, RandomizationFactor: 0.5}
+	for range 100 {
+		if got := b.Delay(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("Delay(2) = %v, want within 2s ± 50%%", got)
+		}
+	}
+}
+
+func TestExponentialBackoffRetry(t *testing.T) {
+	serverError := &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+	throttled := &HTTPStatusError{StatusCode: http.StatusTooManyRequests}
+	b := &ExponentialBackoff{
+		MaxAttempts:     3,
+		InitialInterval: time.Second,
+		MaxElapsedTime:  10 * time.Second,
+	}
+	tests := []struct {
+		name      string
+		attempt   RetryAttempt
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{"idempotent 5xx", RetryAttempt{Attempt: 1, Err: serverError, Idempotent: true}, time.Second, true},
+		{"non-idempotent 5xx", RetryAttempt{Attempt: 1, Err: serverError}, 0, false},
+		{"non-idempotent throttled", RetryAttempt{Attempt: 1, Err: throttled}, time.Second, true},
+		{"network error", RetryAttempt{Attempt: 2, Err: io.ErrUnexpectedEOF, Idempotent: true}, 2 * time.Second, true},
+		{"client error", RetryAttempt{Attempt: 1, Err: &HTTPStatusError{StatusCode: http.StatusUnprocessableEntity}, Idempotent: true}, 0, false},
+		{"cancelled", RetryAttempt{Attempt: 1, Err: context.Canceled, Idempotent: true}, 0, false},
+		{"last attempt", RetryAttempt{Attempt: 3, Err: serverError, Idempotent: true}, 0, false},
+		{"Retry-After longer than backoff", RetryAttempt{Attempt: 1, Err: throttled, RetryAfter: 3 * time.Second}, 3 * time.Second, true},
+		{"past max elapsed time", RetryAttempt{Attempt: 1, Err: serverError, Idempotent: true, Elapsed: 9500 * time.Millisecond}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := b.Retry(tt.attempt)
+			if delay != tt.wantDelay || retry != tt.wantRetry {
+				t.Errorf("Retry = %v, %v, want %v, %v", delay, retry, tt.wantDelay, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&HTTPStatusError{StatusCode: http.StatusInternalServerError}, true},
+		{&HTTPStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{&HTTPStatusError{StatusCode: http.StatusNotFound}, false},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{context.Canceled, false},
+		{errors.New("invalid input"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGraphQLIdempotent(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		want  bool
+	}{
+		{"query", ctx, "query { shop { name } }", true},
+		{"shorthand query", ctx, "{ shop { name } }", true},
+		{"mutation", ctx, "\n\tmutation { orderCreate { order { id } } }", false},
+		{"mutation with key", WithIdempotencyKey(ctx, "cart-1"), "mutation { orderCreate { order { id } } }", true},
+		{"@idempotent mutation", ctx, "mutation Pay @idempotent { orderMarkAsPaid { order { id } } }", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphQLIdempotent(tt.ctx, tt.query); got != tt.want {
+				t.Errorf("graphQLIdempotent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// unavailableServer answers every request with 503 and counts them
+func unavailableServer(t *testing.T) (*Client, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	return client, &requests
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name         string
+		call         func(c *Client) error
+		wantRequests int32
+	}{
+		{"GraphQL query is retried", func(c *Client) error {
+			_, err := c.postGraphQL(ctx, "query { shop { name } }", nil)
+			return err
+		}, 3},
+		{"GraphQL mutation is not retried", func(c *Client) error {
+			_, err := c.postGraphQL(ctx, "mutation { orderCreate { order { id } } }", nil)
+			return err
+		}, 1},
+		{"GraphQL mutation with idempotency key is retried", func(c *Client) error {
+			_, err := c.postGraphQL(WithIdempotencyKey(ctx, "cart-1"), "mutation { orderCreate { order { id } } }", nil)
+			return err
+		}, 3},
+		{"REST GET is retried", func(c *Client) error {
+			_, _, err := c.doREST(ctx, http.MethodGet, "orders/1.json", nil)
+			return err
+		}, 3},
+		{"REST POST is not retried", func(c *Client) error {
+			_, _, err := c.doREST(ctx, http.MethodPost, "orders.json", map[string]string{})
+			return err
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := unavailableServer(t)
+			err := tt.call(client)
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", n, tt.wantRequests)
+			}
+			var statusErr *HTTPStatusError
+			if err != nil && !errors.As(err, &statusErr) {
+				t.Errorf("error = %v, want *HTTPStatusError", err)
+			}
+		})
+	}
+}
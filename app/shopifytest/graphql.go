@@ -0,0 +1,492 @@
+package shopifytest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file holds a small GraphQL executor: enough of the language to run the
+// documents the app sends (operations, variables, arguments, aliases and inline
+// fragments), projecting in-memory objects onto the selected fields so responses
+// have the same shape as the real Admin API.
+
+// object is a GraphQL object value. Field values are scalars, objects, lists or
+// a resolver for fields that take arguments (connections, metafield(key:), ...).
+type object map[string]interface{}
+
+// resolver computes a field value from the field's arguments
+type resolver func(args map[string]interface{}) (interface{}, error)
+
+// selection is a field or an inline fragment (Name is empty) of a selection set
+type selection struct {
+	Alias         string
+	Name          string
+	Args          map[string]interface{}
+	TypeCondition string
+	Selections    []*selection
+}
+
+// key returns the response key of a field
+func (s *selection) key() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// operation is a parsed GraphQL document with its variables substituted
+type operation struct {
+	Type       string // "query" or "mutation"
+	Name       string
+	Selections []*selection
+}
+
+// gqlError is a top-level GraphQL error
+type gqlError struct {
+	Message string
+	Code    string
+}
+
+func (e *gqlError) Error() string {
+	return e.Message
+}
+
+// parser is a recursive-descent parser over the characters of a document
+type parser struct {
+	src       string
+	pos       int
+	variables map[string]interface{}
+}
+
+// parseOperation parses the first operation of a document
+func parseOperation(src string, variables map[string]interface{}) (op *operation, err error) {
+	p := &parser{src: src, variables: variables}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*gqlError)
+			if !ok {
+				panic(r)
+			}
+			op, err = nil, perr
+		}
+	}()
+
+	op = &operation{Type: "query"}
+	p.skipIgnored()
+	if !p.peek('{') {
+		op.Type = p.name()
+		if op.Type != "query" && op.Type != "mutation" {
+			p.fail("unsupported operation type %q", op.Type)
+		}
+		p.skipIgnored()
+		if p.isNameStart() {
+			op.Name = p.name()
+		}
+		p.skipIgnored()
+		if p.peek('(') {
+			p.skipBalanced('(', ')')
+		}
+		p.directives()
+	}
+	op.Selections = p.selectionSet()
+	return op, nil
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(&gqlError{
+		Message: fmt.Sprintf("Parse error at offset %d: %s", p.pos, fmt.Sprintf(format, args...)),
+		Code:    "syntaxError",
+	})
+}
+
+// skipIgnored skips whitespace, commas and comments
+func (p *parser) skipIgnored() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ',' || unicode.IsSpace(rune(c)) || c == 0xEF || c == 0xBB || c == 0xBF:
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) peek(c byte) bool {
+	p.skipIgnored()
+	return p.pos < len(p.src) && p.src[p.pos] == c
+}
+
+func (p *parser) expect(c byte) {
+	if !p.peek(c) {
+		p.fail("expected %q", c)
+	}
+	p.pos++
+}
+
+func (p *parser) isNameStart() bool {
+	if p.pos >= len(p.src) {
+		return false
+	}
+	c := p.src[p.pos]
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *parser) name() string {
+	p.skipIgnored()
+	if !p.isNameStart() {
+		p.fail("expected a name")
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// skipBalanced skips a bracketed group such as variable definitions
+func (p *parser) skipBalanced(open, close byte) {
+	depth := 0
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '"':
+			p.stringValue()
+			continue
+		case c == open:
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				p.pos++
+				return
+			}
+		}
+		p.pos++
+	}
+	p.fail("unterminated %q", open)
+}
+
+// directives parses the directives of a selection and reports whether it is included.
+// @include(if:) and @skip(if:) are applied; others such as @idempotent are ignored.
+func (p *parser) directives() bool {
+	included := true
+	for p.peek('@') {
+		p.pos++
+		name := p.name()
+		args := map[string]interface{}{}
+		if p.peek('(') {
+			p.pos++
+			for !p.peek(')') {
+				argName := p.name()
+				p.expect(':')
+				args[argName] = p.value()
+			}
+			p.pos++
+		}
+		condition, _ := args["if"].(bool)
+		if (name == "include" && !condition) || (name == "skip" && condition) {
+			included = false
+		}
+	}
+	return included
+}
+
+func (p *parser) selectionSet() []*selection {
+	p.expect('{')
+	var selections []*selection
+	for !p.peek('}') {
+		if p.pos >= len(p.src) {
+			p.fail("unterminated selection set")
+		}
+		if s := p.selection(); s != nil {
+			selections = append(selections, s)
+		}
+	}
+	p.pos++
+	return selections
+}
+
+// selection parses a field or inline fragment; it returns nil when a directive excludes it
+func (p *parser) selection() *selection {
+	if strings.HasPrefix(p.src[p.pos:], "...") {
+		p.pos += 3
+		if p.name() != "on" {
+			p.fail("fragment spreads are not supported, use inline fragments")
+		}
+		s := &selection{TypeCondition: p.name()}
+		included := p.directives()
+		s.Selections = p.selectionSet()
+		if !included {
+			return nil
+		}
+		return s
+	}
+
+	s := &selection{Name: p.name()}
+	if p.peek(':') {
+		p.pos++
+		s.Alias = s.Name
+		s.Name = p.name()
+	}
+	s.Args = map[string]interface{}{}
+	if p.peek('(') {
+		p.pos++
+		for !p.peek(')') {
+			argName := p.name()
+			p.expect(':')
+			s.Args[argName] = p.value()
+		}
+		p.pos++
+	}
+	included := p.directives()
+	if p.peek('{') {
+		s.Selections = p.selectionSet()
+	}
+	if !included {
+		return nil
+	}
+	return s
+}
+
+// value parses an argument value, substituting variables
+func (p *parser) value() interface{} {
+	p.skipIgnored()
+	if p.pos >= len(p.src) {
+		p.fail("expected a value")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '$':
+		p.pos++
+		return p.variables[p.name()]
+	case c == '"':
+		return p.stringValue()
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for !p.peek(']') {
+			list = append(list, p.value())
+		}
+		p.pos++
+		return list
+	case c == '{':
+		p.pos++
+		obj := map[string]interface{}{}
+		for !p.peek('}') {
+			field := p.name()
+			p.expect(':')
+			obj[field] = p.value()
+		}
+		p.pos++
+		return obj
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			p.fail("invalid number %q", p.src[start:p.pos])
+		}
+		return n
+	default:
+		switch word := p.name(); word {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		default:
+			// Enum values are passed on as strings
+			return word
+		}
+	}
+}
+
+func (p *parser) stringValue() string {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return strings.TrimSpace(s)
+	}
+
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.fail("unterminated string")
+	}
+	p.pos++
+	s, err := strconv.Unquote(p.src[start:p.pos])
+	if err != nil {
+		p.fail("invalid string %s", p.src[start:p.pos])
+	}
+	return s
+}
+
+// project returns the part of v selected by selections. Lists are projected
+// element by element and resolvers are called with the field's arguments.
+func project(v interface{}, selections []*selection) (interface{}, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case object:
+		if value == nil {
+			return nil, nil
+		}
+		out := make(map[string]interface{}, len(selections))
+		if err := projectInto(out, value, selections); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case []object:
+		out := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			projected, err := project(item, selections)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, projected)
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			projected, err := project(item, selections)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, projected)
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func projectInto(out map[string]interface{}, obj object, selections []*selection) error {
+	for _, s := range selections {
+		if s.Name == "" {
+			typeName, _ := obj["__typename"].(string)
+			if typeName == "" || typeName == s.TypeCondition {
+				if err := projectInto(out, obj, s.Selections); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value, ok := obj[s.Name]
+		if !ok {
+			typeName, _ := obj["__typename"].(string)
+			return &gqlError{
+				Message: fmt.Sprintf("Field '%s' doesn't exist on type '%s'", s.Name, typeName),
+				Code:    "undefinedField",
+			}
+		}
+		if r, ok := value.(resolver); ok {
+			var err error
+			if value, err = r(s.Args); err != nil {
+				return err
+			}
+		}
+		projected, err := project(value, s.Selections)
+		if err != nil {
+			return err
+		}
+		out[s.key()] = projected
+	}
+	return nil
+}
+
+// requestedCost computes the requested cost of an operation the way the Admin
+// API does: scalars are free, an object costs 1 plus its fields, a connection or
+// a list sized by first or last costs 2 plus the size times the cost of one
+// item, and each mutation adds 10.
+func requestedCost(op *operation) int {
+	cost := selectionsCost(op.Selections)
+	if op.Type == "mutation" {
+		cost += 10 * len(op.Selections)
+	}
+	return cost
+}
+
+// selectionsCost returns the cost of a selection set. Inline fragments are
+// alternatives, so only the most expensive one counts.
+func selectionsCost(selections []*selection) int {
+	cost, fragment := 0, 0
+	for _, s := range selections {
+		if s.Name == "" {
+			fragment = max(fragment, selectionsCost(s.Selections))
+			continue
+		}
+		cost += fieldCost(s)
+	}
+	return cost + fragment
+}
+
+func fieldCost(s *selection) int {
+	if len(s.Selections) == 0 {
+		return 0
+	}
+	size, ok := pageSize(s.Args)
+	if !ok {
+		return 1 + selectionsCost(s.Selections)
+	}
+	return 2 + size*itemCost(s.Selections)
+}
+
+// itemCost returns the cost of one item of a sized field: the node of a
+// connection (selected through nodes or edges { node }) or an element of a list
+func itemCost(selections []*selection) int {
+	cost, connection := 0, false
+	for _, s := range selections {
+		switch s.Name {
+		case "nodes":
+			cost, connection = max(cost, 1+selectionsCost(s.Selections)), true
+		case "edges":
+			connection = true
+			for _, e := range s.Selections {
+				if e.Name == "node" {
+					cost = max(cost, 1+selectionsCost(e.Selections))
+				}
+			}
+		case "pageInfo":
+			connection = true
+		}
+	}
+	if connection {
+		return cost
+	}
+	return 1 + selectionsCost(selections)
+}
+
+// pageSize returns the first or last argument of a field
+func pageSize(args map[string]interface{}) (int, bool) {
+	for _, name := range []string{"first", "last"} {
+		if n, ok := args[name].(float64); ok {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
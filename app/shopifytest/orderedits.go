@@ -0,0 +1,487 @@
+package shopifytest
+
+func (s *Server) orderEditBegin(args map[string]interface{}) (interface{}, error) {
+	o := s.findOrder(args["id"])
+	if o == nil {
+		return payload(object{"calculatedOrder": nil}, userError("The order does not exist.", "id")), nil
+	}
+	if o.CancelledAt != nil {
+		return payload(object{"calculatedOrder": nil}, userError("Cancelled orders can't be edited.", "id")), nil
+	}
+	co := &calculatedOrder{ID: s.newID(), OrderID: o.ID, LineItems: cloneLineItems(o.LineItems), Restock: map[int64]bool{}}
+	for _, sl := range o.ShippingLines {
+		co.ShippingLines = append(co.ShippingLines, &calculatedShippingLine{ID: s.newID(), Title: sl.Title, Price: sl.Price, Status: "NONE"})
+	}
+	s.calculatedOrders = append(s.calculatedOrders, co)
+	return payload(object{"calculatedOrder": s.calculatedOrderObject(co)}), nil
+}
+
+// openCalculatedOrder returns the edit session for id, or a userError
+func (s *Server) openCalculatedOrder(id interface{}) (*calculatedOrder, []object) {
+	co := s.findCalculatedOrder(id)
+	if co == nil {
+		return nil, []object{userError("The calculated order does not exist.", "id")}
+	}
+	if co.Committed {
+		return nil, []object{userError("The calculated order has already been committed.", "id")}
+	}
+	return co, nil
+}
+
+// findLineItem returns the order's line item with the ID, or nil
+func findLineItem(o *Order, id int64) *LineItem {
+	for _, li := range o.LineItems {
+		if li.ID == id {
+			return li
+		}
+	}
+	return nil
+}
+
+// lineItem returns the session's line item with the (Calculated)LineItem ID, or nil
+func (co *calculatedOrder) lineItem(id interface{}) *LineItem {
+	n := parseID(id)
+	for _, li := range co.LineItems {
+		if li.ID == n {
+			return li
+		}
+	}
+	return nil
+}
+
+// shippingLine returns the session's shipping line with the ID, or nil
+func (co *calculatedOrder) shippingLine(id interface{}) *calculatedShippingLine {
+	n := parseID(id)
+	for _, sl := range co.ShippingLines {
+		if sl.ID == n {
+			return sl
+		}
+	}
+	return nil
+}
+
+// editDiscount decodes an OrderEditAppliedDiscountInput. It returns nil when
+// neither percentValue nor fixedValue is set.
+func editDiscount(arg interface{}) (*AppliedDiscount, error) {
+	var in struct {
+		Description  string      `json:"description"`
+		PercentValue *flexFloat  `json:"percentValue"`
+		FixedValue   *moneyInput `json:"fixedValue"`
+		FixedAmount  *flexFloat  `json:"-"`
+	}
+	// fixedValue is MoneyInput in the schema; a bare number is accepted too
+	if discount, ok := arg.(map[string]interface{}); ok {
+		if fixed, ok := discount["fixedValue"].(float64); ok {
+			amount := flexFloat(fixed)
+			in.FixedAmount = &amount
+			delete(discount, "fixedValue")
+		}
+	}
+	if err := decodeArg(arg, &in); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case in.PercentValue != nil:
+		return &AppliedDiscount{Description: in.Description, ValueType: "PERCENTAGE", Value: float64(*in.PercentValue)}, nil
+	case in.FixedAmount != nil:
+		return &AppliedDiscount{Description: in.Description, ValueType: "FIXED_AMOUNT", Value: float64(*in.FixedAmount)}, nil
+	case in.FixedValue != nil:
+		return &AppliedDiscount{Description: in.Description, ValueType: "FIXED_AMOUNT", Value: float64(in.FixedValue.Amount)}, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) orderEditAddVariant(args map[string]interface{}) (interface{}, error) {
+	none := object{"calculatedOrder": nil, "calculatedLineItem": nil}
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(none, userErrors...), nil
+	}
+	var in struct {
+		VariantID       string `json:"variantId"`
+		Quantity        int    `json:"quantity"`
+		LocationID      string `json:"locationId"`
+		AllowDuplicates bool   `json:"allowDuplicates"`
+	}
+	if err := decodeArg(args, &in); err != nil {
+		return nil, err
+	}
+	v := s.findVariant(in.VariantID)
+	if v == nil {
+		return payload(none, userError("The variant does not exist.", "variantId")), nil
+	}
+	if in.Quantity <= 0 {
+		return payload(none, userError("Quantity must be greater than 0.", "quantity")), nil
+	}
+	if in.LocationID != "" && s.findLocation(in.LocationID) == nil {
+		return payload(none, userError("The location does not exist.", "locationId")), nil
+	}
+	if !in.AllowDuplicates {
+		for _, li := range co.LineItems {
+			if li.VariantID == v.ID && li.Quantity > 0 {
+				return payload(none, userError("The variant is already on the order.", "variantId")), nil
+			}
+		}
+	}
+
+	li := &LineItem{ID: s.newID(), VariantID: v.ID, Title: v.Title, SKU: v.SKU, Quantity: in.Quantity, Price: v.Price, Taxable: !v.NotTaxable}
+	co.LineItems = append(co.LineItems, li)
+	return payload(object{
+		"calculatedOrder":    s.calculatedOrderObject(co),
+		"calculatedLineItem": s.calculatedLineItemObject(co, li),
+	}), nil
+}
+
+func (s *Server) orderEditAddCustomItem(args map[string]interface{}) (interface{}, error) {
+	none := object{"calculatedOrder": nil, "calculatedLineItem": nil}
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(none, userErrors...), nil
+	}
+	var in struct {
+		Title            string     `json:"title"`
+		Price            moneyInput `json:"price"`
+		Quantity         int        `json:"quantity"`
+		Taxable          *bool      `json:"taxable"`
+		RequiresShipping *bool      `json:"requiresShipping"`
+		LocationID       string     `json:"locationId"`
+	}
+	if err := decodeArg(args, &in); err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return payload(none, userError("Title can't be blank.", "title")), nil
+	}
+	if in.Quantity <= 0 {
+		return payload(none, userError("Quantity must be greater than 0.", "quantity")), nil
+	}
+	if in.Price.Amount < 0 {
+		return payload(none, userError("Price must be greater than or equal to 0.", "price")), nil
+	}
+	if in.LocationID != "" && s.findLocation(in.LocationID) == nil {
+		return payload(none, userError("The location does not exist.", "locationId")), nil
+	}
+
+	li := &LineItem{ID: s.newID(), Title: in.Title, Quantity: in.Quantity, Price: float64(in.Price.Amount), Taxable: in.Taxable == nil || *in.Taxable}
+	co.LineItems = append(co.LineItems, li)
+	return payload(object{
+		"calculatedOrder":    s.calculatedOrderObject(co),
+		"calculatedLineItem": s.calculatedLineItemObject(co, li),
+	}), nil
+}
+
+func (s *Server) orderEditSetQuantity(args map[string]interface{}) (interface{}, error) {
+	none := object{"calculatedOrder": nil, "calculatedLineItem": nil}
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(none, userErrors...), nil
+	}
+	var in struct {
+		LineItemID string `json:"lineItemId"`
+		Quantity   int    `json:"quantity"`
+		Restock    bool   `json:"restock"`
+	}
+	if err := decodeArg(args, &in); err != nil {
+		return nil, err
+	}
+	li := co.lineItem(in.LineItemID)
+	if li == nil {
+		return payload(none, userError("The line item does not exist.", "lineItemId")), nil
+	}
+	if in.Quantity < 0 {
+		return payload(none, userError("Quantity must be greater than or equal to 0.", "quantity")), nil
+	}
+
+	original := findLineItem(s.findOrder(co.OrderID), li.ID)
+	if original == nil {
+		// Lines added by this edit are dropped rather than kept at zero
+		li.Quantity = in.Quantity
+		if in.Quantity == 0 {
+			for i, item := range co.LineItems {
+				if item == li {
+					co.LineItems = append(co.LineItems[:i], co.LineItems[i+1:]...)
+					break
+				}
+			}
+		}
+		return payload(object{
+			"calculatedOrder":    s.calculatedOrderObject(co),
+			"calculatedLineItem": s.calculatedLineItemObject(co, li),
+		}), nil
+	}
+	if fulfilled := original.Quantity - original.FulfillableQuantity; in.Quantity < fulfilled {
+		return payload(none, userError("Quantity can't be less than the fulfilled quantity.", "quantity")), nil
+	}
+	li.Quantity = in.Quantity
+	co.Restock[li.ID] = in.Restock && in.Quantity < original.Quantity
+	return payload(object{
+		"calculatedOrder":    s.calculatedOrderObject(co),
+		"calculatedLineItem": s.calculatedLineItemObject(co, li),
+	}), nil
+}
+
+func (s *Server) orderEditAddLineItemDiscount(args map[string]interface{}) (interface{}, error) {
+	none := object{"calculatedOrder": nil, "calculatedLineItem": nil, "addedDiscountStagedChange": nil}
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(none, userErrors...), nil
+	}
+	discount, err := editDiscount(args["discount"])
+	if err != nil {
+		return nil, err
+	}
+
+	li := co.lineItem(args["lineItemId"])
+	if li == nil {
+		return payload(none, userError("The line item does not exist.", "lineItemId")), nil
+	}
+	if discount == nil {
+		return payload(none, userError("Either percentValue or fixedValue is required.", "discount")), nil
+	}
+	li.Discount = discount
+
+	return payload(object{
+		"calculatedOrder":           s.calculatedOrderObject(co),
+		"calculatedLineItem":        s.calculatedLineItemObject(co, li),
+		"addedDiscountStagedChange": object{"__typename": "OrderStagedChangeAddLineItemDiscount", "id": gid("OrderStagedChangeAddLineItemDiscount", s.newID())},
+	}), nil
+}
+
+// discountLineItem returns the session's line item carrying the discount
+// application. A line has at most one discount, identified by the line's ID.
+func (co *calculatedOrder) discountLineItem(discountApplicationID interface{}) *LineItem {
+	if gidType(discountApplicationID) != "CalculatedManualDiscountApplication" {
+		return nil
+	}
+	li := co.lineItem(discountApplicationID)
+	if li == nil || li.Discount == nil {
+		return nil
+	}
+	return li
+}
+
+func (s *Server) orderEditUpdateDiscount(args map[string]interface{}) (interface{}, error) {
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(object{"calculatedOrder": nil}, userErrors...), nil
+	}
+	discount, err := editDiscount(args["discount"])
+	if err != nil {
+		return nil, err
+	}
+	li := co.discountLineItem(args["discountApplicationId"])
+	if li == nil {
+		return payload(object{"calculatedOrder": nil}, userError("The discount does not exist.", "discountApplicationId")), nil
+	}
+	if discount == nil {
+		return payload(object{"calculatedOrder": nil}, userError("Either percentValue or fixedValue is required.", "discount")), nil
+	}
+	if discount.Description == "" {
+		discount.Description = li.Discount.Description
+	}
+	li.Discount = discount
+	return payload(object{"calculatedOrder": s.calculatedOrderObject(co)}), nil
+}
+
+func (s *Server) orderEditRemoveDiscount(args map[string]interface{}) (interface{}, error) {
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(object{"calculatedOrder": nil}, userErrors...), nil
+	}
+	li := co.discountLineItem(args["discountApplicationId"])
+	if li == nil {
+		return payload(object{"calculatedOrder": nil}, userError("The discount does not exist.", "discountApplicationId")), nil
+	}
+	li.Discount = nil
+	return payload(object{"calculatedOrder": s.calculatedOrderObject(co)}), nil
+}
+
+func (s *Server) orderEditAddShippingLine(args map[string]interface{}) (interface{}, error) {
+	none := object{"calculatedOrder": nil, "calculatedShippingLine": nil}
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(none, userErrors...), nil
+	}
+	var in struct {
+		Title string     `json:"title"`
+		Price moneyInput `json:"price"`
+	}
+	if err := decodeArg(args["shippingLine"], &in); err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return payload(none, userError("Title can't be blank.", "shippingLine", "title")), nil
+	}
+	if in.Price.Amount < 0 {
+		return payload(none, userError("Price must be greater than or equal to 0.", "shippingLine", "price")), nil
+	}
+
+	sl := &calculatedShippingLine{ID: s.newID(), Title: in.Title, Price: float64(in.Price.Amount), Status: "ADDED"}
+	co.ShippingLines = append(co.ShippingLines, sl)
+	return payload(object{
+		"calculatedOrder":        s.calculatedOrderObject(co),
+		"calculatedShippingLine": s.calculatedShippingLineObject(sl),
+	}), nil
+}
+
+func (s *Server) orderEditUpdateShippingLine(args map[string]interface{}) (interface{}, error) {
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(object{"calculatedOrder": nil}, userErrors...), nil
+	}
+	var in struct {
+		Title *string     `json:"title"`
+		Price *moneyInput `json:"price"`
+	}
+	if err := decodeArg(args["shippingLine"], &in); err != nil {
+		return nil, err
+	}
+	sl := co.shippingLine(args["shippingLineId"])
+	if sl == nil {
+		return payload(object{"calculatedOrder": nil}, userError("The shipping line does not exist.", "shippingLineId")), nil
+	}
+	// Like Shopify, only shipping lines staged by this edit can be changed
+	if sl.Status != "ADDED" {
+		return payload(object{"calculatedOrder": nil}, userError("Only shipping lines added in this edit can be updated.", "shippingLineId")), nil
+	}
+	if in.Title != nil {
+		if *in.Title == "" {
+			return payload(object{"calculatedOrder": nil}, userError("Title can't be blank.", "shippingLine", "title")), nil
+		}
+		sl.Title = *in.Title
+	}
+	if in.Price != nil {
+		if in.Price.Amount < 0 {
+			return payload(object{"calculatedOrder": nil}, userError("Price must be greater than or equal to 0.", "shippingLine", "price")), nil
+		}
+		sl.Price = float64(in.Price.Amount)
+	}
+	return payload(object{"calculatedOrder": s.calculatedOrderObject(co)}), nil
+}
+
+func (s *Server) orderEditRemoveShippingLine(args map[string]interface{}) (interface{}, error) {
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(object{"calculatedOrder": nil}, userErrors...), nil
+	}
+	sl := co.shippingLine(args["shippingLineId"])
+	if sl == nil || sl.Status == "REMOVED" {
+		return payload(object{"calculatedOrder": nil}, userError("The shipping line does not exist.", "shippingLineId")), nil
+	}
+	if sl.Status == "ADDED" {
+		for i, line := range co.ShippingLines {
+			if line == sl {
+				co.ShippingLines = append(co.ShippingLines[:i], co.ShippingLines[i+1:]...)
+				break
+			}
+		}
+	} else {
+		sl.Status = "REMOVED"
+	}
+	return payload(object{"calculatedOrder": s.calculatedOrderObject(co)}), nil
+}
+
+func (s *Server) orderEditCommit(args map[string]interface{}) (interface{}, error) {
+	co, userErrors := s.openCalculatedOrder(args["id"])
+	if userErrors != nil {
+		return payload(object{"order": nil}, userErrors...), nil
+	}
+	o := s.findOrder(co.OrderID)
+
+	original := map[int64]*LineItem{}
+	for _, li := range o.LineItems {
+		original[li.ID] = li
+	}
+	for _, li := range co.LineItems {
+		before, ok := original[li.ID]
+		if !ok {
+			li.FulfillableQuantity = li.Quantity
+			s.resizeFulfillmentOrders(o, li.ID, li.Quantity)
+			continue
+		}
+		delta := li.Quantity - before.Quantity
+		li.FulfillableQuantity = before.FulfillableQuantity + delta
+		s.resizeFulfillmentOrders(o, li.ID, delta)
+		if co.Restock[li.ID] && li.VariantID != 0 {
+			if v := s.findVariant(li.VariantID); v != nil {
+				v.InventoryQuantity -= delta
+			}
+		}
+	}
+	o.LineItems = co.LineItems
+	if o.FulfillmentStatus == "FULFILLED" {
+		for _, li := range o.LineItems {
+			if li.FulfillableQuantity > 0 {
+				o.FulfillmentStatus = "PARTIALLY_FULFILLED"
+			}
+		}
+	}
+
+	o.ShippingLines = nil
+	for _, sl := range co.ShippingLines {
+		if sl.Status != "REMOVED" {
+			o.ShippingLines = append(o.ShippingLines, ShippingLine{Title: sl.Title, Price: sl.Price})
+		}
+	}
+
+	// Like Shopify, committing an edit recalculates taxes with the shop's rates,
+	// which drops custom tax lines set when the order was created
+	o.TaxLines = nil
+	s.applyTax(o.LineItems, false)
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	co.Committed = true
+	co.LineItems = cloneLineItems(o.LineItems)
+	return payload(object{"order": s.orderObject(o)}), nil
+}
+
+// resizeFulfillmentOrders adds delta units of a line item to the order's first
+// open fulfillment order, or takes them from the remaining quantities when negative
+func (s *Server) resizeFulfillmentOrders(o *Order, lineItemID int64, delta int) {
+	if delta < 0 {
+		left := -delta
+		for _, fo := range o.FulfillmentOrders {
+			for _, foli := range fo.LineItems {
+				if foli.LineItemID == lineItemID && left > 0 {
+					n := min(left, foli.RemainingQuantity)
+					foli.RemainingQuantity -= n
+					foli.TotalQuantity -= n
+					left -= n
+				}
+			}
+		}
+		return
+	}
+	if delta == 0 {
+		return
+	}
+
+	var open *FulfillmentOrder
+	for _, fo := range o.FulfillmentOrders {
+		if fo.Status != "CLOSED" {
+			open = fo
+			break
+		}
+	}
+	if open == nil {
+		open = &FulfillmentOrder{ID: s.newID(), Status: "OPEN", RequestStatus: "UNSUBMITTED"}
+		if len(s.locations) > 0 {
+			open.LocationID = s.locations[0].ID
+		}
+		o.FulfillmentOrders = append(o.FulfillmentOrders, open)
+	}
+	for _, foli := range open.LineItems {
+		if foli.LineItemID == lineItemID {
+			foli.TotalQuantity += delta
+			foli.RemainingQuantity += delta
+			return
+		}
+	}
+	open.LineItems = append(open.LineItems, &FulfillmentOrderLineItem{
+		ID:                s.newID(),
+		LineItemID:        lineItemID,
+		TotalQuantity:     delta,
+		RemainingQuantity: delta,
+	})
+}
@@ -0,0 +1,414 @@
+package shopifytest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"shopify-demo/app"
+	"shopify-demo/app/shopifytest"
+)
+
+func newPipeline(t *testing.T) (*shopifytest.Server, *app.Client) {
+	t.Helper()
+	srv := shopifytest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.TaxRate = 0.1
+	return srv, srv.Client(app.WithLogger(slog.New(slog.DiscardHandler)))
+}
+
+func TestDraftCompleteFulfill(t *testing.T) {
+	tests := []struct {
+		name           string
+		paymentPending bool
+		// fulfillmentOrders queries answered with no fulfillment orders
+		foDelay       int
+		wantPolls     int
+		wantFinancial string
+	}{
+		{"paid", false, 0, 0, "PAID"},
+		{"payment pending", true, 0, 0, "PENDING"},
+		{"fulfillment orders routed late", false, 2, 2, "PAID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, client := newPipeline(t)
+			srv.FulfillmentOrderDelay = tt.foDelay
+			shirt := srv.AddVariant(shopifytest.Variant{Title: "T-Shirt", Price: 25})
+			mug := srv.AddVariant(shopifytest.Variant{Title: "Mug", Price: 12.5})
+			ctx := context.Background()
+
+			info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{
+				Email: "buyer@example.com",
+				LineItems: []app.DraftLineItemInput{
+					{VariantID: shirt.GID(), Quantity: 2},
+					{VariantID: mug.GID(), Quantity: 1},
+				},
+			}, tt.paymentPending)
+			if err != nil {
+				t.Fatalf("CreateOrderFromDraft: %v", err)
+			}
+			if info.OrderID == "" || info.DraftID == "" {
+				t.Fatalf("OrderInfo = %+v, want order and draft IDs", info)
+			}
+			if draft, _ := srv.DraftOrder(info.DraftID); draft.Status != "COMPLETED" {
+				t.Errorf("draft status = %q, want COMPLETED", draft.Status)
+			}
+
+			// CompleteDraftOrder looked once; poll until routing is done
+			fos := info.FulfillmentOrders
+			polls := 0
+			for len(fos) == 0 && polls < 5 {
+				polls++
+				if fos, err = client.GetFulfillmentOrdersWithRetry(ctx, info.OrderID, 3, time.Millisecond); err != nil {
+					t.Fatalf("GetFulfillmentOrdersWithRetry: %v", err)
+				}
+			}
+			if polls != tt.wantPolls {
+				t.Errorf("polled %d times, want %d", polls, tt.wantPolls)
+			}
+			if len(fos) != 1 || len(fos[0].LineItems) != 2 {
+				t.Fatalf("fulfillment orders = %+v, want one with 2 lines", fos)
+			}
+			if _, err := client.CreateFulfillment(ctx, []string{fos[0].ID}, &app.TrackingInfo{
+				TrackingNumber:  "1Z999",
+				TrackingCompany: "UPS",
+			}); err != nil {
+				t.Fatalf("CreateFulfillment: %v", err)
+			}
+
+			order, ok := srv.Order(info.OrderID)
+			if !ok {
+				t.Fatalf("order %s not found", info.OrderID)
+			}
+			if order.Subtotal() != 62.5 || order.TotalTax() != 6.25 || order.Total() != 68.75 {
+				t.Errorf("subtotal, tax, total = %v, %v, %v, want 62.5, 6.25, 68.75",
+					order.Subtotal(), order.TotalTax(), order.Total())
+			}
+			if order.FinancialStatus != tt.wantFinancial {
+				t.Errorf("financial status = %q, want %q", order.FinancialStatus, tt.wantFinancial)
+			}
+			if order.FulfillmentStatus != "FULFILLED" {
+				t.Errorf("fulfillment status = %q, want FULFILLED", order.FulfillmentStatus)
+			}
+			if len(order.Fulfillments) != 1 || order.Fulfillments[0].TrackingNumber != "1Z999" {
+				t.Errorf("fulfillments = %+v, want one tracked by 1Z999", order.Fulfillments)
+			}
+		})
+	}
+}
+
+func TestOrderEditPipeline(t *testing.T) {
+	srv, client := newPipeline(t)
+	shirt := srv.AddVariant(shopifytest.Variant{Title: "T-Shirt", Price: 25})
+	mug := srv.AddVariant(shopifytest.Variant{Title: "Mug", Price: 12.5})
+	ctx := context.Background()
+
+	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{
+		LineItems: []app.DraftLineItemInput{{VariantID: shirt.GID(), Quantity: 2}},
+	}, false)
+	if err != nil {
+		t.Fatalf("CreateOrderFromDraft: %v", err)
+	}
+
+	session, err := client.EditOrder(ctx, info.OrderID)
+	if err != nil {
+		t.Fatalf("EditOrder: %v", err)
+	}
+	preview, err := session.Preview(ctx)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if len(preview.LineItems) != 1 {
+		t.Fatalf("calculated lines = %+v, want the order's line", preview.LineItems)
+	}
+	shirtLine := preview.LineItems[0].ID
+
+	if _, err := session.SetQuantity(ctx, shirtLine, 3, false); err != nil {
+		t.Fatalf("SetQuantity: %v", err)
+	}
+	added, err := session.AddVariant(ctx, mug.GID(), 2)
+	if err != nil {
+		t.Fatalf("AddVariant: %v", err)
+	}
+	if _, err := session.AddDiscount(ctx, added.ID, app.OrderEditDiscount{
+		Description:  "Loyalty",
+		PercentValue: decimal.NewFromInt(20),
+		IsPercentage: true,
+	}); err != nil {
+		t.Fatalf("AddDiscount: %v", err)
+	}
+
+	// 3 x 25 + 2 x (12.5 - 20%)
+	preview, err = session.Preview(ctx)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if got := preview.SubtotalPriceSet.ShopMoney.Amount; !got.Equal(app.MustParseMoney("95", "USD")) {
+		t.Errorf("previewed subtotal = %v, want 95", got)
+	}
+	if err := session.Commit(ctx, false); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	order, _ := srv.Order(info.OrderID)
+	quantities := map[string]int{}
+	for _, li := range order.LineItems {
+		quantities[li.Title] += li.Quantity
+	}
+	if quantities["T-Shirt"] != 3 || quantities["Mug"] != 2 {
+		t.Errorf("committed quantities = %v, want 3 T-Shirts and 2 Mugs", quantities)
+	}
+	if order.Subtotal() != 95 || order.TotalTax() != 9.5 {
+		t.Errorf("subtotal, tax = %v, %v, want 95, 9.5", order.Subtotal(), order.TotalTax())
+	}
+
+	// A committed session cannot be committed again
+	if err := session.Commit(ctx, false); err == nil {
+		t.Error("second Commit succeeded, want an error")
+	}
+}
+
+func countCalls(srv *shopifytest.Server, operation string) int {
+	n := 0
+	for _, call := range srv.Calls() {
+		if call == operation {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReconcileOrderPipeline(t *testing.T) {
+	srv, client := newPipeline(t)
+	shirt := srv.AddVariant(shopifytest.Variant{Title: "T-Shirt", Price: 25})
+	mug := srv.AddVariant(shopifytest.Variant{Title: "Mug", Price: 12.5})
+	ctx := context.Background()
+
+	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{
+		LineItems: []app.DraftLineItemInput{{VariantID: shirt.GID(), Quantity: 2}},
+	}, false)
+	if err != nil {
+		t.Fatalf("CreateOrderFromDraft: %v", err)
+	}
+	loyalty := &app.OrderEditDiscount{Description: "Loyalty", PercentValue: decimal.NewFromInt(20), IsPercentage: true}
+	exchanged := []app.CartLine{
+		{VariantID: shirt.GID(), Quantity: 1},
+		{VariantID: mug.GID(), Quantity: 2, Discount: loyalty},
+	}
+
+	tests := []struct {
+		name          string
+		cart          []app.CartLine
+		wantChanges   int
+		wantCommitted bool
+	}{
+		{"no change", []app.CartLine{{VariantID: shirt.GID(), Quantity: 2}}, 0, false},
+		{"exchange", exchanged, 2, true},
+		{"exchanged order matches the cart", exchanged, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			begun := countCalls(srv, "orderEditBegin")
+			result, err := client.ReconcileOrder(ctx, info.OrderID, tt.cart, app.ReconcileOptions{})
+			if err != nil {
+				t.Fatalf("ReconcileOrder: %v", err)
+			}
+			if len(result.Changes) != tt.wantChanges || result.Committed != tt.wantCommitted {
+				t.Errorf("changes, committed = %+v, %v, want %d, %v", result.Changes, result.Committed, tt.wantChanges, tt.wantCommitted)
+			}
+			wantBegun := 0
+			if tt.wantChanges > 0 {
+				wantBegun = 1
+			}
+			if n := countCalls(srv, "orderEditBegin") - begun; n != wantBegun {
+				t.Errorf("began %d order edits, want %d", n, wantBegun)
+			}
+		})
+	}
+
+	// The exchange committed the mug's discount, which a new order edit cannot drop
+	begun := countCalls(srv, "orderEditBegin")
+	_, err = client.ReconcileOrder(ctx, info.OrderID, []app.CartLine{
+		{VariantID: shirt.GID(), Quantity: 1},
+		{VariantID: mug.GID(), Quantity: 2},
+	}, app.ReconcileOptions{})
+	if !errors.Is(err, app.ErrDiscountNotEditable) {
+		t.Errorf("ReconcileOrder dropping a committed discount = %v, want ErrDiscountNotEditable", err)
+	}
+	if n := countCalls(srv, "orderEditBegin") - begun; n != 0 {
+		t.Errorf("began %d order edits, want 0", n)
+	}
+
+	// 1 x 25 + 2 x (12.5 - 20%), plus 10% tax
+	order, _ := srv.Order(info.OrderID)
+	if order.Subtotal() != 45 || order.Total() != 49.5 {
+		t.Errorf("subtotal, total = %v, %v, want 45, 49.5", order.Subtotal(), order.Total())
+	}
+}
+
+func TestCancelOrderPipeline(t *testing.T) {
+	srv, client := newPipeline(t)
+	srv.JobDelay = 2
+	ctx := context.Background()
+
+	// More lines than the first page of refund line items
+	var lineItems []app.DraftLineItemInput
+	for i := range 30 {
+		variant := srv.AddVariant(shopifytest.Variant{Title: fmt.Sprintf("Item %d", i), Price: 10})
+		lineItems = append(lineItems, app.DraftLineItemInput{VariantID: variant.GID(), Quantity: 1})
+	}
+	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{LineItems: lineItems}, false)
+	if err != nil {
+		t.Fatalf("CreateOrderFromDraft: %v", err)
+	}
+
+	result, err := client.CancelOrder(ctx, info.OrderID, app.CancelOptions{
+		Reason:       app.CancelReasonCustomer,
+		Refund:       app.RefundOriginalPayment,
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if result.CancelledAt.IsZero() || result.CancelReason != app.CancelReasonCustomer {
+		t.Errorf("cancelled at %v for %q, want a time and CUSTOMER", result.CancelledAt, result.CancelReason)
+	}
+	if len(result.Refunds) != 1 {
+		t.Fatalf("refunds = %+v, want one", result.Refunds)
+	}
+	refund := result.Refunds[0]
+	if len(refund.Transactions) != 1 || refund.Transactions[0].Kind != "REFUND" {
+		t.Errorf("refund transactions = %+v, want one REFUND", refund.Transactions)
+	}
+	if len(refund.RefundLineItems) != 30 {
+		t.Errorf("refund has %d line items, want 30", len(refund.RefundLineItems))
+	}
+	// Two polls see the job running, the third sees it done
+	if n := countCalls(srv, "job"); n != 3 {
+		t.Errorf("polled the job %d times, want 3", n)
+	}
+}
+
+func TestGetOrderPipeline(t *testing.T) {
+	srv, client := newPipeline(t)
+	ctx := context.Background()
+
+	// More lines than the first pages of GetOrderQuery
+	var lineItems []app.DraftLineItemInput
+	for i := range 30 {
+		variant := srv.AddVariant(shopifytest.Variant{Title: fmt.Sprintf("Item %d", i), Price: 10})
+		lineItems = append(lineItems, app.DraftLineItemInput{VariantID: variant.GID(), Quantity: 1})
+	}
+	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{LineItems: lineItems}, false)
+	if err != nil {
+		t.Fatalf("CreateOrderFromDraft: %v", err)
+	}
+	if len(info.FulfillmentOrders) != 1 {
+		t.Fatalf("fulfillment orders = %+v, want one", info.FulfillmentOrders)
+	}
+	if _, err := client.CreateFulfillment(ctx, []string{info.FulfillmentOrders[0].ID}, nil); err != nil {
+		t.Fatalf("CreateFulfillment: %v", err)
+	}
+
+	order, err := client.GetOrder(ctx, info.OrderID)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if len(order.LineItems) != 30 {
+		t.Errorf("order has %d line items, want 30", len(order.LineItems))
+	}
+	if len(order.FulfillmentOrders) != 1 || len(order.FulfillmentOrders[0].LineItems) != 30 {
+		t.Errorf("fulfillment orders = %+v, want one with 30 lines", order.FulfillmentOrders)
+	}
+	if len(order.Fulfillments) != 1 || len(order.Fulfillments[0].FulfillmentLineItems) != 30 {
+		t.Errorf("fulfillments = %+v, want one with 30 lines", order.Fulfillments)
+	}
+}
+
+func TestQueryCost(t *testing.T) {
+	srv, client := newPipeline(t)
+	shirt := srv.AddVariant(shopifytest.Variant{Title: "T-Shirt", Price: 25})
+	ctx := context.Background()
+
+	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{
+		LineItems: []app.DraftLineItemInput{{VariantID: shirt.GID(), Quantity: 2}},
+	}, false)
+	if err != nil {
+		t.Fatalf("CreateOrderFromDraft: %v", err)
+	}
+	id := map[string]interface{}{"id": info.OrderID}
+	everySection := map[string]interface{}{"id": info.OrderID, "metafieldNamespace": nil}
+	for _, section := range []string{"lineItems", "shippingLines", "transactions", "fulfillments",
+		"fulfillmentOrders", "metafields", "customer", "addresses"} {
+		everySection[section] = true
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      float64
+	}{
+		{"scalars", `query($id: ID!) { order(id: $id) { id name } }`, id, 1},
+		{"objects", `query($id: ID!) { order(id: $id) { id totalPriceSet { shopMoney { amount } } } }`, id, 3},
+		{"connection", `query($id: ID!) { order(id: $id) {
+			lineItems(first: 10) { nodes { id variant { id } } pageInfo { hasNextPage } }
+		} }`, id, 1 + 2 + 10*2},
+		{"sized list", `query($id: ID!) { order(id: $id) {
+			transactions(first: 20) { id amountSet { shopMoney { amount } } }
+		} }`, id, 1 + 2 + 20*3},
+		{"most expensive fragment", `query($id: ID!) { node(id: $id) {
+			... on Order { totalPriceSet { shopMoney { amount } } }
+			... on DraftOrder { id }
+		} }`, id, 3},
+		{"mutation", `mutation($id: ID!) { orderEditBegin(id: $id) { calculatedOrder { id } } }`, id, 10 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := client.CallAdminGraphQL(ctx, tt.query, tt.variables)
+			if err != nil {
+				t.Fatalf("CallAdminGraphQL: %v", err)
+			}
+			if got := requestedCost(t, response); got != tt.want {
+				t.Errorf("requested cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("order with every section", func(t *testing.T) {
+		response, err := client.CallAdminGraphQL(ctx, app.GetOrderQuery, everySection)
+		if err != nil {
+			t.Fatalf("CallAdminGraphQL: %v", err)
+		}
+		if got := requestedCost(t, response); got > 1000 {
+			t.Errorf("requested cost = %v, want at most 1000", got)
+		}
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		_, err := client.CallAdminGraphQL(ctx, `query($id: ID!) { order(id: $id) {
+			lineItems(first: 250) { nodes { id variant { id } originalUnitPriceSet { shopMoney { amount } } } }
+		} }`, id)
+		var gqlErrs *app.GraphQLErrors
+		if !errors.As(err, &gqlErrs) || !gqlErrs.HasCode(app.ErrorCodeMaxCostExceeded) {
+			t.Errorf("CallAdminGraphQL error = %v, want MAX_COST_EXCEEDED", err)
+		}
+	})
+}
+
+func requestedCost(t *testing.T, response map[string]interface{}) float64 {
+	t.Helper()
+	extensions, _ := response["extensions"].(map[string]interface{})
+	cost, _ := extensions["cost"].(map[string]interface{})
+	requested, ok := cost["requestedQueryCost"].(float64)
+	if !ok {
+		t.Fatalf("response has no requested cost: %v", response)
+	}
+	return requested
+}
@@ -0,0 +1,555 @@
+package shopifytest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type refundLineItemInput struct {
+	LineItemID  string `json:"lineItemId"`
+	Quantity    int    `json:"quantity"`
+	RestockType string `json:"restockType"`
+	LocationID  string `json:"locationId"`
+}
+
+// refundedQuantity returns the quantity of a line item covered by earlier refunds
+func (s *Server) refundedQuantity(o *Order, lineItemID int64) int {
+	n := 0
+	for _, r := range o.Refunds {
+		for _, rli := range r.LineItems {
+			if rli.LineItemID == lineItemID {
+				n += rli.Quantity
+			}
+		}
+	}
+	return n
+}
+
+// refundedShipping returns the shipping refunded so far
+func (s *Server) refundedShipping(o *Order) float64 {
+	total := 0.0
+	for _, r := range o.Refunds {
+		total += r.Shipping
+	}
+	return round2(total)
+}
+
+// refundableTransactions returns the successful sales and captures that still
+// have money to refund, with the amount left on each
+func (s *Server) refundableTransactions(o *Order) ([]*Transaction, []float64) {
+	var parents []*Transaction
+	var remaining []float64
+	for _, t := range o.Transactions {
+		if t.Status != "success" || (t.Kind != "sale" && t.Kind != "capture") {
+			continue
+		}
+		if left := round2(t.Amount - s.childAmount(o, t.ID, "refund")); left > 0 {
+			parents = append(parents, t)
+			remaining = append(remaining, left)
+		}
+	}
+	return parents, remaining
+}
+
+// refundLines validates refund line item inputs and prices them. The second
+// result is a user error, nil when the inputs are valid.
+func (s *Server) refundLines(o *Order, inputs []refundLineItemInput, path ...string) ([]RefundLineItem, object) {
+	lines := make([]RefundLineItem, 0, len(inputs))
+	requested := map[int64]int{}
+	for i, in := range inputs {
+		field := append(append([]string(nil), path...), fmt.Sprint(i))
+		var li *LineItem
+		for _, item := range o.LineItems {
+			if item.ID == parseID(in.LineItemID) {
+				li = item
+			}
+		}
+		if li == nil {
+			return nil, userError("Line item does not exist", append(field, "lineItemId")...)
+		}
+		requested[li.ID] += in.Quantity
+		if in.Quantity <= 0 || requested[li.ID] > li.Quantity-s.refundedQuantity(o, li.ID) {
+			return nil, userError(fmt.Sprintf("Quantity cannot refund more items than were purchased for %s", li.Title), append(field, "quantity")...)
+		}
+		restockType := in.RestockType
+		switch restockType {
+		case "":
+			restockType = "NO_RESTOCK"
+		case "NO_RESTOCK":
+		case "CANCEL":
+			if in.Quantity > li.FulfillableQuantity {
+				return nil, userError("Cannot cancel more items than are unfulfilled", append(field, "restockType")...)
+			}
+		case "RETURN":
+			if in.Quantity > li.Quantity-li.FulfillableQuantity {
+				return nil, userError("Cannot return more items than were fulfilled", append(field, "restockType")...)
+			}
+		default:
+			return nil, userError(fmt.Sprintf("Restock type %q is invalid", in.RestockType), append(field, "restockType")...)
+		}
+		if in.LocationID != "" && s.findLocation(in.LocationID) == nil {
+			return nil, userError("Location does not exist", append(field, "locationId")...)
+		}
+		lines = append(lines, RefundLineItem{
+			LineItemID:  li.ID,
+			Quantity:    in.Quantity,
+			RestockType: restockType,
+			Subtotal:    round2(li.DiscountedUnitPrice() * float64(in.Quantity)),
+			TotalTax:    round2(sumTaxLines(li.TaxLines) * float64(in.Quantity) / float64(li.Quantity)),
+		})
+	}
+	return lines, nil
+}
+
+// restock applies the inventory effect of refund lines
+func (s *Server) restock(o *Order, lines []RefundLineItem) {
+	for _, rli := range lines {
+		if rli.RestockType == "NO_RESTOCK" {
+			continue
+		}
+		for _, li := range o.LineItems {
+			if li.ID != rli.LineItemID {
+				continue
+			}
+			if v := s.findVariant(li.VariantID); v != nil {
+				v.InventoryQuantity += rli.Quantity
+			}
+			if rli.RestockType != "CANCEL" {
+				continue
+			}
+			li.FulfillableQuantity -= rli.Quantity
+			s.resizeFulfillmentOrders(o, li.ID, -rli.Quantity)
+		}
+	}
+}
+
+func (s *Server) suggestedRefund(o *Order, args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		RefundLineItems   []refundLineItemInput `json:"refundLineItems"`
+		ShippingAmount    *flexFloat            `json:"shippingAmount"`
+		RefundShipping    bool                  `json:"refundShipping"`
+		SuggestFullRefund bool                  `json:"suggestFullRefund"`
+	}
+	if err := decodeArg(args, &in); err != nil {
+		return nil, err
+	}
+	if in.SuggestFullRefund {
+		in.RefundLineItems = nil
+		for _, li := range o.LineItems {
+			if n := li.Quantity - s.refundedQuantity(o, li.ID); n > 0 {
+				in.RefundLineItems = append(in.RefundLineItems, refundLineItemInput{LineItemID: li.GID(), Quantity: n})
+			}
+		}
+		in.RefundShipping = true
+	}
+	lines, userErr := s.refundLines(o, in.RefundLineItems, "refundLineItems")
+	if userErr != nil {
+		return nil, &gqlError{Message: userErr["message"].(string), Code: "BAD_REQUEST"}
+	}
+
+	subtotal, tax := 0.0, 0.0
+	refundLineItems := make([]object, 0, len(lines))
+	for _, rli := range lines {
+		subtotal += rli.Subtotal
+		tax += rli.TotalTax
+		refundLineItems = append(refundLineItems, object{
+			"__typename":  "RefundLineItem",
+			"lineItem":    s.orderLineItemObject(o, rli.LineItemID),
+			"quantity":    rli.Quantity,
+			"restockType": rli.RestockType,
+			"restocked":   false,
+			"priceSet":    s.moneyBag(rli.Subtotal / float64(rli.Quantity)),
+			"subtotalSet": s.moneyBag(rli.Subtotal),
+			"totalTaxSet": s.moneyBag(rli.TotalTax),
+		})
+	}
+	maxShipping := round2(o.ShippingTotal() - s.refundedShipping(o))
+	shipping := 0.0
+	switch {
+	case in.ShippingAmount != nil:
+		shipping = math.Min(float64(*in.ShippingAmount), maxShipping)
+	case in.RefundShipping:
+		shipping = maxShipping
+	}
+
+	parents, remaining := s.refundableTransactions(o)
+	maxRefundable := 0.0
+	for _, left := range remaining {
+		maxRefundable += left
+	}
+	total := math.Min(round2(subtotal+tax+shipping), round2(maxRefundable))
+
+	transactions := []object{}
+	left := total
+	for i, t := range parents {
+		if left <= 0 {
+			break
+		}
+		n := math.Min(left, remaining[i])
+		left = round2(left - n)
+		transactions = append(transactions, object{
+			"__typename":           "SuggestedOrderTransaction",
+			"kind":                 "SUGGESTED_REFUND",
+			"gateway":              t.Gateway,
+			"formattedGateway":     t.Gateway,
+			"amountSet":            s.moneyBag(n),
+			"maximumRefundableSet": s.moneyBag(remaining[i]),
+			"parentTransaction":    s.transactionObject(o, t),
+		})
+	}
+
+	return object{
+		"__typename":                 "SuggestedRefund",
+		"amountSet":                  s.moneyBag(total),
+		"subtotalSet":                s.moneyBag(subtotal),
+		"totalTaxSet":                s.moneyBag(tax),
+		"totalCartDiscountAmountSet": s.moneyBag(0),
+		"maximumRefundableSet":       s.moneyBag(maxRefundable),
+		"shipping": object{
+			"__typename":           "ShippingRefund",
+			"amountSet":            s.moneyBag(shipping),
+			"maximumRefundableSet": s.moneyBag(maxShipping),
+			"taxSet":               s.moneyBag(0),
+		},
+		"refundLineItems":       refundLineItems,
+		"suggestedTransactions": transactions,
+	}, nil
+}
+
+type refundTransactionInput struct {
+	Gateway  string    `json:"gateway"`
+	Kind     string    `json:"kind"`
+	Amount   flexFloat `json:"amount"`
+	ParentID string    `json:"parentId"`
+}
+
+// refundTransactions validates refund transactions against the order's
+// refundable payments. A transaction without a parent is matched to a payment
+// by gateway.
+func (s *Server) refundTransactions(o *Order, inputs []refundTransactionInput, path ...string) ([]*Transaction, object) {
+	parents, remaining := s.refundableTransactions(o)
+	var out []*Transaction
+	for i, in := range inputs {
+		field := append(append([]string(nil), path...), fmt.Sprint(i))
+		if in.Kind != "" && !strings.EqualFold(in.Kind, "refund") {
+			return nil, userError("Transactions must be of kind refund", append(field, "kind")...)
+		}
+		amount := round2(float64(in.Amount))
+		if amount <= 0 {
+			return nil, userError("Amount must be greater than 0", append(field, "amount")...)
+		}
+		parent := -1
+		for j, t := range parents {
+			switch {
+			case in.ParentID != "":
+				if t.ID == parseID(in.ParentID) {
+					parent = j
+				}
+			case in.Gateway == "" || t.Gateway == in.Gateway:
+				if parent < 0 && remaining[j] >= amount {
+					parent = j
+				}
+			}
+		}
+		if parent < 0 {
+			return nil, userError(fmt.Sprintf("No refundable %s payment of %s", in.Gateway, price(amount)), append(field, "parentId")...)
+		}
+		if amount > remaining[parent] {
+			return nil, userError(fmt.Sprintf("Amount %s is greater than the refundable %s of the transaction", price(amount), price(remaining[parent])), append(field, "amount")...)
+		}
+		remaining[parent] = round2(remaining[parent] - amount)
+		t := parents[parent]
+		out = append(out, &Transaction{Kind: "refund", Status: "success", Gateway: t.Gateway, ParentID: t.ID, Amount: amount})
+	}
+	return out, nil
+}
+
+func (s *Server) refundCreate(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		OrderID  string `json:"orderId"`
+		Note     string `json:"note"`
+		Notify   bool   `json:"notify"`
+		Shipping *struct {
+			Amount     *flexFloat `json:"amount"`
+			FullRefund bool       `json:"fullRefund"`
+		} `json:"shipping"`
+		RefundLineItems []refundLineItemInput    `json:"refundLineItems"`
+		Transactions    []refundTransactionInput `json:"transactions"`
+	}
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	fail := func(message string, field ...string) (interface{}, error) {
+		return payload(object{"refund": nil, "order": nil}, userError(message, field...)), nil
+	}
+	o := s.findOrder(in.OrderID)
+	if o == nil {
+		return fail("Order does not exist", "orderId")
+	}
+	lines, userErr := s.refundLines(o, in.RefundLineItems, "refundLineItems")
+	if userErr != nil {
+		return payload(object{"refund": nil, "order": nil}, userErr), nil
+	}
+	shipping := 0.0
+	if in.Shipping != nil {
+		maxShipping := round2(o.ShippingTotal() - s.refundedShipping(o))
+		switch {
+		case in.Shipping.FullRefund:
+			shipping = maxShipping
+		case in.Shipping.Amount != nil:
+			shipping = round2(float64(*in.Shipping.Amount))
+		}
+		if shipping > maxShipping {
+			return fail(fmt.Sprintf("Shipping refund amount must be less than or equal to %s", price(maxShipping)), "shipping", "amount")
+		}
+	}
+	transactions, userErr := s.refundTransactions(o, in.Transactions, "transactions")
+	if userErr != nil {
+		return payload(object{"refund": nil, "order": nil}, userErr), nil
+	}
+
+	s.restock(o, lines)
+	refund := &Refund{ID: s.newID(), Note: in.Note, CreatedAt: s.now(), LineItems: lines, Shipping: shipping}
+	for _, t := range transactions {
+		s.addTransaction(o, t)
+		refund.Transactions = append(refund.Transactions, t)
+	}
+	o.Refunds = append(o.Refunds, refund)
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	return payload(object{"refund": s.refundObject(o, refund), "order": s.orderObject(o)}), nil
+}
+
+// findRefund returns a refund and its order. It must be called with s.mu held.
+func (s *Server) findRefund(id interface{}) (*Order, *Refund) {
+	n := toID(id)
+	for _, o := range s.orders {
+		for _, r := range o.Refunds {
+			if r.ID == n {
+				return o, r
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findReturn returns a return and its order. It must be called with s.mu held.
+func (s *Server) findReturn(id interface{}) (*Order, *Return) {
+	n := toID(id)
+	for _, o := range s.orders {
+		for _, r := range o.Returns {
+			if r.ID == n {
+				return o, r
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) returnObject(o *Order, r *Return) object {
+	lineItems := make([]object, 0, len(r.LineItems))
+	for _, rli := range r.LineItems {
+		var fulfillmentLineItem interface{}
+		for _, f := range o.Fulfillments {
+			for _, fli := range f.LineItems {
+				if fli.ID == rli.FulfillmentLineItemID {
+					fulfillmentLineItem = s.fulfillmentLineItemObject(o, fli)
+				}
+			}
+		}
+		lineItems = append(lineItems, object{
+			"__typename":          "ReturnLineItem",
+			"id":                  gid("ReturnLineItem", rli.ID),
+			"quantity":            rli.Quantity,
+			"refundableQuantity":  rli.Quantity - rli.RefundedQuantity,
+			"refundedQuantity":    rli.RefundedQuantity,
+			"returnReason":        rli.ReturnReason,
+			"returnReasonNote":    rli.ReturnReasonNote,
+			"customerNote":        rli.CustomerNote,
+			"fulfillmentLineItem": fulfillmentLineItem,
+		})
+	}
+	return object{
+		"__typename":      "Return",
+		"id":              gid("Return", r.ID),
+		"name":            r.Name,
+		"status":          r.Status,
+		"order":           object{"__typename": "Order", "id": o.GID()},
+		"returnLineItems": connection(lineItems),
+	}
+}
+
+func (s *Server) returnCreate(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		OrderID         string `json:"orderId"`
+		ReturnLineItems []struct {
+			FulfillmentLineItemID string `json:"fulfillmentLineItemId"`
+			Quantity              int    `json:"quantity"`
+			ReturnReason          string `json:"returnReason"`
+			ReturnReasonNote      string `json:"returnReasonNote"`
+			CustomerNote          string `json:"customerNote"`
+		} `json:"returnLineItems"`
+		NotifyCustomer bool `json:"notifyCustomer"`
+	}
+	if err := decodeArg(args["returnInput"], &in); err != nil {
+		return nil, err
+	}
+	fail := func(message string, field ...string) (interface{}, error) {
+		return payload(object{"return": nil}, userError(message, append([]string{"returnInput"}, field...)...)), nil
+	}
+	o := s.findOrder(in.OrderID)
+	if o == nil {
+		return fail("Order does not exist", "orderId")
+	}
+	if len(in.ReturnLineItems) == 0 {
+		return fail("Return line items can't be blank", "returnLineItems")
+	}
+
+	returned := map[int64]int{}
+	for _, r := range o.Returns {
+		for _, rli := range r.LineItems {
+			returned[rli.FulfillmentLineItemID] += rli.Quantity
+		}
+	}
+	ret := &Return{ID: s.newID(), Name: fmt.Sprintf("%s-R%d", o.Name, len(o.Returns)+1), Status: "OPEN"}
+	for i, item := range in.ReturnLineItems {
+		field := []string{"returnLineItems", fmt.Sprint(i)}
+		var found *FulfillmentLineItem
+		for _, f := range o.Fulfillments {
+			for _, fli := range f.LineItems {
+				if fli.ID == parseID(item.FulfillmentLineItemID) {
+					found = fli
+				}
+			}
+		}
+		if found == nil {
+			return fail("Fulfillment line item does not exist", append(field, "fulfillmentLineItemId")...)
+		}
+		returned[found.ID] += item.Quantity
+		if item.Quantity <= 0 || returned[found.ID] > found.Quantity {
+			return fail("Quantity must be less than or equal to the returnable quantity", append(field, "quantity")...)
+		}
+		reason := item.ReturnReason
+		if reason == "" {
+			reason = "UNKNOWN"
+		}
+		ret.LineItems = append(ret.LineItems, &ReturnLineItem{
+			ID:                    s.newID(),
+			FulfillmentLineItemID: found.ID,
+			LineItemID:            found.LineItemID,
+			Quantity:              item.Quantity,
+			ReturnReason:          reason,
+			ReturnReasonNote:      item.ReturnReasonNote,
+			CustomerNote:          item.CustomerNote,
+		})
+	}
+	o.Returns = append(o.Returns, ret)
+	o.UpdatedAt = s.now()
+	return payload(object{"return": s.returnObject(o, ret)}), nil
+}
+
+func (s *Server) returnRefund(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		ReturnID              string `json:"returnId"`
+		ReturnRefundLineItems []struct {
+			ReturnLineItemID string `json:"returnLineItemId"`
+			Quantity         int    `json:"quantity"`
+		} `json:"returnRefundLineItems"`
+		RefundShipping *struct {
+			ShippingRefundAmount *struct {
+				Amount flexFloat `json:"amount"`
+			} `json:"shippingRefundAmount"`
+			FullShippingRefund bool `json:"fullShippingRefund"`
+		} `json:"refundShipping"`
+		OrderTransactions []struct {
+			TransactionAmount struct {
+				Amount flexFloat `json:"amount"`
+			} `json:"transactionAmount"`
+			ParentID string `json:"parentId"`
+		} `json:"orderTransactions"`
+		NotifyCustomer bool `json:"notifyCustomer"`
+	}
+	if err := decodeArg(args["returnRefundInput"], &in); err != nil {
+		return nil, err
+	}
+	fail := func(message string, field ...string) (interface{}, error) {
+		return payload(object{"refund": nil}, userError(message, append([]string{"returnRefundInput"}, field...)...)), nil
+	}
+	o, ret := s.findReturn(in.ReturnID)
+	if ret == nil {
+		return fail("Return does not exist", "returnId")
+	}
+	if ret.Status != "OPEN" {
+		return fail("Return is not open", "returnId")
+	}
+
+	type quantity struct {
+		item *ReturnLineItem
+		n    int
+	}
+	var quantities []quantity
+	var lineInputs []refundLineItemInput
+	for i, item := range in.ReturnRefundLineItems {
+		field := []string{"returnRefundLineItems", fmt.Sprint(i)}
+		var found *ReturnLineItem
+		for _, rli := range ret.LineItems {
+			if rli.ID == parseID(item.ReturnLineItemID) {
+				found = rli
+			}
+		}
+		if found == nil {
+			return fail("Return line item does not exist", append(field, "returnLineItemId")...)
+		}
+		if item.Quantity <= 0 || item.Quantity > found.Quantity-found.RefundedQuantity {
+			return fail("Quantity must be less than or equal to the refundable quantity", append(field, "quantity")...)
+		}
+		quantities = append(quantities, quantity{found, item.Quantity})
+		lineInputs = append(lineInputs, refundLineItemInput{LineItemID: gid("LineItem", found.LineItemID), Quantity: item.Quantity})
+	}
+	lines, userErr := s.refundLines(o, lineInputs, "returnRefundInput", "returnRefundLineItems")
+	if userErr != nil {
+		return payload(object{"refund": nil}, userErr), nil
+	}
+	shipping := 0.0
+	if in.RefundShipping != nil {
+		maxShipping := round2(o.ShippingTotal() - s.refundedShipping(o))
+		switch {
+		case in.RefundShipping.FullShippingRefund:
+			shipping = maxShipping
+		case in.RefundShipping.ShippingRefundAmount != nil:
+			shipping = round2(float64(in.RefundShipping.ShippingRefundAmount.Amount))
+		}
+		if shipping > maxShipping {
+			return fail(fmt.Sprintf("Shipping refund amount must be less than or equal to %s", price(maxShipping)), "refundShipping")
+		}
+	}
+	transactionInputs := make([]refundTransactionInput, 0, len(in.OrderTransactions))
+	for _, t := range in.OrderTransactions {
+		transactionInputs = append(transactionInputs, refundTransactionInput{Amount: t.TransactionAmount.Amount, ParentID: t.ParentID})
+	}
+	transactions, userErr := s.refundTransactions(o, transactionInputs, "returnRefundInput", "orderTransactions")
+	if userErr != nil {
+		return payload(object{"refund": nil}, userErr), nil
+	}
+
+	refund := &Refund{ID: s.newID(), CreatedAt: s.now(), LineItems: lines, Shipping: shipping}
+	for _, t := range transactions {
+		s.addTransaction(o, t)
+		refund.Transactions = append(refund.Transactions, t)
+	}
+	o.Refunds = append(o.Refunds, refund)
+	open := false
+	for _, q := range quantities {
+		q.item.RefundedQuantity += q.n
+	}
+	for _, rli := range ret.LineItems {
+		open = open || rli.RefundedQuantity < rli.Quantity
+	}
+	if !open {
+		ret.Status = "CLOSED"
+	}
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	return payload(object{"refund": s.refundObject(o, refund)}), nil
+}
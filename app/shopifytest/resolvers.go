@@ -0,0 +1,1313 @@
+package shopifytest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rootField resolves a root query or mutation field from its arguments
+type rootField func(args map[string]interface{}) (interface{}, error)
+
+func (s *Server) queryRoot() map[string]rootField {
+	return map[string]rootField{
+		"shop": s.queryShop,
+		"node": s.queryNode,
+		"draftOrder": func(args map[string]interface{}) (interface{}, error) {
+			return s.draftOrderObject(s.findDraftOrder(args["id"])), nil
+		},
+		"order":            s.queryOrder,
+		"orders":           s.queryOrders,
+		"fulfillmentOrder": s.queryFulfillmentOrder,
+		"customer": func(args map[string]interface{}) (interface{}, error) {
+			return s.customerObject(s.findCustomer(args["id"])), nil
+		},
+		"customers": s.queryCustomers,
+		"productVariant": func(args map[string]interface{}) (interface{}, error) {
+			return s.variantObject(s.findVariant(args["id"])), nil
+		},
+		"location": func(args map[string]interface{}) (interface{}, error) {
+			return s.locationObject(s.findLocation(args["id"])), nil
+		},
+		"locations":            s.queryLocations,
+		"job":                  s.queryJob,
+		"metafieldDefinitions": s.queryMetafieldDefinitions,
+	}
+}
+
+func (s *Server) mutationRoot() map[string]rootField {
+	return map[string]rootField{
+		"draftOrderCreate":             s.draftOrderCreate,
+		"draftOrderCalculate":          s.draftOrderCalculate,
+		"draftOrderUpdate":             s.draftOrderUpdate,
+		"draftOrderComplete":           s.draftOrderComplete,
+		"orderCreate":                  s.orderCreate,
+		"orderUpdate":                  s.orderUpdate,
+		"orderCancel":                  s.orderCancel,
+		"refundCreate":                 s.refundCreate,
+		"returnCreate":                 s.returnCreate,
+		"returnRefund":                 s.returnRefund,
+		"orderMarkAsPaid":              s.orderMarkAsPaid,
+		"orderCapture":                 s.orderCapture,
+		"transactionVoid":              s.transactionVoid,
+		"fulfillmentCreateV2":          s.fulfillmentCreateV2,
+		"orderEditBegin":               s.orderEditBegin,
+		"orderEditAddVariant":          s.orderEditAddVariant,
+		"orderEditAddCustomItem":       s.orderEditAddCustomItem,
+		"orderEditSetQuantity":         s.orderEditSetQuantity,
+		"orderEditAddLineItemDiscount": s.orderEditAddLineItemDiscount,
+		"orderEditUpdateDiscount":      s.orderEditUpdateDiscount,
+		"orderEditRemoveDiscount":      s.orderEditRemoveDiscount,
+		"orderEditAddShippingLine":     s.orderEditAddShippingLine,
+		"orderEditUpdateShippingLine":  s.orderEditUpdateShippingLine,
+		"orderEditRemoveShippingLine":  s.orderEditRemoveShippingLine,
+		"orderEditCommit":              s.orderEditCommit,
+		"metafieldsSet":                s.metafieldsSet,
+		"metafieldDefinitionCreate":    s.metafieldDefinitionCreate,
+		"customerAddressCreate":        s.customerAddressCreate,
+		"customerAddressDelete":        s.customerAddressDelete,
+		"customerUpdateDefaultAddress": s.customerUpdateDefaultAddress,
+	}
+}
+
+// payload builds a mutation payload with its userErrors
+func payload(fields object, userErrors ...object) object {
+	if userErrors == nil {
+		userErrors = []object{}
+	}
+	fields["userErrors"] = userErrors
+	return fields
+}
+
+func userError(message string, field ...string) object {
+	return object{"__typename": "UserError", "field": field, "message": message}
+}
+
+// Finders take a GID, a numeric string or an int64. They must be called with s.mu held.
+
+func (s *Server) findVariant(id interface{}) *Variant {
+	n := toID(id)
+	for _, v := range s.variants {
+		if v.ID == n {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *Server) findLocation(id interface{}) *Location {
+	n := toID(id)
+	for _, l := range s.locations {
+		if l.ID == n {
+			return l
+		}
+	}
+	return nil
+}
+
+func (s *Server) findCustomer(id interface{}) *Customer {
+	n := toID(id)
+	for _, c := range s.customers {
+		if c.ID == n {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) findDraftOrder(id interface{}) *DraftOrder {
+	n := toID(id)
+	for _, d := range s.draftOrders {
+		if d.ID == n {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Server) findOrder(id interface{}) *Order {
+	n := toID(id)
+	for _, o := range s.orders {
+		if o.ID == n {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) findFulfillmentOrder(id interface{}) (*Order, *FulfillmentOrder) {
+	n := toID(id)
+	for _, o := range s.orders {
+		for _, fo := range o.FulfillmentOrders {
+			if fo.ID == n {
+				return o, fo
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) findFulfillment(id interface{}) (*Order, *Fulfillment) {
+	n := toID(id)
+	for _, o := range s.orders {
+		for _, f := range o.Fulfillments {
+			if f.ID == n {
+				return o, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) findCalculatedOrder(id interface{}) *calculatedOrder {
+	n := toID(id)
+	for _, co := range s.calculatedOrders {
+		if co.ID == n {
+			return co
+		}
+	}
+	return nil
+}
+
+func toID(id interface{}) int64 {
+	if n, ok := id.(int64); ok {
+		return n
+	}
+	return parseID(id)
+}
+
+// gidType returns the type name of a GID, e.g. "Order"
+func gidType(id interface{}) string {
+	s, _ := id.(string)
+	s = strings.TrimPrefix(s, "gid://shopify/")
+	typeName, _, _ := strings.Cut(s, "/")
+	return typeName
+}
+
+func (s *Server) queryShop(map[string]interface{}) (interface{}, error) {
+	return object{
+		"__typename":      "Shop",
+		"id":              gid("Shop", 1),
+		"name":            "Shopify Test Shop",
+		"email":           "owner@example.com",
+		"myshopifyDomain": s.ShopDomain(),
+		"currencyCode":    s.Currency,
+	}, nil
+}
+
+func (s *Server) queryNode(args map[string]interface{}) (interface{}, error) {
+	switch gidType(args["id"]) {
+	case "Order":
+		return s.queryOrder(args)
+	case "DraftOrder":
+		return s.draftOrderObject(s.findDraftOrder(args["id"])), nil
+	case "CalculatedOrder":
+		return s.calculatedOrderObject(s.findCalculatedOrder(args["id"])), nil
+	case "FulfillmentOrder":
+		return s.queryFulfillmentOrder(args)
+	case "Customer":
+		return s.customerObject(s.findCustomer(args["id"])), nil
+	case "ProductVariant":
+		return s.variantObject(s.findVariant(args["id"])), nil
+	case "Location":
+		return s.locationObject(s.findLocation(args["id"])), nil
+	case "Refund":
+		if o, r := s.findRefund(args["id"]); r != nil {
+			return s.refundObject(o, r), nil
+		}
+	case "Fulfillment":
+		if o, f := s.findFulfillment(args["id"]); f != nil {
+			return s.fulfillmentObject(o, f), nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) queryOrder(args map[string]interface{}) (interface{}, error) {
+	o := s.findOrder(args["id"])
+	if o == nil {
+		return nil, nil
+	}
+	// Fulfillment orders appear after FulfillmentOrderDelay order queries
+	order := s.orderObject(o)
+	s.foQueries[o.ID]++
+	return order, nil
+}
+
+func (s *Server) queryOrders(args map[string]interface{}) (interface{}, error) {
+	query, _ := args["query"].(string)
+	filter := parseSearchQuery(query)
+	var orders []object
+	for _, o := range s.orders {
+		if filter.matchOrder(o) {
+			orders = append(orders, s.orderObject(o).(object))
+		}
+	}
+	if reverse, _ := args["reverse"].(bool); reverse {
+		for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+			orders[i], orders[j] = orders[j], orders[i]
+		}
+	}
+	return connection(orders)(args)
+}
+
+// queryJob reports a job as done after JobDelay queries
+func (s *Server) queryJob(args map[string]interface{}) (interface{}, error) {
+	id, _ := args["id"].(string)
+	queries, ok := s.jobQueries[id]
+	if !ok {
+		return nil, nil
+	}
+	s.jobQueries[id] = queries + 1
+	return object{"__typename": "Job", "id": id, "done": queries >= s.JobDelay}, nil
+}
+
+func (s *Server) queryFulfillmentOrder(args map[string]interface{}) (interface{}, error) {
+	o, fo := s.findFulfillmentOrder(args["id"])
+	if fo == nil {
+		return nil, nil
+	}
+	return s.fulfillmentOrderObject(o, fo), nil
+}
+
+func (s *Server) queryCustomers(args map[string]interface{}) (interface{}, error) {
+	query, _ := args["query"].(string)
+	filter := parseSearchQuery(query)
+	var customers []object
+	for _, c := range s.customers {
+		if filter.matchCustomer(c) {
+			customers = append(customers, s.customerObject(c).(object))
+		}
+	}
+	return connection(customers)(args)
+}
+
+func (s *Server) queryLocations(args map[string]interface{}) (interface{}, error) {
+	var locations []object
+	for _, l := range s.locations {
+		locations = append(locations, s.locationObject(l).(object))
+	}
+	return connection(locations)(args)
+}
+
+func (s *Server) queryMetafieldDefinitions(args map[string]interface{}) (interface{}, error) {
+	ownerType, _ := args["ownerType"].(string)
+	namespace, _ := args["namespace"].(string)
+	var definitions []object
+	for _, d := range s.metafieldDefinitions {
+		if (ownerType == "" || d.OwnerType == ownerType) && (namespace == "" || d.Namespace == namespace) {
+			definitions = append(definitions, metafieldDefinitionObject(d))
+		}
+	}
+	return connection(definitions)(args)
+}
+
+// searchQuery is a parsed Shopify search string such as "tag:'pos:abc' name:#1001"
+type searchQuery struct {
+	terms map[string][]string
+	text  []string
+}
+
+func parseSearchQuery(query string) searchQuery {
+	q := searchQuery{terms: map[string][]string{}}
+	for _, token := range splitSearchTokens(query) {
+		if token == "AND" {
+			continue
+		}
+		key, value, ok := strings.Cut(token, ":")
+		if !ok {
+			q.text = append(q.text, strings.ToLower(unquoteSearch(token)))
+			continue
+		}
+		q.terms[strings.ToLower(key)] = append(q.terms[strings.ToLower(key)], unquoteSearch(value))
+	}
+	return q
+}
+
+// splitSearchTokens splits on spaces outside quotes
+func splitSearchTokens(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range query {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+			continue
+		case r == '\\':
+			current.WriteRune(r)
+			escaped = true
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ':
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func unquoteSearch(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	var out strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func (q searchQuery) matchOrder(o *Order) bool {
+	for key, values := range q.terms {
+		for _, value := range values {
+			var ok bool
+			switch key {
+			case "tag":
+				ok = containsFold(o.Tags, value)
+			case "name":
+				ok = strings.EqualFold(strings.TrimPrefix(o.Name, "#"), strings.TrimPrefix(value, "#"))
+			case "email":
+				ok = strings.EqualFold(o.Email, value)
+			case "financial_status":
+				ok = strings.EqualFold(o.FinancialStatus, value)
+			case "fulfillment_status":
+				ok = strings.EqualFold(o.FulfillmentStatus, value)
+			case "id":
+				ok = strconv.FormatInt(o.ID, 10) == value
+			default:
+				// Unsupported filters (status, created_at, ...) match everything
+				ok = true
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	for _, text := range q.text {
+		if !strings.Contains(strings.ToLower(o.Name+" "+o.Email+" "+o.Note), text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q searchQuery) matchCustomer(c *Customer) bool {
+	for key, values := range q.terms {
+		for _, value := range values {
+			var ok bool
+			switch key {
+			case "email":
+				ok = strings.EqualFold(c.Email, value)
+			case "phone":
+				ok = c.Phone == value
+			case "id":
+				ok = strconv.FormatInt(c.ID, 10) == value
+			default:
+				ok = true
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	for _, text := range q.text {
+		if !strings.Contains(strings.ToLower(c.FirstName+" "+c.LastName+" "+c.Email), text) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mutation inputs. Only the fields the fake uses are decoded.
+
+type moneyInput struct {
+	Amount flexFloat `json:"amount"`
+}
+
+type moneyBagInput struct {
+	ShopMoney *moneyInput `json:"shopMoney"`
+}
+
+type taxLineInput struct {
+	Title    string         `json:"title"`
+	Rate     flexFloat      `json:"rate"`
+	PriceSet *moneyBagInput `json:"priceSet"`
+}
+
+type appliedDiscountInput struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ValueType   string    `json:"valueType"`
+	Value       flexFloat `json:"value"`
+}
+
+type draftLineItemInput struct {
+	VariantID         string                `json:"variantId"`
+	Quantity          int                   `json:"quantity"`
+	OriginalUnitPrice *flexFloat            `json:"originalUnitPrice"`
+	Title             string                `json:"title"`
+	SKU               string                `json:"sku"`
+	Taxable           *bool                 `json:"taxable"`
+	AppliedDiscount   *appliedDiscountInput `json:"appliedDiscount"`
+}
+
+type shippingLineInput struct {
+	Title    string         `json:"title"`
+	Price    flexFloat      `json:"price"`
+	PriceSet *moneyBagInput `json:"priceSet"`
+}
+
+type draftOrderInput struct {
+	Email           string                `json:"email"`
+	Note            string                `json:"note"`
+	Tags            flexTags              `json:"tags"`
+	TaxExempt       bool                  `json:"taxExempt"`
+	LineItems       []draftLineItemInput  `json:"lineItems"`
+	ShippingAddress *Address              `json:"shippingAddress"`
+	BillingAddress  *Address              `json:"billingAddress"`
+	ShippingLine    *shippingLineInput    `json:"shippingLine"`
+	AppliedDiscount *appliedDiscountInput `json:"appliedDiscount"`
+}
+
+func (in *appliedDiscountInput) discount() *AppliedDiscount {
+	if in == nil {
+		return nil
+	}
+	return &AppliedDiscount{Title: in.Title, Description: in.Description, ValueType: in.ValueType, Value: float64(in.Value)}
+}
+
+// draftLineItems converts draft line item inputs, resolving variants
+func (s *Server) draftLineItems(inputs []draftLineItemInput) ([]*LineItem, []object) {
+	var items []*LineItem
+	var userErrors []object
+	for i, in := range inputs {
+		field := []string{"lineItems", fmt.Sprint(i)}
+		if in.Quantity <= 0 {
+			userErrors = append(userErrors, userError("Quantity must be greater than 0", append(field, "quantity")...))
+			continue
+		}
+		li := &LineItem{ID: s.newID(), Title: in.Title, SKU: in.SKU, Quantity: in.Quantity, Taxable: true, Discount: in.AppliedDiscount.discount()}
+		if in.VariantID != "" {
+			v := s.findVariant(in.VariantID)
+			if v == nil {
+				userErrors = append(userErrors, userError(fmt.Sprintf("Product with ID %d is no longer available.", parseID(in.VariantID)), append(field, "variantId")...))
+				continue
+			}
+			li.VariantID = v.ID
+			li.Price = v.Price
+			li.Taxable = !v.NotTaxable
+			if li.Title == "" {
+				li.Title = v.Title
+			}
+			if li.SKU == "" {
+				li.SKU = v.SKU
+			}
+		} else if in.Title == "" {
+			userErrors = append(userErrors, userError("Title can't be blank for custom line items", append(field, "title")...))
+			continue
+		}
+		if in.OriginalUnitPrice != nil {
+			li.Price = float64(*in.OriginalUnitPrice)
+		}
+		if in.Taxable != nil && in.VariantID == "" {
+			li.Taxable = *in.Taxable
+		}
+		li.FulfillableQuantity = li.Quantity
+		items = append(items, li)
+	}
+	if len(inputs) == 0 {
+		userErrors = append(userErrors, userError("Add at least 1 product", "lineItems"))
+	}
+	return items, userErrors
+}
+
+// buildDraftOrder applies a DraftOrderInput to d and calculates tax
+func (s *Server) buildDraftOrder(d *DraftOrder, in draftOrderInput) []object {
+	items, userErrors := s.draftLineItems(in.LineItems)
+	if len(userErrors) > 0 {
+		return userErrors
+	}
+	d.Email = in.Email
+	d.Note = in.Note
+	d.Tags = in.Tags
+	d.TaxExempt = in.TaxExempt
+	d.LineItems = items
+	d.ShippingAddress = in.ShippingAddress
+	d.BillingAddress = in.BillingAddress
+	d.ShippingLine = nil
+	if in.ShippingLine != nil {
+		price := float64(in.ShippingLine.Price)
+		if in.ShippingLine.PriceSet != nil && in.ShippingLine.PriceSet.ShopMoney != nil {
+			price = float64(in.ShippingLine.PriceSet.ShopMoney.Amount)
+		}
+		d.ShippingLine = &ShippingLine{Title: in.ShippingLine.Title, Price: price}
+	}
+	s.applyTax(d.LineItems, d.TaxExempt)
+	return nil
+}
+
+func (s *Server) draftOrderCreate(args map[string]interface{}) (interface{}, error) {
+	var in draftOrderInput
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	d := &DraftOrder{ID: s.newID(), Status: "OPEN", CreatedAt: s.now()}
+	if userErrors := s.buildDraftOrder(d, in); userErrors != nil {
+		return payload(object{"draftOrder": nil}, userErrors...), nil
+	}
+	d.Name = fmt.Sprintf("#D%d", s.nextDraftNumber)
+	s.nextDraftNumber++
+	s.draftOrders = append(s.draftOrders, d)
+	return payload(object{"draftOrder": s.draftOrderObject(d)}), nil
+}
+
+func (s *Server) draftOrderCalculate(args map[string]interface{}) (interface{}, error) {
+	var in draftOrderInput
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	d := &DraftOrder{Status: "OPEN", CreatedAt: s.now()}
+	if userErrors := s.buildDraftOrder(d, in); userErrors != nil {
+		return payload(object{"calculatedDraftOrder": nil}, userErrors...), nil
+	}
+	return payload(object{"calculatedDraftOrder": s.draftOrderObject(d)}), nil
+}
+
+func (s *Server) draftOrderUpdate(args map[string]interface{}) (interface{}, error) {
+	d := s.findDraftOrder(args["id"])
+	if d == nil {
+		return payload(object{"draftOrder": nil}, userError("Draft order does not exist", "id")), nil
+	}
+	if d.Status == "COMPLETED" {
+		return payload(object{"draftOrder": nil}, userError("This order has been paid and can no longer be edited", "id")), nil
+	}
+	var in draftOrderInput
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	updated := d.clone()
+	if in.LineItems == nil {
+		// Line items are only replaced when given
+		for _, li := range updated.LineItems {
+			in.LineItems = append(in.LineItems, draftLineItemInput{
+				VariantID:         gidOrEmpty("ProductVariant", li.VariantID),
+				Quantity:          li.Quantity,
+				OriginalUnitPrice: (*flexFloat)(&li.Price),
+				Title:             li.Title,
+				SKU:               li.SKU,
+				Taxable:           &li.Taxable,
+			})
+			if li.Discount != nil {
+				in.LineItems[len(in.LineItems)-1].AppliedDiscount = &appliedDiscountInput{
+					Title: li.Discount.Title, Description: li.Discount.Description,
+					ValueType: li.Discount.ValueType, Value: flexFloat(li.Discount.Value),
+				}
+			}
+		}
+	}
+	if userErrors := s.buildDraftOrder(&updated, in); userErrors != nil {
+		return payload(object{"draftOrder": nil}, userErrors...), nil
+	}
+	*d = updated
+	return payload(object{"draftOrder": s.draftOrderObject(d)}), nil
+}
+
+func gidOrEmpty(typeName string, id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return gid(typeName, id)
+}
+
+func (s *Server) draftOrderComplete(args map[string]interface{}) (interface{}, error) {
+	d := s.findDraftOrder(args["id"])
+	if d == nil {
+		return payload(object{"draftOrder": nil}, userError("Draft order does not exist", "id")), nil
+	}
+	if d.Status == "COMPLETED" {
+		return payload(object{"draftOrder": nil}, userError("This order has already been paid", "id")), nil
+	}
+	paymentPending, _ := args["paymentPending"].(bool)
+
+	o := s.newOrder()
+	o.Email = d.Email
+	o.Note = d.Note
+	o.Tags = append([]string(nil), d.Tags...)
+	o.LineItems = cloneLineItems(d.LineItems)
+	for _, li := range o.LineItems {
+		li.ID = s.newID()
+	}
+	o.ShippingAddress = d.ShippingAddress
+	o.BillingAddress = d.BillingAddress
+	if d.ShippingLine != nil {
+		o.ShippingLines = []ShippingLine{*d.ShippingLine}
+	}
+	o.DraftOrderID = d.ID
+	o.SourceName = "shopify_draft_order"
+	s.placeOrder(o)
+	if paymentPending {
+		o.FinancialStatus = "PENDING"
+	} else {
+		s.addTransaction(o, &Transaction{Kind: "sale", Status: "success", Amount: o.Total(), Gateway: "manual"})
+		o.FinancialStatus = "PAID"
+	}
+
+	d.Status = "COMPLETED"
+	d.OrderID = o.ID
+	return payload(object{"draftOrder": s.draftOrderObject(d)}), nil
+}
+
+// newOrder allocates an order with the next ID and name
+func (s *Server) newOrder() *Order {
+	o := &Order{
+		ID:                s.newID(),
+		Number:            s.nextOrderNumber,
+		FinancialStatus:   "PENDING",
+		FulfillmentStatus: "UNFULFILLED",
+		CreatedAt:         s.now(),
+	}
+	o.UpdatedAt = o.CreatedAt
+	o.Name = fmt.Sprintf("#%d", o.Number)
+	s.nextOrderNumber++
+	return o
+}
+
+// placeOrder stores a new order and routes it to the first location
+func (s *Server) placeOrder(o *Order) {
+	fo := &FulfillmentOrder{ID: s.newID(), Status: "OPEN", RequestStatus: "UNSUBMITTED"}
+	if len(s.locations) > 0 {
+		fo.LocationID = s.locations[0].ID
+	}
+	for _, li := range o.LineItems {
+		li.FulfillableQuantity = li.Quantity
+		fo.LineItems = append(fo.LineItems, &FulfillmentOrderLineItem{
+			ID:                s.newID(),
+			LineItemID:        li.ID,
+			TotalQuantity:     li.Quantity,
+			RemainingQuantity: li.Quantity,
+		})
+	}
+	o.FulfillmentOrders = []*FulfillmentOrder{fo}
+	s.orders = append(s.orders, o)
+}
+
+func (s *Server) addTransaction(o *Order, t *Transaction) {
+	t.ID = s.newID()
+	if t.Currency == "" {
+		t.Currency = s.Currency
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = s.now()
+	}
+	o.Transactions = append(o.Transactions, t)
+}
+
+type orderCreateLineItemInput struct {
+	VariantID string         `json:"variantId"`
+	Quantity  int            `json:"quantity"`
+	Price     *flexFloat     `json:"price"`
+	PriceSet  *moneyBagInput `json:"priceSet"`
+	Title     string         `json:"title"`
+	SKU       string         `json:"sku"`
+	Taxable   *bool          `json:"taxable"`
+	TaxLines  []taxLineInput `json:"taxLines"`
+}
+
+type orderCreateInput struct {
+	Email           string                     `json:"email"`
+	Note            string                     `json:"note"`
+	Tags            flexTags                   `json:"tags"`
+	FinancialStatus string                     `json:"financialStatus"`
+	LineItems       []orderCreateLineItemInput `json:"lineItems"`
+	ShippingAddress *Address                   `json:"shippingAddress"`
+	BillingAddress  *Address                   `json:"billingAddress"`
+	ShippingLines   []shippingLineInput        `json:"shippingLines"`
+	TaxLines        []taxLineInput             `json:"taxLines"`
+	CustomerID      string                     `json:"customerId"`
+	Customer        *struct {
+		ToAssociate *struct {
+			ID string `json:"id"`
+		} `json:"toAssociate"`
+		ID string `json:"id"`
+	} `json:"customer"`
+	Metafields []struct {
+		Namespace string `json:"namespace"`
+		Key       string `json:"key"`
+		Type      string `json:"type"`
+		Value     string `json:"value"`
+	} `json:"metafields"`
+	Transactions []struct {
+		Kind      string         `json:"kind"`
+		Status    string         `json:"status"`
+		Gateway   string         `json:"gateway"`
+		AmountSet *moneyBagInput `json:"amountSet"`
+	} `json:"transactions"`
+}
+
+func taxLines(inputs []taxLineInput) []TaxLine {
+	var out []TaxLine
+	for _, in := range inputs {
+		tl := TaxLine{Title: in.Title, Rate: float64(in.Rate)}
+		if in.PriceSet != nil && in.PriceSet.ShopMoney != nil {
+			tl.Price = float64(in.PriceSet.ShopMoney.Amount)
+		}
+		out = append(out, tl)
+	}
+	return out
+}
+
+func (s *Server) orderCreate(args map[string]interface{}) (interface{}, error) {
+	arg, ok := args["order"]
+	if !ok {
+		arg = args["input"]
+	}
+	var in orderCreateInput
+	if err := decodeArg(arg, &in); err != nil {
+		return nil, err
+	}
+
+	var userErrors []object
+	var items []*LineItem
+	for i, li := range in.LineItems {
+		field := []string{"order", "lineItems", fmt.Sprint(i)}
+		item := &LineItem{ID: s.newID(), Title: li.Title, SKU: li.SKU, Quantity: li.Quantity, Taxable: true, TaxLines: taxLines(li.TaxLines)}
+		if li.VariantID != "" {
+			v := s.findVariant(li.VariantID)
+			if v == nil {
+				userErrors = append(userErrors, userError("invalid variant", append(field, "variantId")...))
+				continue
+			}
+			item.VariantID = v.ID
+			item.Price = v.Price
+			item.Taxable = !v.NotTaxable
+			if item.Title == "" {
+				item.Title = v.Title
+			}
+			if item.SKU == "" {
+				item.SKU = v.SKU
+			}
+		}
+		if li.Price != nil {
+			item.Price = float64(*li.Price)
+		}
+		if li.PriceSet != nil && li.PriceSet.ShopMoney != nil {
+			item.Price = float64(li.PriceSet.ShopMoney.Amount)
+		}
+		if li.Taxable != nil {
+			item.Taxable = *li.Taxable
+		}
+		if item.Quantity <= 0 {
+			userErrors = append(userErrors, userError("Quantity must be greater than 0", append(field, "quantity")...))
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(in.LineItems) == 0 {
+		userErrors = append(userErrors, userError("Line items must have at least one line item", "order", "lineItems"))
+	}
+	orderTaxLines := taxLines(in.TaxLines)
+	if len(orderTaxLines) > 0 {
+		for _, li := range items {
+			if len(li.TaxLines) > 0 {
+				userErrors = append(userErrors, userError("Tax lines can be set on the order or on line items, not both", "order", "taxLines"))
+				break
+			}
+		}
+	}
+	if len(userErrors) > 0 {
+		return payload(object{"order": nil}, userErrors...), nil
+	}
+
+	o := s.newOrder()
+	o.Email = in.Email
+	o.Note = in.Note
+	o.Tags = in.Tags
+	o.LineItems = items
+	o.TaxLines = orderTaxLines
+	o.ShippingAddress = in.ShippingAddress
+	o.BillingAddress = in.BillingAddress
+	o.SourceName = "api"
+	for _, sl := range in.ShippingLines {
+		price := float64(sl.Price)
+		if sl.PriceSet != nil && sl.PriceSet.ShopMoney != nil {
+			price = float64(sl.PriceSet.ShopMoney.Amount)
+		}
+		o.ShippingLines = append(o.ShippingLines, ShippingLine{Title: sl.Title, Price: price})
+	}
+	customerID := in.CustomerID
+	if in.Customer != nil {
+		customerID = in.Customer.ID
+		if in.Customer.ToAssociate != nil {
+			customerID = in.Customer.ToAssociate.ID
+		}
+	}
+	if c := s.findCustomer(customerID); c != nil {
+		o.CustomerID = c.ID
+	}
+	for _, m := range in.Metafields {
+		o.Metafields = append(o.Metafields, &Metafield{ID: s.newID(), Namespace: m.Namespace, Key: m.Key, Type: m.Type, Value: m.Value})
+	}
+	s.placeOrder(o)
+
+	for _, t := range in.Transactions {
+		transaction := &Transaction{Kind: strings.ToLower(t.Kind), Status: strings.ToLower(t.Status), Gateway: t.Gateway}
+		if transaction.Status == "" {
+			transaction.Status = "success"
+		}
+		if t.AmountSet != nil && t.AmountSet.ShopMoney != nil {
+			transaction.Amount = float64(t.AmountSet.ShopMoney.Amount)
+		}
+		s.addTransaction(o, transaction)
+	}
+	if in.FinancialStatus != "" {
+		o.FinancialStatus = strings.ToUpper(in.FinancialStatus)
+	} else {
+		s.updateFinancialStatus(o)
+	}
+
+	return payload(object{"order": s.orderObject(o)}), nil
+}
+
+func (s *Server) orderUpdate(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		ID              string   `json:"id"`
+		Note            *string  `json:"note"`
+		Tags            flexTags `json:"tags"`
+		Email           *string  `json:"email"`
+		ShippingAddress *Address `json:"shippingAddress"`
+	}
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	id := args["id"]
+	if in.ID != "" {
+		id = in.ID
+	}
+	o := s.findOrder(id)
+	if o == nil {
+		return payload(object{"order": nil}, userError("Order does not exist", "id")), nil
+	}
+	if in.Note != nil {
+		o.Note = *in.Note
+	}
+	if in.Tags != nil {
+		o.Tags = in.Tags
+	}
+	if in.Email != nil {
+		o.Email = *in.Email
+	}
+	if in.ShippingAddress != nil {
+		o.ShippingAddress = in.ShippingAddress
+	}
+	o.UpdatedAt = s.now()
+	return payload(object{"order": s.orderObject(o)}), nil
+}
+
+// newJob starts an async job; its effects are applied by the caller right away
+func (s *Server) newJob() object {
+	id := gid("Job", s.newID())
+	s.jobQueries[id] = 0
+	return object{"__typename": "Job", "id": id, "done": false}
+}
+
+func orderCancelPayload(job interface{}, message, code string, field ...string) object {
+	out := payload(object{"job": job})
+	out["orderCancelUserErrors"] = []object{}
+	if message != "" {
+		out["userErrors"] = []object{userError(message, field...)}
+		out["orderCancelUserErrors"] = []object{{"__typename": "OrderCancelUserError", "field": field, "message": message, "code": code}}
+	}
+	return out
+}
+
+func (s *Server) orderCancel(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		OrderID        string `json:"orderId"`
+		Reason         string `json:"reason"`
+		Restock        bool   `json:"restock"`
+		Refund         *bool  `json:"refund"`
+		NotifyCustomer bool   `json:"notifyCustomer"`
+		StaffNote      string `json:"staffNote"`
+		RefundMethod   *struct {
+			OriginalPaymentMethodsRefund bool `json:"originalPaymentMethodsRefund"`
+			StoreCreditRefund            *struct {
+				ExpiresAt *string `json:"expiresAt"`
+			} `json:"storeCreditRefund"`
+		} `json:"refundMethod"`
+	}
+	if err := decodeArg(args, &in); err != nil {
+		return nil, err
+	}
+	o := s.findOrder(in.OrderID)
+	switch {
+	case o == nil:
+		return orderCancelPayload(nil, "Order does not exist", "NOT_FOUND", "orderId"), nil
+	case o.CancelledAt != nil:
+		return orderCancelPayload(nil, "Cannot cancel an order that has already been cancelled", "INVALID", "orderId"), nil
+	case in.Refund != nil && in.RefundMethod != nil:
+		return orderCancelPayload(nil, "Cannot specify both refund and refundMethod", "INVALID", "refundMethod"), nil
+	}
+	switch in.Reason {
+	case "CUSTOMER", "DECLINED", "FRAUD", "INVENTORY", "STAFF", "OTHER":
+	default:
+		return nil, &gqlError{Message: fmt.Sprintf("Variable $reason of type OrderCancelReason! was provided invalid value %q", in.Reason), Code: "INVALID_VARIABLE"}
+	}
+
+	now := s.now()
+	o.CancelledAt = &now
+	o.CancelReason = in.Reason
+	o.StaffNote = in.StaffNote
+
+	for _, li := range o.LineItems {
+		if in.Restock && li.VariantID != 0 {
+			if v := s.findVariant(li.VariantID); v != nil {
+				v.InventoryQuantity += li.FulfillableQuantity
+			}
+		}
+		li.FulfillableQuantity = 0
+	}
+	for _, fo := range o.FulfillmentOrders {
+		fo.Status = "CLOSED"
+		for _, foli := range fo.LineItems {
+			foli.RemainingQuantity = 0
+		}
+	}
+
+	originalRefund := in.Refund != nil && *in.Refund
+	storeCredit := false
+	if in.RefundMethod != nil {
+		originalRefund = in.RefundMethod.OriginalPaymentMethodsRefund
+		storeCredit = in.RefundMethod.StoreCreditRefund != nil
+	}
+	refund := &Refund{ID: s.newID(), CreatedAt: now, Note: in.StaffNote}
+	for _, t := range append([]*Transaction(nil), o.Transactions...) {
+		if t.Status != "success" {
+			continue
+		}
+		switch t.Kind {
+		case "authorization":
+			if s.childAmount(o, t.ID, "void")+s.childAmount(o, t.ID, "capture") == 0 {
+				s.addTransaction(o, &Transaction{Kind: "void", Status: "success", Gateway: t.Gateway, ParentID: t.ID, Amount: t.Amount})
+			}
+		case "sale", "capture":
+			remaining := round2(t.Amount - s.childAmount(o, t.ID, "refund"))
+			if remaining <= 0 || !(originalRefund || storeCredit) {
+				continue
+			}
+			rt := &Transaction{Kind: "refund", Status: "success", Gateway: t.Gateway, ParentID: t.ID, Amount: remaining}
+			if storeCredit && !originalRefund {
+				rt.Gateway = "shopify_store_credit"
+				rt.ParentID = 0
+			}
+			s.addTransaction(o, rt)
+			refund.Transactions = append(refund.Transactions, rt)
+		}
+	}
+	if len(refund.Transactions) > 0 {
+		for _, li := range o.LineItems {
+			restockType := "NO_RESTOCK"
+			if in.Restock {
+				restockType = "CANCEL"
+			}
+			refund.LineItems = append(refund.LineItems, RefundLineItem{LineItemID: li.ID, Quantity: li.Quantity, RestockType: restockType, Subtotal: li.Total()})
+		}
+		o.Refunds = append(o.Refunds, refund)
+	}
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = now
+	return orderCancelPayload(s.newJob(), "", ""), nil
+}
+
+// childAmount returns the total of the successful transactions of a kind with the given parent
+func (s *Server) childAmount(o *Order, parentID int64, kind string) float64 {
+	total := 0.0
+	for _, t := range o.Transactions {
+		if t.ParentID == parentID && t.Kind == kind && t.Status == "success" {
+			total += t.Amount
+		}
+	}
+	return round2(total)
+}
+
+func (s *Server) fulfillmentCreateV2(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		NotifyCustomer bool `json:"notifyCustomer"`
+		TrackingInfo   *struct {
+			Number  string `json:"number"`
+			Company string `json:"company"`
+			URL     string `json:"url"`
+		} `json:"trackingInfo"`
+		LineItemsByFulfillmentOrder []struct {
+			FulfillmentOrderID        string `json:"fulfillmentOrderId"`
+			FulfillmentOrderLineItems []struct {
+				ID       string `json:"id"`
+				Quantity int    `json:"quantity"`
+			} `json:"fulfillmentOrderLineItems"`
+		} `json:"lineItemsByFulfillmentOrder"`
+	}
+	if err := decodeArg(args["fulfillment"], &in); err != nil {
+		return nil, err
+	}
+	if len(in.LineItemsByFulfillmentOrder) == 0 {
+		return payload(object{"fulfillment": nil}, userError("Line items by fulfillment order can't be blank", "fulfillment", "lineItemsByFulfillmentOrder")), nil
+	}
+
+	// Validate everything before changing state
+	var order *Order
+	type quantity struct {
+		item *FulfillmentOrderLineItem
+		n    int
+	}
+	var quantities []quantity
+	for i, group := range in.LineItemsByFulfillmentOrder {
+		field := []string{"fulfillment", "lineItemsByFulfillmentOrder", fmt.Sprint(i)}
+		o, fo := s.findFulfillmentOrder(group.FulfillmentOrderID)
+		if fo == nil {
+			return payload(object{"fulfillment": nil}, userError("Fulfillment order does not exist.", append(field, "fulfillmentOrderId")...)), nil
+		}
+		if order != nil && order != o {
+			return payload(object{"fulfillment": nil}, userError("All fulfillment orders must belong to the same order.", field...)), nil
+		}
+		order = o
+		if fo.Status == "CLOSED" {
+			return payload(object{"fulfillment": nil}, userError("Fulfillment order "+gid("FulfillmentOrder", fo.ID)+" has an unfulfillable status= closed.", field...)), nil
+		}
+		if len(group.FulfillmentOrderLineItems) == 0 {
+			for _, item := range fo.LineItems {
+				if item.RemainingQuantity > 0 {
+					quantities = append(quantities, quantity{item, item.RemainingQuantity})
+				}
+			}
+			continue
+		}
+		for _, requested := range group.FulfillmentOrderLineItems {
+			var found *FulfillmentOrderLineItem
+			for _, item := range fo.LineItems {
+				if item.ID == parseID(requested.ID) {
+					found = item
+				}
+			}
+			if found == nil || requested.Quantity <= 0 || requested.Quantity > found.RemainingQuantity {
+				return payload(object{"fulfillment": nil}, userError("Invalid fulfillment order line item quantity requested.", field...)), nil
+			}
+			quantities = append(quantities, quantity{found, requested.Quantity})
+		}
+	}
+
+	for _, q := range quantities {
+		q.item.RemainingQuantity -= q.n
+		for _, li := range order.LineItems {
+			if li.ID == q.item.LineItemID {
+				li.FulfillableQuantity -= q.n
+			}
+		}
+	}
+	remaining := 0
+	fulfilled := 0
+	for _, fo := range order.FulfillmentOrders {
+		foRemaining := 0
+		for _, item := range fo.LineItems {
+			foRemaining += item.RemainingQuantity
+			fulfilled += item.TotalQuantity - item.RemainingQuantity
+		}
+		if foRemaining == 0 {
+			fo.Status = "CLOSED"
+		} else if foRemaining > 0 && fo.Status == "OPEN" && len(quantities) > 0 {
+			fo.Status = "IN_PROGRESS"
+		}
+		remaining += foRemaining
+	}
+	switch {
+	case remaining == 0:
+		order.FulfillmentStatus = "FULFILLED"
+	case fulfilled > 0:
+		order.FulfillmentStatus = "PARTIALLY_FULFILLED"
+	}
+
+	f := &Fulfillment{ID: s.newID(), Status: "SUCCESS", CreatedAt: s.now()}
+	for _, q := range quantities {
+		var fli *FulfillmentLineItem
+		for _, existing := range f.LineItems {
+			if existing.LineItemID == q.item.LineItemID {
+				fli = existing
+			}
+		}
+		if fli == nil {
+			fli = &FulfillmentLineItem{ID: s.newID(), LineItemID: q.item.LineItemID}
+			f.LineItems = append(f.LineItems, fli)
+		}
+		fli.Quantity += q.n
+	}
+	if in.TrackingInfo != nil {
+		f.TrackingNumber = in.TrackingInfo.Number
+		f.TrackingCompany = in.TrackingInfo.Company
+		f.TrackingURL = in.TrackingInfo.URL
+	}
+	order.Fulfillments = append(order.Fulfillments, f)
+	return payload(object{"fulfillment": s.fulfillmentObject(order, f)}), nil
+}
+
+func (s *Server) metafieldsSet(args map[string]interface{}) (interface{}, error) {
+	var inputs []struct {
+		OwnerID   string `json:"ownerId"`
+		Namespace string `json:"namespace"`
+		Key       string `json:"key"`
+		Type      string `json:"type"`
+		Value     string `json:"value"`
+	}
+	if err := decodeArg(args["metafields"], &inputs); err != nil {
+		return nil, err
+	}
+
+	var userErrors []object
+	for i, in := range inputs {
+		field := []string{"metafields", fmt.Sprint(i)}
+		if gidType(in.OwnerID) != "Order" || s.findOrder(in.OwnerID) == nil {
+			userErrors = append(userErrors, userError("Owner does not exist.", append(field, "ownerId")...))
+		}
+		if in.Key == "" {
+			userErrors = append(userErrors, userError("Key can't be blank.", append(field, "key")...))
+		}
+	}
+	if userErrors != nil {
+		return payload(object{"metafields": nil}, userErrors...), nil
+	}
+
+	var metafields []object
+	for _, in := range inputs {
+		o := s.findOrder(in.OwnerID)
+		namespace := in.Namespace
+		if namespace == "" {
+			namespace = "$app"
+		}
+		var m *Metafield
+		for _, existing := range o.Metafields {
+			if existing.Namespace == namespace && existing.Key == in.Key {
+				m = existing
+			}
+		}
+		if m == nil {
+			m = &Metafield{ID: s.newID(), Namespace: namespace, Key: in.Key}
+			o.Metafields = append(o.Metafields, m)
+		}
+		m.Type = in.Type
+		m.Value = in.Value
+		metafields = append(metafields, metafieldObject(m))
+	}
+	return payload(object{"metafields": metafields}), nil
+}
+
+func (s *Server) metafieldDefinitionCreate(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		Name      string      `json:"name"`
+		Namespace string      `json:"namespace"`
+		Key       string      `json:"key"`
+		OwnerType string      `json:"ownerType"`
+		Type      interface{} `json:"type"`
+	}
+	if err := decodeArg(args["definition"], &in); err != nil {
+		return nil, err
+	}
+	typeName, _ := in.Type.(string)
+	if m, ok := in.Type.(map[string]interface{}); ok {
+		typeName, _ = m["name"].(string)
+	}
+	for _, d := range s.metafieldDefinitions {
+		if d.OwnerType == in.OwnerType && d.Namespace == in.Namespace && d.Key == in.Key {
+			return payload(object{"createdDefinition": nil}, object{
+				"__typename": "MetafieldDefinitionCreateUserError",
+				"field":      []string{"definition", "key"},
+				"message":    "Key is in use for Order metafields on the 'connectpos' namespace.",
+				"code":       "TAKEN",
+			}), nil
+		}
+	}
+	d := &MetafieldDefinition{ID: s.newID(), Name: in.Name, Namespace: in.Namespace, Key: in.Key, Type: typeName, OwnerType: in.OwnerType}
+	s.metafieldDefinitions = append(s.metafieldDefinitions, d)
+	return payload(object{"createdDefinition": metafieldDefinitionObject(d)}), nil
+}
+
+func (s *Server) customerAddressCreate(args map[string]interface{}) (interface{}, error) {
+	c := s.findCustomer(args["customerId"])
+	if c == nil {
+		return payload(object{"address": nil}, userError("Customer does not exist", "customerId")), nil
+	}
+	var address Address
+	if err := decodeArg(args["address"], &address); err != nil {
+		return nil, err
+	}
+	address.ID = s.newID()
+	c.Addresses = append(c.Addresses, &address)
+	if setAsDefault, _ := args["setAsDefault"].(bool); setAsDefault || c.DefaultAddressID == 0 {
+		c.DefaultAddressID = address.ID
+	}
+	return payload(object{"address": addressObject(&address)}), nil
+}
+
+func (s *Server) customerAddressDelete(args map[string]interface{}) (interface{}, error) {
+	c := s.findCustomer(args["customerId"])
+	if c == nil {
+		return payload(object{"deletedAddressId": nil}, userError("Customer does not exist", "customerId")), nil
+	}
+	id := parseID(args["addressId"])
+	for i, a := range c.Addresses {
+		if a.ID != id {
+			continue
+		}
+		c.Addresses = append(c.Addresses[:i], c.Addresses[i+1:]...)
+		if c.DefaultAddressID == id {
+			// Shopify promotes the next address to default
+			c.DefaultAddressID = 0
+			if len(c.Addresses) > 0 {
+				c.DefaultAddressID = c.Addresses[0].ID
+			}
+		}
+		return payload(object{"deletedAddressId": a.GID()}), nil
+	}
+	return payload(object{"deletedAddressId": nil}, userError("Address does not exist", "addressId")), nil
+}
+
+func (s *Server) customerUpdateDefaultAddress(args map[string]interface{}) (interface{}, error) {
+	c := s.findCustomer(args["customerId"])
+	if c == nil {
+		return payload(object{"customer": nil}, userError("Customer does not exist", "customerId")), nil
+	}
+	id := parseID(args["addressId"])
+	for _, a := range c.Addresses {
+		if a.ID == id {
+			c.DefaultAddressID = id
+			return payload(object{"customer": s.customerObject(c)}), nil
+		}
+	}
+	return payload(object{"customer": nil}, userError("Address does not exist", "addressId")), nil
+}
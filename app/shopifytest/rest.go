@@ -0,0 +1,658 @@
+package shopifytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// REST resources use snake_case fields, numeric IDs and lowercase enums
+
+// serveREST serves the REST endpoints the app uses: orders, transactions,
+// fulfillment orders, variants, locations and metafield deletion
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, r.Method+" "+path)
+	w.Header().Set("X-Shopify-Shop-Api-Call-Limit", "1/40")
+
+	segments := strings.Split(strings.TrimSuffix(path, ".json"), "/")
+	route := make([]string, len(segments))
+	var id int64
+	for i, segment := range segments {
+		route[i] = segment
+		if n, err := strconv.ParseInt(segment, 10, 64); err == nil && i == 1 {
+			route[i] = ":id"
+			id = n
+		}
+	}
+
+	var status int
+	var response interface{}
+	switch r.Method + " " + strings.Join(route, "/") {
+	case "GET orders":
+		status, response = s.restListOrders(r)
+	case "POST orders":
+		status, response = s.restCreateOrder(body)
+	case "GET orders/:id":
+		status, response = s.restGetOrder(id)
+	case "PUT orders/:id":
+		status, response = s.restUpdateOrder(id, body)
+	case "GET orders/:id/transactions":
+		status, response = s.restListTransactions(id)
+	case "POST orders/:id/transactions":
+		status, response = s.restCreateTransaction(id, body)
+	case "GET orders/:id/fulfillment_orders":
+		status, response = s.restListFulfillmentOrders(id)
+	case "GET variants/:id":
+		status, response = s.restGetVariant(id)
+	case "PUT variants/:id":
+		status, response = s.restUpdateVariant(id, body)
+	case "GET locations":
+		status, response = s.restListLocations()
+	case "DELETE metafields/:id":
+		status, response = s.restDeleteMetafield(id)
+	default:
+		status, response = notFound()
+	}
+	writeJSON(w, status, response)
+}
+
+func notFound() (int, interface{}) {
+	return http.StatusNotFound, map[string]interface{}{"errors": "Not Found"}
+}
+
+func unprocessable(field, message string) (int, interface{}) {
+	return http.StatusUnprocessableEntity, map[string]interface{}{
+		"errors": map[string][]string{field: {message}},
+	}
+}
+
+func badRequest(err error) (int, interface{}) {
+	return http.StatusBadRequest, map[string]interface{}{"errors": map[string]string{"error": err.Error()}}
+}
+
+func (s *Server) restListOrders(r *http.Request) (int, interface{}) {
+	query := r.URL.Query()
+	name := strings.TrimPrefix(query.Get("name"), "#")
+	financialStatus := query.Get("financial_status")
+	orders := []interface{}{}
+	for _, o := range s.orders {
+		if name != "" && strings.TrimPrefix(o.Name, "#") != name {
+			continue
+		}
+		if financialStatus != "" && financialStatus != "any" && !strings.EqualFold(o.FinancialStatus, financialStatus) {
+			continue
+		}
+		orders = append(orders, s.restOrder(o))
+	}
+	return http.StatusOK, map[string]interface{}{"orders": orders}
+}
+
+func (s *Server) restGetOrder(id int64) (int, interface{}) {
+	o := s.findOrder(id)
+	if o == nil {
+		return notFound()
+	}
+	return http.StatusOK, map[string]interface{}{"order": s.restOrder(o)}
+}
+
+type restTaxLineInput struct {
+	Title string    `json:"title"`
+	Rate  flexFloat `json:"rate"`
+	Price flexFloat `json:"price"`
+}
+
+func restTaxLines(inputs []restTaxLineInput) []TaxLine {
+	var out []TaxLine
+	for _, in := range inputs {
+		out = append(out, TaxLine{Title: in.Title, Rate: float64(in.Rate), Price: float64(in.Price)})
+	}
+	return out
+}
+
+type restAddressInput struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Company   string `json:"company"`
+	Address1  string `json:"address1"`
+	Address2  string `json:"address2"`
+	City      string `json:"city"`
+	Province  string `json:"province"`
+	Country   string `json:"country"`
+	Zip       string `json:"zip"`
+	Phone     string `json:"phone"`
+}
+
+func (in *restAddressInput) address() *Address {
+	if in == nil {
+		return nil
+	}
+	return &Address{
+		FirstName: in.FirstName, LastName: in.LastName, Company: in.Company,
+		Address1: in.Address1, Address2: in.Address2, City: in.City,
+		Province: in.Province, Country: in.Country, Zip: in.Zip, Phone: in.Phone,
+	}
+}
+
+func (s *Server) restCreateOrder(body []byte) (int, interface{}) {
+	var request struct {
+		Order struct {
+			Email           string   `json:"email"`
+			Note            string   `json:"note"`
+			Tags            flexTags `json:"tags"`
+			FinancialStatus string   `json:"financial_status"`
+			LineItems       []struct {
+				VariantID interface{}        `json:"variant_id"`
+				Title     string             `json:"title"`
+				SKU       string             `json:"sku"`
+				Quantity  int                `json:"quantity"`
+				Price     *flexFloat         `json:"price"`
+				Taxable   *bool              `json:"taxable"`
+				TaxLines  []restTaxLineInput `json:"tax_lines"`
+			} `json:"line_items"`
+			TaxLines      []restTaxLineInput `json:"tax_lines"`
+			ShippingLines []struct {
+				Title string    `json:"title"`
+				Price flexFloat `json:"price"`
+			} `json:"shipping_lines"`
+			ShippingAddress *restAddressInput `json:"shipping_address"`
+			BillingAddress  *restAddressInput `json:"billing_address"`
+			Transactions    []struct {
+				Kind    string    `json:"kind"`
+				Status  string    `json:"status"`
+				Amount  flexFloat `json:"amount"`
+				Gateway string    `json:"gateway"`
+			} `json:"transactions"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return badRequest(err)
+	}
+	in := request.Order
+	if len(in.LineItems) == 0 {
+		return unprocessable("line_items", "must have at least one line item")
+	}
+
+	var items []*LineItem
+	for _, li := range in.LineItems {
+		item := &LineItem{ID: s.newID(), Title: li.Title, SKU: li.SKU, Quantity: li.Quantity, Taxable: true, TaxLines: restTaxLines(li.TaxLines)}
+		if li.VariantID != nil {
+			v := s.findVariant(parseID(li.VariantID))
+			if v == nil {
+				return unprocessable("line_items", "variant does not exist")
+			}
+			item.VariantID = v.ID
+			item.Price = v.Price
+			item.Taxable = !v.NotTaxable
+			if item.Title == "" {
+				item.Title = v.Title
+			}
+			if item.SKU == "" {
+				item.SKU = v.SKU
+			}
+		} else if li.Title == "" {
+			return unprocessable("line_items", "title can't be blank")
+		}
+		if li.Price != nil {
+			item.Price = float64(*li.Price)
+		}
+		if li.Taxable != nil {
+			item.Taxable = *li.Taxable
+		}
+		if item.Quantity <= 0 {
+			return unprocessable("line_items", "quantity must be greater than 0")
+		}
+		items = append(items, item)
+	}
+
+	o := s.newOrder()
+	o.Email = in.Email
+	o.Note = in.Note
+	o.Tags = in.Tags
+	o.LineItems = items
+	o.TaxLines = restTaxLines(in.TaxLines)
+	o.ShippingAddress = in.ShippingAddress.address()
+	o.BillingAddress = in.BillingAddress.address()
+	o.SourceName = "api"
+	for _, sl := range in.ShippingLines {
+		o.ShippingLines = append(o.ShippingLines, ShippingLine{Title: sl.Title, Price: float64(sl.Price)})
+	}
+	s.placeOrder(o)
+	for _, t := range in.Transactions {
+		status := t.Status
+		if status == "" {
+			status = "success"
+		}
+		s.addTransaction(o, &Transaction{Kind: t.Kind, Status: status, Amount: float64(t.Amount), Gateway: t.Gateway})
+	}
+	if in.FinancialStatus != "" {
+		o.FinancialStatus = strings.ToUpper(in.FinancialStatus)
+	} else {
+		s.updateFinancialStatus(o)
+	}
+	return http.StatusCreated, map[string]interface{}{"order": s.restOrder(o)}
+}
+
+func (s *Server) restUpdateOrder(id int64, body []byte) (int, interface{}) {
+	o := s.findOrder(id)
+	if o == nil {
+		return notFound()
+	}
+	var request struct {
+		Order struct {
+			Email           *string            `json:"email"`
+			Note            *string            `json:"note"`
+			Tags            flexTags           `json:"tags"`
+			FinancialStatus string             `json:"financial_status"`
+			TaxLines        []restTaxLineInput `json:"tax_lines"`
+			LineItems       []struct {
+				ID       interface{}        `json:"id"`
+				TaxLines []restTaxLineInput `json:"tax_lines"`
+			} `json:"line_items"`
+			ShippingAddress *restAddressInput `json:"shipping_address"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return badRequest(err)
+	}
+	in := request.Order
+
+	// A present but empty tax_lines clears the order-level tax lines
+	var raw struct {
+		Order map[string]json.RawMessage `json:"order"`
+	}
+	json.Unmarshal(body, &raw)
+	if _, ok := raw.Order["tax_lines"]; ok {
+		o.TaxLines = restTaxLines(in.TaxLines)
+	}
+	for _, update := range in.LineItems {
+		for _, li := range o.LineItems {
+			if li.ID == parseID(update.ID) {
+				li.TaxLines = restTaxLines(update.TaxLines)
+			}
+		}
+	}
+	if in.Email != nil {
+		o.Email = *in.Email
+	}
+	if in.Note != nil {
+		o.Note = *in.Note
+	}
+	if in.Tags != nil {
+		o.Tags = in.Tags
+	}
+	if in.FinancialStatus != "" {
+		o.FinancialStatus = strings.ToUpper(in.FinancialStatus)
+	}
+	if in.ShippingAddress != nil {
+		o.ShippingAddress = in.ShippingAddress.address()
+	}
+	o.UpdatedAt = s.now()
+	return http.StatusOK, map[string]interface{}{"order": s.restOrder(o)}
+}
+
+func (s *Server) restListTransactions(orderID int64) (int, interface{}) {
+	o := s.findOrder(orderID)
+	if o == nil {
+		return notFound()
+	}
+	transactions := []interface{}{}
+	for _, t := range o.Transactions {
+		transactions = append(transactions, restTransaction(o, t))
+	}
+	return http.StatusOK, map[string]interface{}{"transactions": transactions}
+}
+
+func (s *Server) restCreateTransaction(orderID int64, body []byte) (int, interface{}) {
+	o := s.findOrder(orderID)
+	if o == nil {
+		return notFound()
+	}
+	var request struct {
+		Transaction struct {
+			Kind        string      `json:"kind"`
+			Status      string      `json:"status"`
+			Amount      *flexFloat  `json:"amount"`
+			Currency    string      `json:"currency"`
+			Gateway     string      `json:"gateway"`
+			Source      string      `json:"source"`
+			ParentID    interface{} `json:"parent_id"`
+			ProcessedAt *time.Time  `json:"processed_at"`
+		} `json:"transaction"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return badRequest(err)
+	}
+	in := request.Transaction
+	switch in.Kind {
+	case "sale", "authorization", "capture", "refund", "void":
+	case "":
+		return unprocessable("kind", "can't be blank")
+	default:
+		return unprocessable("kind", "is not included in the list")
+	}
+
+	t := &Transaction{Kind: in.Kind, Status: in.Status, Currency: in.Currency, Gateway: in.Gateway, Source: in.Source, ParentID: parseID(in.ParentID), ProcessedAt: in.ProcessedAt}
+	if t.Status == "" {
+		t.Status = "success"
+	}
+	if t.Gateway == "" {
+		t.Gateway = "manual"
+	}
+	var parent *Transaction
+	if t.ParentID != 0 {
+		for _, existing := range o.Transactions {
+			if existing.ID == t.ParentID {
+				parent = existing
+			}
+		}
+		if parent == nil {
+			return unprocessable("parent_id", "is invalid")
+		}
+	}
+	switch {
+	case in.Amount != nil:
+		t.Amount = float64(*in.Amount)
+	case parent != nil:
+		t.Amount = parent.Amount
+	default:
+		t.Amount = s.outstanding(o)
+	}
+	if (t.Kind == "capture" || t.Kind == "void") && parent == nil {
+		return unprocessable("parent_id", "must reference an authorization")
+	}
+	s.addTransaction(o, t)
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	return http.StatusCreated, map[string]interface{}{"transaction": restTransaction(o, t)}
+}
+
+// updateFinancialStatus derives the financial status from the transactions
+func (s *Server) updateFinancialStatus(o *Order) {
+	var paid, authorized, refunded float64
+	voided := map[int64]bool{}
+	for _, t := range o.Transactions {
+		if t.Kind == "void" && t.Status == "success" {
+			voided[t.ParentID] = true
+		}
+	}
+	for _, t := range o.Transactions {
+		if t.Status != "success" {
+			continue
+		}
+		switch t.Kind {
+		case "sale", "capture":
+			paid += t.Amount
+		case "authorization":
+			if !voided[t.ID] {
+				authorized += t.Amount
+			}
+		case "refund":
+			refunded += t.Amount
+		}
+	}
+	total := o.Total()
+	switch {
+	case refunded > 0 && refunded >= paid:
+		o.FinancialStatus = "REFUNDED"
+	case refunded > 0:
+		o.FinancialStatus = "PARTIALLY_REFUNDED"
+	case paid > 0 && round2(paid) >= total:
+		o.FinancialStatus = "PAID"
+	case paid > 0:
+		o.FinancialStatus = "PARTIALLY_PAID"
+	case authorized > 0:
+		o.FinancialStatus = "AUTHORIZED"
+	case len(voided) > 0:
+		o.FinancialStatus = "VOIDED"
+	}
+}
+
+func (s *Server) restListFulfillmentOrders(orderID int64) (int, interface{}) {
+	o := s.findOrder(orderID)
+	if o == nil {
+		return notFound()
+	}
+	fulfillmentOrders := []interface{}{}
+	if s.foQueries[o.ID] >= s.FulfillmentOrderDelay {
+		for _, fo := range o.FulfillmentOrders {
+			lineItems := []interface{}{}
+			for _, li := range fo.LineItems {
+				lineItems = append(lineItems, map[string]interface{}{
+					"id":                   li.ID,
+					"fulfillment_order_id": fo.ID,
+					"line_item_id":         li.LineItemID,
+					"total_quantity":       li.TotalQuantity,
+					"fulfillable_quantity": li.RemainingQuantity,
+				})
+			}
+			fulfillmentOrders = append(fulfillmentOrders, map[string]interface{}{
+				"id":                   fo.ID,
+				"order_id":             o.ID,
+				"status":               strings.ToLower(fo.Status),
+				"request_status":       strings.ToLower(fo.RequestStatus),
+				"assigned_location_id": fo.LocationID,
+				"line_items":           lineItems,
+			})
+		}
+	}
+	s.foQueries[o.ID]++
+	return http.StatusOK, map[string]interface{}{"fulfillment_orders": fulfillmentOrders}
+}
+
+func (s *Server) restGetVariant(id int64) (int, interface{}) {
+	v := s.findVariant(id)
+	if v == nil {
+		return notFound()
+	}
+	return http.StatusOK, map[string]interface{}{"variant": restVariant(v)}
+}
+
+func (s *Server) restUpdateVariant(id int64, body []byte) (int, interface{}) {
+	v := s.findVariant(id)
+	if v == nil {
+		return notFound()
+	}
+	var request struct {
+		Variant struct {
+			Title          *string    `json:"title"`
+			SKU            *string    `json:"sku"`
+			Price          *flexFloat `json:"price"`
+			CompareAtPrice *flexFloat `json:"compare_at_price"`
+			Taxable        *bool      `json:"taxable"`
+		} `json:"variant"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return badRequest(err)
+	}
+	in := request.Variant
+	if in.Title != nil {
+		v.Title = *in.Title
+	}
+	if in.SKU != nil {
+		v.SKU = *in.SKU
+	}
+	if in.Price != nil {
+		v.Price = float64(*in.Price)
+	}
+	if in.CompareAtPrice != nil {
+		v.CompareAtPrice = float64(*in.CompareAtPrice)
+	}
+	if in.Taxable != nil {
+		v.NotTaxable = !*in.Taxable
+	}
+	return http.StatusOK, map[string]interface{}{"variant": restVariant(v)}
+}
+
+func (s *Server) restListLocations() (int, interface{}) {
+	locations := []interface{}{}
+	for _, l := range s.locations {
+		locations = append(locations, map[string]interface{}{
+			"id":                   l.ID,
+			"name":                 l.Name,
+			"active":               true,
+			"admin_graphql_api_id": l.GID(),
+		})
+	}
+	return http.StatusOK, map[string]interface{}{"locations": locations}
+}
+
+func (s *Server) restDeleteMetafield(id int64) (int, interface{}) {
+	for _, o := range s.orders {
+		for i, m := range o.Metafields {
+			if m.ID == id {
+				o.Metafields = append(o.Metafields[:i], o.Metafields[i+1:]...)
+				return http.StatusOK, map[string]interface{}{}
+			}
+		}
+	}
+	return notFound()
+}
+
+func restVariant(v *Variant) map[string]interface{} {
+	var compareAtPrice interface{}
+	if v.CompareAtPrice != 0 {
+		compareAtPrice = price(v.CompareAtPrice)
+	}
+	return map[string]interface{}{
+		"id":                   v.ID,
+		"product_id":           v.ProductID,
+		"title":                v.Title,
+		"sku":                  v.SKU,
+		"price":                price(v.Price),
+		"compare_at_price":     compareAtPrice,
+		"inventory_quantity":   v.InventoryQuantity,
+		"taxable":              !v.NotTaxable,
+		"admin_graphql_api_id": v.GID(),
+	}
+}
+
+func (s *Server) restOrder(o *Order) map[string]interface{} {
+	lineItems := []interface{}{}
+	for _, li := range o.LineItems {
+		var variantID interface{}
+		if li.VariantID != 0 {
+			variantID = li.VariantID
+		}
+		lineItems = append(lineItems, map[string]interface{}{
+			"id":                   li.ID,
+			"admin_graphql_api_id": li.GID(),
+			"variant_id":           variantID,
+			"title":                li.Title,
+			"sku":                  li.SKU,
+			"quantity":             li.Quantity,
+			"price":                price(li.Price),
+			"total_discount":       price(li.Price*float64(li.Quantity) - li.Total()),
+			"taxable":              li.Taxable,
+			"fulfillable_quantity": li.FulfillableQuantity,
+			"tax_lines":            restTaxLineObjects(li.TaxLines),
+		})
+	}
+	shippingLines := []interface{}{}
+	for _, sl := range o.ShippingLines {
+		shippingLines = append(shippingLines, map[string]interface{}{"title": sl.Title, "price": price(sl.Price)})
+	}
+
+	var fulfillmentStatus interface{}
+	switch o.FulfillmentStatus {
+	case "FULFILLED":
+		fulfillmentStatus = "fulfilled"
+	case "PARTIALLY_FULFILLED":
+		fulfillmentStatus = "partial"
+	}
+	var customer interface{}
+	if c := s.findCustomer(o.CustomerID); c != nil {
+		customer = map[string]interface{}{
+			"id":         c.ID,
+			"email":      c.Email,
+			"first_name": c.FirstName,
+			"last_name":  c.LastName,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":                   o.ID,
+		"admin_graphql_api_id": o.GID(),
+		"name":                 o.Name,
+		"order_number":         o.Number,
+		"email":                o.Email,
+		"note":                 o.Note,
+		"tags":                 strings.Join(o.Tags, ", "),
+		"currency":             s.Currency,
+		"financial_status":     strings.ToLower(o.FinancialStatus),
+		"fulfillment_status":   fulfillmentStatus,
+		"source_name":          o.SourceName,
+		"created_at":           formatTime(o.CreatedAt),
+		"updated_at":           formatTime(o.UpdatedAt),
+		"line_items":           lineItems,
+		"shipping_lines":       shippingLines,
+		"shipping_address":     restAddress(o.ShippingAddress),
+		"billing_address":      restAddress(o.BillingAddress),
+		"customer":             customer,
+		"tax_lines":            restTaxLineObjects(o.EffectiveTaxLines()),
+		"subtotal_price":       price(o.Subtotal()),
+		"total_tax":            price(o.TotalTax()),
+		"total_price":          price(o.Total()),
+		"total_outstanding":    price(s.outstanding(o)),
+	}
+}
+
+func restTaxLineObjects(taxLines []TaxLine) []interface{} {
+	out := []interface{}{}
+	for _, tl := range taxLines {
+		out = append(out, map[string]interface{}{
+			"title": tl.Title,
+			"rate":  tl.Rate,
+			"price": price(tl.Price),
+		})
+	}
+	return out
+}
+
+func restAddress(a *Address) interface{} {
+	if a == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"first_name": a.FirstName,
+		"last_name":  a.LastName,
+		"company":    a.Company,
+		"address1":   a.Address1,
+		"address2":   a.Address2,
+		"city":       a.City,
+		"province":   a.Province,
+		"country":    a.Country,
+		"zip":        a.Zip,
+		"phone":      a.Phone,
+	}
+}
+
+func restTransaction(o *Order, t *Transaction) map[string]interface{} {
+	var parentID interface{}
+	if t.ParentID != 0 {
+		parentID = t.ParentID
+	}
+	return map[string]interface{}{
+		"id":                   t.ID,
+		"admin_graphql_api_id": gid("OrderTransaction", t.ID),
+		"order_id":             o.ID,
+		"kind":                 t.Kind,
+		"status":               t.Status,
+		"amount":               price(t.Amount),
+		"currency":             t.Currency,
+		"gateway":              t.Gateway,
+		"source_name":          t.Source,
+		"parent_id":            parentID,
+		"created_at":           formatTime(t.CreatedAt),
+		"processed_at":         formatTime(t.processedAt()),
+	}
+}
+
+// price formats a REST money amount with two decimals, e.g. "12.50"
+func price(v float64) string {
+	return strconv.FormatFloat(round2(v), 'f', 2, 64)
+}
@@ -0,0 +1,407 @@
+// Package shopifytest is an in-memory fake of the Shopify Admin API for running
+// the order pipeline offline. It serves the GraphQL operations and REST endpoints
+// the app uses from an httptest TLS server:
+//
+//	srv := shopifytest.NewServer()
+//	defer srv.Close()
+//	variant := srv.AddVariant(shopifytest.Variant{Title: "T-Shirt", Price: 25})
+//	client := srv.Client()
+//	info, err := client.CreateOrderFromDraft(ctx, app.DraftOrderInput{
+//		LineItems: []app.DraftLineItemInput{{VariantID: variant.GID(), Quantity: 2}},
+//	}, false)
+//
+// GraphQL responses only contain the selected fields, so a document selecting a
+// field the fake does not know fails with an undefinedField error, like Shopify.
+// Taxes are simplified: taxable lines are charged Server.TaxRate on their discounted
+// price, and committing an order edit recalculates them the same way.
+package shopifytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shopify-demo/app"
+)
+
+// AccessToken is the token the fake server accepts
+const AccessToken = "shpat_shopifytest"
+
+// Server is a fake Admin API for a single shop. Configure the exported fields
+// before the first request; inspect state with the accessor methods.
+type Server struct {
+	// The underlying TLS test server
+	HTTP *httptest.Server
+	// Shop currency. Defaults to "USD".
+	Currency string
+	// Tax rate (e.g. 0.1 for 10%) charged on taxable lines. Zero means no tax.
+	TaxRate float64
+	// Title of calculated tax lines. Defaults to "Tax".
+	TaxTitle string
+	// Number of fulfillmentOrders queries per order that return no fulfillment
+	// orders, to simulate Shopify routing them asynchronously after creation
+	FulfillmentOrderDelay int
+	// Number of job queries that report an async job (such as the one started
+	// by orderCancel) as not done yet
+	JobDelay int
+
+	mu                   sync.Mutex
+	nextID               int64
+	nextOrderNumber      int
+	nextDraftNumber      int
+	calls                []string
+	variants             []*Variant
+	locations            []*Location
+	customers            []*Customer
+	draftOrders          []*DraftOrder
+	orders               []*Order
+	calculatedOrders     []*calculatedOrder
+	metafieldDefinitions []*MetafieldDefinition
+	foQueries            map[int64]int
+	jobQueries           map[string]int
+}
+
+// NewServer starts a fake Admin API with one location, "Shop location"
+func NewServer() *Server {
+	s := &Server{
+		Currency:        "USD",
+		TaxTitle:        "Tax",
+		nextID:          1000,
+		nextOrderNumber: 1001,
+		nextDraftNumber: 1,
+		foQueries:       make(map[int64]int),
+		jobQueries:      make(map[string]int),
+	}
+	s.HTTP = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	s.AddLocation(Location{Name: "Shop location"})
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.HTTP.Close()
+}
+
+// ShopDomain returns the host:port to use as a client's shop domain
+func (s *Server) ShopDomain() string {
+	return strings.TrimPrefix(s.HTTP.URL, "https://")
+}
+
+// Client returns an app.Client for the fake shop with its own rate limiter.
+// opts are applied after the defaults.
+func (s *Server) Client(opts ...app.ClientOption) *app.Client {
+	defaults := []app.ClientOption{
+		app.WithHTTPClient(s.HTTP.Client()),
+		app.WithRateLimiter(app.NewRateLimiter()),
+		app.WithDeprecationLog(app.NewDeprecationLog()),
+	}
+	return app.NewClient(s.ShopDomain(), AccessToken, append(defaults, opts...)...)
+}
+
+// Calls returns the operations received so far: GraphQL root fields
+// (e.g. "draftOrderCreate") and REST calls (e.g. "GET orders/1001.json")
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// AddVariant adds a product variant. ID and ProductID are assigned when zero.
+func (s *Server) AddVariant(v Variant) *Variant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v.ID == 0 {
+		v.ID = s.newID()
+	}
+	if v.ProductID == 0 {
+		v.ProductID = s.newID()
+	}
+	if v.Title == "" {
+		v.Title = "Default Title"
+	}
+	variant := v
+	s.variants = append(s.variants, &variant)
+	return &variant
+}
+
+// AddLocation adds a location. The first location receives new fulfillment orders.
+func (s *Server) AddLocation(l Location) *Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l.ID == 0 {
+		l.ID = s.newID()
+	}
+	location := l
+	s.locations = append(s.locations, &location)
+	return &location
+}
+
+// AddCustomer adds a customer with its addresses. The first address becomes
+// the default address unless DefaultAddressID is set.
+func (s *Server) AddCustomer(c Customer) *Customer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.ID == 0 {
+		c.ID = s.newID()
+	}
+	customer := c
+	customer.Addresses = nil
+	for _, a := range c.Addresses {
+		address := *a
+		if address.ID == 0 {
+			address.ID = s.newID()
+		}
+		customer.Addresses = append(customer.Addresses, &address)
+	}
+	if customer.DefaultAddressID == 0 && len(customer.Addresses) > 0 {
+		customer.DefaultAddressID = customer.Addresses[0].ID
+	}
+	s.customers = append(s.customers, &customer)
+	return &customer
+}
+
+// Order returns a copy of the order with the given GID or numeric ID
+func (s *Server) Order(id string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order := s.findOrder(id)
+	if order == nil {
+		return Order{}, false
+	}
+	return order.clone(), true
+}
+
+// Orders returns copies of all orders in creation order
+func (s *Server) Orders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		orders = append(orders, order.clone())
+	}
+	return orders
+}
+
+// DraftOrder returns a copy of the draft order with the given GID or numeric ID
+func (s *Server) DraftOrder(id string) (DraftOrder, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	draft := s.findDraftOrder(id)
+	if draft == nil {
+		return DraftOrder{}, false
+	}
+	return draft.clone(), true
+}
+
+// Customer returns a copy of the customer with the given GID or numeric ID
+func (s *Server) Customer(id string) (Customer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	customer := s.findCustomer(id)
+	if customer == nil {
+		return Customer{}, false
+	}
+	return customer.clone(), true
+}
+
+// Variant returns a copy of the variant with the given GID or numeric ID
+func (s *Server) Variant(id string) (Variant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	variant := s.findVariant(id)
+	if variant == nil {
+		return Variant{}, false
+	}
+	return *variant, true
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Shopify-Access-Token") != AccessToken {
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+			"errors": "[API] Invalid API key or access token (unrecognized login or wrong password)",
+		})
+		return
+	}
+
+	// /admin/api/{version}/{path}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 4)
+	if len(parts) < 4 || parts[0] != "admin" || parts[1] != "api" {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": "Not Found"})
+		return
+	}
+	path := parts[3]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": err.Error()})
+		return
+	}
+
+	if path == "graphql.json" {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"errors": "Method Not Allowed"})
+			return
+		}
+		s.serveGraphQL(w, body)
+		return
+	}
+	s.serveREST(w, r, path, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// maxQueryCost is the most a single query may request, like on Shopify
+const maxQueryCost = 1000
+
+// serveGraphQL runs a GraphQL request against the in-memory state
+func (s *Server) serveGraphQL(w http.ResponseWriter, body []byte) {
+	var request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": "invalid JSON body"})
+		return
+	}
+
+	// A query over the limit is rejected before it runs and costs nothing
+	var data interface{}
+	cost, actualCost := 0, 0
+	op, err := parseOperation(request.Query, request.Variables)
+	if err == nil {
+		cost = requestedCost(op)
+		if cost > maxQueryCost {
+			err = &gqlError{
+				Message: fmt.Sprintf("Query cost is %d, which exceeds the single query max cost limit (%d).", cost, maxQueryCost),
+				Code:    app.ErrorCodeMaxCostExceeded,
+			}
+		} else {
+			actualCost = cost
+			data, err = s.execute(op)
+		}
+	}
+
+	response := map[string]interface{}{
+		"extensions": map[string]interface{}{
+			"cost": app.QueryCost{
+				RequestedQueryCost: float64(cost),
+				ActualQueryCost:    float64(actualCost),
+				ThrottleStatus: app.ThrottleStatus{
+					MaximumAvailable:   2000,
+					CurrentlyAvailable: float64(2000 - actualCost),
+					RestoreRate:        100,
+				},
+			},
+		},
+	}
+	if err != nil {
+		gqlErr, ok := err.(*gqlError)
+		if !ok {
+			gqlErr = &gqlError{Message: err.Error(), Code: app.ErrorCodeInternalError}
+		}
+		response["errors"] = []interface{}{map[string]interface{}{
+			"message":    gqlErr.Message,
+			"extensions": map[string]interface{}{"code": gqlErr.Code},
+		}}
+	}
+	response["data"] = data
+	writeJSON(w, http.StatusOK, response)
+}
+
+// execute resolves the root fields of a parsed operation
+func (s *Server) execute(op *operation) (interface{}, error) {
+	roots := s.mutationRoot()
+	rootType := "Mutation"
+	if op.Type == "query" {
+		roots = s.queryRoot()
+		rootType = "QueryRoot"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make(map[string]interface{}, len(op.Selections))
+	for _, sel := range op.Selections {
+		if sel.Name == "" {
+			return nil, &gqlError{Message: "inline fragments are not supported on the root type", Code: "syntaxError"}
+		}
+		s.calls = append(s.calls, sel.Name)
+		field, ok := roots[sel.Name]
+		if !ok {
+			return nil, &gqlError{
+				Message: fmt.Sprintf("Field '%s' doesn't exist on type '%s'", sel.Name, rootType),
+				Code:    "undefinedField",
+			}
+		}
+		value, err := field(sel.Args)
+		if err != nil {
+			return nil, err
+		}
+		projected, err := project(value, sel.Selections)
+		if err != nil {
+			return nil, err
+		}
+		data[sel.key()] = projected
+	}
+	return data, nil
+}
+
+// gid formats a global ID, e.g. gid("Order", 1001) = "gid://shopify/Order/1001"
+func gid(typeName string, id int64) string {
+	return fmt.Sprintf("gid://shopify/%s/%d", typeName, id)
+}
+
+// parseID returns the numeric ID of a GID (query parameters allowed) or of a numeric string
+func parseID(value interface{}) int64 {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64:
+		return int64(v)
+	case json.Number:
+		s = v.String()
+	default:
+		return 0
+	}
+	s, _, _ = strings.Cut(s, "?")
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		s = s[i+1:]
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// decodeArg converts a decoded JSON argument into a typed input struct
+func decodeArg(arg interface{}, out interface{}) error {
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return &gqlError{Message: fmt.Sprintf("Variable input is invalid: %v", err), Code: "INVALID_VARIABLE"}
+	}
+	return nil
+}
@@ -0,0 +1,652 @@
+package shopifytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variant is a product variant
+type Variant struct {
+	ID                int64
+	ProductID         int64
+	Title             string
+	SKU               string
+	Price             float64
+	CompareAtPrice    float64
+	InventoryQuantity int
+	// Taxable variants are charged Server.TaxRate. Set NotTaxable for exempt products.
+	NotTaxable bool
+}
+
+// GID returns the variant's global ID
+func (v *Variant) GID() string {
+	return gid("ProductVariant", v.ID)
+}
+
+// Location is a shop location
+type Location struct {
+	ID   int64
+	Name string
+}
+
+// GID returns the location's global ID
+func (l *Location) GID() string {
+	return gid("Location", l.ID)
+}
+
+// Address is a customer or order mailing address
+type Address struct {
+	ID        int64  `json:"-"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Company   string `json:"company"`
+	Address1  string `json:"address1"`
+	Address2  string `json:"address2"`
+	City      string `json:"city"`
+	Province  string `json:"province"`
+	Country   string `json:"country"`
+	Zip       string `json:"zip"`
+	Phone     string `json:"phone"`
+}
+
+// GID returns the address's global ID in the form Shopify uses for customer addresses
+func (a *Address) GID() string {
+	return gid("MailingAddress", a.ID) + "?model_name=CustomerAddress"
+}
+
+// Customer is a customer with its addresses
+type Customer struct {
+	ID               int64
+	FirstName        string
+	LastName         string
+	Email            string
+	Phone            string
+	Addresses        []*Address
+	DefaultAddressID int64
+}
+
+// GID returns the customer's global ID
+func (c *Customer) GID() string {
+	return gid("Customer", c.ID)
+}
+
+func (c *Customer) clone() Customer {
+	out := *c
+	out.Addresses = nil
+	for _, a := range c.Addresses {
+		address := *a
+		out.Addresses = append(out.Addresses, &address)
+	}
+	return out
+}
+
+// TaxLine is a tax charged on a line or an order
+type TaxLine struct {
+	Title string
+	Rate  float64
+	Price float64
+}
+
+// AppliedDiscount is a discount on a line item: a percentage or a fixed amount per unit
+type AppliedDiscount struct {
+	Title       string
+	Description string
+	ValueType   string // PERCENTAGE or FIXED_AMOUNT
+	Value       float64
+}
+
+// unitAmount returns the discount per unit for a unit price
+func (d *AppliedDiscount) unitAmount(price float64) float64 {
+	if d == nil {
+		return 0
+	}
+	if d.ValueType == "PERCENTAGE" {
+		return round2(price * d.Value / 100)
+	}
+	return math.Min(d.Value, price)
+}
+
+// LineItem is a line of a draft order or an order
+type LineItem struct {
+	ID        int64
+	VariantID int64
+	Title     string
+	SKU       string
+	Quantity  int
+	Price     float64
+	Discount  *AppliedDiscount
+	Taxable   bool
+	TaxLines  []TaxLine
+	// Fulfillable quantity not yet fulfilled
+	FulfillableQuantity int
+}
+
+// GID returns the line item's global ID
+func (li *LineItem) GID() string {
+	return gid("LineItem", li.ID)
+}
+
+// DiscountedUnitPrice returns the unit price after the line's discount
+func (li *LineItem) DiscountedUnitPrice() float64 {
+	return round2(li.Price - li.Discount.unitAmount(li.Price))
+}
+
+// Total returns quantity times the discounted unit price
+func (li *LineItem) Total() float64 {
+	return round2(li.DiscountedUnitPrice() * float64(li.Quantity))
+}
+
+func (li *LineItem) clone() *LineItem {
+	out := *li
+	out.TaxLines = append([]TaxLine(nil), li.TaxLines...)
+	if li.Discount != nil {
+		discount := *li.Discount
+		out.Discount = &discount
+	}
+	return &out
+}
+
+// ShippingLine is the shipping charge of a draft order or an order
+type ShippingLine struct {
+	Title string
+	Price float64
+}
+
+// DraftOrder is a draft order
+type DraftOrder struct {
+	ID              int64
+	Name            string
+	Email           string
+	Note            string
+	Tags            []string
+	LineItems       []*LineItem
+	ShippingAddress *Address
+	BillingAddress  *Address
+	ShippingLine    *ShippingLine
+	TaxExempt       bool
+	Status          string // OPEN or COMPLETED
+	OrderID         int64
+	CreatedAt       time.Time
+}
+
+// GID returns the draft order's global ID
+func (d *DraftOrder) GID() string {
+	return gid("DraftOrder", d.ID)
+}
+
+func (d *DraftOrder) clone() DraftOrder {
+	out := *d
+	out.Tags = append([]string(nil), d.Tags...)
+	out.LineItems = cloneLineItems(d.LineItems)
+	return out
+}
+
+// Order is an order
+type Order struct {
+	ID              int64
+	Name            string
+	Number          int
+	Email           string
+	Note            string
+	Tags            []string
+	LineItems       []*LineItem
+	ShippingAddress *Address
+	BillingAddress  *Address
+	ShippingLines   []ShippingLine
+	// Order-level tax lines. When empty, the line items' tax lines are used.
+	TaxLines          []TaxLine
+	FinancialStatus   string // PENDING, AUTHORIZED, PAID, ...
+	FulfillmentStatus string // UNFULFILLED, PARTIALLY_FULFILLED or FULFILLED
+	CustomerID        int64
+	DraftOrderID      int64
+	SourceName        string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Metafields        []*Metafield
+	Transactions      []*Transaction
+	FulfillmentOrders []*FulfillmentOrder
+	Fulfillments      []*Fulfillment
+	Refunds           []*Refund
+	Returns           []*Return
+	// Set by orderCancel
+	CancelledAt  *time.Time
+	CancelReason string
+	StaffNote    string
+}
+
+// GID returns the order's global ID
+func (o *Order) GID() string {
+	return gid("Order", o.ID)
+}
+
+// Subtotal returns the sum of the discounted line totals
+func (o *Order) Subtotal() float64 {
+	return lineItemsSubtotal(o.LineItems)
+}
+
+// ShippingTotal returns the sum of the shipping lines
+func (o *Order) ShippingTotal() float64 {
+	total := 0.0
+	for _, sl := range o.ShippingLines {
+		total += sl.Price
+	}
+	return round2(total)
+}
+
+// EffectiveTaxLines returns the order-level tax lines, or the line items' tax
+// lines merged by title when the order has none
+func (o *Order) EffectiveTaxLines() []TaxLine {
+	if len(o.TaxLines) > 0 {
+		return o.TaxLines
+	}
+	return mergeTaxLines(o.LineItems)
+}
+
+// TotalTax returns the sum of EffectiveTaxLines
+func (o *Order) TotalTax() float64 {
+	return sumTaxLines(o.EffectiveTaxLines())
+}
+
+// Total returns subtotal, shipping and tax
+func (o *Order) Total() float64 {
+	return round2(o.Subtotal() + o.ShippingTotal() + o.TotalTax())
+}
+
+func (o *Order) clone() Order {
+	out := *o
+	out.Tags = append([]string(nil), o.Tags...)
+	out.LineItems = cloneLineItems(o.LineItems)
+	out.ShippingLines = append([]ShippingLine(nil), o.ShippingLines...)
+	out.TaxLines = append([]TaxLine(nil), o.TaxLines...)
+	out.Metafields = nil
+	for _, m := range o.Metafields {
+		metafield := *m
+		out.Metafields = append(out.Metafields, &metafield)
+	}
+	out.Transactions = nil
+	for _, t := range o.Transactions {
+		transaction := *t
+		out.Transactions = append(out.Transactions, &transaction)
+	}
+	out.FulfillmentOrders = nil
+	for _, fo := range o.FulfillmentOrders {
+		fulfillmentOrder := *fo
+		fulfillmentOrder.LineItems = nil
+		for _, li := range fo.LineItems {
+			lineItem := *li
+			fulfillmentOrder.LineItems = append(fulfillmentOrder.LineItems, &lineItem)
+		}
+		out.FulfillmentOrders = append(out.FulfillmentOrders, &fulfillmentOrder)
+	}
+	out.Fulfillments = nil
+	for _, f := range o.Fulfillments {
+		fulfillment := *f
+		fulfillment.LineItems = nil
+		for _, li := range f.LineItems {
+			lineItem := *li
+			fulfillment.LineItems = append(fulfillment.LineItems, &lineItem)
+		}
+		out.Fulfillments = append(out.Fulfillments, &fulfillment)
+	}
+	out.Refunds = nil
+	for _, r := range o.Refunds {
+		refund := *r
+		refund.LineItems = append([]RefundLineItem(nil), r.LineItems...)
+		out.Refunds = append(out.Refunds, &refund)
+	}
+	out.Returns = nil
+	for _, r := range o.Returns {
+		ret := *r
+		ret.LineItems = nil
+		for _, li := range r.LineItems {
+			lineItem := *li
+			ret.LineItems = append(ret.LineItems, &lineItem)
+		}
+		out.Returns = append(out.Returns, &ret)
+	}
+	return out
+}
+
+// Metafield is a metafield of an order
+type Metafield struct {
+	ID        int64
+	Namespace string
+	Key       string
+	Type      string
+	Value     string
+}
+
+// MetafieldDefinition is a metafield definition
+type MetafieldDefinition struct {
+	ID        int64
+	Name      string
+	Namespace string
+	Key       string
+	Type      string
+	OwnerType string
+}
+
+// Transaction is a payment transaction of an order
+type Transaction struct {
+	ID        int64
+	Kind      string // sale, authorization, capture, refund, void
+	Status    string // success, pending, failure
+	Amount    float64
+	Currency  string
+	Gateway   string
+	Source    string
+	ParentID  int64
+	CreatedAt time.Time
+	// When the payment was taken, if not CreatedAt
+	ProcessedAt *time.Time
+}
+
+// processedAt returns ProcessedAt, or CreatedAt when it is not set
+func (t *Transaction) processedAt() time.Time {
+	if t.ProcessedAt != nil {
+		return *t.ProcessedAt
+	}
+	return t.CreatedAt
+}
+
+// Refund is money returned to the customer, with the line items it covers
+type Refund struct {
+	ID        int64
+	Note      string
+	CreatedAt time.Time
+	// Refund transactions, also listed in Order.Transactions
+	Transactions []*Transaction
+	LineItems    []RefundLineItem
+	// Refunded shipping
+	Shipping float64
+}
+
+// RefundLineItem is a quantity of a line item covered by a refund
+type RefundLineItem struct {
+	LineItemID  int64
+	Quantity    int
+	RestockType string // NO_RESTOCK, CANCEL or RETURN
+	Subtotal    float64
+	TotalTax    float64
+}
+
+// Return is a return of fulfilled items, created by returnCreate
+type Return struct {
+	ID        int64
+	Name      string
+	Status    string // OPEN or CLOSED
+	LineItems []*ReturnLineItem
+}
+
+// ReturnLineItem is a quantity of a fulfillment line item being returned
+type ReturnLineItem struct {
+	ID                    int64
+	FulfillmentLineItemID int64
+	LineItemID            int64
+	Quantity              int
+	RefundedQuantity      int
+	ReturnReason          string
+	ReturnReasonNote      string
+	CustomerNote          string
+}
+
+// Total returns the sum of the successful refund transactions
+func (r *Refund) Total() float64 {
+	total := 0.0
+	for _, t := range r.Transactions {
+		if t.Status == "success" {
+			total += t.Amount
+		}
+	}
+	return round2(total)
+}
+
+// FulfillmentOrder is the work to fulfill (part of) an order from a location
+type FulfillmentOrder struct {
+	ID            int64
+	Status        string // OPEN, IN_PROGRESS or CLOSED
+	RequestStatus string
+	LocationID    int64
+	LineItems     []*FulfillmentOrderLineItem
+}
+
+// FulfillmentOrderLineItem is a line of a fulfillment order
+type FulfillmentOrderLineItem struct {
+	ID                int64
+	LineItemID        int64
+	TotalQuantity     int
+	RemainingQuantity int
+}
+
+// Fulfillment is a shipment of fulfillment order lines
+type Fulfillment struct {
+	ID              int64
+	Status          string
+	LineItems       []*FulfillmentLineItem
+	TrackingNumber  string
+	TrackingCompany string
+	TrackingURL     string
+	CreatedAt       time.Time
+}
+
+// FulfillmentLineItem is a quantity of a line item shipped by a fulfillment
+type FulfillmentLineItem struct {
+	ID         int64
+	LineItemID int64
+	Quantity   int
+}
+
+// calculatedOrder is an order edit session. Its line items are copies of the
+// order's; lines added by the edit have IDs the order does not have yet.
+type calculatedOrder struct {
+	ID            int64
+	OrderID       int64
+	LineItems     []*LineItem
+	ShippingLines []*calculatedShippingLine
+	// Line items whose removed quantity goes back to inventory on commit
+	Restock   map[int64]bool
+	Committed bool
+}
+
+// calculatedShippingLine is a shipping line of an order edit session
+type calculatedShippingLine struct {
+	ID     int64
+	Title  string
+	Price  float64
+	Status string // NONE, ADDED or REMOVED
+}
+
+func cloneLineItems(items []*LineItem) []*LineItem {
+	out := make([]*LineItem, 0, len(items))
+	for _, li := range items {
+		out = append(out, li.clone())
+	}
+	return out
+}
+
+func lineItemsSubtotal(items []*LineItem) float64 {
+	total := 0.0
+	for _, li := range items {
+		total += li.Total()
+	}
+	return round2(total)
+}
+
+func mergeTaxLines(items []*LineItem) []TaxLine {
+	var merged []TaxLine
+	index := map[string]int{}
+	for _, li := range items {
+		for _, tl := range li.TaxLines {
+			if i, ok := index[tl.Title]; ok {
+				merged[i].Price = round2(merged[i].Price + tl.Price)
+				continue
+			}
+			index[tl.Title] = len(merged)
+			merged = append(merged, tl)
+		}
+	}
+	return merged
+}
+
+func sumTaxLines(taxLines []TaxLine) float64 {
+	total := 0.0
+	for _, tl := range taxLines {
+		total += tl.Price
+	}
+	return round2(total)
+}
+
+// applyTax replaces the tax lines of taxable lines with the shop's calculated tax
+func (s *Server) applyTax(items []*LineItem, exempt bool) {
+	for _, li := range items {
+		li.TaxLines = nil
+		if exempt || !li.Taxable || s.TaxRate == 0 {
+			continue
+		}
+		li.TaxLines = []TaxLine{{
+			Title: s.TaxTitle,
+			Rate:  s.TaxRate,
+			Price: round2(li.Total() * s.TaxRate),
+		}}
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// amount formats a money amount the way the Admin API does, e.g. "12.5"
+func amount(v float64) string {
+	return strconv.FormatFloat(round2(v), 'f', -1, 64)
+}
+
+// flexFloat decodes a number sent either as a JSON number or as a string (Decimal, Money)
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s == "" {
+			*f = 0
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid decimal %q", s)
+		}
+		*f = flexFloat(v)
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = flexFloat(v)
+	return nil
+}
+
+// flexTags decodes tags sent either as a list or as a comma-separated string
+type flexTags []string
+
+func (t *flexTags) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = splitTags(s)
+	return nil
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// list returns a resolver for a list field that its first argument truncates
+func list(items []object) resolver {
+	return func(args map[string]interface{}) (interface{}, error) {
+		if first, ok := args["first"].(float64); ok && int(first) < len(items) {
+			return items[:int(first)], nil
+		}
+		return items, nil
+	}
+}
+
+// connection returns a resolver serving items as a cursor connection
+// (edges, nodes and pageInfo) that honours first, last and after
+func connection(items []object) resolver {
+	return func(args map[string]interface{}) (interface{}, error) {
+		start := 0
+		if after, ok := args["after"].(string); ok && after != "" {
+			raw, err := base64.StdEncoding.DecodeString(after)
+			n, convErr := strconv.Atoi(strings.TrimPrefix(string(raw), "cursor:"))
+			if err != nil || convErr != nil {
+				return nil, &gqlError{Message: fmt.Sprintf("Invalid cursor for current pagination sort: %s", after), Code: "BAD_REQUEST"}
+			}
+			start = n + 1
+		}
+		if start > len(items) {
+			start = len(items)
+		}
+		end := len(items)
+		if first, ok := args["first"].(float64); ok {
+			if first > 250 {
+				return nil, &gqlError{Message: "The first argument cannot exceed 250", Code: "BAD_REQUEST"}
+			}
+			end = min(start+int(first), len(items))
+		}
+		if last, ok := args["last"].(float64); ok {
+			start = max(end-int(last), start)
+		}
+
+		page := items[start:end]
+		edges := make([]object, 0, len(page))
+		nodes := make([]object, 0, len(page))
+		for i, item := range page {
+			edges = append(edges, object{
+				"__typename": "Edge",
+				"cursor":     cursor(start + i),
+				"node":       item,
+			})
+			nodes = append(nodes, item)
+		}
+
+		pageInfo := object{
+			"__typename":      "PageInfo",
+			"hasNextPage":     end < len(items),
+			"hasPreviousPage": start > 0,
+			"startCursor":     nil,
+			"endCursor":       nil,
+		}
+		if len(page) > 0 {
+			pageInfo["startCursor"] = cursor(start)
+			pageInfo["endCursor"] = cursor(end - 1)
+		}
+
+		return object{
+			"__typename": "Connection",
+			"edges":      edges,
+			"nodes":      nodes,
+			"pageInfo":   pageInfo,
+		}, nil
+	}
+}
+
+func cursor(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(i)))
+}
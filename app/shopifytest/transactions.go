@@ -0,0 +1,105 @@
+package shopifytest
+
+import "fmt"
+
+// findTransaction returns the order's transaction with the ID, or nil
+func findTransaction(o *Order, id interface{}) *Transaction {
+	n := toID(id)
+	for _, t := range o.Transactions {
+		if t.ID == n {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Server) orderMarkAsPaid(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		ID string `json:"id"`
+	}
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	o := s.findOrder(in.ID)
+	if o == nil {
+		return payload(object{"order": nil}, userError("Order does not exist", "id")), nil
+	}
+	outstanding := s.outstanding(o)
+	if o.CancelledAt != nil || outstanding <= 0 {
+		return payload(object{"order": nil}, userError("Order cannot be marked as paid.", "id")), nil
+	}
+	s.addTransaction(o, &Transaction{Kind: "sale", Status: "success", Gateway: "manual", Amount: outstanding})
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	return payload(object{"order": s.orderObject(o)}), nil
+}
+
+// authorization returns the order's uncaptured, unvoided authorization with the
+// ID and the amount left to capture, or a user error message
+func (s *Server) authorization(o *Order, id interface{}) (*Transaction, float64, string) {
+	t := findTransaction(o, id)
+	if t == nil || t.Kind != "authorization" || t.Status != "success" {
+		return nil, 0, "Parent transaction must be a successful authorization"
+	}
+	if s.childAmount(o, t.ID, "void") > 0 {
+		return nil, 0, "Authorization has been voided"
+	}
+	return t, round2(t.Amount - s.childAmount(o, t.ID, "capture")), ""
+}
+
+func (s *Server) orderCapture(args map[string]interface{}) (interface{}, error) {
+	var in struct {
+		ID                  string     `json:"id"`
+		ParentTransactionID string     `json:"parentTransactionId"`
+		Amount              *flexFloat `json:"amount"`
+		Currency            string     `json:"currency"`
+		FinalCapture        bool       `json:"finalCapture"`
+	}
+	if err := decodeArg(args["input"], &in); err != nil {
+		return nil, err
+	}
+	if in.Amount == nil {
+		return nil, &gqlError{Message: "Variable $input of type OrderCaptureInput! was provided invalid value for amount (Expected value to not be null)", Code: "INVALID_VARIABLE"}
+	}
+	o := s.findOrder(in.ID)
+	if o == nil {
+		return payload(object{"transaction": nil}, userError("Order does not exist", "id")), nil
+	}
+	parent, capturable, message := s.authorization(o, in.ParentTransactionID)
+	if parent == nil {
+		return payload(object{"transaction": nil}, userError(message, "parentTransactionId")), nil
+	}
+	amount := round2(float64(*in.Amount))
+	if amount <= 0 || amount > capturable {
+		return payload(object{"transaction": nil}, userError(fmt.Sprintf("Amount must be between 0 and the capturable %s", price(capturable)), "amount")), nil
+	}
+	t := &Transaction{Kind: "capture", Status: "success", Gateway: parent.Gateway, ParentID: parent.ID, Amount: amount, Currency: in.Currency}
+	s.addTransaction(o, t)
+	if in.FinalCapture && amount < capturable {
+		s.addTransaction(o, &Transaction{Kind: "void", Status: "success", Gateway: parent.Gateway, ParentID: parent.ID, Amount: round2(capturable - amount)})
+	}
+	s.updateFinancialStatus(o)
+	o.UpdatedAt = s.now()
+	return payload(object{"transaction": s.transactionObject(o, t)}), nil
+}
+
+func (s *Server) transactionVoid(args map[string]interface{}) (interface{}, error) {
+	for _, o := range s.orders {
+		if findTransaction(o, args["parentTransactionId"]) == nil {
+			continue
+		}
+		parent, capturable, message := s.authorization(o, args["parentTransactionId"])
+		if parent == nil {
+			return payload(object{"transaction": nil}, userError(message, "parentTransactionId")), nil
+		}
+		if capturable < parent.Amount {
+			return payload(object{"transaction": nil}, userError("Cannot void an authorization that has been captured", "parentTransactionId")), nil
+		}
+		t := &Transaction{Kind: "void", Status: "success", Gateway: parent.Gateway, ParentID: parent.ID, Amount: parent.Amount}
+		s.addTransaction(o, t)
+		s.updateFinancialStatus(o)
+		o.UpdatedAt = s.now()
+		return payload(object{"transaction": s.transactionObject(o, t)}), nil
+	}
+	return payload(object{"transaction": nil}, userError("Transaction does not exist", "parentTransactionId")), nil
+}
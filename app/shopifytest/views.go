@@ -0,0 +1,677 @@
+package shopifytest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object views of the in-memory state. Each view holds every field the fake
+// supports for its type; the executor keeps only the selected ones.
+
+func (s *Server) money(v float64) object {
+	return object{
+		"__typename":   "MoneyV2",
+		"amount":       amount(v),
+		"currencyCode": s.Currency,
+	}
+}
+
+func (s *Server) moneyBag(v float64) object {
+	money := s.money(v)
+	return object{
+		"__typename":       "MoneyBag",
+		"shopMoney":        money,
+		"presentmentMoney": money,
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (s *Server) taxLineObjects(taxLines []TaxLine) []object {
+	out := make([]object, 0, len(taxLines))
+	for _, tl := range taxLines {
+		out = append(out, object{
+			"__typename":     "TaxLine",
+			"title":          tl.Title,
+			"rate":           tl.Rate,
+			"ratePercentage": round2(tl.Rate * 100),
+			"priceSet":       s.moneyBag(tl.Price),
+			"channelLiable":  false,
+			"source":         nil,
+		})
+	}
+	return out
+}
+
+func (s *Server) variantObject(v *Variant) interface{} {
+	if v == nil {
+		return nil
+	}
+	return object{
+		"__typename":        "ProductVariant",
+		"id":                v.GID(),
+		"title":             v.Title,
+		"displayName":       v.Title,
+		"sku":               v.SKU,
+		"price":             amount(v.Price),
+		"compareAtPrice":    compareAt(v.CompareAtPrice),
+		"inventoryQuantity": v.InventoryQuantity,
+		"taxable":           !v.NotTaxable,
+		"product": object{
+			"__typename": "Product",
+			"id":         gid("Product", v.ProductID),
+		},
+	}
+}
+
+func compareAt(v float64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return amount(v)
+}
+
+func (s *Server) locationObject(l *Location) interface{} {
+	if l == nil {
+		return nil
+	}
+	return object{
+		"__typename": "Location",
+		"id":         l.GID(),
+		"name":       l.Name,
+		"isActive":   true,
+	}
+}
+
+func addressObject(a *Address) interface{} {
+	if a == nil {
+		return nil
+	}
+	name := strings.TrimSpace(a.FirstName + " " + a.LastName)
+	id := interface{}(nil)
+	if a.ID != 0 {
+		id = a.GID()
+	}
+	return object{
+		"__typename": "MailingAddress",
+		"id":         id,
+		"firstName":  a.FirstName,
+		"lastName":   a.LastName,
+		"name":       name,
+		"company":    a.Company,
+		"address1":   a.Address1,
+		"address2":   a.Address2,
+		"city":       a.City,
+		"province":   a.Province,
+		"country":    a.Country,
+		"zip":        a.Zip,
+		"phone":      a.Phone,
+	}
+}
+
+func (s *Server) customerObject(c *Customer) interface{} {
+	if c == nil {
+		return nil
+	}
+	addresses := make([]object, 0, len(c.Addresses))
+	var defaultAddress interface{}
+	for _, a := range c.Addresses {
+		addresses = append(addresses, addressObject(a).(object))
+		if a.ID == c.DefaultAddressID {
+			defaultAddress = addressObject(a)
+		}
+	}
+	return object{
+		"__typename":     "Customer",
+		"id":             c.GID(),
+		"firstName":      c.FirstName,
+		"lastName":       c.LastName,
+		"displayName":    strings.TrimSpace(c.FirstName + " " + c.LastName),
+		"email":          c.Email,
+		"phone":          c.Phone,
+		"defaultAddress": defaultAddress,
+		"addresses": resolver(func(args map[string]interface{}) (interface{}, error) {
+			if first, ok := args["first"].(float64); ok && int(first) < len(addresses) {
+				return addresses[:int(first)], nil
+			}
+			return addresses, nil
+		}),
+		"addressesV2": connection(addresses),
+	}
+}
+
+func appliedDiscountObject(d *AppliedDiscount, price float64) interface{} {
+	if d == nil {
+		return nil
+	}
+	return object{
+		"__typename":  "DraftOrderAppliedDiscount",
+		"title":       d.Title,
+		"description": d.Description,
+		"valueType":   d.ValueType,
+		"value":       d.Value,
+		"amount":      amount(d.unitAmount(price)),
+	}
+}
+
+// lineItemObject is a LineItem, DraftOrderLineItem or CalculatedLineItem
+func (s *Server) lineItemObject(typeName, id string, li *LineItem) object {
+	var variant *Variant
+	if li.VariantID != 0 {
+		variant = s.findVariant(li.VariantID)
+	}
+	discount := li.Discount.unitAmount(li.Price)
+	discountAllocations := []object{}
+	if li.Discount != nil && discount > 0 {
+		discountAllocations = append(discountAllocations, object{
+			"__typename":          "DiscountAllocation",
+			"allocatedAmountSet":  s.moneyBag(round2(discount * float64(li.Quantity))),
+			"discountApplication": s.discountApplicationObject(li.Discount),
+		})
+	}
+	return object{
+		"__typename":             typeName,
+		"id":                     id,
+		"title":                  li.Title,
+		"name":                   li.Title,
+		"sku":                    li.SKU,
+		"quantity":               li.Quantity,
+		"currentQuantity":        li.Quantity,
+		"editableQuantity":       li.Quantity,
+		"fulfillableQuantity":    li.FulfillableQuantity,
+		"taxable":                li.Taxable,
+		"custom":                 li.VariantID == 0,
+		"variant":                s.variantObject(variant),
+		"appliedDiscount":        appliedDiscountObject(li.Discount, li.Price),
+		"originalUnitPriceSet":   s.moneyBag(li.Price),
+		"discountedUnitPriceSet": s.moneyBag(li.DiscountedUnitPrice()),
+		"originalTotalSet":       s.moneyBag(li.Price * float64(li.Quantity)),
+		"discountedTotalSet":     s.moneyBag(li.Total()),
+		"totalDiscountSet":       s.moneyBag(discount * float64(li.Quantity)),
+		"taxLines":               s.taxLineObjects(li.TaxLines),
+		"discountAllocations":    discountAllocations,
+	}
+}
+
+// discountApplicationObject is the ManualDiscountApplication of a line item discount
+func (s *Server) discountApplicationObject(d *AppliedDiscount) object {
+	value := object{"__typename": "PricingPercentageValue", "percentage": d.Value}
+	if d.ValueType != "PERCENTAGE" {
+		value = s.money(d.Value)
+	}
+	return object{
+		"__typename":       "ManualDiscountApplication",
+		"title":            d.Title,
+		"description":      d.Description,
+		"allocationMethod": "EACH",
+		"targetSelection":  "EXPLICIT",
+		"targetType":       "LINE_ITEM",
+		"value":            value,
+	}
+}
+
+// draftOrderObject is a DraftOrder; calculated drafts (draftOrderCalculate) have ID 0
+func (s *Server) draftOrderObject(d *DraftOrder) interface{} {
+	if d == nil {
+		return nil
+	}
+	lineItems := make([]object, 0, len(d.LineItems))
+	for _, li := range d.LineItems {
+		lineItems = append(lineItems, s.lineItemObject("DraftOrderLineItem", gid("DraftOrderLineItem", li.ID), li))
+	}
+
+	shipping := 0.0
+	var shippingLine interface{}
+	if d.ShippingLine != nil {
+		shipping = d.ShippingLine.Price
+		shippingLine = object{
+			"__typename":         "ShippingLine",
+			"title":              d.ShippingLine.Title,
+			"originalPriceSet":   s.moneyBag(d.ShippingLine.Price),
+			"discountedPriceSet": s.moneyBag(d.ShippingLine.Price),
+		}
+	}
+	subtotal := lineItemsSubtotal(d.LineItems)
+	taxLines := mergeTaxLines(d.LineItems)
+	tax := sumTaxLines(taxLines)
+
+	var order interface{}
+	if d.OrderID != 0 {
+		o := s.findOrder(d.OrderID)
+		order = object{"__typename": "Order", "id": o.GID(), "name": o.Name}
+	}
+	var id interface{}
+	if d.ID != 0 {
+		id = d.GID()
+	}
+
+	return object{
+		"__typename":            "DraftOrder",
+		"id":                    id,
+		"name":                  d.Name,
+		"email":                 d.Email,
+		"note2":                 d.Note,
+		"tags":                  d.Tags,
+		"status":                d.Status,
+		"taxExempt":             d.TaxExempt,
+		"taxesIncluded":         false,
+		"createdAt":             formatTime(d.CreatedAt),
+		"lineItems":             connection(lineItems),
+		"shippingAddress":       addressObject(d.ShippingAddress),
+		"billingAddress":        addressObject(d.BillingAddress),
+		"shippingLine":          shippingLine,
+		"taxLines":              s.taxLineObjects(taxLines),
+		"subtotalPriceSet":      s.moneyBag(subtotal),
+		"totalShippingPriceSet": s.moneyBag(shipping),
+		"totalTaxSet":           s.moneyBag(tax),
+		"totalPriceSet":         s.moneyBag(subtotal + shipping + tax),
+		"order":                 order,
+		"invoiceUrl":            nil,
+	}
+}
+
+func (s *Server) orderObject(o *Order) interface{} {
+	if o == nil {
+		return nil
+	}
+	lineItems := make([]object, 0, len(o.LineItems))
+	for _, li := range o.LineItems {
+		lineItem := s.lineItemObject("LineItem", li.GID(), li)
+		lineItem["currentQuantity"] = li.Quantity - s.refundedQuantity(o, li.ID)
+		lineItems = append(lineItems, lineItem)
+	}
+
+	shippingLines := make([]object, 0, len(o.ShippingLines))
+	for _, sl := range o.ShippingLines {
+		shippingLines = append(shippingLines, object{
+			"__typename":         "ShippingLine",
+			"title":              sl.Title,
+			"code":               nil,
+			"originalPriceSet":   s.moneyBag(sl.Price),
+			"discountedPriceSet": s.moneyBag(sl.Price),
+			"taxLines":           []object{},
+		})
+	}
+	var shippingLine interface{}
+	if len(shippingLines) > 0 {
+		shippingLine = shippingLines[0]
+	}
+
+	fulfillmentOrders := make([]object, 0, len(o.FulfillmentOrders))
+	if s.foQueries[o.ID] >= s.FulfillmentOrderDelay {
+		for _, fo := range o.FulfillmentOrders {
+			fulfillmentOrders = append(fulfillmentOrders, s.fulfillmentOrderObject(o, fo))
+		}
+	}
+
+	fulfillments := make([]object, 0, len(o.Fulfillments))
+	for _, f := range o.Fulfillments {
+		fulfillments = append(fulfillments, s.fulfillmentObject(o, f))
+	}
+
+	transactions := make([]object, 0, len(o.Transactions))
+	for _, t := range o.Transactions {
+		transactions = append(transactions, s.transactionObject(o, t))
+	}
+
+	metafields := make([]object, 0, len(o.Metafields))
+	for _, m := range o.Metafields {
+		metafields = append(metafields, metafieldObject(m))
+	}
+
+	var cancelledAt, cancelReason interface{}
+	if o.CancelledAt != nil {
+		cancelledAt = formatTime(*o.CancelledAt)
+		cancelReason = o.CancelReason
+	}
+
+	refunds := make([]object, 0, len(o.Refunds))
+	refunded := 0.0
+	for _, r := range o.Refunds {
+		refunds = append(refunds, s.refundObject(o, r))
+		refunded += r.Total()
+	}
+
+	var customer interface{}
+	if o.CustomerID != 0 {
+		customer = s.customerObject(s.findCustomer(o.CustomerID))
+	}
+
+	discounts := 0.0
+	for _, li := range o.LineItems {
+		discounts += li.Price*float64(li.Quantity) - li.Total()
+	}
+
+	return object{
+		"__typename":               "Order",
+		"id":                       o.GID(),
+		"legacyResourceId":         strconv.FormatInt(o.ID, 10),
+		"name":                     o.Name,
+		"number":                   o.Number,
+		"orderNumber":              o.Number,
+		"email":                    o.Email,
+		"phone":                    nil,
+		"note":                     o.Note,
+		"tags":                     o.Tags,
+		"sourceName":               o.SourceName,
+		"createdAt":                formatTime(o.CreatedAt),
+		"updatedAt":                formatTime(o.UpdatedAt),
+		"processedAt":              formatTime(o.CreatedAt),
+		"cancelledAt":              cancelledAt,
+		"cancelReason":             cancelReason,
+		"closed":                   false,
+		"currencyCode":             s.Currency,
+		"displayFinancialStatus":   o.FinancialStatus,
+		"displayFulfillmentStatus": o.FulfillmentStatus,
+		"taxesIncluded":            false,
+		"lineItems":                connection(lineItems),
+		"shippingLine":             shippingLine,
+		"shippingLines":            connection(shippingLines),
+		"shippingAddress":          addressObject(o.ShippingAddress),
+		"billingAddress":           addressObject(o.BillingAddress),
+		"customer":                 customer,
+		"taxLines":                 s.taxLineObjects(o.EffectiveTaxLines()),
+		"subtotalPriceSet":         s.moneyBag(o.Subtotal()),
+		"currentSubtotalPriceSet":  s.moneyBag(o.Subtotal()),
+		"totalShippingPriceSet":    s.moneyBag(o.ShippingTotal()),
+		"totalDiscountsSet":        s.moneyBag(discounts),
+		"totalTaxSet":              s.moneyBag(o.TotalTax()),
+		"currentTotalTaxSet":       s.moneyBag(o.TotalTax()),
+		"totalPriceSet":            s.moneyBag(o.Total()),
+		"currentTotalPriceSet":     s.moneyBag(o.Total()),
+		"totalOutstandingSet":      s.moneyBag(s.outstanding(o)),
+		"fulfillmentOrders":        connection(fulfillmentOrders),
+		"fulfillments":             list(fulfillments),
+		"transactions":             list(transactions),
+		"refunds":                  refunds,
+		"totalRefundedSet":         s.moneyBag(refunded),
+		"metafields":               connection(metafields),
+		"metafield": resolver(func(args map[string]interface{}) (interface{}, error) {
+			namespace, _ := args["namespace"].(string)
+			key, _ := args["key"].(string)
+			for _, m := range o.Metafields {
+				if m.Namespace == namespace && m.Key == key {
+					return metafieldObject(m), nil
+				}
+			}
+			return nil, nil
+		}),
+		"suggestedRefund": resolver(func(args map[string]interface{}) (interface{}, error) {
+			return s.suggestedRefund(o, args)
+		}),
+	}
+}
+
+// outstanding returns the order total less successful sales and captures
+func (s *Server) outstanding(o *Order) float64 {
+	return round2(o.Total() - s.netPayments(o))
+}
+
+// netPayments returns the successful sales and captures less refunds
+func (s *Server) netPayments(o *Order) float64 {
+	paid := 0.0
+	for _, t := range o.Transactions {
+		if t.Status != "success" {
+			continue
+		}
+		switch t.Kind {
+		case "sale", "capture":
+			paid += t.Amount
+		case "refund":
+			paid -= t.Amount
+		}
+	}
+	return round2(paid)
+}
+
+func (s *Server) fulfillmentOrderObject(o *Order, fo *FulfillmentOrder) object {
+	lineItems := make([]object, 0, len(fo.LineItems))
+	for _, foli := range fo.LineItems {
+		lineItems = append(lineItems, object{
+			"__typename":        "FulfillmentOrderLineItem",
+			"id":                gid("FulfillmentOrderLineItem", foli.ID),
+			"totalQuantity":     foli.TotalQuantity,
+			"remainingQuantity": foli.RemainingQuantity,
+			"lineItem": object{
+				"__typename": "LineItem",
+				"id":         gid("LineItem", foli.LineItemID),
+			},
+		})
+	}
+	return object{
+		"__typename":    "FulfillmentOrder",
+		"id":            gid("FulfillmentOrder", fo.ID),
+		"status":        fo.Status,
+		"requestStatus": fo.RequestStatus,
+		"orderId":       o.GID(),
+		"order":         object{"__typename": "Order", "id": o.GID(), "name": o.Name},
+		"assignedLocation": object{
+			"__typename": "FulfillmentOrderAssignedLocation",
+			"name":       s.locationName(fo.LocationID),
+			"location":   s.locationObject(s.findLocation(fo.LocationID)),
+		},
+		"lineItems": connection(lineItems),
+	}
+}
+
+func (s *Server) locationName(id int64) string {
+	if l := s.findLocation(id); l != nil {
+		return l.Name
+	}
+	return ""
+}
+
+func (s *Server) fulfillmentObject(o *Order, f *Fulfillment) object {
+	var trackingInfo []object
+	if f.TrackingNumber != "" {
+		trackingInfo = append(trackingInfo, object{
+			"__typename": "FulfillmentTrackingInfo",
+			"number":     f.TrackingNumber,
+			"company":    f.TrackingCompany,
+			"url":        f.TrackingURL,
+		})
+	}
+	lineItems := make([]object, 0, len(f.LineItems))
+	for _, fli := range f.LineItems {
+		lineItems = append(lineItems, s.fulfillmentLineItemObject(o, fli))
+	}
+	return object{
+		"__typename":           "Fulfillment",
+		"id":                   gid("Fulfillment", f.ID),
+		"status":               f.Status,
+		"createdAt":            formatTime(f.CreatedAt),
+		"trackingInfo":         trackingInfo,
+		"fulfillmentLineItems": connection(lineItems),
+	}
+}
+
+func (s *Server) fulfillmentLineItemObject(o *Order, fli *FulfillmentLineItem) object {
+	return object{
+		"__typename": "FulfillmentLineItem",
+		"id":         gid("FulfillmentLineItem", fli.ID),
+		"quantity":   fli.Quantity,
+		"lineItem":   s.orderLineItemObject(o, fli.LineItemID),
+	}
+}
+
+// orderLineItemObject returns the order's line item with the ID, or nil
+func (s *Server) orderLineItemObject(o *Order, id int64) interface{} {
+	for _, li := range o.LineItems {
+		if li.ID == id {
+			return s.lineItemObject("LineItem", li.GID(), li)
+		}
+	}
+	return nil
+}
+
+func (s *Server) transactionObject(o *Order, t *Transaction) object {
+	var parent interface{}
+	if t.ParentID != 0 {
+		parent = object{"__typename": "OrderTransaction", "id": gid("OrderTransaction", t.ParentID)}
+	}
+	return object{
+		"__typename":        "OrderTransaction",
+		"id":                gid("OrderTransaction", t.ID),
+		"kind":              strings.ToUpper(t.Kind),
+		"status":            strings.ToUpper(t.Status),
+		"gateway":           t.Gateway,
+		"formattedGateway":  t.Gateway,
+		"test":              false,
+		"createdAt":         formatTime(t.CreatedAt),
+		"processedAt":       formatTime(t.processedAt()),
+		"amountSet":         s.moneyBag(t.Amount),
+		"parentTransaction": parent,
+		"order":             object{"__typename": "Order", "id": o.GID()},
+	}
+}
+
+func (s *Server) refundObject(o *Order, r *Refund) object {
+	transactions := make([]object, 0, len(r.Transactions))
+	for _, t := range r.Transactions {
+		transactions = append(transactions, s.transactionObject(o, t))
+	}
+	lineItems := make([]object, 0, len(r.LineItems))
+	for _, rli := range r.LineItems {
+		lineItems = append(lineItems, object{
+			"__typename":  "RefundLineItem",
+			"lineItem":    s.orderLineItemObject(o, rli.LineItemID),
+			"quantity":    rli.Quantity,
+			"restockType": rli.RestockType,
+			"restocked":   rli.RestockType != "NO_RESTOCK",
+			"subtotalSet": s.moneyBag(rli.Subtotal),
+			"totalTaxSet": s.moneyBag(rli.TotalTax),
+		})
+	}
+	return object{
+		"__typename":       "Refund",
+		"id":               gid("Refund", r.ID),
+		"note":             r.Note,
+		"createdAt":        formatTime(r.CreatedAt),
+		"totalRefundedSet": s.moneyBag(r.Total()),
+		"transactions":     connection(transactions),
+		"refundLineItems":  connection(lineItems),
+		"order": resolver(func(map[string]interface{}) (interface{}, error) {
+			return s.orderObject(o), nil
+		}),
+	}
+}
+
+func metafieldObject(m *Metafield) object {
+	return object{
+		"__typename": "Metafield",
+		"id":         gid("Metafield", m.ID),
+		"namespace":  m.Namespace,
+		"key":        m.Key,
+		"type":       m.Type,
+		"value":      m.Value,
+	}
+}
+
+func metafieldDefinitionObject(d *MetafieldDefinition) object {
+	return object{
+		"__typename": "MetafieldDefinition",
+		"id":         gid("MetafieldDefinition", d.ID),
+		"name":       d.Name,
+		"namespace":  d.Namespace,
+		"key":        d.Key,
+		"ownerType":  d.OwnerType,
+		"type": object{
+			"__typename": "MetafieldDefinitionType",
+			"name":       d.Type,
+		},
+	}
+}
+
+func (s *Server) calculatedOrderObject(co *calculatedOrder) interface{} {
+	if co == nil {
+		return nil
+	}
+	o := s.findOrder(co.OrderID)
+	// The staged totals carry the tax Shopify will charge on commit
+	items := cloneLineItems(co.LineItems)
+	s.applyTax(items, false)
+
+	lineItems := make([]object, 0, len(items))
+	addedLineItems := []object{}
+	quantity := 0
+	for _, li := range items {
+		lineItem := s.calculatedLineItemObject(co, li)
+		lineItems = append(lineItems, lineItem)
+		if findLineItem(o, li.ID) == nil {
+			addedLineItems = append(addedLineItems, lineItem)
+		}
+		quantity += li.Quantity
+	}
+
+	shippingLines := make([]object, 0, len(co.ShippingLines))
+	shipping := 0.0
+	for _, sl := range co.ShippingLines {
+		shippingLines = append(shippingLines, s.calculatedShippingLineObject(sl))
+		if sl.Status != "REMOVED" {
+			shipping += sl.Price
+		}
+	}
+
+	subtotal := lineItemsSubtotal(items)
+	taxLines := mergeTaxLines(items)
+	total := round2(subtotal + shipping + sumTaxLines(taxLines))
+	return object{
+		"__typename":                "CalculatedOrder",
+		"id":                        gid("CalculatedOrder", co.ID),
+		"originalOrder":             object{"__typename": "Order", "id": o.GID(), "name": o.Name},
+		"committed":                 co.Committed,
+		"lineItems":                 connection(lineItems),
+		"addedLineItems":            connection(addedLineItems),
+		"shippingLines":             shippingLines,
+		"taxLines":                  s.taxLineObjects(taxLines),
+		"subtotalLineItemsQuantity": quantity,
+		"subtotalPriceSet":          s.moneyBag(subtotal),
+		"totalPriceSet":             s.moneyBag(total),
+		"totalOutstandingSet":       s.moneyBag(total - s.netPayments(o)),
+	}
+}
+
+// calculatedLineItemObject is a CalculatedLineItem of an edit session
+func (s *Server) calculatedLineItemObject(co *calculatedOrder, li *LineItem) object {
+	lineItem := s.lineItemObject("CalculatedLineItem", gid("CalculatedLineItem", li.ID), li)
+	o := s.findOrder(co.OrderID)
+	original := findLineItem(o, li.ID)
+
+	editable := li.Quantity
+	if original != nil {
+		editable -= original.Quantity - original.FulfillableQuantity
+	}
+	allocations := []object{}
+	if discount := li.Discount.unitAmount(li.Price); discount > 0 {
+		allocations = append(allocations, object{
+			"__typename":         "CalculatedDiscountAllocation",
+			"allocatedAmountSet": s.moneyBag(discount * float64(li.Quantity)),
+			"discountApplication": object{
+				"__typename":  "CalculatedManualDiscountApplication",
+				"id":          gid("CalculatedManualDiscountApplication", li.ID),
+				"description": li.Discount.Description,
+			},
+		})
+	}
+	lineItem["editableQuantity"] = editable
+	lineItem["restockable"] = original != nil && li.VariantID != 0
+	lineItem["restocking"] = co.Restock[li.ID]
+	lineItem["editableSubtotalSet"] = s.moneyBag(li.Total())
+	lineItem["calculatedDiscountAllocations"] = allocations
+	lineItem["hasStagedLineItemDiscount"] = li.Discount != nil
+	return lineItem
+}
+
+func (s *Server) calculatedShippingLineObject(sl *calculatedShippingLine) object {
+	return object{
+		"__typename":   "CalculatedShippingLine",
+		"id":           gid("CalculatedShippingLine", sl.ID),
+		"title":        sl.Title,
+		"price":        s.moneyBag(sl.Price),
+		"stagedStatus": sl.Status,
+		"taxLines":     []object{},
+	}
+}
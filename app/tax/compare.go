@@ -0,0 +1,198 @@
+package tax
+
+import (
+	"fmt"
+	"strconv"
+
+	"shopify-demo/app"
+)
+
+// Tolerance is the largest difference Compare does not report, so the cent
+// per-line and per-invoice rounding can disagree by is not a discrepancy
+var Tolerance = app.MustParseMoney("0.01", "")
+
+// Sources of the totals compared with a Result
+const (
+	SourcePOS     = "pos"
+	SourceShopify = "shopify"
+)
+
+// Totals are the tax and totals of an order as calculated elsewhere
+type Totals struct {
+	Subtotal app.Money
+	TotalTax app.Money
+	Total    app.Money
+	// Nil skips the comparison of tax lines
+	TaxLines []Amount
+	// Tax of each line item, in the order of the invoice's lines. Nil skips
+	// the comparison of lines.
+	LineTaxes []app.Money
+}
+
+// Discrepancy is an amount that differs from the calculated one by more than
+// Tolerance
+type Discrepancy struct {
+	Source string
+	// "subtotal", "totalTax", "total", "taxLine Washington" or "line 0"
+	Field      string
+	Calculated app.Money
+	Reported   app.Money
+}
+
+// Difference returns the reported amount minus the calculated one
+func (d Discrepancy) Difference() app.Money {
+	return d.Reported.Sub(d.Calculated)
+}
+
+func (d Discrepancy) String() string {
+	difference := d.Difference().StringFixed()
+	if d.Difference().IsPositive() {
+		difference = "+" + difference
+	}
+	return fmt.Sprintf("%s %s: calculated %s, got %s (%s)", d.Source, d.Field,
+		d.Calculated.StringFixed(), d.Reported.StringFixed(), difference)
+}
+
+// Totals returns the result as Totals
+func (r *Result) Totals() Totals {
+	t := Totals{Subtotal: r.Subtotal, TotalTax: r.TotalTax, Total: r.Total, TaxLines: r.TaxLines}
+	for _, line := range r.Lines {
+		t.LineTaxes = append(t.LineTaxes, line.Tax)
+	}
+	return t
+}
+
+// Compare returns the amounts of totals that differ from the result by more
+// than Tolerance. Tax lines are matched by title; a line missing on one side
+// counts as 0. The amounts must be in the result's currency, see CheckCurrency.
+func (r *Result) Compare(source string, totals Totals) []Discrepancy {
+	var out []Discrepancy
+	check := func(field string, calculated, reported app.Money) {
+		if reported.Sub(calculated).Abs().Cmp(Tolerance) > 0 {
+			out = append(out, Discrepancy{Source: source, Field: field, Calculated: calculated, Reported: reported})
+		}
+	}
+
+	check("subtotal", r.Subtotal, totals.Subtotal)
+	check("totalTax", r.TotalTax, totals.TotalTax)
+	check("total", r.Total, totals.Total)
+
+	if totals.TaxLines != nil {
+		reported := &taxLines{}
+		for _, tl := range totals.TaxLines {
+			reported.add(Rate{Title: tl.Title, Rate: tl.Rate}, tl.Amount)
+		}
+		seen := map[string]bool{}
+		for _, tl := range r.TaxLines {
+			seen[tl.Title] = true
+			check("taxLine "+tl.Title, tl.Amount, reported.amount(tl.Title))
+		}
+		for _, tl := range reported.lines {
+			if !seen[tl.Title] {
+				check("taxLine "+tl.Title, app.Money{}, tl.Amount)
+			}
+		}
+	}
+
+	if totals.LineTaxes != nil {
+		for i := 0; i < max(len(r.Lines), len(totals.LineTaxes)); i++ {
+			var calculated, reported app.Money
+			if i < len(r.Lines) {
+				calculated = r.Lines[i].Tax
+			}
+			if i < len(totals.LineTaxes) {
+				reported = totals.LineTaxes[i]
+			}
+			check("line "+strconv.Itoa(i), calculated, reported)
+		}
+	}
+	return out
+}
+
+// CheckCurrency returns an error wrapping app.ErrCurrencyMismatch when an
+// amount of totals is in another currency than the result
+func (r *Result) CheckCurrency(totals Totals) error {
+	calculated := r.Total
+	amounts := []app.Money{totals.Subtotal, totals.TotalTax, totals.Total}
+	for _, tl := range totals.TaxLines {
+		amounts = append(amounts, tl.Amount)
+	}
+	amounts = append(amounts, totals.LineTaxes...)
+	for _, amount := range amounts {
+		if err := calculated.CheckCurrency(amount); err != nil {
+			return err
+		}
+		if calculated.Currency() == "" {
+			calculated = amount
+		}
+	}
+	return nil
+}
+
+func (t *taxLines) amount(title string) app.Money {
+	for _, tl := range t.lines {
+		if tl.Title == title {
+			return tl.Amount
+		}
+	}
+	return app.Money{}
+}
+
+// OrderTotals returns the totals Shopify calculated for an order. Line taxes
+// are included when the order was fetched with its line items.
+func OrderTotals(order *app.Order) Totals {
+	t := Totals{
+		Subtotal: order.SubtotalPriceSet.ShopMoney.Amount,
+		TotalTax: order.TotalTaxSet.ShopMoney.Amount,
+		Total:    order.TotalPriceSet.ShopMoney.Amount,
+		TaxLines: []Amount{},
+	}
+	for _, tl := range order.TaxLines {
+		t.TaxLines = append(t.TaxLines, Amount{Title: tl.Title, Rate: tl.Rate, Amount: tl.PriceSet.ShopMoney.Amount})
+	}
+	if order.LineItems != nil {
+		t.LineTaxes = []app.Money{}
+		for _, li := range order.LineItems {
+			var lineTax app.Money
+			for _, tl := range li.TaxLines {
+				lineTax = lineTax.Add(tl.PriceSet.ShopMoney.Amount)
+			}
+			t.LineTaxes = append(t.LineTaxes, lineTax)
+		}
+	}
+	return t
+}
+
+// Report is a calculation compared with the POS and Shopify
+type Report struct {
+	Result *Result
+	// Differences with the POS and with Shopify, each nil when they agree
+	POS     []Discrepancy
+	Shopify []Discrepancy
+}
+
+// OK reports whether the POS and Shopify both agree with the calculation
+func (r *Report) OK() bool {
+	return len(r.POS) == 0 && len(r.Shopify) == 0
+}
+
+// Check calculates the tax of an invoice and compares it with the POS totals
+// and, unless order is nil, with the totals Shopify calculated for the order
+func Check(inv Invoice, pos Totals, order *app.Order) (*Report, error) {
+	result, err := Calculate(inv)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.CheckCurrency(pos); err != nil {
+		return nil, fmt.Errorf("POS totals: %w", err)
+	}
+	report := &Report{Result: result, POS: result.Compare(SourcePOS, pos)}
+	if order != nil {
+		shopify := OrderTotals(order)
+		if err := result.CheckCurrency(shopify); err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.Name, err)
+		}
+		report.Shopify = result.Compare(SourceShopify, shopify)
+	}
+	return report, nil
+}
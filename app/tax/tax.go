@@ -0,0 +1,280 @@
+// Package tax calculates the tax of an order from its rates, so the tax sent by
+// the POS and the tax Shopify calculated can both be checked against it:
+//
+//	result, err := tax.Calculate(tax.Invoice{
+//		Lines:         []tax.Line{{ID: "48360774729968", Price: app.MustParseMoney("699.95", "USD"), Quantity: 1, Taxable: true}},
+//		Rates:         []tax.Rate{{Title: "Washington", Rate: decimal.RequireFromString("0.065")}},
+//		TaxesIncluded: true,
+//	})
+//	discrepancies := result.Compare(tax.SourcePOS, posTotals)
+//
+// Amounts are in the order's currency and results are rounded half up to its
+// minor unit.
+package tax
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"shopify-demo/app"
+)
+
+// Rounding says when tax amounts are rounded to cents
+type Rounding int
+
+const (
+	// RoundPerLine rounds the tax of each line and sums the rounded amounts,
+	// like Shopify
+	RoundPerLine Rounding = iota
+	// RoundPerInvoice sums the exact tax of the lines and rounds each tax line
+	// of the invoice once
+	RoundPerInvoice
+)
+
+// Rate is a tax charged on taxable lines
+type Rate struct {
+	Title string
+	// As a decimal, e.g. 0.065 for 6.5%
+	Rate decimal.Decimal
+	// A compound rate is charged on the price plus the rates before it, e.g.
+	// Quebec's QST on top of GST
+	Compound bool
+}
+
+// Line is a line item of an invoice
+type Line struct {
+	// Identifies the line in the result, e.g. the POS product ID
+	ID string
+	// Unit price, including tax when the invoice's TaxesIncluded
+	Price    app.Money
+	Quantity int
+	// Total discount of the line, on the same basis as Price. Tax is charged
+	// after discounts.
+	Discount app.Money
+	Taxable  bool
+	// Rates of the line, e.g. those of its tax class. Nil means Invoice.Rates.
+	Rates []Rate
+}
+
+// Invoice is what Calculate computes the tax of
+type Invoice struct {
+	Lines []Line
+	Rates []Rate
+	// Shipping price, including tax when TaxesIncluded
+	Shipping app.Money
+	// Charge Rates on shipping
+	TaxShipping   bool
+	TaxesIncluded bool
+	Rounding      Rounding
+}
+
+// Amount is the amount of one tax
+type Amount struct {
+	Title  string
+	Rate   decimal.Decimal
+	Amount app.Money
+}
+
+// LineResult is the tax of a line, or of shipping
+type LineResult struct {
+	ID string
+	// Price after discounts, without tax
+	Net      app.Money
+	Tax      app.Money
+	Gross    app.Money
+	TaxLines []Amount
+}
+
+// Result is the tax of an invoice. With RoundPerInvoice the invoice's tax
+// lines are rounded once, so the lines' rounded taxes may not add up to them.
+type Result struct {
+	Lines    []LineResult
+	Shipping LineResult
+	// One per tax title, lines and shipping together
+	TaxLines []Amount
+	// Line items after discounts, with tax when prices include it, like
+	// Shopify's subtotalPrice
+	Subtotal app.Money
+	TotalTax app.Money
+	// Subtotal plus shipping, plus tax when prices exclude it
+	Total app.Money
+}
+
+// Calculate computes the tax of each line, of shipping and of the invoice.
+// Amounts in different currencies are reported as an error wrapping
+// app.ErrCurrencyMismatch.
+func Calculate(inv Invoice) (*Result, error) {
+	if err := validateRates(inv.Rates); err != nil {
+		return nil, err
+	}
+	if err := checkCurrency(inv); err != nil {
+		return nil, err
+	}
+	if inv.Shipping.IsNegative() {
+		return nil, fmt.Errorf("shipping: price must not be negative")
+	}
+
+	result := &Result{}
+	invoice := &taxLines{}
+	var subtotal app.Money
+	for i, line := range inv.Lines {
+		if line.Quantity < 0 || line.Price.IsNegative() {
+			return nil, fmt.Errorf("line %d: price and quantity must not be negative", i)
+		}
+		base := line.Price.MulInt(line.Quantity).Sub(line.Discount)
+		if line.Discount.IsNegative() || base.IsNegative() {
+			return nil, fmt.Errorf("line %d: discount must be between 0 and the line price", i)
+		}
+		rates := line.Rates
+		if rates == nil {
+			rates = inv.Rates
+		} else if err := validateRates(rates); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		if !line.Taxable {
+			rates = nil
+		}
+		lr := calculateLine(line.ID, base, rates, inv, invoice)
+		result.Lines = append(result.Lines, lr)
+		if inv.TaxesIncluded {
+			subtotal = subtotal.Add(base)
+		} else {
+			subtotal = subtotal.Add(lr.Net)
+		}
+	}
+
+	var shippingRates []Rate
+	if inv.TaxShipping {
+		shippingRates = inv.Rates
+	}
+	result.Shipping = calculateLine("shipping", inv.Shipping, shippingRates, inv, invoice)
+
+	result.TaxLines = invoice.rounded()
+	for _, tl := range result.TaxLines {
+		result.TotalTax = result.TotalTax.Add(tl.Amount)
+	}
+	result.Subtotal = round(subtotal)
+	result.Total = round(subtotal.Add(inv.Shipping))
+	if !inv.TaxesIncluded {
+		result.Total = result.Total.Add(result.TotalTax)
+	}
+	return result, nil
+}
+
+// calculateLine computes the tax of an amount and adds it to the invoice's tax
+// lines: per line rounded amounts, or exact ones when rounding per invoice
+func calculateLine(id string, base app.Money, rates []Rate, inv Invoice, invoice *taxLines) LineResult {
+	exact := exactTax(base, rates, inv.TaxesIncluded)
+	lr := LineResult{ID: id}
+	for i, rate := range rates {
+		amount := round(exact[i])
+		lr.TaxLines = append(lr.TaxLines, Amount{Title: rate.Title, Rate: rate.Rate, Amount: amount})
+		lr.Tax = lr.Tax.Add(amount)
+		if inv.Rounding == RoundPerInvoice {
+			invoice.add(rate, exact[i])
+		} else {
+			invoice.add(rate, amount)
+		}
+	}
+	if inv.TaxesIncluded {
+		lr.Gross = round(base)
+		lr.Net = lr.Gross.Sub(lr.Tax)
+	} else {
+		lr.Net = round(base)
+		lr.Gross = lr.Net.Add(lr.Tax)
+	}
+	return lr
+}
+
+// exactTax returns the unrounded tax of each rate on an amount. Simple rates
+// are charged on the net amount; each compound rate on the net amount plus
+// all the tax before it.
+func exactTax(base app.Money, rates []Rate, included bool) []app.Money {
+	net := base
+	if included {
+		factor := decimal.NewFromInt(1)
+		for _, rate := range rates {
+			if !rate.Compound {
+				factor = factor.Add(rate.Rate)
+			}
+		}
+		for _, rate := range rates {
+			if rate.Compound {
+				factor = factor.Mul(rate.Rate.Add(decimal.NewFromInt(1)))
+			}
+		}
+		net = base.Div(factor)
+	}
+
+	amounts := make([]app.Money, len(rates))
+	taxed := net
+	for i, rate := range rates {
+		if !rate.Compound {
+			amounts[i] = net.Mul(rate.Rate)
+			taxed = taxed.Add(amounts[i])
+		}
+	}
+	for i, rate := range rates {
+		if rate.Compound {
+			amounts[i] = taxed.Mul(rate.Rate)
+			taxed = taxed.Add(amounts[i])
+		}
+	}
+	return amounts
+}
+
+// taxLines sums the tax of an invoice by title, keeping the order of the titles
+type taxLines struct {
+	lines []Amount
+}
+
+func (t *taxLines) add(rate Rate, amount app.Money) {
+	for i := range t.lines {
+		if t.lines[i].Title == rate.Title {
+			t.lines[i].Amount = t.lines[i].Amount.Add(amount)
+			return
+		}
+	}
+	t.lines = append(t.lines, Amount{Title: rate.Title, Rate: rate.Rate, Amount: amount})
+}
+
+func (t *taxLines) rounded() []Amount {
+	out := make([]Amount, 0, len(t.lines))
+	for _, tl := range t.lines {
+		tl.Amount = round(tl.Amount)
+		out = append(out, tl)
+	}
+	return out
+}
+
+// checkCurrency returns an error when the prices, discounts and shipping of an
+// invoice are not all in one currency. Amounts without a currency match any.
+func checkCurrency(inv Invoice) error {
+	currency := inv.Shipping
+	for i, line := range inv.Lines {
+		for _, amount := range []app.Money{line.Price, line.Discount} {
+			if err := currency.CheckCurrency(amount); err != nil {
+				return fmt.Errorf("line %d: %w", i, err)
+			}
+			if amount.Currency() != "" {
+				currency = amount
+			}
+		}
+	}
+	return nil
+}
+
+func validateRates(rates []Rate) error {
+	for _, rate := range rates {
+		if rate.Rate.IsNegative() || rate.Rate.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+			return fmt.Errorf("rate %s: %s is not a decimal rate between 0 and 1", rate.Title, rate.Rate)
+		}
+	}
+	return nil
+}
+
+// round rounds an amount to cents, half away from zero
+func round(amount app.Money) app.Money {
+	return amount.Round(app.RoundHalfUp)
+}
@@ -0,0 +1,251 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"shopify-demo/app"
+)
+
+func usd(s string) app.Money {
+	return app.MustParseMoney(s, "USD")
+}
+
+func rate(title, value string) Rate {
+	return Rate{Title: title, Rate: decimal.RequireFromString(value)}
+}
+
+// taxLineAmounts formats tax lines as "Title amount"
+func taxLineAmounts(lines []Amount) string {
+	var out []string
+	for _, tl := range lines {
+		out = append(out, tl.Title+" "+tl.Amount.StringFixed())
+	}
+	return fmt.Sprint(out)
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		inv  Invoice
+		// Formatted with StringFixed
+		wantSubtotal, wantTax, wantTotal string
+		wantTaxLines                     string
+		wantLineTaxes                    string
+	}{
+		{
+			// 699.95 / 1.065 = 657.2300…, so the price includes 42.72 of tax
+			name: "inclusive back-out",
+			inv: Invoice{
+				Lines:         []Line{{ID: "1", Price: usd("699.95"), Quantity: 1, Taxable: true}},
+				Rates:         []Rate{rate("Washington", "0.065")},
+				TaxesIncluded: true,
+			},
+			wantSubtotal: "699.95", wantTax: "42.72", wantTotal: "699.95",
+			wantTaxLines:  "[Washington 42.72]",
+			wantLineTaxes: "[42.72]",
+		},
+		{
+			// GST 5% of 100 = 5.00; QST 9.975% of 105 = 10.47375
+			name: "compound rate over a base rate",
+			inv: Invoice{
+				Lines: []Line{{ID: "1", Price: usd("100"), Quantity: 1, Taxable: true}},
+				Rates: []Rate{rate("GST", "0.05"), {Title: "QST", Rate: decimal.RequireFromString("0.09975"), Compound: true}},
+			},
+			wantSubtotal: "100.00", wantTax: "15.47", wantTotal: "115.47",
+			wantTaxLines:  "[GST 5.00 QST 10.47]",
+			wantLineTaxes: "[15.47]",
+		},
+		{
+			// 5% of 1.10 is 0.055 per line: three rounded 0.06s
+			name: "rounded per line",
+			inv: Invoice{
+				Lines: []Line{
+					{ID: "1", Price: usd("1.10"), Quantity: 1, Taxable: true},
+					{ID: "2", Price: usd("1.10"), Quantity: 1, Taxable: true},
+					{ID: "3", Price: usd("1.10"), Quantity: 1, Taxable: true},
+				},
+				Rates: []Rate{rate("Tax", "0.05")},
+			},
+			wantSubtotal: "3.30", wantTax: "0.18", wantTotal: "3.48",
+			wantTaxLines:  "[Tax 0.18]",
+			wantLineTaxes: "[0.06 0.06 0.06]",
+		},
+		{
+			// The same lines sum to 0.165, rounded once to 0.17
+			name: "rounded per invoice",
+			inv: Invoice{
+				Lines: []Line{
+					{ID: "1", Price: usd("1.10"), Quantity: 1, Taxable: true},
+					{ID: "2", Price: usd("1.10"), Quantity: 1, Taxable: true},
+					{ID: "3", Price: usd("1.10"), Quantity: 1, Taxable: true},
+				},
+				Rates:    []Rate{rate("Tax", "0.05")},
+				Rounding: RoundPerInvoice,
+			},
+			wantSubtotal: "3.30", wantTax: "0.17", wantTotal: "3.47",
+			wantTaxLines:  "[Tax 0.17]",
+			wantLineTaxes: "[0.06 0.06 0.06]",
+		},
+		{
+			name: "discount, exempt line and taxed shipping",
+			inv: Invoice{
+				Lines: []Line{
+					{ID: "1", Price: usd("20"), Quantity: 2, Discount: usd("4"), Taxable: true},
+					{ID: "2", Price: usd("10"), Quantity: 1},
+				},
+				Rates:       []Rate{rate("Tax", "0.1")},
+				Shipping:    usd("5"),
+				TaxShipping: true,
+			},
+			wantSubtotal: "46.00", wantTax: "4.10", wantTotal: "55.10",
+			wantTaxLines:  "[Tax 4.10]",
+			wantLineTaxes: "[3.60 0.00]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Calculate(tt.inv)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			got := [3]string{result.Subtotal.StringFixed(), result.TotalTax.StringFixed(), result.Total.StringFixed()}
+			if want := [3]string{tt.wantSubtotal, tt.wantTax, tt.wantTotal}; got != want {
+				t.Errorf("subtotal, tax, total = %v, want %v", got, want)
+			}
+			if got := taxLineAmounts(result.TaxLines); got != tt.wantTaxLines {
+				t.Errorf("tax lines = %s, want %s", got, tt.wantTaxLines)
+			}
+			var lineTaxes []string
+			for _, line := range result.Lines {
+				lineTaxes = append(lineTaxes, line.Tax.StringFixed())
+			}
+			if got := fmt.Sprint(lineTaxes); got != tt.wantLineTaxes {
+				t.Errorf("line taxes = %s, want %s", got, tt.wantLineTaxes)
+			}
+		})
+	}
+}
+
+func TestCalculateInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		inv  Invoice
+	}{
+		{"rate of 1 or more", Invoice{Rates: []Rate{rate("Tax", "6.5")}}},
+		{"negative rate", Invoice{Rates: []Rate{rate("Tax", "-0.1")}}},
+		{"negative quantity", Invoice{Lines: []Line{{Price: usd("1"), Quantity: -1}}}},
+		{"discount above the line price", Invoice{Lines: []Line{{Price: usd("1"), Quantity: 1, Discount: usd("2")}}}},
+		{"negative shipping", Invoice{Shipping: usd("-5")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Calculate(tt.inv); err == nil {
+				t.Error("Calculate succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCalculateCurrencyMismatch(t *testing.T) {
+	eur := app.MustParseMoney("1", "EUR")
+	tests := []struct {
+		name string
+		inv  Invoice
+	}{
+		{"lines", Invoice{Lines: []Line{{Price: usd("10"), Quantity: 1}, {Price: eur, Quantity: 1}}}},
+		{"discount", Invoice{Lines: []Line{{Price: usd("10"), Quantity: 1, Discount: eur}}}},
+		{"shipping", Invoice{Lines: []Line{{Price: usd("10"), Quantity: 1}}, Shipping: eur}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Calculate(tt.inv); !errors.Is(err, app.ErrCurrencyMismatch) {
+				t.Errorf("Calculate error = %v, want ErrCurrencyMismatch", err)
+			}
+		})
+	}
+
+	// Amounts without a currency, like a zero discount, match any
+	inv := Invoice{Lines: []Line{{Price: eur, Quantity: 2, Discount: app.Money{}}}, Shipping: eur}
+	result, err := Calculate(inv)
+	if err != nil {
+		t.Fatalf("Calculate: %v", err)
+	}
+	if result.Total.StringFixed() != "3.00" {
+		t.Errorf("total = %s, want 3.00", result.Total.StringFixed())
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	result := &Result{
+		Subtotal: usd("3.30"),
+		TotalTax: usd("0.17"),
+		Total:    usd("3.47"),
+		TaxLines: []Amount{{Title: "Tax", Amount: usd("0.17")}},
+	}
+	tests := []struct {
+		name     string
+		totalTax string
+		want     int
+	}{
+		{"equal", "0.17", 0},
+		{"a cent over", "0.18", 0},
+		{"a cent under", "0.16", 0},
+		{"just over a cent", "0.181", 1},
+		{"two cents over", "0.19", 1},
+		{"two cents under", "0.15", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals := Totals{Subtotal: usd("3.30"), TotalTax: usd(tt.totalTax), Total: usd("3.47")}
+			got := result.Compare(SourcePOS, totals)
+			if len(got) != tt.want {
+				t.Fatalf("Compare = %v, want %d discrepancies", got, tt.want)
+			}
+			if tt.want > 0 && got[0].Field != "totalTax" {
+				t.Errorf("discrepancy field = %q, want totalTax", got[0].Field)
+			}
+		})
+	}
+}
+
+func TestCompareTaxLines(t *testing.T) {
+	result := &Result{TaxLines: []Amount{{Title: "GST", Amount: usd("5.00")}, {Title: "QST", Amount: usd("10.47")}}}
+	totals := Totals{TaxLines: []Amount{
+		{Title: "GST", Amount: usd("5.01")},
+		{Title: "PST", Amount: usd("7.00")},
+	}}
+	var fields []string
+	for _, d := range result.Compare(SourceShopify, totals) {
+		fields = append(fields, d.Field)
+	}
+	// GST is within a cent; QST is missing and PST unexpected
+	if want := "[taxLine QST taxLine PST]"; fmt.Sprint(fields) != want {
+		t.Errorf("discrepancies = %v, want %s", fields, want)
+	}
+}
+
+func TestRoundingModesAgreeWithinTolerance(t *testing.T) {
+	lines := []Line{
+		{ID: "1", Price: usd("1.10"), Quantity: 1, Taxable: true},
+		{ID: "2", Price: usd("1.10"), Quantity: 1, Taxable: true},
+		{ID: "3", Price: usd("1.10"), Quantity: 1, Taxable: true},
+	}
+	perLine, err := Calculate(Invoice{Lines: lines, Rates: []Rate{rate("Tax", "0.05")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	perInvoice, err := Calculate(Invoice{Lines: lines, Rates: []Rate{rate("Tax", "0.05")}, Rounding: RoundPerInvoice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perLine.TotalTax.Equal(perInvoice.TotalTax) {
+		t.Fatalf("both roundings give %s, want them a cent apart", perLine.TotalTax)
+	}
+	if d := perInvoice.Compare(SourcePOS, perLine.Totals()); len(d) != 0 {
+		t.Errorf("Compare = %v, want no discrepancies", d)
+	}
+}
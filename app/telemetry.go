@@ -0,0 +1,390 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package's spans
+const instrumentationName = "shopify-demo/app"
+
+// Metrics holds the Prometheus collectors for Admin API calls. Every series is
+// labeled by operation (GraphQL operation name or REST "METHOD path") and shop.
+type Metrics struct {
+	// shopify_requests_total: calls by kind and outcome (ok, user_error, error)
+	requests *prometheus.CounterVec
+	// shopify_request_duration_seconds: call latency including retries and waits
+	duration *prometheus.HistogramVec
+	// shopify_http_attempts_total: HTTP attempts by response status ("0" for network errors)
+	attempts *prometheus.CounterVec
+	// shopify_throttle_wait_seconds: time spent waiting for the rate limiter
+	throttleWait *prometheus.HistogramVec
+	// shopify_retries_total: retried attempts by reason (throttled, server_error, network)
+	retries *prometheus.CounterVec
+	// shopify_user_errors_total: userErrors returned by mutations
+	userErrors *prometheus.CounterVec
+	// shopify_graphql_cost_total: actual GraphQL query cost
+	cost *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors and registers them with reg. Collectors
+// already registered by another Metrics are reused.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	labels := []string{"operation", "shop"}
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shopify_requests_total",
+			Help: "Admin API calls by kind and outcome (ok, user_error, error).",
+		}, append(labels, "kind", "outcome")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "shopify_request_duration_seconds",
+			Help:    "Admin API call latency, including retries and rate limiter waits.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60},
+		}, labels),
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shopify_http_attempts_total",
+			Help: "HTTP requests sent to the Admin API by response status (0 for network errors).",
+		}, append(labels, "status")),
+		throttleWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "shopify_throttle_wait_seconds",
+			Help:    "Time spent waiting for room in the shop's rate limit bucket.",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 2, 5, 10, 30},
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shopify_retries_total",
+			Help: "Admin API attempts that were retried, by reason (throttled, server_error, network).",
+		}, append(labels, "reason")),
+		userErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shopify_user_errors_total",
+			Help: "userErrors returned by Admin API mutations.",
+		}, labels),
+		cost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shopify_graphql_cost_total",
+			Help: "Actual GraphQL query cost charged to the shop's bucket.",
+		}, labels),
+	}
+
+	var err error
+	m.requests, err = register(reg, m.requests)
+	if err == nil {
+		m.duration, err = register(reg, m.duration)
+	}
+	if err == nil {
+		m.attempts, err = register(reg, m.attempts)
+	}
+	if err == nil {
+		m.throttleWait, err = register(reg, m.throttleWait)
+	}
+	if err == nil {
+		m.retries, err = register(reg, m.retries)
+	}
+	if err == nil {
+		m.userErrors, err = register(reg, m.userErrors)
+	}
+	if err == nil {
+		m.cost, err = register(reg, m.cost)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers c, or returns the equal collector that is already registered
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+var (
+	defaultMetricsOnce sync.Once
+	defaultMetrics     *Metrics
+)
+
+// DefaultMetrics returns the metrics used by clients without WithMetrics,
+// registered with prometheus.DefaultRegisterer
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		m, err := NewMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			DefaultLogger().Warn("Shopify metrics are not exported", "error", err)
+			m, _ = NewMetrics(prometheus.NewRegistry())
+		}
+		defaultMetrics = m
+	})
+	return defaultMetrics
+}
+
+// MetricsHandler serves the metrics of prometheus.DefaultRegisterer for scraping,
+// e.g. http.Handle("/metrics", app.MetricsHandler())
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// WithMetrics sets where the client's Prometheus metrics are recorded
+func WithMetrics(metrics *Metrics) ClientOption {
+	return func(c *Client) {
+		c.Metrics = metrics
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used for the client's spans
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		c.TracerProvider = provider
+	}
+}
+
+func (c *Client) metrics() *Metrics {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+	return DefaultMetrics()
+}
+
+func (c *Client) tracer() trace.Tracer {
+	provider := c.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// startSpan starts a span for an app operation such as CreateOrderFromDraftWithTax.
+// Admin API calls made with the returned context become its children.
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("shopify.shop", c.ShopDomain))
+	return c.tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err (if any) on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startCallSpan starts the span of an Admin API call; each HTTP attempt gets a child span
+func (c *Client) startCallSpan(ctx context.Context, call *apiCall) context.Context {
+	ctx, call.span = c.tracer().Start(ctx, "shopify "+call.operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("shopify.shop", c.ShopDomain),
+			attribute.String("shopify.api_version", c.apiVersion()),
+			attribute.String("shopify.operation", call.operation),
+			attribute.String("shopify.kind", call.kind),
+		))
+	return ctx
+}
+
+// startAttemptSpan starts the span of one HTTP attempt of call
+func (c *Client) startAttemptSpan(ctx context.Context, call *apiCall, method, path string, attempt int) (context.Context, trace.Span) {
+	return c.tracer().Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("server.address", c.ShopDomain),
+			attribute.String("url.path", path),
+			attribute.String("shopify.operation", call.operation),
+			attribute.Int("shopify.attempt", attempt),
+		))
+}
+
+// endAttempt ends an attempt span and counts the attempt
+func (c *Client) endAttempt(span trace.Span, call *apiCall, resp *http.Response, err error) {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusBadRequest && err == nil {
+			err = &HTTPStatusError{StatusCode: status}
+		}
+	}
+	c.metrics().attempts.WithLabelValues(call.operation, c.ShopDomain, strconv.Itoa(status)).Inc()
+	endSpan(span, err)
+}
+
+// observeThrottleWait records time spent waiting for the rate limiter before an attempt
+func (c *Client) observeThrottleWait(ctx context.Context, call *apiCall, waited time.Duration) {
+	c.metrics().throttleWait.WithLabelValues(call.operation, c.ShopDomain).Observe(waited.Seconds())
+	if waited > 0 {
+		trace.SpanFromContext(ctx).AddEvent("throttle wait",
+			trace.WithAttributes(attribute.Int64("shopify.wait_ms", waited.Milliseconds())))
+	}
+}
+
+// observeRetry records a retried attempt
+func (c *Client) observeRetry(ctx context.Context, attempt RetryAttempt, delay time.Duration) {
+	reason := retryReason(attempt.Err)
+	c.metrics().retries.WithLabelValues(attempt.Operation, c.ShopDomain, reason).Inc()
+	trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+		attribute.Int("shopify.attempt", attempt.Attempt),
+		attribute.String("shopify.retry_reason", reason),
+		attribute.Int64("shopify.wait_ms", delay.Milliseconds()),
+	))
+}
+
+// retryReason classifies why an attempt failed for the retries metric
+func retryReason(err error) string {
+	var statusErr *HTTPStatusError
+	var gqlErrs *GraphQLErrors
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		return "throttled"
+	case errors.As(err, &statusErr):
+		return "server_error"
+	case errors.As(err, &gqlErrs):
+		for _, gqlErr := range gqlErrs.Errors {
+			if gqlErr.Extensions.Code == ErrorCodeThrottled {
+				return "throttled"
+			}
+		}
+		return "graphql_error"
+	}
+	return "network"
+}
+
+// observeMetrics records a finished call in the metrics and ends its span
+func (c *Client) observeMetrics(call *apiCall, duration time.Duration, userErrs *UserErrors) {
+	m := c.metrics()
+	outcome := "ok"
+	switch {
+	case call.err != nil:
+		outcome = "error"
+	case userErrs != nil:
+		outcome = "user_error"
+		m.userErrors.WithLabelValues(call.operation, c.ShopDomain).Add(float64(len(userErrs.Errors)))
+	}
+	m.requests.WithLabelValues(call.operation, c.ShopDomain, call.kind, outcome).Inc()
+	m.duration.WithLabelValues(call.operation, c.ShopDomain).Observe(duration.Seconds())
+	if call.cost != nil {
+		m.cost.WithLabelValues(call.operation, c.ShopDomain).Add(call.cost.ActualQueryCost)
+	}
+
+	if call.span == nil {
+		return
+	}
+	call.span.SetAttributes(
+		attribute.Int("shopify.attempts", call.attempts),
+		attribute.String("shopify.outcome", outcome),
+	)
+	if call.status != 0 {
+		call.span.SetAttributes(attribute.Int("http.response.status_code", call.status))
+	}
+	if call.cost != nil {
+		call.span.SetAttributes(
+			attribute.Float64("shopify.cost.requested", call.cost.RequestedQueryCost),
+			attribute.Float64("shopify.cost.actual", call.cost.ActualQueryCost),
+		)
+	}
+	if userErrs != nil {
+		call.span.AddEvent("userErrors", trace.WithAttributes(attribute.String("shopify.user_errors", userErrs.Error())))
+	}
+	endSpan(call.span, call.err)
+}
+
+var (
+	envTelemetryOnce  sync.Once
+	envTracerProvider *sdktrace.TracerProvider
+)
+
+// ServeMetrics serves MetricsHandler at /metrics on addr (e.g. ":9464") in the
+// background, for a collector to scrape while the process runs. An empty addr
+// (SHOPIFY_METRICS_ADDR unset) does nothing. Clients only record metrics; the
+// cmd tools call ServeMetrics to expose them.
+func ServeMetrics(addr string) error {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			DefaultLogger().Warn("metrics server stopped", "addr", addr, "error", err)
+		}
+	}()
+	return nil
+}
+
+// envTelemetryOption returns WithTracerProvider for an OTLP/HTTP exporter when
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set (the
+// standard OTEL_* variables configure it), or nil. Spans are batched and exported
+// in the background, so a slow collector does not hold up API calls; call
+// ShutdownTelemetry before exiting to send the last batch.
+func envTelemetryOption() ClientOption {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+	envTelemetryOnce.Do(func() {
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			DefaultLogger().Warn("spans are not exported", "error", err)
+			return
+		}
+		serviceName := os.Getenv("OTEL_SERVICE_NAME")
+		if serviceName == "" {
+			serviceName = DefaultUserAgent
+		}
+		res, err := resource.Merge(resource.Default(),
+			resource.NewSchemaless(attribute.String("service.name", serviceName)))
+		if err != nil {
+			res = resource.Default()
+		}
+		envTracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+		)
+	})
+	if envTracerProvider == nil {
+		return nil
+	}
+	return WithTracerProvider(envTracerProvider)
+}
+
+// ShutdownTelemetry exports the spans still batched by the tracer provider set up
+// from the OTEL_* variables and stops it; spans ended afterwards are dropped. It
+// does nothing when no exporter is configured. The cmd tools defer it in main.
+func ShutdownTelemetry(ctx context.Context) error {
+	// Waits for a setup in progress; clients created afterwards do not export spans
+	envTelemetryOnce.Do(func() {})
+	if envTracerProvider == nil {
+		return nil
+	}
+	if err := envTracerProvider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("cannot export spans: %w", err)
+	}
+	return nil
+}
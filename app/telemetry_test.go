@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestServeMetrics(t *testing.T) {
+	if err := ServeMetrics(""); err != nil {
+		t.Fatalf("ServeMetrics(\"\") = %v, want nil", err)
+	}
+
+	addr := freeAddr(t)
+	if err := ServeMetrics(addr); err != nil {
+		t.Fatalf("ServeMetrics: %v", err)
+	}
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "go_goroutines") {
+		t.Errorf("GET /metrics = %d without the default collectors", resp.StatusCode)
+	}
+
+	if err := ServeMetrics(addr); err == nil {
+		t.Error("ServeMetrics on a busy address succeeded, want an error")
+	}
+}
+
+func TestNewClientFromEnvDoesNotServeMetrics(t *testing.T) {
+	addr := freeAddr(t)
+	t.Setenv("SHOPIFY_METRICS_ADDR", addr)
+	NewClientFromEnv()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("client construction listens on %s: %v", addr, err)
+	}
+	listener.Close()
+}
+
+func TestShutdownTelemetryExportsBatchedSpans(t *testing.T) {
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			exports.Add(1)
+		}
+	}))
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", collector.URL+"/v1/traces")
+
+	client := NewClientFromEnv()
+	_, span := client.startSpan(context.Background(), "TestOperation")
+	span.End()
+
+	if err := ShutdownTelemetry(context.Background()); err != nil {
+		t.Fatalf("ShutdownTelemetry: %v", err)
+	}
+	if exports.Load() == 0 {
+		t.Error("the batched span was not exported on shutdown")
+	}
+}
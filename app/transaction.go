@@ -0,0 +1,361 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Transaction kinds (OrderTransactionKind)
+const (
+	TransactionKindSale          = "SALE"
+	TransactionKindAuthorization = "AUTHORIZATION"
+	TransactionKindCapture       = "CAPTURE"
+	TransactionKindVoid          = "VOID"
+	TransactionKindRefund        = "REFUND"
+)
+
+// TransactionInput is a payment taken outside Shopify, recorded by CreateTransaction
+type TransactionInput struct {
+	// One of the TransactionKind constants. Empty means TransactionKindSale.
+	Kind string
+	// Gateway the payment was taken with, e.g. "cash". Empty means "manual".
+	Gateway string
+	Amount  Money
+	// Empty means the currency of Amount, or else the shop currency
+	Currency string
+	// The authorization captured or voided, or the payment refunded
+	ParentID string
+	// When the payment was taken. Nil means now.
+	ProcessedAt *time.Time
+}
+
+// PaymentData is an entry of the payments of a ConnectPOS order payload.
+// Split tenders are separate entries.
+type PaymentData struct {
+	PaymentCode string     `json:"paymentCode"`
+	PaymentName string     `json:"paymentName"`
+	Amount      Money      `json:"amount"`
+	Currency    string     `json:"currency"`
+	ProcessedAt *time.Time `json:"processedAt"`
+	// "sale" or "authorization"; empty means "sale"
+	Type string `json:"type"`
+}
+
+// restTransaction is a transaction as returned by the REST transactions endpoint
+type restTransaction struct {
+	ID          int64     `json:"id"`
+	GraphQLID   string    `json:"admin_graphql_api_id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Gateway     string    `json:"gateway"`
+	Test        bool      `json:"test"`
+	ParentID    *int64    `json:"parent_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+func (t restTransaction) transaction() OrderTransaction {
+	transaction := OrderTransaction{
+		ID:          t.GraphQLID,
+		Kind:        strings.ToUpper(t.Kind),
+		Status:      strings.ToUpper(t.Status),
+		Gateway:     t.Gateway,
+		Test:        t.Test,
+		CreatedAt:   t.CreatedAt,
+		ProcessedAt: t.ProcessedAt,
+		AmountSet:   MoneyBag{ShopMoney: MoneyV2{Amount: t.Amount.WithCurrency(t.Currency), CurrencyCode: t.Currency}},
+	}
+	if transaction.ID == "" {
+		transaction.ID = resourceGID("OrderTransaction", strconv.FormatInt(t.ID, 10))
+	}
+	if t.ParentID != nil {
+		transaction.ParentTransaction = &struct {
+			ID string `json:"id"`
+		}{ID: resourceGID("OrderTransaction", strconv.FormatInt(*t.ParentID, 10))}
+	}
+	return transaction
+}
+
+// ListTransactions returns the payment transactions of an order, oldest first
+func (c *Client) ListTransactions(ctx context.Context, orderID string) (_ []OrderTransaction, err error) {
+	const query = `
+		query ListTransactions($id: ID!) {
+			order(id: $id) {
+				transactions(first: 100) { ` + orderTransactionFields + ` }
+			}
+		}`
+
+	orderID = OrderGID(orderID)
+	ctx, span := c.startSpan(ctx, "ListTransactions", attribute.String("shopify.order_id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	var order struct {
+		Transactions []OrderTransaction `json:"transactions"`
+	}
+	if err := c.queryPath(ctx, query, map[string]interface{}{"id": orderID}, []string{"order"}, &order); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+		}
+		return nil, err
+	}
+	if order.Transactions == nil {
+		order.Transactions = []OrderTransaction{}
+	}
+	return order.Transactions, nil
+}
+
+// MarkOrderAsPaid records a manual sale of the order's outstanding amount with
+// orderMarkAsPaid. An order with nothing outstanding is reported as *UserErrors.
+func (c *Client) MarkOrderAsPaid(ctx context.Context, orderID string) (err error) {
+	const mutation = `
+		mutation OrderMarkAsPaid($input: OrderMarkAsPaidInput!) {
+			orderMarkAsPaid(input: $input) {
+				order {
+					id
+					displayFinancialStatus
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	orderID = OrderGID(orderID)
+	ctx, span := c.startSpan(ctx, "MarkOrderAsPaid", attribute.String("shopify.order_id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	data, err := Do[struct {
+		OrderMarkAsPaid struct {
+			Order *struct {
+				ID                     string `json:"id"`
+				DisplayFinancialStatus string `json:"displayFinancialStatus"`
+			} `json:"order"`
+		} `json:"orderMarkAsPaid"`
+	}](ctx, c, mutation, map[string]interface{}{"input": map[string]interface{}{"id": orderID}})
+	if err != nil {
+		return err
+	}
+	if data.OrderMarkAsPaid.Order == nil {
+		return fmt.Errorf("orderMarkAsPaid returned no order for %s", orderID)
+	}
+	span.SetAttributes(attribute.String("shopify.financial_status", data.OrderMarkAsPaid.Order.DisplayFinancialStatus))
+	return nil
+}
+
+// CreateTransaction records a transaction on an order through the REST
+// transactions endpoint, marked as an external payment. GraphQL has no
+// equivalent for sales taken outside Shopify, such as POS tenders.
+func (c *Client) CreateTransaction(ctx context.Context, orderID string, input TransactionInput) (_ *OrderTransaction, err error) {
+	orderID = OrderGID(orderID)
+	kind := input.Kind
+	if kind == "" {
+		kind = TransactionKindSale
+	}
+	gateway := input.Gateway
+	if gateway == "" {
+		gateway = "manual"
+	}
+
+	ctx, span := c.startSpan(ctx, "CreateTransaction",
+		attribute.String("shopify.order_id", orderID),
+		attribute.String("shopify.transaction_kind", kind),
+		attribute.String("shopify.gateway", gateway))
+	defer func() { endSpan(span, err) }()
+
+	transaction := map[string]interface{}{
+		"kind":    strings.ToLower(kind),
+		"status":  "success",
+		"gateway": gateway,
+		"amount":  input.Amount,
+		"source":  "external", // Required for API-created orders to accept "sale" kind
+	}
+	if currency := input.Currency; currency != "" {
+		transaction["currency"] = currency
+	} else if currency := input.Amount.Currency(); currency != "" {
+		transaction["currency"] = currency
+	}
+	if input.ParentID != "" {
+		transaction["parent_id"] = strings.TrimPrefix(input.ParentID, "gid://shopify/OrderTransaction/")
+	}
+	if input.ProcessedAt != nil {
+		transaction["processed_at"] = input.ProcessedAt.UTC().Format(time.RFC3339)
+	}
+
+	orderNum := strings.TrimPrefix(orderID, "gid://shopify/Order/")
+	status, bodyBytes, err := c.doREST(ctx, http.MethodPost, fmt.Sprintf("orders/%s/transactions.json", orderNum),
+		map[string]interface{}{"transaction": transaction})
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: status, Body: string(bodyBytes)}
+	}
+
+	var result struct {
+		Transaction restTransaction `json:"transaction"`
+	}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction response: %w", err)
+	}
+	created := result.Transaction.transaction()
+	span.SetAttributes(attribute.String("shopify.transaction_id", created.ID))
+	return &created, nil
+}
+
+// RecordPayments records ConnectPOS payments on an order, one transaction per
+// payment with the payment code as gateway, so split tenders stay separate.
+// Every payment is checked before any is recorded; if recording fails part way,
+// the transactions already created are returned with the error.
+func (c *Client) RecordPayments(ctx context.Context, orderID string, payments []PaymentData) ([]OrderTransaction, error) {
+	inputs := make([]TransactionInput, 0, len(payments))
+	for i, payment := range payments {
+		if payment.PaymentCode == "" {
+			return nil, fmt.Errorf("payment %d has no paymentCode", i)
+		}
+		if !payment.Amount.IsPositive() {
+			return nil, fmt.Errorf("payment %d (%s) has invalid amount %q", i, payment.PaymentCode, payment.Amount)
+		}
+		kind := strings.ToUpper(payment.Type)
+		switch kind {
+		case "":
+			kind = TransactionKindSale
+		case TransactionKindSale, TransactionKindAuthorization:
+		default:
+			return nil, fmt.Errorf("payment %d (%s) has type %q; only sale and authorization can be recorded", i, payment.PaymentCode, payment.Type)
+		}
+		inputs = append(inputs, TransactionInput{
+			Kind:        kind,
+			Gateway:     payment.PaymentCode,
+			Amount:      payment.Amount,
+			Currency:    payment.Currency,
+			ProcessedAt: payment.ProcessedAt,
+		})
+	}
+
+	transactions := []OrderTransaction{}
+	for i, input := range inputs {
+		transaction, err := c.CreateTransaction(ctx, orderID, input)
+		if err != nil {
+			return transactions, fmt.Errorf("failed to record payment %d (%s %s): %w", i, input.Gateway, input.Amount, err)
+		}
+		transactions = append(transactions, *transaction)
+	}
+	return transactions, nil
+}
+
+// CaptureTransaction captures an authorization with orderCapture. A zero
+// amount captures what is left of the authorization.
+func (c *Client) CaptureTransaction(ctx context.Context, orderID, authorizationID string, amount Money) (_ *OrderTransaction, err error) {
+	const mutation = `
+		mutation OrderCapture($input: OrderCaptureInput!) {
+			orderCapture(input: $input) {
+				transaction { ` + orderTransactionFields + ` }
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	orderID = OrderGID(orderID)
+	authorizationID = resourceGID("OrderTransaction", authorizationID)
+	ctx, span := c.startSpan(ctx, "CaptureTransaction",
+		attribute.String("shopify.order_id", orderID),
+		attribute.String("shopify.transaction_id", authorizationID))
+	defer func() { endSpan(span, err) }()
+
+	if amount.IsZero() {
+		if amount, err = c.capturableAmount(ctx, orderID, authorizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := Do[struct {
+		OrderCapture struct {
+			Transaction *OrderTransaction `json:"transaction"`
+		} `json:"orderCapture"`
+	}](ctx, c, mutation, map[string]interface{}{"input": map[string]interface{}{
+		"id":                  orderID,
+		"parentTransactionId": authorizationID,
+		"amount":              amount,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if data.OrderCapture.Transaction == nil {
+		return nil, fmt.Errorf("orderCapture returned no transaction for %s", authorizationID)
+	}
+	return data.OrderCapture.Transaction, nil
+}
+
+// capturableAmount returns what is left to capture of an authorization
+func (c *Client) capturableAmount(ctx context.Context, orderID, authorizationID string) (Money, error) {
+	transactions, err := c.ListTransactions(ctx, orderID)
+	if err != nil {
+		return Money{}, err
+	}
+	var authorized, captured Money
+	found := false
+	for _, t := range transactions {
+		amount := t.AmountSet.ShopMoney.Amount
+		switch {
+		case t.ID == authorizationID:
+			found = true
+			authorized = amount
+		case t.ParentTransaction != nil && t.ParentTransaction.ID == authorizationID &&
+			t.Kind == TransactionKindCapture && t.Status == "SUCCESS":
+			if err := captured.CheckCurrency(amount); err != nil {
+				return Money{}, fmt.Errorf("captures of %s: %w", authorizationID, err)
+			}
+			captured = captured.Add(amount)
+		}
+	}
+	if !found {
+		return Money{}, fmt.Errorf("transaction %s of %s: %w", authorizationID, orderID, ErrNotFound)
+	}
+	if err := authorized.CheckCurrency(captured); err != nil {
+		return Money{}, fmt.Errorf("captures of %s: %w", authorizationID, err)
+	}
+	return authorized.Sub(captured), nil
+}
+
+// VoidTransaction voids an uncaptured authorization with transactionVoid
+func (c *Client) VoidTransaction(ctx context.Context, authorizationID string) (_ *OrderTransaction, err error) {
+	const mutation = `
+		mutation TransactionVoid($parentTransactionId: ID!) {
+			transactionVoid(parentTransactionId: $parentTransactionId) {
+				transaction { ` + orderTransactionFields + ` }
+				userErrors {
+					field
+					message
+				}
+			}
+		}`
+
+	authorizationID = resourceGID("OrderTransaction", authorizationID)
+	ctx, span := c.startSpan(ctx, "VoidTransaction", attribute.String("shopify.transaction_id", authorizationID))
+	defer func() { endSpan(span, err) }()
+
+	data, err := Do[struct {
+		TransactionVoid struct {
+			Transaction *OrderTransaction `json:"transaction"`
+		} `json:"transactionVoid"`
+	}](ctx, c, mutation, map[string]interface{}{"parentTransactionId": authorizationID})
+	if err != nil {
+		return nil, err
+	}
+	if data.TransactionVoid.Transaction == nil {
+		return nil, fmt.Errorf("transactionVoid returned no transaction for %s", authorizationID)
+	}
+	return data.TransactionVoid.Transaction, nil
+}
@@ -1,24 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
-	"time"
+
+	"shopify-demo/app"
 )
 
 func main() {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		log.Fatal("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set in environment variables")
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
 	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	// Ctrl+C cancels in-flight Shopify calls and retry waits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	inputPath := "cmd/CreateOrderWithPickUpMethod/input.json"
 	if len(os.Args) > 1 {
@@ -30,6 +34,18 @@ func main() {
 		log.Fatalf("failed to load input: %v", err)
 	}
 
+	// Resolve the shop from the POS storeId (SHOPIFY_SHOPS_FILE),
+	// falling back to SHOPIFY_SHOP_DOMAIN/SHOPIFY_API_SECRET when no registry is configured
+	storeID := coerceString(rawInput["storeId"])
+	client, err := app.ClientForStore(storeID)
+	if err != nil {
+		log.Fatalf("failed to resolve shop for store %q: %v", storeID, err)
+	}
+
+	if client.ShopDomain == "" || client.AccessToken == "" {
+		log.Fatal("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set in environment variables")
+	}
+
 	// Verify input has order object
 	if _, ok := rawInput["order"].(map[string]interface{}); !ok {
 		log.Fatalf("input.json must contain an \"order\" object")
@@ -42,35 +58,20 @@ func main() {
 		log.Fatalf("failed to build Shopify order payload: %v", err)
 	}
 
-	bodyBytes, err := json.Marshal(map[string]interface{}{
+	status, bodyBytes, err := client.CallAdminREST(ctx, http.MethodPost, "orders.json", map[string]interface{}{
 		"order": shopifyOrder,
 	})
-	if err != nil {
-		log.Fatalf("failed to marshal order payload: %v", err)
-	}
-
-	url := fmt.Sprintf("https://%s/admin/api/2025-10/orders.json", shopDomain)
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(string(bodyBytes)))
-	if err != nil {
-		log.Fatalf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
 		log.Fatalf("failed to execute request: %v", err)
 	}
-	defer resp.Body.Close()
 
 	var respData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+	if err := json.Unmarshal(bodyBytes, &respData); err != nil {
 		log.Fatalf("failed to parse response: %v", err)
 	}
 
-	if resp.StatusCode >= 400 {
-		log.Fatalf("Shopify returned %d: %+v", resp.StatusCode, respData)
+	if status >= 400 {
+		log.Fatalf("Shopify returned %d: %+v", status, respData)
 	}
 
 	// Print minimal order info for quick verification.
@@ -94,11 +95,13 @@ func main() {
 
 // fetchFirstLocationID calls Shopify REST API to get locations and returns the first ID.
 // NOTE: This is a convenience fallback; prefer setting SHOPIFY_PICKUP_LOCATION_ID explicitly.
-func fetchFirstLocationID(shopDomain, accessToken string) (string, error) {
-	cmd := exec.Command("sh", "-c", fmt.Sprintf("curl -s -H \"X-Shopify-Access-Token: %s\" https://%s/admin/api/2025-10/locations.json", accessToken, shopDomain))
-	out, err := cmd.Output()
+func fetchFirstLocationID(ctx context.Context, client *app.Client) (string, error) {
+	status, out, err := client.CallAdminREST(ctx, http.MethodGet, "locations.json", nil)
 	if err != nil {
-		return "", fmt.Errorf("curl locations failed: %w", err)
+		return "", fmt.Errorf("get locations failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("get locations failed with status %d: %s", status, string(out))
 	}
 	var resp struct {
 		Locations []struct {
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"shopify-demo/app"
 )
@@ -15,6 +17,11 @@ import (
 // (you can change the payload inside buildProductInput if needed)
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	fmt.Println("=== Creating demo product with custom fields ===")
 
 	input := buildProductInput()
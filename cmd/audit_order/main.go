@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"shopify-demo/app"
+)
+
+// Shows exactly what was sent to Shopify for an order, from the audit journal
+// written when SHOPIFY_AUDIT_FILE is set:
+//
+//	go run ./cmd/audit_order "#2291" [audit.jsonl]
+//	go run ./cmd/audit_order gid://shopify/Order/5512345678901
+//
+// The trail includes the draft order the order was completed from, edits,
+// tax updates and transactions, oldest first, with each mutation's variables
+// and Shopify's response. Add -brief after the order to omit the bodies.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: %s <order name, ID or GID> [audit journal] [-brief]", os.Args[0])
+	}
+	ref := os.Args[1]
+	path := os.Getenv("SHOPIFY_AUDIT_FILE")
+	brief := false
+	for _, arg := range os.Args[2:] {
+		if arg == "-brief" {
+			brief = true
+		} else {
+			path = arg
+		}
+	}
+	if path == "" {
+		log.Fatal("No audit journal. Set SHOPIFY_AUDIT_FILE when running the tools, or pass the journal path.")
+	}
+
+	entries, err := app.ReadAuditJournal(path)
+	if err != nil {
+		log.Fatalf("Failed to read audit journal: %v", err)
+	}
+
+	trail := app.AuditTrail(entries, ref)
+	if len(trail) == 0 {
+		fmt.Printf("No mutations for %s in %s (%d entries)\n", ref, path, len(entries))
+		os.Exit(1)
+	}
+
+	fmt.Printf("=== %d mutation(s) for %s ===\n", len(trail), ref)
+	for i, entry := range trail {
+		status := "✓"
+		switch {
+		case entry.Error != "":
+			status = "✗"
+		case len(entry.UserErrors) > 0:
+			status = "⚠️ "
+		}
+		fmt.Printf("\n%d. %s %s %s [%s, API %s]\n", i+1, status, entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Operation, entry.Shop, entry.APIVersion)
+		if entry.Kind == "rest" {
+			fmt.Printf("   %s %s -> %d\n", entry.Method, entry.Path, entry.Status)
+		}
+		if entry.IdempotencyKey != "" {
+			fmt.Printf("   Idempotency key: %s\n", entry.IdempotencyKey)
+		}
+		fmt.Printf("   Attempts: %d, %d ms\n", entry.Attempts, entry.DurationMS)
+		for _, userErr := range entry.UserErrors {
+			fmt.Printf("   User error: %v: %s\n", userErr.Field, userErr.Message)
+		}
+		if entry.Error != "" {
+			fmt.Printf("   Error: %s\n", entry.Error)
+		}
+		if brief {
+			continue
+		}
+		if len(entry.Request) > 0 && string(entry.Request) != "null" {
+			fmt.Printf("   Sent:\n%s\n", indent(entry.Request))
+		}
+		if len(entry.Response) > 0 {
+			fmt.Printf("   Response:\n%s\n", indent(entry.Response))
+		}
+	}
+}
+
+func indent(data json.RawMessage) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "      ", "  "); err != nil {
+		return "      " + string(data)
+	}
+	return "      " + out.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log"
+	"os"
+	"os/signal"
+
+	"shopify-demo/app"
+)
+
+// Exports every order, customer or variant with a bulk operation and prints
+// one JSON record per line, e.g.:
+//
+//	go run ./cmd/bulk_export orders > orders.jsonl
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	resource := "orders"
+	if len(os.Args) > 1 {
+		resource = os.Args[1]
+	}
+
+	// Ctrl+C stops waiting for the bulk operation
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := app.DefaultClient()
+	opts := app.BulkWaitOptions{}
+
+	var count int
+	var err error
+	switch resource {
+	case "orders":
+		count, err = writeRecords(app.BulkRecords[app.BulkOrder](ctx, client, app.BulkOrdersQuery, opts))
+	case "customers":
+		count, err = writeRecords(app.BulkRecords[app.BulkCustomer](ctx, client, app.BulkCustomersQuery, opts))
+	case "variants":
+		count, err = writeRecords(app.BulkRecords[app.BulkVariant](ctx, client, app.BulkVariantsQuery, opts))
+	default:
+		log.Fatalf("unknown resource %q (use orders, customers or variants)", resource)
+	}
+	if err != nil {
+		log.Fatalf("Bulk export failed after %d record(s): %v", count, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Exported %d %s\n", count, resource)
+}
+
+// writeRecords prints each record as one JSON line on stdout
+func writeRecords[T any](records iter.Seq2[T, error]) (int, error) {
+	encoder := json.NewEncoder(os.Stdout)
+	count := 0
+	for record, err := range records {
+		if err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
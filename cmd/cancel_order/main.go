@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"shopify-demo/app"
+)
+
+// Cancels an order, e.g. a POS void:
+//
+//	go run ./cmd/cancel_order -reason CUSTOMER -restock -refund original -note "Voided at till 2" 5512345678901
+//
+// -refund is none (keep the payment), original (refund to the payment methods used)
+// or store-credit. Uncaptured authorizations are voided in every case.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	reason := flag.String("reason", app.CancelReasonOther, "CUSTOMER, DECLINED, FRAUD, INVENTORY, STAFF or OTHER")
+	restock := flag.Bool("restock", false, "return the unfulfilled items to inventory")
+	refund := flag.String("refund", "none", "none, original or store-credit")
+	notify := flag.Bool("notify", false, "email the customer")
+	note := flag.String("note", "", "staff note")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("Usage: %s [flags] <order ID or GID>", os.Args[0])
+	}
+
+	opts := app.CancelOptions{
+		Reason:         strings.ToUpper(*reason),
+		Restock:        *restock,
+		NotifyCustomer: *notify,
+		StaffNote:      *note,
+	}
+	switch *refund {
+	case "none":
+		opts.Refund = app.RefundNone
+	case "original":
+		opts.Refund = app.RefundOriginalPayment
+	case "store-credit":
+		opts.Refund = app.RefundStoreCredit
+	default:
+		log.Fatalf("Unknown -refund %q: use none, original or store-credit", *refund)
+	}
+
+	// Ctrl+C stops waiting for the cancellation job
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := app.DefaultClient().CancelOrder(ctx, flag.Arg(0), opts)
+	if err != nil {
+		log.Fatalf("Failed to cancel order: %v", err)
+	}
+
+	fmt.Printf("✓ Order %s (%s) cancelled at %s\n", result.OrderName, result.OrderID, result.CancelledAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Reason: %s\n", result.CancelReason)
+	fmt.Printf("  Financial status: %s\n", result.FinancialStatus)
+	fmt.Printf("  Total refunded: %s %s\n", result.TotalRefundedSet.ShopMoney.Amount, result.TotalRefundedSet.ShopMoney.CurrencyCode)
+	for _, refund := range result.Refunds {
+		fmt.Printf("\n  Refund %s\n", refund.ID)
+		for _, transaction := range refund.Transactions {
+			fmt.Printf("    %s %s via %s: %s %s\n", transaction.Kind, transaction.Status, transaction.Gateway,
+				transaction.AmountSet.ShopMoney.Amount, transaction.AmountSet.ShopMoney.CurrencyCode)
+		}
+		for _, item := range refund.RefundLineItems {
+			fmt.Printf("    %d x %s (%s)\n", item.Quantity, item.LineItemID, item.RestockType)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"shopify-demo/app"
 )
 
 func main() {
-	fmt.Println("=== Checking API Access Scopes ===\n")
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	fmt.Print("=== Checking API Access Scopes ===\n\n")
 
 	const query = `
 		query {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Kiểm tra environment variables
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -18,7 +24,7 @@ func main() {
 		log.Fatal("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set in environment variables")
 	}
 
-	fmt.Println("=== Checking Shopify App Configuration ===\n")
+	fmt.Print("=== Checking Shopify App Configuration ===\n\n")
 	fmt.Printf("Shop Domain: %s\n", shopDomain)
 	fmt.Printf("Access Token: %s...%s\n\n", accessToken[:10], accessToken[len(accessToken)-10:])
 
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"shopify-demo/app"
+)
+
+// Reports the operations we call that are deprecated in the configured API version
+// (SHOPIFY_API_VERSION, or app.DefaultAPIVersion), so upgrades can be planned:
+//
+//	go run ./cmd/check_deprecations [deprecation-log.json]
+//
+// Two sources are combined:
+//   - the deprecation log written by the other tools when SHOPIFY_DEPRECATION_FILE is set
+//     (every response with X-Shopify-API-Deprecated-Reason is recorded there)
+//   - Shopify's own report of deprecated calls made by the app in the past 30 days
+//
+// To check an upcoming version, run the usual tools with SHOPIFY_API_VERSION set to it
+// first, then run this report with the same setting.
+// The exit status is 1 when any deprecated operation is found.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	logPath := os.Getenv("SHOPIFY_DEPRECATION_FILE")
+	if len(os.Args) > 1 {
+		logPath = os.Args[1]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := app.DefaultClient()
+	version := client.APIVersion
+	fmt.Printf("=== Deprecated operations in API version %s ===\n", version)
+
+	found := 0
+
+	// 1. Calls recorded from X-Shopify-API-Deprecated-Reason
+	fmt.Println("\n--- 1. Recorded by our tools ---")
+	if logPath == "" {
+		fmt.Println("No deprecation log. Set SHOPIFY_DEPRECATION_FILE when running the tools to record deprecated calls.")
+	} else {
+		deprecations, err := app.OpenDeprecationLog(logPath)
+		if err != nil {
+			log.Fatalf("Failed to open deprecation log: %v", err)
+		}
+
+		var otherVersions []app.DeprecatedCall
+		for _, call := range deprecations.Calls() {
+			if call.APIVersion != version {
+				otherVersions = append(otherVersions, call)
+				continue
+			}
+			found++
+			fmt.Printf("⚠️  %s\n", call.Operation)
+			fmt.Printf("   Reason: %s\n", call.Reason)
+			fmt.Printf("   Calls: %d (last seen %s)\n", call.Count, call.LastSeen.Format("2006-01-02 15:04"))
+		}
+		if found == 0 {
+			fmt.Printf("✓ No deprecated calls recorded for %s in %s\n", version, logPath)
+		}
+
+		if len(otherVersions) > 0 {
+			fmt.Println("\nRecorded for other API versions:")
+			for _, call := range otherVersions {
+				fmt.Printf("  [%s] %s: %s\n", call.APIVersion, call.Operation, call.Reason)
+			}
+		}
+	}
+
+	// 2. Shopify's report for the app
+	fmt.Println("\n--- 2. Reported by Shopify (past 30 days) ---")
+	calls, err := client.DeprecatedAPICalls(ctx)
+	var statusErr *app.HTTPStatusError
+	switch {
+	case errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusForbidden):
+		fmt.Printf("Note: Shopify's report is not available for this app (status %d)\n", statusErr.StatusCode)
+	case err != nil:
+		fmt.Printf("Note: Cannot get Shopify's report: %v\n", err)
+	case len(calls) == 0:
+		fmt.Println("✓ Shopify reports no deprecated calls")
+	default:
+		for _, call := range calls {
+			found++
+			fmt.Printf("⚠️  [%s %s] %s\n", call.APIType, call.Version, call.Endpoint)
+			fmt.Printf("   %s\n", call.Description)
+			if call.MigrationDeadline != "" {
+				fmt.Printf("   Migration deadline: %s\n", call.MigrationDeadline)
+			}
+			if call.DocumentationURL != "" {
+				fmt.Printf("   Docs: %s\n", call.DocumentationURL)
+			}
+			fmt.Printf("   Last call: %s\n", call.LastCallAt)
+		}
+	}
+
+	if found > 0 {
+		fmt.Printf("\n%d deprecated operation(s) found\n", found)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +13,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	if len(os.Args) < 2 {
 		log.Fatal("Usage: go run cmd/check_fulfillments/main.go <order_id>")
 	}
@@ -37,7 +43,7 @@ func checkFulfillmentOrdersREST(orderID string) {
 		log.Fatal("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
 	}
 
-	apiVersion := "2025-10"
+	apiVersion := app.ConfiguredAPIVersion()
 
 	// Extract numeric ID from GID if needed
 	numericOrderID := orderID
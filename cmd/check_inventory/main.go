@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	if len(os.Args) < 2 {
 		log.Fatal("Usage: go run cmd/check_inventory/main.go <variant_id>")
 	}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"shopify-demo/app"
 )
 
 func main() {
-	fmt.Println("=== Checking Shopify Locations ===\n")
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	fmt.Print("=== Checking Shopify Locations ===\n\n")
 
 	const query = `
 		query {
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/shopspring/decimal"
+
+	"shopify-demo/app"
+	"shopify-demo/app/tax"
+)
+
+// Calculates the tax of a ConnectPOS order payload from its tax rates and
+// reports where the POS, and Shopify's order if given, differ by more than a cent:
+//
+//	go run ./cmd/check_tax -order 5725042999448 cmd/create_order/input.json
+//
+// -rounding is line (round each line's tax, like Shopify) or invoice.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	orderID := flag.String("order", "", "Shopify order ID or GID to compare with")
+	rounding := flag.String("rounding", "line", "line or invoice")
+	taxShipping := flag.Bool("tax-shipping", false, "charge the tax rates on shipping")
+	flag.Parse()
+	inputPath := "cmd/create_order/input.json"
+	if flag.NArg() > 0 {
+		inputPath = flag.Arg(0)
+	}
+
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		log.Fatalf("Failed to load input data: %v", err)
+	}
+	var input struct {
+		Order posOrder `json:"order"`
+	}
+	if err := json.Unmarshal(content, &input); err != nil {
+		log.Fatalf("Failed to load input data: invalid JSON: %v", err)
+	}
+
+	inv, pos, err := input.Order.invoice()
+	if err != nil {
+		log.Fatalf("Invalid input data: %v", err)
+	}
+	inv.TaxShipping = *taxShipping
+	switch *rounding {
+	case "line":
+		inv.Rounding = tax.RoundPerLine
+	case "invoice":
+		inv.Rounding = tax.RoundPerInvoice
+	default:
+		log.Fatalf("Unknown -rounding %q: use line or invoice", *rounding)
+	}
+
+	var order *app.Order
+	if *orderID != "" {
+		order, err = app.GetOrder(*orderID, app.WithOrderSections(app.OrderSectionLineItems))
+		if err != nil {
+			log.Fatalf("Failed to get order: %v", err)
+		}
+	}
+
+	report, err := tax.Check(inv, pos, order)
+	if err != nil {
+		log.Fatalf("Failed to calculate tax: %v", err)
+	}
+
+	result := report.Result
+	fmt.Printf("Calculated: subtotal %s, tax %s, total %s\n",
+		result.Subtotal.StringFixed(), result.TotalTax.StringFixed(), result.Total.StringFixed())
+	for _, tl := range result.TaxLines {
+		fmt.Printf("  %s (%s%%): %s\n", tl.Title, tl.Rate.Shift(2), tl.Amount.StringFixed())
+	}
+	for i, line := range result.Lines {
+		fmt.Printf("  line %d %s: net %s, tax %s\n", i, line.ID, line.Net.StringFixed(), line.Tax.StringFixed())
+	}
+	if !result.Shipping.Gross.IsZero() {
+		fmt.Printf("  shipping: net %s, tax %s\n", result.Shipping.Net.StringFixed(), result.Shipping.Tax.StringFixed())
+	}
+
+	if report.OK() {
+		fmt.Println("✓ Tax matches")
+		return
+	}
+	for _, d := range append(report.POS, report.Shopify...) {
+		fmt.Printf("✗ %s\n", d)
+	}
+	os.Exit(1)
+}
+
+type posOrder struct {
+	TaxLines      []posTaxLine `json:"taxLines"`
+	TaxesIncluded bool         `json:"taxesIncluded"`
+	TotalTax      string       `json:"totalTax"`
+	Items         []posItem    `json:"items"`
+	SubtotalPrice string       `json:"subtotalPrice"`
+	TotalPrice    string       `json:"totalPrice"`
+	TotalShipping string       `json:"totalShipping"`
+}
+
+// posTaxLine is a tax of the POS order. Its taxClassId is not read: the items
+// do not say which tax class they are in, so every rate applies to every
+// taxable item.
+type posTaxLine struct {
+	Rate  string `json:"rate"`
+	Price string `json:"price"`
+	Title string `json:"title"`
+}
+
+type posItem struct {
+	ProductID     string `json:"productId"`
+	Quantity      int    `json:"quantity"`
+	Price         string `json:"price"`
+	TotalTax      string `json:"totalTax"`
+	Taxable       bool   `json:"taxable"`
+	TotalDiscount string `json:"totalDiscount"`
+}
+
+// invoice returns the invoice to calculate for the POS order and the totals
+// the POS calculated for it
+func (o posOrder) invoice() (tax.Invoice, tax.Totals, error) {
+	var p amountParser
+	inv := tax.Invoice{TaxesIncluded: o.TaxesIncluded, Shipping: p.parse("totalShipping", o.TotalShipping)}
+	pos := tax.Totals{
+		Subtotal: p.parse("subtotalPrice", o.SubtotalPrice),
+		TotalTax: p.parse("totalTax", o.TotalTax),
+		Total:    p.parse("totalPrice", o.TotalPrice),
+		TaxLines: []tax.Amount{},
+	}
+	for i, tl := range o.TaxLines {
+		rate, err := decimal.NewFromString(tl.Rate)
+		if err != nil {
+			return tax.Invoice{}, tax.Totals{}, fmt.Errorf("taxLines[%d].rate: invalid rate %q", i, tl.Rate)
+		}
+		inv.Rates = append(inv.Rates, tax.Rate{Title: tl.Title, Rate: rate})
+		pos.TaxLines = append(pos.TaxLines, tax.Amount{Title: tl.Title, Rate: rate,
+			Amount: p.parse(fmt.Sprintf("taxLines[%d].price", i), tl.Price)})
+	}
+	for i, item := range o.Items {
+		field := fmt.Sprintf("items[%d].", i)
+		inv.Lines = append(inv.Lines, tax.Line{
+			ID:       item.ProductID,
+			Price:    p.parse(field+"price", item.Price).Round(app.RoundHalfUp),
+			Quantity: item.Quantity,
+			Discount: p.parse(field+"totalDiscount", item.TotalDiscount).Round(app.RoundHalfUp),
+			Taxable:  item.Taxable,
+		})
+		pos.LineTaxes = append(pos.LineTaxes, p.parse(field+"totalTax", item.TotalTax).Round(app.RoundHalfUp))
+	}
+	if p.err != nil {
+		return tax.Invoice{}, tax.Totals{}, p.err
+	}
+	return inv, pos, nil
+}
+
+// amountParser parses POS amounts and keeps the first error
+type amountParser struct {
+	err error
+}
+
+// parse parses the amount of a field. Only "" (or null) is 0; anything else
+// that is not a number is an error.
+func (p *amountParser) parse(field, s string) app.Money {
+	if s == "" || p.err != nil {
+		return app.Money{}
+	}
+	amount, err := app.ParseMoney(s, "")
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", field, err)
+	}
+	return amount
+}
@@ -1,20 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"strconv"
 	"strings"
 
+	"github.com/shopspring/decimal"
+
 	"shopify-demo/app"
 )
 
 // InputData represents the structure of input.json
 type InputData struct {
-	Order OrderData `json:"order"`
+	// POS store the order belongs to, resolved with the shop registry
+	StoreID string    `json:"storeId"`
+	Order   OrderData `json:"order"`
 }
 
 type DiscountApplicationData struct {
@@ -41,6 +44,8 @@ type AdditionalData struct {
 }
 
 type OrderData struct {
+	CartID              *string                  `json:"cartId"`
+	HashID              string                   `json:"hashId"`
 	TaxLines            []TaxLineData            `json:"taxLines"`
 	TaxesIncluded       bool                     `json:"taxesIncluded"`
 	TotalTax            string                   `json:"totalTax"`
@@ -59,6 +64,8 @@ type OrderData struct {
 	DiscountCodes       []string                 `json:"discountCodes,omitempty"`
 	SubtotalPrice       string                   `json:"subtotalPrice,omitempty"`
 	TotalPrice          string                   `json:"totalPrice,omitempty"`
+	// Currency of the amounts above, e.g. "USD"
+	Currency            string                   `json:"currency"`
 }
 
 type TaxLineData struct {
@@ -96,6 +103,11 @@ type AddressData struct {
 }
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Kiểm tra environment variables
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -117,6 +129,39 @@ func main() {
 	// Convert input.json data to OrderInput for CreateOrderGraphQL
 	orderInput := buildOrderInputForGraphQL(inputData)
 
+	// A cashier retrying after a timeout resends the same cartId/hashId;
+	// return the order created the first time instead of a duplicate
+	cartID := ""
+	if inputData.Order.CartID != nil {
+		cartID = *inputData.Order.CartID
+	}
+	if key := app.IdempotencyKeyFor(cartID, inputData.Order.HashID); key != "" {
+		client, err := app.ClientForStore(inputData.StoreID)
+		if err != nil {
+			log.Fatalf("Failed to resolve shop for store %q: %v", inputData.StoreID, err)
+		}
+		if path := os.Getenv("SHOPIFY_IDEMPOTENCY_FILE"); path != "" {
+			store, err := app.OpenFileIdempotencyStore(path)
+			if err != nil {
+				log.Fatalf("Failed to open idempotency store: %v", err)
+			}
+			client = client.With(app.WithIdempotencyStore(store))
+		}
+
+		orderInfo, err := client.CreateOrderGraphQLIdempotent(context.Background(), key, orderInput)
+		if err != nil {
+			log.Fatalf("Failed to create order: %v", err)
+		}
+		if orderInfo.Replayed {
+			fmt.Printf("✓ Order already created for key %s (not created again)\n", key)
+		} else {
+			fmt.Println("✓ Order created successfully!")
+		}
+		fmt.Printf("Order ID: %s\n", orderInfo.OrderID)
+		fmt.Printf("Order Name: %s\n", orderInfo.OrderName)
+		return
+	}
+
 	// Create order using GraphQL orderCreate
 	response, err := app.CreateOrderGraphQL(orderInput)
 	if err != nil {
@@ -133,23 +178,15 @@ func main() {
 	fmt.Printf("Total Price: %s %s\n",
 		order.TotalPriceSet.ShopMoney.Amount,
 		order.TotalPriceSet.ShopMoney.CurrencyCode)
-	if order.TotalTaxSet.ShopMoney.Amount != "" {
+	if !order.TotalTaxSet.ShopMoney.Amount.IsZero() {
 		fmt.Printf("Total Tax: %s %s\n",
 			order.TotalTaxSet.ShopMoney.Amount,
 			order.TotalTaxSet.ShopMoney.CurrencyCode)
 	}
 	if len(order.TaxLines) > 0 {
 		for i, tl := range order.TaxLines {
-			rateStr := ""
-			if rateFloat, ok := tl.Rate.(float64); ok {
-				rateStr = fmt.Sprintf("%.3f", rateFloat)
-			} else if rateStrVal, ok := tl.Rate.(string); ok {
-				rateStr = rateStrVal
-			} else {
-				rateStr = fmt.Sprintf("%v", tl.Rate)
-			}
 			fmt.Printf("Tax Line %d: %s (rate: %s, amount: %s)\n",
-				i+1, tl.Title, rateStr, tl.PriceSet.ShopMoney.Amount)
+				i+1, tl.Title, tl.Rate, tl.PriceSet.ShopMoney.Amount)
 		}
 	}
 }
@@ -164,6 +201,9 @@ func loadInputData(path string) (*InputData, error) {
 	if err := json.Unmarshal(content, &data); err != nil {
 		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
+	if data.Order.Currency == "" {
+		return nil, fmt.Errorf("order.currency is missing")
+	}
 
 	return &data, nil
 }
@@ -210,6 +250,7 @@ func parseTags(tags string) []string {
 // with proper data from input.json instead of hardcoded values
 // If tax lines exist, we try to add them to line items in draft order
 func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error) {
+	currency := inputData.Order.Currency
 	draftInput := app.DraftOrderInput{
 		Email: inputData.Order.Email,
 		Note:  inputData.Order.Note,
@@ -220,7 +261,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	if len(inputData.Order.DiscountApplications) > 0 {
 		// Use first discount application for order-level discount
 		discount := inputData.Order.DiscountApplications[0]
-		value, _ := strconv.ParseFloat(discount.Value, 64)
+		value, _ := decimal.NewFromString(discount.Value)
 		draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 			Title:       discount.Title,
 			Description: discount.Title,
@@ -228,24 +269,25 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 		if discount.ValueType == "percentage" {
 			draftInput.AppliedDiscount.ValueType = "PERCENTAGE"
 			// Round to 2 decimal places
-			draftInput.AppliedDiscount.Value = math.Round(value*100) / 100
+			draftInput.AppliedDiscount.Value = value.Round(2)
 		} else {
 			draftInput.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-			if amount, ok := parsePrice(discount.Amount); ok {
+			if amount, ok := parsePrice(discount.Amount, currency); ok {
 				// Round to 2 decimal places
-				draftInput.AppliedDiscount.Value = math.Round(amount*100) / 100
+				draftInput.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
 			}
 		}
 	} else if inputData.Order.TotalDiscounts != "" {
 		// If no discount applications but totalDiscounts exists, calculate percentage
 		// This is a fallback - ideally discountApplications should be provided
-		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts); ok {
-			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice); ok && subtotal > 0 {
-				percentage := (totalDiscount / subtotal) * 100
+		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts, currency); ok {
+			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice, currency); ok && subtotal.IsPositive() {
+				percentage := totalDiscount.Decimal().Div(subtotal.Decimal()).Shift(2)
 				// Round to 2 decimal places
+				value := percentage.Round(2)
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "PERCENTAGE",
-					Value:       math.Round(percentage*100) / 100,
+					Value:       value,
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
@@ -253,7 +295,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 				// Use fixed amount
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "FIXED_AMOUNT",
-					Value:       math.Round(totalDiscount*100) / 100,
+					Value:       totalDiscount.Round(app.RoundHalfUp).Decimal(),
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
@@ -274,10 +316,10 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 
 		// Set originalUnitPrice if available (use price or originPrice)
 		// This allows custom pricing for the line item
-		if price, ok := parsePrice(item.Price); ok {
+		if price, ok := parsePrice(item.Price, currency); ok {
 			lineItem.OriginalUnitPrice = price
 		} else if item.OriginPrice != "" {
-			if originPrice, ok := parsePrice(item.OriginPrice); ok {
+			if originPrice, ok := parsePrice(item.OriginPrice, currency); ok {
 				lineItem.OriginalUnitPrice = originPrice
 			}
 		}
@@ -285,7 +327,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 		// Add discount from input.json for this line item
 		if len(item.DiscountApplications) > 0 {
 			discount := item.DiscountApplications[0]
-			value, _ := strconv.ParseFloat(discount.Value, 64)
+			value, _ := decimal.NewFromString(discount.Value)
 			lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 				Title:       discount.Title,
 				Description: discount.Title,
@@ -293,19 +335,19 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 			if discount.ValueType == "percentage" {
 				lineItem.AppliedDiscount.ValueType = "PERCENTAGE"
 				// Round to 2 decimal places
-				lineItem.AppliedDiscount.Value = math.Round(value*100) / 100
+				lineItem.AppliedDiscount.Value = value.Round(2)
 			} else {
 				lineItem.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-				if amount, ok := parsePrice(discount.Amount); ok {
+				if amount, ok := parsePrice(discount.Amount, currency); ok {
 					// Round to 2 decimal places
-					lineItem.AppliedDiscount.Value = math.Round(amount*100) / 100
+					lineItem.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
 				}
 			}
 		} else if item.TotalDiscount != "" {
 			// Fallback: use totalDiscount if discountApplications not available
-			if totalDiscount, ok := parsePrice(item.TotalDiscount); ok {
-				if price, ok := parsePrice(item.Price); ok && price > 0 {
-					percentage := (totalDiscount / price) * 100
+			if totalDiscount, ok := parsePrice(item.TotalDiscount, currency); ok {
+				if price, ok := parsePrice(item.Price, currency); ok && price.IsPositive() {
+					percentage := totalDiscount.Decimal().Div(price.Decimal()).Shift(2)
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "PERCENTAGE",
 						Value:       percentage,
@@ -315,7 +357,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	} else {
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "FIXED_AMOUNT",
-						Value:       totalDiscount,
+						Value:       totalDiscount.Decimal(),
 						Title:       "Item Discount",
 						Description: fmt.Sprintf("Discount: %s", item.TotalDiscount),
 					}
@@ -367,20 +409,27 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	return draftInput, nil
 }
 
-// parsePrice parses a price string to float64
-func parsePrice(priceStr string) (float64, bool) {
+// parsePrice parses a price string to app.Money in the order's currency
+func parsePrice(priceStr, currency string) (app.Money, bool) {
 	if priceStr == "" {
-		return 0, false
+		return app.Money{}, false
 	}
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := app.ParseMoney(priceStr, currency)
 	if err != nil {
-		return 0, false
+		return app.Money{}, false
 	}
 	return price, true
 }
 
+// parseRate parses a tax rate string such as "0.065", "" being 0
+func parseRate(rateStr string) decimal.Decimal {
+	rate, _ := decimal.NewFromString(rateStr)
+	return rate
+}
+
 // buildOrderInputFromInput converts input.json OrderData to OrderInput for CreateOrderWithTax
 func buildOrderInputFromInput(inputData *InputData) app.OrderInput {
+	currency := inputData.Order.Currency
 	orderInput := app.OrderInput{
 		Email:           inputData.Order.Email,
 		Note:            inputData.Order.Note,
@@ -396,9 +445,9 @@ func buildOrderInputFromInput(inputData *InputData) app.OrderInput {
 		}
 		
 		// Keep original price (don't apply discount to price)
-		// Instead, use discount_allocations to show discount separately
-		originalPrice, _ := parsePrice(item.Price)
-		lineItem.Price = fmt.Sprintf("%.2f", originalPrice)
+		// Instead, show the discount separately as a line item property
+		originalPrice, _ := parsePrice(item.Price, currency)
+		lineItem.Price = originalPrice
 		
 		// Add discount allocations if discount exists
 		if len(item.DiscountApplications) > 0 {
@@ -408,27 +457,28 @@ func buildOrderInputFromInput(inputData *InputData) app.OrderInput {
 			// If amount is not provided, calculate it
 			if discountAmount == "" {
 				if discount.ValueType == "percentage" {
-					value, _ := strconv.ParseFloat(discount.Value, 64)
-					discountAmount = fmt.Sprintf("%.2f", originalPrice*value/100)
+					discountAmount = originalPrice.Percent(parseRate(discount.Value)).StringFixed()
 				} else if discount.ValueType == "fixed_amount" {
 					discountAmount = discount.Value
 				}
 			}
 			
+			// orderCreate line items don't support discount allocations,
+			// so record the discount as a line item property instead
 			if discountAmount != "" {
-				lineItem.DiscountAllocations = []app.DiscountAllocationInput{
+				lineItem.Properties = []app.LineItemPropertyInput{
 					{
-						Amount: discountAmount,
-						Title:  discount.Title,
+						Name:  discount.Title,
+						Value: discountAmount,
 					},
 				}
 			}
 		} else if item.TotalDiscount != "" {
 			// Fallback: use totalDiscount
-			lineItem.DiscountAllocations = []app.DiscountAllocationInput{
+			lineItem.Properties = []app.LineItemPropertyInput{
 				{
-					Amount: item.TotalDiscount,
-					Title:  "Item Discount",
+					Name:  "Item Discount",
+					Value: item.TotalDiscount,
 				},
 			}
 		}
@@ -454,13 +504,14 @@ func buildOrderInputFromInput(inputData *InputData) app.OrderInput {
 	if len(inputData.Order.TaxLines) > 0 {
 		taxLines := make([]app.OrderCreateTaxLineInput, len(inputData.Order.TaxLines))
 		for i, tl := range inputData.Order.TaxLines {
+			price, _ := parsePrice(tl.Price, currency)
 			taxLines[i] = app.OrderCreateTaxLineInput{
 				Title: tl.Title,
-				Rate:  tl.Rate, // Keep as string for OrderCreateTaxLineInput
+				Rate:  parseRate(tl.Rate),
 				PriceSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       tl.Price,
-						CurrencyCode: "USD", // Default to USD, can be made dynamic
+						Amount:       price,
+						CurrencyCode: currency,
 					},
 				},
 			}
@@ -477,6 +528,7 @@ func buildOrderInputFromInput(inputData *InputData) app.OrderInput {
 // - Order-level discount: via discountCode field
 // - Line-item discount: calculate discounted price and set in priceSet, add note in properties
 func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
+	currency := inputData.Order.Currency
 	orderInput := app.OrderInput{
 		Email:           inputData.Order.Email,
 		Note:            inputData.Order.Note,
@@ -504,13 +556,14 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 		}
 
 		// Get original price
-		originalPrice, _ := parsePrice(item.Price)
-		if originalPrice == 0 && item.OriginPrice != "" {
-			originalPrice, _ = parsePrice(item.OriginPrice)
+		originalPrice, _ := parsePrice(item.Price, currency)
+		if originalPrice.IsZero() && item.OriginPrice != "" {
+			originalPrice, _ = parsePrice(item.OriginPrice, currency)
 		}
 
 		// Calculate discounted price if discount exists
-		// Apply discounts sequentially: each discount uses the already-discounted price
+		// Apply discounts sequentially: each discount uses the already-discounted price,
+		// unrounded, and the discounted price is rounded to cents once at the end
 		discountedPrice := originalPrice
 		var discountNotes []string
 		
@@ -523,25 +576,24 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 					continue // Skip this discount application
 				}
 				
-				var discountAmount float64
+				var discountAmount app.Money
 				if discount.ValueType == "percentage" {
-					value, _ := strconv.ParseFloat(discount.Value, 64)
 					// Calculate discount from current price (sequential)
-					discountAmount = currentPrice * value / 100
+					discountAmount = currentPrice.Percent(parseRate(discount.Value))
 					// Professional format: "• X% off (Title)"
 					discountNotes = append(discountNotes, fmt.Sprintf("• %s%% off (%s)", discount.Value, discount.Title))
 				} else if discount.ValueType == "fixed_amount" {
-					discountAmount, _ = parsePrice(discount.Amount)
+					discountAmount, _ = parsePrice(discount.Amount, currency)
 					// Professional format: "• $X.XX (Title)"
 					discountNotes = append(discountNotes, fmt.Sprintf("• $%s (%s)", discount.Amount, discount.Title))
 				}
 				// Apply discount to current price
-				currentPrice = currentPrice - discountAmount
+				currentPrice = currentPrice.Sub(discountAmount)
 			}
-			discountedPrice = currentPrice
+			discountedPrice = currentPrice.Round(app.RoundHalfUp)
 		} else if item.TotalDiscount != "" {
-			discountAmount, _ := parsePrice(item.TotalDiscount)
-			discountedPrice = originalPrice - discountAmount
+			discountAmount, _ := parsePrice(item.TotalDiscount, currency)
+			discountedPrice = originalPrice.Sub(discountAmount)
 			discountNotes = append(discountNotes, fmt.Sprintf("$%s", item.TotalDiscount))
 		}
 		
@@ -552,7 +604,7 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 		lineItemDiscountValue := ""
 		if len(discountNotes) > 0 {
 			// Use ONLY U+0336 per character (most compatible in Shopify Admin UI)
-			originalPriceStr := fmt.Sprintf("$%.2f", originalPrice)
+			originalPriceStr := "$" + originalPrice.StringFixed()
 			originalPriceValue = applyUnicodeStrikethrough(originalPriceStr)
 
 			// Format: each discount on its own line for easier reading in Shopify Admin UI
@@ -562,8 +614,8 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 		// Set priceSet with discounted price (as recommended by Shopify)
 		lineItem.PriceSet = &app.MoneyBagInput{
 			ShopMoney: &app.MoneyInput{
-				Amount:       fmt.Sprintf("%.2f", discountedPrice),
-				CurrencyCode: "USD",
+				Amount:       discountedPrice,
+				CurrencyCode: currency,
 			},
 		}
 
@@ -606,13 +658,14 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 	if len(inputData.Order.TaxLines) > 0 {
 		taxLines := make([]app.OrderCreateTaxLineInput, len(inputData.Order.TaxLines))
 		for i, tl := range inputData.Order.TaxLines {
+			price, _ := parsePrice(tl.Price, currency)
 			taxLines[i] = app.OrderCreateTaxLineInput{
 				Title: tl.Title,
-				Rate:  tl.Rate, // Keep as string for OrderCreateTaxLineInput
+				Rate:  parseRate(tl.Rate),
 				PriceSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       tl.Price,
-						CurrencyCode: "USD",
+						Amount:       price,
+						CurrencyCode: currency,
 					},
 				},
 			}
@@ -624,7 +677,7 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 	if len(inputData.Order.DiscountApplications) > 0 {
 		discount := inputData.Order.DiscountApplications[0]
 		if discount.ValueType == "percentage" {
-			value, _ := strconv.ParseFloat(discount.Value, 64)
+			value, _ := decimal.NewFromString(discount.Value)
 			orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 				ItemPercentageDiscountCode: &app.ItemPercentageDiscountCodeInput{
 					Code:       discount.Title,
@@ -632,14 +685,14 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 				},
 			}
 		} else if discount.ValueType == "fixed_amount" {
-			amount, _ := parsePrice(discount.Amount)
+			amount, _ := parsePrice(discount.Amount, currency)
 			orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 				ItemFixedDiscountCode: &app.ItemFixedDiscountCodeInput{
 					Code: discount.Title,
 					AmountSet: &app.MoneyBagInput{
 						ShopMoney: &app.MoneyInput{
-							Amount:       fmt.Sprintf("%.2f", amount),
-							CurrencyCode: "USD",
+							Amount:       amount,
+							CurrencyCode: currency,
 						},
 					},
 				},
@@ -647,14 +700,14 @@ func buildOrderInputForGraphQL(inputData *InputData) app.OrderInput {
 		}
 	} else if inputData.Order.TotalDiscounts != "" {
 		// Fallback: use totalDiscounts
-		totalDiscount, _ := parsePrice(inputData.Order.TotalDiscounts)
+		totalDiscount, _ := parsePrice(inputData.Order.TotalDiscounts, currency)
 		orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 			ItemFixedDiscountCode: &app.ItemFixedDiscountCodeInput{
 				Code: "Order Discount",
 				AmountSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       fmt.Sprintf("%.2f", totalDiscount),
-						CurrencyCode: "USD",
+						Amount:       totalDiscount,
+						CurrencyCode: currency,
 					},
 				},
 			},
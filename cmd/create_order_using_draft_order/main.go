@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
 
+	"github.com/shopspring/decimal"
+
 	"shopify-demo/app"
 )
 
@@ -59,6 +61,8 @@ type OrderData struct {
 	DiscountCodes       []string                 `json:"discountCodes,omitempty"`
 	SubtotalPrice       string                   `json:"subtotalPrice,omitempty"`
 	TotalPrice          string                   `json:"totalPrice,omitempty"`
+	// Currency of the amounts in the order, e.g. "USD"
+	Currency            string                   `json:"currency"`
 	ShippingMethod      string                   `json:"shippingMethod,omitempty"`
 	TotalShipping       string                   `json:"totalShipping,omitempty"`
 	TotalShippingIncTax string                   `json:"totalShippingIncTax,omitempty"`
@@ -101,13 +105,10 @@ type AddressData struct {
 }
 
 func main() {
-	// Kiểm tra environment variables
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		log.Fatal("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set in environment variables")
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
 	}
+	defer app.ShutdownTelemetry(context.Background())
 
 	inputPath := "cmd/create_order_using_draft_order/input.json"
 	if len(os.Args) > 1 {
@@ -125,13 +126,19 @@ func main() {
 		log.Fatalf("Failed to build draft order input: %v", err)
 	}
 
+	// The client reports a missing SHOPIFY_SHOP_DOMAIN or SHOPIFY_API_SECRET on its first call
+	client := app.DefaultClient()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Step 1: Create draft order
-	draftResp, err := app.CreateDraftOrder(draftInput)
+	draftResp, err := client.CreateDraftOrder(ctx, draftInput)
 	if err != nil {
 		log.Fatalf("Failed to create draft order: %v", err)
 	}
 
 	draftID := draftResp.Data.DraftOrderCreate.DraftOrder.ID
+	currency := inputData.Order.Currency
 
 	// Step 3: Convert tax lines from input.json if available
 	// Separate product tax lines from shipping tax
@@ -144,14 +151,15 @@ func main() {
 			// Skip shipping tax lines (they will be added separately)
 			// Shipping tax is identified by checking if it's related to shipping
 			// For now, we'll add all tax lines as product tax, and shipping tax separately
-			rate, _ := strconv.ParseFloat(tl.Rate, 64)
+			rate, _ := decimal.NewFromString(tl.Rate)
+			price, _ := parsePrice(tl.Price, currency)
 			productTaxLines = append(productTaxLines, app.TaxLineInput{
 				Title: tl.Title,
 				Rate:  rate,
 				PriceSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       tl.Price,
-						CurrencyCode: "USD",
+						Amount:       price,
+						CurrencyCode: currency,
 					},
 				},
 			})
@@ -160,21 +168,21 @@ func main() {
 
 	// Calculate shipping tax line if shipping tax data is available
 	if inputData.Order.TotalTaxShipping != "" {
-		if shippingTaxAmount, ok := parsePrice(inputData.Order.TotalTaxShipping); ok && shippingTaxAmount > 0 {
+		if shippingTaxAmount, ok := parsePrice(inputData.Order.TotalTaxShipping, currency); ok && shippingTaxAmount.IsPositive() {
 			// Calculate shipping tax rate from totalTaxShipping and totalShippingExTax
-			shippingTaxRate := 0.0
+			shippingTaxRate := decimal.Zero
 			if inputData.Order.TotalShippingExTax != "" {
-				if shippingExTax, ok := parsePrice(inputData.Order.TotalShippingExTax); ok && shippingExTax > 0 {
-					shippingTaxRate = shippingTaxAmount / shippingExTax
+				if shippingExTax, ok := parsePrice(inputData.Order.TotalShippingExTax, currency); ok && shippingExTax.IsPositive() {
+					shippingTaxRate = shippingTaxAmount.Decimal().Div(shippingExTax.Decimal()).Round(4)
 				}
 			}
 			
 			// If rate is 0, try to get from tax lines (look for shipping-related tax)
-			if shippingTaxRate == 0 && len(inputData.Order.TaxLines) > 0 {
+			if shippingTaxRate.IsZero() && len(inputData.Order.TaxLines) > 0 {
 				// Use the rate from the last tax line (often shipping tax is last)
 				// Or use a common shipping tax rate like 0.065 (6.5%)
 				for _, tl := range inputData.Order.TaxLines {
-					if rate, err := strconv.ParseFloat(tl.Rate, 64); err == nil {
+					if rate, err := decimal.NewFromString(tl.Rate); err == nil {
 						shippingTaxRate = rate
 						break
 					}
@@ -186,34 +194,34 @@ func main() {
 				Rate:  shippingTaxRate,
 				PriceSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       fmt.Sprintf("%.2f", shippingTaxAmount),
-						CurrencyCode: "USD",
+						Amount:       shippingTaxAmount,
+						CurrencyCode: currency,
 					},
 				},
 				Source: "external", // Mark as external source
 			}
-			fmt.Printf("Debug: Shipping tax calculated: %.2f (rate: %.4f)\n", shippingTaxAmount, shippingTaxRate)
+			fmt.Printf("Debug: Shipping tax calculated: %s (rate: %s)\n", shippingTaxAmount.StringFixed(), shippingTaxRate.StringFixed(4))
 		}
 	}
 
 	// Step 4: Ensure metafield definition exists (for shipping note)
 	shippingNote := getShippingNote(inputData.Order)
 	if shippingNote != "" {
-		if err := app.EnsureShippingNoteMetafieldDefinition(); err != nil {
+		if err := client.EnsureShippingNoteMetafieldDefinition(ctx); err != nil {
 			log.Printf("Warning: Could not ensure metafield definition: %v\n", err)
 		}
 	}
 
 	// Step 5: Complete draft order
 	paymentPending := false
-	orderInfo, err := app.CompleteDraftOrder(draftID, paymentPending)
+	orderInfo, err := client.CompleteDraftOrder(ctx, draftID, paymentPending)
 	if err != nil {
 		log.Fatalf("Failed to complete draft order: %v", err)
 	}
 
 	// Step 6: Add shipping note metafield to order (after completion)
 	if shippingNote != "" && orderInfo.OrderID != "" {
-		if err := addShippingNoteMetafield(orderInfo.OrderID, shippingNote); err != nil {
+		if err := addShippingNoteMetafield(ctx, client, orderInfo.OrderID, shippingNote); err != nil {
 			log.Printf("Warning: Failed to add shipping note metafield: %v\n", err)
 		} else {
 			log.Printf("✓ Successfully added shipping note metafield to order\n")
@@ -223,7 +231,7 @@ func main() {
 	// Step 6b: Add shipping tax info to order note if available
 	// This ensures shipping tax is visible in admin even if Shopify merges tax lines
 	if shippingTaxLine != nil && orderInfo.OrderID != "" {
-		if err := addShippingTaxToOrderNote(orderInfo.OrderID, shippingTaxLine); err != nil {
+		if err := addShippingTaxToOrderNote(ctx, client, orderInfo.OrderID, shippingTaxLine); err != nil {
 			log.Printf("Warning: Failed to add shipping tax to order note: %v\n", err)
 		}
 	}
@@ -238,14 +246,14 @@ func main() {
 	// Add shipping tax line if available
 	if shippingTaxLine != nil {
 		allTaxLines = append(allTaxLines, *shippingTaxLine)
-		fmt.Printf("Debug: Shipping tax will be added: %s (rate: %.4f)\n", 
+		fmt.Printf("Debug: Shipping tax will be added: %s (rate: %s)\n", 
 			shippingTaxLine.PriceSet.ShopMoney.Amount, 
-			shippingTaxLine.Rate)
+			shippingTaxLine.Rate.StringFixed(4))
 	}
 
 	// Add all tax lines to order (after completion)
 	if len(allTaxLines) > 0 && orderInfo.OrderID != "" {
-		if err := app.AddTaxToOrder(orderInfo.OrderID, allTaxLines); err != nil {
+		if err := client.AddTaxToOrder(ctx, orderInfo.OrderID, allTaxLines); err != nil {
 			log.Printf("Warning: Failed to add tax to order: %v\n", err)
 		} else {
 			if shippingTaxLine != nil {
@@ -260,156 +268,49 @@ func main() {
 
 	// Query order details to show tax information and metafields
 	if orderInfo.OrderID != "" {
-		queryOrderDetails(orderInfo.OrderID)
+		queryOrderDetails(ctx, client, orderInfo.OrderID)
 		if shippingNote != "" {
-			queryOrderMetafields(orderInfo.OrderID)
+			queryOrderMetafields(ctx, client, orderInfo.OrderID)
 		}
 	}
 }
 
-// queryOrderDetails queries the order to get detailed information including tax
-func queryOrderDetails(orderID string) {
-	const query = `
-		query GetOrder($id: ID!) {
-			order(id: $id) {
-				id
-				name
-				email
-				legacyResourceId
-				totalPriceSet {
-					shopMoney {
-						amount
-						currencyCode
-					}
-				}
-				totalTaxSet {
-					shopMoney {
-						amount
-						currencyCode
-					}
-				}
-				taxLines {
-					title
-					rate
-					priceSet {
-						shopMoney {
-							amount
-							currencyCode
-						}
-					}
-				}
-				shippingLine {
-					title
-					priceSet {
-						shopMoney {
-							amount
-							currencyCode
-						}
-					}
-					discountedPriceSet {
-						shopMoney {
-							amount
-							currencyCode
-						}
-					}
-				}
-			}
-		}`
-
-	variables := map[string]interface{}{
-		"id": orderID,
-	}
-
-	resp, err := app.CallAdminGraphQL(query, variables)
+// queryOrderDetails prints the order totals and shipping line
+func queryOrderDetails(ctx context.Context, client *app.Client, orderID string) {
+	order, err := client.GetOrder(ctx, orderID, app.WithOrderSections(app.OrderSectionShippingLines))
 	if err != nil {
 		return
 	}
 
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if order, ok := data["order"].(map[string]interface{}); ok {
-			if totalPrice, ok := order["totalPriceSet"].(map[string]interface{}); ok {
-				if shopMoney, ok := totalPrice["shopMoney"].(map[string]interface{}); ok {
-					if amount, ok := shopMoney["amount"].(string); ok {
-						fmt.Printf("Total Price: %s %s\n", amount, shopMoney["currencyCode"])
-					}
-				}
-			}
-			if totalTax, ok := order["totalTaxSet"].(map[string]interface{}); ok {
-				if shopMoney, ok := totalTax["shopMoney"].(map[string]interface{}); ok {
-					if amount, ok := shopMoney["amount"].(string); ok && amount != "" {
-						fmt.Printf("Total Tax: %s %s\n", amount, shopMoney["currencyCode"])
-					}
-				}
-			}
-			if shippingLine, ok := order["shippingLine"].(map[string]interface{}); ok {
-				if priceSet, ok := shippingLine["priceSet"].(map[string]interface{}); ok {
-					if shopMoney, ok := priceSet["shopMoney"].(map[string]interface{}); ok {
-						if amount, ok := shopMoney["amount"].(string); ok && amount != "" {
-							title := ""
-							if titleVal, ok := shippingLine["title"].(string); ok {
-								title = titleVal
-							}
-							fmt.Printf("Shipping Line: %s - %s %s\n", title, amount, shopMoney["currencyCode"])
-						}
-					}
-				}
-			}
+	fmt.Printf("Total Price: %s %s\n", order.TotalPriceSet.ShopMoney.Amount, order.TotalPriceSet.ShopMoney.CurrencyCode)
+	if tax := order.TotalTaxSet.ShopMoney; !tax.Amount.IsZero() {
+		fmt.Printf("Total Tax: %s %s\n", tax.Amount, tax.CurrencyCode)
+	}
+	if len(order.ShippingLines) > 0 {
+		shippingLine := order.ShippingLines[0]
+		if price := shippingLine.OriginalPriceSet.ShopMoney; !price.Amount.IsZero() {
+			fmt.Printf("Shipping Line: %s - %s %s\n", shippingLine.Title, price.Amount, price.CurrencyCode)
 		}
 	}
 }
 
-// queryOrderMetafields queries the order to get metafields information
-func queryOrderMetafields(orderID string) {
-	const query = `
-		query GetOrderMetafields($id: ID!) {
-			order(id: $id) {
-				id
-				name
-				metafields(first: 10, namespace: "connectpos") {
-					edges {
-						node {
-							id
-							namespace
-							key
-							value
-							type
-						}
-					}
-				}
-			}
-		}`
-
-	variables := map[string]interface{}{
-		"id": orderID,
-	}
-
-	resp, err := app.CallAdminGraphQL(query, variables)
+// queryOrderMetafields prints the connectpos metafields of the order
+func queryOrderMetafields(ctx context.Context, client *app.Client, orderID string) {
+	order, err := client.GetOrder(ctx, orderID,
+		app.WithOrderSections(app.OrderSectionMetafields),
+		app.WithOrderMetafieldNamespace("connectpos"))
 	if err != nil {
 		log.Printf("Warning: Failed to query order metafields: %v\n", err)
 		return
 	}
 
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if order, ok := data["order"].(map[string]interface{}); ok {
-			if metafields, ok := order["metafields"].(map[string]interface{}); ok {
-				if edges, ok := metafields["edges"].([]interface{}); ok {
-					if len(edges) > 0 {
-						fmt.Printf("\nOrder Metafields:\n")
-						for _, edge := range edges {
-							if edgeMap, ok := edge.(map[string]interface{}); ok {
-								if node, ok := edgeMap["node"].(map[string]interface{}); ok {
-									key := node["key"]
-									value := node["value"]
-									fmt.Printf("  - %s: %v\n", key, value)
-								}
-							}
-						}
-					} else {
-						fmt.Printf("\n⚠ No metafields found in order (namespace: connectpos)\n")
-					}
-				}
-			}
-		}
+	if len(order.Metafields) == 0 {
+		fmt.Printf("\n⚠ No metafields found in order (namespace: connectpos)\n")
+		return
+	}
+	fmt.Printf("\nOrder Metafields:\n")
+	for _, metafield := range order.Metafields {
+		fmt.Printf("  - %s: %v\n", metafield.Key, metafield.Value)
 	}
 }
 
@@ -423,6 +324,9 @@ func loadInputData(path string) (*InputData, error) {
 	if err := json.Unmarshal(content, &data); err != nil {
 		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
+	if data.Order.Currency == "" {
+		return nil, fmt.Errorf("order.currency is missing")
+	}
 
 	return &data, nil
 }
@@ -469,6 +373,7 @@ func parseTags(tags string) []string {
 // with proper data from input.json instead of hardcoded values
 // If tax lines exist, we try to add them to line items in draft order
 func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error) {
+	currency := inputData.Order.Currency
 	draftInput := app.DraftOrderInput{
 		Email: inputData.Order.Email,
 		Note:  inputData.Order.Note,
@@ -479,7 +384,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	if len(inputData.Order.DiscountApplications) > 0 {
 		// Use first discount application for order-level discount
 		discount := inputData.Order.DiscountApplications[0]
-		value, _ := strconv.ParseFloat(discount.Value, 64)
+		value, _ := decimal.NewFromString(discount.Value)
 		draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 			Title:       discount.Title,
 			Description: discount.Title,
@@ -487,24 +392,25 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 		if discount.ValueType == "percentage" {
 			draftInput.AppliedDiscount.ValueType = "PERCENTAGE"
 			// Round to 2 decimal places
-			draftInput.AppliedDiscount.Value = math.Round(value*100) / 100
+			draftInput.AppliedDiscount.Value = value.Round(2)
 		} else {
 			draftInput.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-			if amount, ok := parsePrice(discount.Amount); ok {
+			if amount, ok := parsePrice(discount.Amount, currency); ok {
 				// Round to 2 decimal places
-				draftInput.AppliedDiscount.Value = math.Round(amount*100) / 100
+				draftInput.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
 			}
 		}
 	} else if inputData.Order.TotalDiscounts != "" {
 		// If no discount applications but totalDiscounts exists, calculate percentage
 		// This is a fallback - ideally discountApplications should be provided
-		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts); ok {
-			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice); ok && subtotal > 0 {
-				percentage := (totalDiscount / subtotal) * 100
+		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts, currency); ok {
+			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice, currency); ok && subtotal.IsPositive() {
+				percentage := totalDiscount.Decimal().Div(subtotal.Decimal()).Shift(2)
 				// Round to 2 decimal places
+				value := percentage.Round(2)
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "PERCENTAGE",
-					Value:       math.Round(percentage*100) / 100,
+					Value:       value,
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
@@ -512,7 +418,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 				// Use fixed amount
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "FIXED_AMOUNT",
-					Value:       math.Round(totalDiscount*100) / 100,
+					Value:       totalDiscount.Round(app.RoundHalfUp).Decimal(),
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
@@ -533,10 +439,10 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 
 		// Set originalUnitPrice if available (use price or originPrice)
 		// This allows custom pricing for the line item
-		if price, ok := parsePrice(item.Price); ok {
+		if price, ok := parsePrice(item.Price, currency); ok {
 			lineItem.OriginalUnitPrice = price
 		} else if item.OriginPrice != "" {
-			if originPrice, ok := parsePrice(item.OriginPrice); ok {
+			if originPrice, ok := parsePrice(item.OriginPrice, currency); ok {
 				lineItem.OriginalUnitPrice = originPrice
 			}
 		}
@@ -544,7 +450,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 		// Add discount from input.json for this line item
 		if len(item.DiscountApplications) > 0 {
 			discount := item.DiscountApplications[0]
-			value, _ := strconv.ParseFloat(discount.Value, 64)
+			value, _ := decimal.NewFromString(discount.Value)
 			lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 				Title:       discount.Title,
 				Description: discount.Title,
@@ -552,19 +458,19 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 			if discount.ValueType == "percentage" {
 				lineItem.AppliedDiscount.ValueType = "PERCENTAGE"
 				// Round to 2 decimal places
-				lineItem.AppliedDiscount.Value = math.Round(value*100) / 100
+				lineItem.AppliedDiscount.Value = value.Round(2)
 			} else {
 				lineItem.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-				if amount, ok := parsePrice(discount.Amount); ok {
+				if amount, ok := parsePrice(discount.Amount, currency); ok {
 					// Round to 2 decimal places
-					lineItem.AppliedDiscount.Value = math.Round(amount*100) / 100
+					lineItem.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
 				}
 			}
 		} else if item.TotalDiscount != "" {
 			// Fallback: use totalDiscount if discountApplications not available
-			if totalDiscount, ok := parsePrice(item.TotalDiscount); ok {
-				if price, ok := parsePrice(item.Price); ok && price > 0 {
-					percentage := (totalDiscount / price) * 100
+			if totalDiscount, ok := parsePrice(item.TotalDiscount, currency); ok {
+				if price, ok := parsePrice(item.Price, currency); ok && price.IsPositive() {
+					percentage := totalDiscount.Decimal().Div(price.Decimal()).Shift(2)
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "PERCENTAGE",
 						Value:       percentage,
@@ -574,7 +480,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	} else {
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "FIXED_AMOUNT",
-						Value:       totalDiscount,
+						Value:       totalDiscount.Decimal(),
 						Title:       "Item Discount",
 						Description: fmt.Sprintf("Discount: %s", item.TotalDiscount),
 					}
@@ -601,43 +507,43 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	// Shipping tax will be calculated automatically by Shopify based on shipping address and tax settings
 	// To specify shipping tax explicitly, add it as a separate tax line after order completion
 	if inputData.Order.TotalShippingIncTax != "" {
-		if shippingPrice, ok := parsePrice(inputData.Order.TotalShippingIncTax); ok && shippingPrice > 0 {
+		if shippingPrice, ok := parsePrice(inputData.Order.TotalShippingIncTax, currency); ok && shippingPrice.IsPositive() {
 			shippingTitle := inputData.Order.ShippingMethod
 			if shippingTitle == "" {
 				shippingTitle = "Shipping"
 			}
 			draftInput.ShippingLine = &app.ShippingLineInput{
 				Title: shippingTitle,
-				Price: math.Round(shippingPrice*100) / 100,
+				Price: shippingPrice.Round(app.RoundHalfUp),
 			}
-			fmt.Printf("Debug: Setting shipping line with totalShippingIncTax: %s (title: %s, price: %.2f)\n",
-				inputData.Order.TotalShippingIncTax, shippingTitle, shippingPrice)
+			fmt.Printf("Debug: Setting shipping line with totalShippingIncTax: %s (title: %s, price: %s)\n",
+				inputData.Order.TotalShippingIncTax, shippingTitle, shippingPrice.StringFixed())
 		}
 	} else if inputData.Order.TotalShippingExTax != "" {
-		if shippingPrice, ok := parsePrice(inputData.Order.TotalShippingExTax); ok && shippingPrice > 0 {
+		if shippingPrice, ok := parsePrice(inputData.Order.TotalShippingExTax, currency); ok && shippingPrice.IsPositive() {
 			shippingTitle := inputData.Order.ShippingMethod
 			if shippingTitle == "" {
 				shippingTitle = "Shipping"
 			}
 			draftInput.ShippingLine = &app.ShippingLineInput{
 				Title: shippingTitle,
-				Price: math.Round(shippingPrice*100) / 100,
+				Price: shippingPrice.Round(app.RoundHalfUp),
 			}
-			fmt.Printf("Debug: Setting shipping line with totalShippingExTax: %s (title: %s, price: %.2f)\n",
-				inputData.Order.TotalShippingExTax, shippingTitle, shippingPrice)
+			fmt.Printf("Debug: Setting shipping line with totalShippingExTax: %s (title: %s, price: %s)\n",
+				inputData.Order.TotalShippingExTax, shippingTitle, shippingPrice.StringFixed())
 		}
 	} else if inputData.Order.TotalShipping != "" {
-		if shippingPrice, ok := parsePrice(inputData.Order.TotalShipping); ok && shippingPrice > 0 {
+		if shippingPrice, ok := parsePrice(inputData.Order.TotalShipping, currency); ok && shippingPrice.IsPositive() {
 			shippingTitle := inputData.Order.ShippingMethod
 			if shippingTitle == "" {
 				shippingTitle = "Shipping"
 			}
 			draftInput.ShippingLine = &app.ShippingLineInput{
 				Title: shippingTitle,
-				Price: math.Round(shippingPrice*100) / 100,
+				Price: shippingPrice.Round(app.RoundHalfUp),
 			}
-			fmt.Printf("Debug: Setting shipping line with totalShipping: %s (title: %s, price: %.2f)\n",
-				inputData.Order.TotalShipping, shippingTitle, shippingPrice)
+			fmt.Printf("Debug: Setting shipping line with totalShipping: %s (title: %s, price: %s)\n",
+				inputData.Order.TotalShipping, shippingTitle, shippingPrice.StringFixed())
 		}
 	}
 
@@ -676,14 +582,14 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	return draftInput, nil
 }
 
-// parsePrice parses a price string to float64
-func parsePrice(priceStr string) (float64, bool) {
+// parsePrice parses a price string to app.Money in the order's currency
+func parsePrice(priceStr, currency string) (app.Money, bool) {
 	if priceStr == "" {
-		return 0, false
+		return app.Money{}, false
 	}
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := app.ParseMoney(priceStr, currency)
 	if err != nil {
-		return 0, false
+		return app.Money{}, false
 	}
 	return price, true
 }
@@ -702,7 +608,7 @@ func getShippingNote(order OrderData) string {
 }
 
 // addShippingNoteMetafield adds shipping note metafield to an order using GraphQL
-func addShippingNoteMetafield(orderID string, shippingNote string) error {
+func addShippingNoteMetafield(ctx context.Context, client *app.Client, orderID string, shippingNote string) error {
 	const mutation = `
 		mutation SetMetafields($metafields: [MetafieldsSetInput!]!) {
 			metafieldsSet(metafields: $metafields) {
@@ -731,7 +637,7 @@ func addShippingNoteMetafield(orderID string, shippingNote string) error {
 		"metafields": []interface{}{metafieldInput},
 	}
 
-	resp, err := app.CallAdminGraphQL(mutation, variables)
+	resp, err := client.CallAdminGraphQL(ctx, mutation, variables)
 	if err != nil {
 		return fmt.Errorf("failed to call GraphQL: %w", err)
 	}
@@ -763,7 +669,7 @@ func addShippingNoteMetafield(orderID string, shippingNote string) error {
 
 // addShippingTaxToOrderNote adds shipping tax information to order note
 // This ensures shipping tax is visible in admin even if Shopify merges tax lines
-func addShippingTaxToOrderNote(orderID string, shippingTaxLine *app.TaxLineInput) error {
+func addShippingTaxToOrderNote(ctx context.Context, client *app.Client, orderID string, shippingTaxLine *app.TaxLineInput) error {
 	// First, get current order note
 	const getQuery = `
 		query GetOrder($id: ID!) {
@@ -777,7 +683,7 @@ func addShippingTaxToOrderNote(orderID string, shippingTaxLine *app.TaxLineInput
 		"id": orderID,
 	}
 
-	resp, err := app.CallAdminGraphQL(getQuery, variables)
+	resp, err := client.CallAdminGraphQL(ctx, getQuery, variables)
 	if err != nil {
 		return fmt.Errorf("failed to get order: %w", err)
 	}
@@ -792,10 +698,10 @@ func addShippingTaxToOrderNote(orderID string, shippingTaxLine *app.TaxLineInput
 	}
 
 	// Append shipping tax info to note
-	shippingTaxNote := fmt.Sprintf("\n\n--- Shipping Tax ---\n%s: %s (Rate: %.2f%%)",
+	shippingTaxNote := fmt.Sprintf("\n\n--- Shipping Tax ---\n%s: %s (Rate: %s%%)",
 		shippingTaxLine.Title,
-		shippingTaxLine.PriceSet.ShopMoney.Amount,
-		shippingTaxLine.Rate*100)
+		shippingTaxLine.PriceSet.ShopMoney.Amount.StringFixed(),
+		shippingTaxLine.Rate.Shift(2).StringFixed(2))
 
 	newNote := currentNote + shippingTaxNote
 
@@ -819,7 +725,7 @@ func addShippingTaxToOrderNote(orderID string, shippingTaxLine *app.TaxLineInput
 		"note": newNote,
 	}
 
-	updateResp, err := app.CallAdminGraphQL(updateMutation, updateVars)
+	updateResp, err := client.CallAdminGraphQL(ctx, updateMutation, updateVars)
 	if err != nil {
 		return fmt.Errorf("failed to update order note: %w", err)
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Kiểm tra environment variables
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -71,7 +77,7 @@ func main() {
 		}`
 
 	variables := map[string]interface{}{
-		"query": fmt.Sprintf("name:%s", orderName),
+		"query": app.NewOrderQuery().Name(orderName).String(),
 	}
 
 	resp, err := app.CallAdminGraphQL(query, variables)
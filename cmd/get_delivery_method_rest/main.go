@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"shopify-demo/app"
 )
 
 // RestOrder represents Shopify Order from REST API
@@ -43,6 +46,11 @@ type OrdersResponse struct {
 }
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Kiểm tra environment variables
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -58,7 +66,7 @@ func main() {
 
 	orderNumber := os.Args[1]
 
-	apiVersion := "2025-01"
+	apiVersion := app.ConfiguredAPIVersion()
 	url := fmt.Sprintf("https://%s/admin/api/%s/orders.json?name=%s&status=any", shopDomain, apiVersion, orderNumber)
 
 	req, err := http.NewRequest("GET", url, nil)
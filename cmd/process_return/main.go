@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"shopify-demo/app"
+)
+
+// Refunds a POS return read from a JSON file:
+//
+//	go run ./cmd/process_return cmd/process_return/return.json
+//
+// Without payments the money goes back as Shopify suggests; with createReturn
+// the refund is recorded against a Shopify return.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	inputPath := "cmd/process_return/return.json"
+	if len(os.Args) > 1 {
+		inputPath = os.Args[1]
+	}
+
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		log.Fatalf("Failed to load return data: %v", err)
+	}
+	var data app.ReturnData
+	if err := json.Unmarshal(content, &data); err != nil {
+		log.Fatalf("Failed to load return data: invalid JSON: %v", err)
+	}
+
+	result, err := app.ProcessReturn(data)
+	if err != nil {
+		if result != nil && result.Return != nil {
+			log.Printf("Return %s (%s) was created; refund it instead of processing the return again", result.Return.Name, result.Return.ID)
+		}
+		if result != nil && result.Refund.ID != "" {
+			log.Printf("Refund %s was made", result.Refund.ID)
+		}
+		log.Fatalf("Failed to process return: %v", err)
+	}
+
+	fmt.Printf("✓ Refunded %s (%s)\n", result.OrderName, result.OrderID)
+	if result.Return != nil {
+		fmt.Printf("  Return: %s (%s)\n", result.Return.Name, result.Return.ID)
+	}
+	suggested := result.Suggested
+	fmt.Printf("  Suggested: %s (subtotal %s, tax %s, shipping %s)\n",
+		suggested.AmountSet.ShopMoney.Amount, suggested.SubtotalSet.ShopMoney.Amount,
+		suggested.TotalTaxSet.ShopMoney.Amount, suggested.Shipping.AmountSet.ShopMoney.Amount)
+	fmt.Printf("  Financial status: %s\n", result.FinancialStatus)
+	fmt.Printf("  Total refunded: %s %s\n", result.TotalRefundedSet.ShopMoney.Amount, result.TotalRefundedSet.ShopMoney.CurrencyCode)
+
+	fmt.Printf("\n  Refund %s\n", result.Refund.ID)
+	for _, transaction := range result.Refund.Transactions {
+		fmt.Printf("    %s %s via %s: %s %s\n", transaction.Kind, transaction.Status, transaction.Gateway,
+			transaction.AmountSet.ShopMoney.Amount, transaction.AmountSet.ShopMoney.CurrencyCode)
+	}
+	for _, item := range result.Refund.RefundLineItems {
+		fmt.Printf("    %d x %s (%s)\n", item.Quantity, item.LineItemID, item.RestockType)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"shopify-demo/app"
+)
+
+// Edits an order to match a POS cart read from a JSON file, e.g. after an exchange:
+//
+//	go run ./cmd/reconcile_order cmd/reconcile_order/cart.json
+//
+// With dryRun the edit is previewed but not committed.
+func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
+	inputPath := "cmd/reconcile_order/cart.json"
+	if len(os.Args) > 1 {
+		inputPath = os.Args[1]
+	}
+
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		log.Fatalf("Failed to load cart: %v", err)
+	}
+	var cart struct {
+		OrderID        string         `json:"orderId"`
+		Items          []app.CartLine `json:"items"`
+		Restock        bool           `json:"restock"`
+		NotifyCustomer bool           `json:"notifyCustomer"`
+		DryRun         bool           `json:"dryRun"`
+	}
+	if err := json.Unmarshal(content, &cart); err != nil {
+		log.Fatalf("Failed to load cart: invalid JSON: %v", err)
+	}
+
+	result, err := app.ReconcileOrder(cart.OrderID, cart.Items, app.ReconcileOptions{
+		Restock:        cart.Restock,
+		NotifyCustomer: cart.NotifyCustomer,
+		DryRun:         cart.DryRun,
+	})
+	if err != nil {
+		log.Fatalf("Failed to reconcile order: %v", err)
+	}
+
+	switch {
+	case len(result.Changes) == 0:
+		fmt.Printf("✓ Order %s already matches the cart\n", result.OrderID)
+	case result.Committed:
+		fmt.Printf("✓ Reconciled order %s (%d changes)\n", result.OrderID, len(result.Changes))
+	default:
+		fmt.Printf("✓ Dry run for order %s (%d changes, not committed)\n", result.OrderID, len(result.Changes))
+	}
+	for _, change := range result.Changes {
+		fmt.Printf("  %-15s %s (%d -> %d)\n", change.Kind, change.Title, change.FromQuantity, change.ToQuantity)
+	}
+	fmt.Printf("  Total: %s -> %s\n", result.TotalBefore, result.TotalAfter)
+	fmt.Printf("  Balance due: %s, refund owed: %s\n", result.BalanceDue, result.RefundOwed)
+}
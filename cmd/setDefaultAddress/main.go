@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,6 +12,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Accept address ID only
 	// Example: go run cmd/setDefaultAddress/main.go 10146295447792
 	if len(os.Args) < 2 {
@@ -153,8 +159,8 @@ func findCustomerFromAddress(addressGID string) (string, error) {
 	// Search customers and check their addresses
 	// We'll search in batches
 	const query = `
-		query SearchCustomersWithAddresses($query: String!) {
-			customers(first: 50, query: $query) {
+		query SearchCustomersWithAddresses($query: String!, $first: Int!, $after: String) {
+			customers(first: $first, after: $after, query: $query) {
 				edges {
 					node {
 						id
@@ -178,36 +184,11 @@ func findCustomerFromAddress(addressGID string) (string, error) {
 		"query": "",
 	}
 
-	resp, err := app.CallAdminGraphQL(query, variables)
-	if err != nil {
-		return "", fmt.Errorf("failed to call API: %w", err)
-	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("no data in response")
-	}
-
-	customers, ok := data["customers"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("customers not found in response")
-	}
-
-	edges, ok := customers["edges"].([]interface{})
-	if !ok {
-		return "", fmt.Errorf("no customers found")
-	}
-
-	// Check each customer's addresses
-	for _, edge := range edges {
-		edgeMap, ok := edge.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		node, ok := edgeMap["node"].(map[string]interface{})
-		if !ok {
-			continue
+	// Check each customer's addresses, following pageInfo until the address is found
+	customers := app.Paginate[map[string]interface{}](context.Background(), app.DefaultClient(), query, variables, "customers")
+	for node, err := range customers {
+		if err != nil {
+			return "", fmt.Errorf("failed to call API: %w", err)
 		}
 
 		customerID, ok := node["id"].(string)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -22,6 +23,11 @@ type AddressInput struct {
 }
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Accept customer query/ID as argument
 	// Example: go run cmd/setupCustomerAddress/main.go "Vo Le"
 	if len(os.Args) < 2 {
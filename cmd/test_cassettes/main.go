@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"shopify-demo/app"
+	"shopify-demo/app/shopifytest"
+)
+
+// Regression runs of the order pipeline against recorded Admin API traffic.
+//
+// Replay every cassette in cmd/test_cassettes/testdata/cassettes (no network, no
+// credentials). go test replays each one as a subtest of TestReplay:
+//
+//	go test ./cmd/test_cassettes
+//	go run ./cmd/test_cassettes
+//
+// Record a scenario from a dev store (SHOPIFY_SHOP_DOMAIN / SHOPIFY_API_SECRET):
+//
+//	go run ./cmd/test_cassettes -record -scenario tax_after_edit -variant gid://shopify/ProductVariant/123
+//
+// or from the in-memory fake (app/shopifytest) with -fake instead of -variant.
+// A recording stores the scenario inputs and its results; a replay runs the same
+// scenario, fails when a request has no recorded response, and compares results.
+// The exit status is 1 when any cassette fails.
+
+// Cassettes directory relative to the repository root, where go run is started
+const cassetteDir = "cmd/test_cassettes/testdata/cassettes"
+
+// scenario runs app calls with inputs from the cassette's meta and returns the
+// results to compare between recording and replay
+type scenario func(ctx context.Context, client *app.Client, params scenarioParams) (map[string]string, error)
+
+var scenarios = map[string]scenario{
+	// CreateOrderFromDraftWithTax: complete as pending, replace tax lines, mark as paid
+	"draft_with_tax": runDraftWithTax,
+	// Shopify recalculates taxes when an order edit is committed, dropping custom tax lines
+	"tax_after_edit": runTaxAfterEdit,
+	// Fulfillment orders are routed asynchronously: the first queries return none
+	"fulfillment_routing": runFulfillmentRouting,
+}
+
+type scenarioParams struct {
+	VariantID string
+	Quantity  int
+	TaxTitle  string
+	TaxRate   decimal.Decimal
+	TaxAmount app.Money
+	// Delay between fulfillment order polls; zero when replaying
+	PollInterval time.Duration
+}
+
+func main() {
+	record := flag.Bool("record", false, "record a scenario instead of replaying the cassettes")
+	fake := flag.Bool("fake", false, "record against the in-memory fake Admin API instead of the dev store")
+	name := flag.String("scenario", "", "scenario to record: draft_with_tax, tax_after_edit or fulfillment_routing")
+	variantID := flag.String("variant", "", "product variant GID to order when recording from the dev store")
+	quantity := flag.Int("quantity", 2, "quantity to order")
+	taxTitle := flag.String("tax-title", "GST", "title of the custom tax line")
+	taxRate := flag.String("tax-rate", "0.05", "rate of the custom tax line")
+	taxAmount := flag.String("tax-amount", "2.50", "amount of the custom tax line")
+	currency := flag.String("currency", "USD", "currency of the dev store, for the tax line amount")
+	dir := flag.String("dir", cassetteDir, "directory of the cassettes to replay or record to")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if !*record {
+		os.Exit(replayAll(ctx, *dir))
+	}
+
+	if _, ok := scenarios[*name]; !ok {
+		log.Fatalf("unknown scenario %q (use %s)", *name, strings.Join(scenarioNames(), ", "))
+	}
+	rate, err := decimal.NewFromString(*taxRate)
+	if err != nil {
+		log.Fatalf("Invalid -tax-rate: %v", err)
+	}
+	amount, err := app.ParseMoney(*taxAmount, *currency)
+	if err != nil {
+		log.Fatalf("Invalid -tax-amount: %v", err)
+	}
+	params := scenarioParams{
+		VariantID:    *variantID,
+		Quantity:     *quantity,
+		TaxTitle:     *taxTitle,
+		TaxRate:      rate,
+		TaxAmount:    amount,
+		PollInterval: 3 * time.Second,
+	}
+	if err := recordScenario(ctx, *dir, *name, params, *fake); err != nil {
+		log.Fatalf("Recording failed: %v", err)
+	}
+}
+
+func scenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordScenario runs a scenario through a recording cassette and stores its inputs and results
+func recordScenario(ctx context.Context, dir, name string, params scenarioParams, fake bool) error {
+	path := filepath.Join(dir, name+".json")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var cassette *app.Cassette
+	var client *app.Client
+	if fake {
+		srv := shopifytest.NewServer()
+		defer srv.Close()
+		srv.TaxRate = 0.1
+		srv.FulfillmentOrderDelay = 2
+		params.VariantID = srv.AddVariant(shopifytest.Variant{Title: "Cassette T-Shirt", SKU: "CASSETTE-1", Price: 25}).GID()
+		params.PollInterval = 0
+
+		cassette = app.NewCassetteRecorder(path, srv.HTTP.Client().Transport)
+		client = srv.Client(app.WithCassette(cassette))
+	} else {
+		if params.VariantID == "" {
+			return fmt.Errorf("-variant is required when recording from the dev store")
+		}
+		cassette = app.NewCassetteRecorder(path, nil)
+		client = app.NewClientFromEnv(app.WithCassette(cassette))
+	}
+	if err := runRecording(ctx, cassette, client, name, params); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Recorded %s\n", path)
+	return nil
+}
+
+func runRecording(ctx context.Context, cassette *app.Cassette, client *app.Client, name string, params scenarioParams) error {
+	meta := map[string]string{
+		"scenario":   name,
+		"apiVersion": client.APIVersion,
+		"variantId":  params.VariantID,
+		"quantity":   strconv.Itoa(params.Quantity),
+		"taxTitle":   params.TaxTitle,
+		"taxRate":    params.TaxRate.String(),
+		"taxAmount":  params.TaxAmount.StringFixed(),
+		"currency":   params.TaxAmount.Currency(),
+	}
+	for key, value := range meta {
+		if err := cassette.SetMeta(key, value); err != nil {
+			return err
+		}
+	}
+
+	results, err := scenarios[name](ctx, client, params)
+	if err != nil {
+		return err
+	}
+	expected, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	printResults(results)
+	return cassette.SetMeta("expected", string(expected))
+}
+
+// replayAll replays every cassette in dir and returns the exit status
+func replayAll(ctx context.Context, dir string) int {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Fatalf("Cannot list cassettes: %v", err)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("No cassettes in %s. Record one with -record.\n", dir)
+		return 0
+	}
+
+	failed := 0
+	for _, path := range paths {
+		fmt.Printf("=== %s ===\n", filepath.Base(path))
+		results, err := replay(ctx, path)
+		printResults(results)
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %v\n\n", err)
+			continue
+		}
+		fmt.Printf("✓ Passed\n\n")
+	}
+
+	fmt.Printf("%d/%d cassette(s) passed\n", len(paths)-failed, len(paths))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replay runs the scenario of the cassette at path against its recording and
+// returns the replayed results, with an error when they differ from the recorded ones
+func replay(ctx context.Context, path string) (map[string]string, error) {
+	cassette, err := app.LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	run, ok := scenarios[cassette.Meta("scenario")]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q", cassette.Meta("scenario"))
+	}
+	params, err := paramsFromMeta(cassette)
+	if err != nil {
+		return nil, err
+	}
+
+	// The shop and token are never sent anywhere; requests are matched on path and body
+	client := app.NewClient("cassette.myshopify.com", "replay",
+		app.WithCassette(cassette),
+		app.WithAPIVersion(cassette.Meta("apiVersion")),
+		app.WithRateLimiter(app.NewRateLimiter()),
+		app.WithRetryPolicy(app.NoRetry),
+		app.WithDeprecationLog(app.NewDeprecationLog()),
+	)
+
+	results, err := run(ctx, client, params)
+	if err != nil {
+		if errors.Is(err, app.ErrCassetteMiss) {
+			return nil, fmt.Errorf("the scenario sent a request that was not recorded: %w", err)
+		}
+		return nil, err
+	}
+
+	var expected map[string]string
+	if err := json.Unmarshal([]byte(cassette.Meta("expected")), &expected); err != nil {
+		return results, fmt.Errorf("cassette has no expected results: %w", err)
+	}
+	var diffs []string
+	for _, key := range sortedKeys(expected, results) {
+		if expected[key] != results[key] {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %q, replayed %q", key, expected[key], results[key]))
+		}
+	}
+	if unplayed := cassette.Unplayed(); len(unplayed) > 0 {
+		diffs = append(diffs, fmt.Sprintf("%d recorded request(s) were not sent, first: %s %s",
+			len(unplayed), unplayed[0].Request.Method, unplayed[0].Request.URL))
+	}
+	if len(diffs) > 0 {
+		return results, fmt.Errorf("results differ from the recording:\n  %s", strings.Join(diffs, "\n  "))
+	}
+	return results, nil
+}
+
+func paramsFromMeta(cassette *app.Cassette) (scenarioParams, error) {
+	quantity, err := strconv.Atoi(cassette.Meta("quantity"))
+	if err != nil {
+		return scenarioParams{}, fmt.Errorf("invalid quantity in cassette: %w", err)
+	}
+	taxRate, err := decimal.NewFromString(cassette.Meta("taxRate"))
+	if err != nil {
+		return scenarioParams{}, fmt.Errorf("invalid taxRate in cassette: %w", err)
+	}
+	taxAmount, err := app.ParseMoney(cassette.Meta("taxAmount"), cassette.Meta("currency"))
+	if err != nil {
+		return scenarioParams{}, fmt.Errorf("invalid taxAmount in cassette: %w", err)
+	}
+	return scenarioParams{
+		VariantID: cassette.Meta("variantId"),
+		Quantity:  quantity,
+		TaxTitle:  cassette.Meta("taxTitle"),
+		TaxRate:   taxRate,
+		TaxAmount: taxAmount,
+	}, nil
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func printResults(results map[string]string) {
+	for _, key := range sortedKeys(results) {
+		fmt.Printf("  %s: %s\n", key, results[key])
+	}
+}
+
+func draftInput(params scenarioParams) app.DraftOrderInput {
+	return app.DraftOrderInput{
+		Email: "cassette@example.com",
+		Tags:  []string{"cassette"},
+		LineItems: []app.DraftLineItemInput{
+			{VariantID: params.VariantID, Quantity: params.Quantity},
+		},
+	}
+}
+
+func taxLines(params scenarioParams) []app.TaxLineInput {
+	return []app.TaxLineInput{{
+		Title: params.TaxTitle,
+		Rate:  params.TaxRate,
+		PriceSet: &app.MoneyBagInput{
+			ShopMoney: &app.MoneyInput{Amount: params.TaxAmount, CurrencyCode: params.TaxAmount.Currency()},
+		},
+	}}
+}
+
+func runDraftWithTax(ctx context.Context, client *app.Client, params scenarioParams) (map[string]string, error) {
+	info, err := client.CreateOrderFromDraftWithTax(ctx, draftInput(params), taxLines(params), false)
+	if err != nil {
+		return nil, err
+	}
+	return orderTotals(ctx, client, info.OrderID, "")
+}
+
+func runTaxAfterEdit(ctx context.Context, client *app.Client, params scenarioParams) (map[string]string, error) {
+	info, err := client.CreateOrderFromDraftWithTax(ctx, draftInput(params), taxLines(params), false)
+	if err != nil {
+		return nil, err
+	}
+	results, err := orderTotals(ctx, client, info.OrderID, "before.")
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := client.OrderEditBegin(ctx, info.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(edit.LineItems) == 0 {
+		return nil, fmt.Errorf("order edit has no line items")
+	}
+	if err := client.OrderEditAddLineItemDiscount(ctx, app.OrderEditAddLineItemDiscountInput{
+		CalculatedOrderID: edit.CalculatedOrderID,
+		LineItemID:        edit.LineItems[0].ID,
+		DiscountTitle:     "Cassette 10%",
+		PercentValue:      decimal.NewFromInt(10),
+		IsPercentage:      true,
+	}); err != nil {
+		return nil, err
+	}
+	if err := client.OrderEditCommit(ctx, edit.CalculatedOrderID, false); err != nil {
+		return nil, err
+	}
+
+	after, err := orderTotals(ctx, client, info.OrderID, "after.")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range after {
+		results[key] = value
+	}
+	return results, nil
+}
+
+func runFulfillmentRouting(ctx context.Context, client *app.Client, params scenarioParams) (map[string]string, error) {
+	info, err := client.CreateOrderFromDraft(ctx, draftInput(params), false)
+	if err != nil {
+		return nil, err
+	}
+
+	// CompleteDraftOrder already looked once; keep polling until routing is done
+	fulfillmentOrders := info.FulfillmentOrders
+	emptyPolls := 0
+	for len(fulfillmentOrders) == 0 && emptyPolls < 10 {
+		emptyPolls++
+		if err := sleep(ctx, params.PollInterval); err != nil {
+			return nil, err
+		}
+		fulfillmentOrders, err = client.GetFulfillmentOrdersWithRetry(ctx, info.OrderID, 3, params.PollInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	quantity := 0
+	for _, fo := range fulfillmentOrders {
+		for _, li := range fo.LineItems {
+			quantity += li.Quantity
+		}
+	}
+	return map[string]string{
+		"order":             info.OrderName,
+		"emptyPolls":        strconv.Itoa(emptyPolls),
+		"fulfillmentOrders": strconv.Itoa(len(fulfillmentOrders)),
+		"quantity":          strconv.Itoa(quantity),
+	}, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// orderTotals returns the order's name, financial status and tax lines, keys prefixed with prefix
+func orderTotals(ctx context.Context, client *app.Client, orderID, prefix string) (map[string]string, error) {
+	const query = `
+		query CassetteOrderTotals($id: ID!) {
+			order(id: $id) {
+				name
+				displayFinancialStatus
+				totalTaxSet {
+					shopMoney {
+						amount
+						currencyCode
+					}
+				}
+				totalPriceSet {
+					shopMoney {
+						amount
+						currencyCode
+					}
+				}
+				taxLines {
+					title
+					rate
+					priceSet {
+						shopMoney {
+							amount
+							currencyCode
+						}
+					}
+				}
+			}
+		}`
+
+	data, err := app.Do[struct {
+		Order *struct {
+			Name                   string        `json:"name"`
+			DisplayFinancialStatus string        `json:"displayFinancialStatus"`
+			TotalTaxSet            app.MoneyBag  `json:"totalTaxSet"`
+			TotalPriceSet          app.MoneyBag  `json:"totalPriceSet"`
+			TaxLines               []app.TaxLine `json:"taxLines"`
+		} `json:"order"`
+	}](ctx, client, query, map[string]interface{}{"id": orderID})
+	if err != nil {
+		return nil, err
+	}
+	if data.Order == nil {
+		return nil, fmt.Errorf("order %s: %w", orderID, app.ErrNotFound)
+	}
+
+	var taxLines []string
+	for _, tl := range data.Order.TaxLines {
+		taxLines = append(taxLines, fmt.Sprintf("%s %s", tl.Title, tl.PriceSet.ShopMoney.Amount))
+	}
+	return map[string]string{
+		prefix + "order":           data.Order.Name,
+		prefix + "financialStatus": data.Order.DisplayFinancialStatus,
+		prefix + "totalTax":        data.Order.TotalTaxSet.ShopMoney.Amount.String(),
+		prefix + "totalPrice":      data.Order.TotalPriceSet.ShopMoney.Amount.String(),
+		prefix + "taxLines":        strings.Join(taxLines, ", "),
+	}, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReplay replays every cassette in testdata/cassettes
+func TestReplay(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "cassettes", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no cassettes in testdata/cassettes")
+	}
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			results, err := replay(context.Background(), path)
+			for _, key := range sortedKeys(results) {
+				t.Logf("%s: %s", key, results[key])
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -1,17 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"math"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
-	"time"
+
+	"github.com/shopspring/decimal"
 
 	"shopify-demo/app"
 )
@@ -63,6 +61,8 @@ type OrderData struct {
 	DiscountCodes       []string                 `json:"discountCodes,omitempty"`
 	SubtotalPrice       string                   `json:"subtotalPrice,omitempty"`
 	TotalPrice          string                   `json:"totalPrice,omitempty"`
+	// Currency of the amounts in the order, e.g. "USD"
+	Currency            string                   `json:"currency"`
 }
 
 type TaxLineData struct {
@@ -101,6 +101,11 @@ type AddressData struct {
 }
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Kiểm tra environment variables
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -118,6 +123,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load input data: %v", err)
 	}
+	currency := inputData.Order.Currency
 
 	// =================================================================
 	// HYBRID STRATEGY: orderCreate + orderEdit for strikethrough + tax
@@ -182,15 +188,15 @@ func main() {
 					}
 					
 					if discount.ValueType == "percentage" {
-						value, _ := strconv.ParseFloat(discount.Value, 64)
+						value, _ := decimal.NewFromString(discount.Value)
 						discountInput.IsPercentage = true
 						discountInput.PercentValue = value
-						fmt.Printf("  Adding discount: %s (%.2f%%) to %s\n", discount.Title, value, lineItem.Title)
+						fmt.Printf("  Adding discount: %s (%s%%) to %s\n", discount.Title, value.StringFixed(2), lineItem.Title)
 					} else {
-						amount, _ := parsePrice(discount.Amount)
+						amount, _ := parsePrice(discount.Amount, currency)
 						discountInput.IsPercentage = false
 						discountInput.FixedValue = amount
-						fmt.Printf("  Adding discount: %s ($%.2f) to %s\n", discount.Title, amount, lineItem.Title)
+						fmt.Printf("  Adding discount: %s ($%s) to %s\n", discount.Title, amount.StringFixed(), lineItem.Title)
 					}
 					
 					if err := app.OrderEditAddLineItemDiscount(discountInput); err != nil {
@@ -222,11 +228,10 @@ func main() {
 		if !tl.IsUsed {
 			continue
 		}
-		rate, _ := strconv.ParseFloat(tl.Rate, 64)
-		price, _ := parsePrice(tl.Price)
+		price, _ := parsePrice(tl.Price, currency)
 		restTaxLines = append(restTaxLines, app.TaxLineRestInput{
 			Title: tl.Title,
-			Rate:  rate,
+			Rate:  parseRate(tl.Rate),
 			Price: price,
 		})
 	}
@@ -247,6 +252,7 @@ func main() {
 // buildOrderInputWithOriginalPrice builds order input with ORIGINAL prices (before discount)
 // Discounts will be added later via Order Edit API to show strikethrough
 func buildOrderInputWithOriginalPrice(inputData *InputData) (app.OrderInput, error) {
+	currency := inputData.Order.Currency
 	orderInput := app.OrderInput{
 		Email: inputData.Order.Email,
 		Note:  inputData.Order.Note,
@@ -264,76 +270,76 @@ func buildOrderInputWithOriginalPrice(inputData *InputData) (app.OrderInput, err
 		// Set ORIGINAL price (before discount) using priceSet
 		// The discount will be added via Order Edit API later
 		if item.OriginPrice != "" {
-			if originPrice, ok := parsePrice(item.OriginPrice); ok {
+			if originPrice, ok := parsePrice(item.OriginPrice, currency); ok {
 				lineItem.PriceSet = &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       fmt.Sprintf("%.2f", originPrice),
-						CurrencyCode: "USD",
+						Amount:       originPrice,
+						CurrencyCode: currency,
 					},
 				}
-				fmt.Printf("  → Line item: %s at original price $%.2f\n", item.Name, originPrice)
+				fmt.Printf("  → Line item: %s at original price $%s\n", item.Name, originPrice.StringFixed())
 			}
-		} else if price, ok := parsePrice(item.Price); ok {
+		} else if price, ok := parsePrice(item.Price, currency); ok {
 			lineItem.PriceSet = &app.MoneyBagInput{
 				ShopMoney: &app.MoneyInput{
-					Amount:       fmt.Sprintf("%.2f", price),
-					CurrencyCode: "USD",
+					Amount:       price,
+					CurrencyCode: currency,
 				},
 			}
-			fmt.Printf("  → Line item at price $%.2f\n", price)
+			fmt.Printf("  → Line item at price $%s\n", price.StringFixed())
 		}
 
 		// Add tax lines to line item
 		if len(inputData.Order.TaxLines) > 0 {
 			// Calculate tax proportion for this line item
-			var itemPriceExTax float64
-			if priceExTax, ok := parsePrice(item.PriceExTax); ok {
-				itemPriceExTax = priceExTax * float64(item.Quantity)
-			} else if price, ok := parsePrice(item.Price); ok {
-				if totalTax, ok := parsePrice(item.TotalTax); ok {
-					itemPriceExTax = (price - totalTax) * float64(item.Quantity)
+			var itemPriceExTax app.Money
+			if priceExTax, ok := parsePrice(item.PriceExTax, currency); ok {
+				itemPriceExTax = priceExTax.MulInt(item.Quantity)
+			} else if price, ok := parsePrice(item.Price, currency); ok {
+				if totalTax, ok := parsePrice(item.TotalTax, currency); ok {
+					itemPriceExTax = price.Sub(totalTax).MulInt(item.Quantity)
 				} else {
-					itemPriceExTax = price * float64(item.Quantity)
+					itemPriceExTax = price.MulInt(item.Quantity)
 				}
 			}
 
 			// Calculate total price ex tax
-			var totalPriceExTax float64
+			var totalPriceExTax app.Money
 			for _, it := range inputData.Order.Items {
-				if px, ok := parsePrice(it.PriceExTax); ok {
-					totalPriceExTax += px * float64(it.Quantity)
-				} else if p, ok := parsePrice(it.Price); ok {
-					if tt, ok := parsePrice(it.TotalTax); ok {
-						totalPriceExTax += (p - tt) * float64(it.Quantity)
+				if px, ok := parsePrice(it.PriceExTax, currency); ok {
+					totalPriceExTax = totalPriceExTax.Add(px.MulInt(it.Quantity))
+				} else if p, ok := parsePrice(it.Price, currency); ok {
+					if tt, ok := parsePrice(it.TotalTax, currency); ok {
+						totalPriceExTax = totalPriceExTax.Add(p.Sub(tt).MulInt(it.Quantity))
 					} else {
-						totalPriceExTax += p * float64(it.Quantity)
+						totalPriceExTax = totalPriceExTax.Add(p.MulInt(it.Quantity))
 					}
 				}
 			}
 
 			// Distribute tax lines proportionally
-			if totalPriceExTax > 0 {
-				itemProportion := itemPriceExTax / totalPriceExTax
+			if totalPriceExTax.IsPositive() {
+				itemProportion := itemPriceExTax.Decimal().Div(totalPriceExTax.Decimal())
 				for _, orderTaxLine := range inputData.Order.TaxLines {
 					if !orderTaxLine.IsUsed {
 						continue
 					}
-					orderTaxPrice, _ := parsePrice(orderTaxLine.Price)
-					itemTaxAmount := orderTaxPrice * itemProportion
-					taxRate, _ := strconv.ParseFloat(orderTaxLine.Rate, 64)
+					orderTaxPrice, _ := parsePrice(orderTaxLine.Price, currency)
+					itemTaxAmount := orderTaxPrice.Mul(itemProportion).Round(app.RoundHalfUp)
+					taxRate := parseRate(orderTaxLine.Rate)
 
 					lineItem.TaxLines = append(lineItem.TaxLines, app.OrderCreateTaxLineInput{
 						Title: orderTaxLine.Title,
-						Rate:  fmt.Sprintf("%.4f", taxRate),
+						Rate:  taxRate,
 						PriceSet: &app.MoneyBagInput{
 							ShopMoney: &app.MoneyInput{
-								Amount:       fmt.Sprintf("%.2f", math.Round(itemTaxAmount*100)/100),
-								CurrencyCode: "USD",
+								Amount:       itemTaxAmount,
+								CurrencyCode: currency,
 							},
 						},
 					})
-					fmt.Printf("  → Tax: %s (%.2f%%) = $%.2f\n", 
-						orderTaxLine.Title, taxRate*100, math.Round(itemTaxAmount*100)/100)
+					fmt.Printf("  → Tax: %s (%s%%) = $%s\n", 
+						orderTaxLine.Title, taxRate.Shift(2).StringFixed(2), itemTaxAmount.StringFixed())
 				}
 			}
 		}
@@ -364,6 +370,9 @@ func loadInputData(path string) (*InputData, error) {
 	if err := json.Unmarshal(content, &data); err != nil {
 		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
+	if data.Order.Currency == "" {
+		return nil, fmt.Errorf("order.currency is missing")
+	}
 
 	return &data, nil
 }
@@ -408,6 +417,7 @@ func parseTags(tags string) []string {
 // buildOrderInputFromInput maps data from input.json to OrderInput for orderCreate mutation
 // This supports tax lines, discounts, and compare at price directly in the mutation
 func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
+	currency := inputData.Order.Currency
 	orderInput := app.OrderInput{
 		Email: inputData.Order.Email,
 		Note:  inputData.Order.Note,
@@ -418,7 +428,7 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 	// Add order-level discount from input.json if available
 	if len(inputData.Order.DiscountApplications) > 0 {
 		discount := inputData.Order.DiscountApplications[0]
-		value, _ := strconv.ParseFloat(discount.Value, 64)
+		value, _ := decimal.NewFromString(discount.Value)
 		if discount.ValueType == "percentage" {
 			// Use DiscountCode for percentage discount
 			orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
@@ -427,48 +437,48 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 					Percentage: value,
 				},
 			}
-			fmt.Printf("  → Adding order-level discount: %s (%.2f%%)\n", discount.Title, value)
+			fmt.Printf("  → Adding order-level discount: %s (%s%%)\n", discount.Title, value.StringFixed(2))
 		} else {
 			// Use DiscountCode for fixed amount discount
-			if amount, ok := parsePrice(discount.Amount); ok {
+			if amount, ok := parsePrice(discount.Amount, currency); ok {
 				orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 					ItemFixedDiscountCode: &app.ItemFixedDiscountCodeInput{
 						Code: discount.Title,
 						AmountSet: &app.MoneyBagInput{
 							ShopMoney: &app.MoneyInput{
-								Amount:       fmt.Sprintf("%.2f", amount),
-								CurrencyCode: "USD",
+								Amount:       amount,
+								CurrencyCode: currency,
 							},
 						},
 					},
 				}
-				fmt.Printf("  → Adding order-level discount: %s ($%.2f)\n", discount.Title, amount)
+				fmt.Printf("  → Adding order-level discount: %s ($%s)\n", discount.Title, amount.StringFixed())
 			}
 		}
 	} else if inputData.Order.TotalDiscounts != "" {
-		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts); ok {
-			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice); ok && subtotal > 0 {
-				percentage := (totalDiscount / subtotal) * 100
+		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts, currency); ok {
+			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice, currency); ok && subtotal.IsPositive() {
+				percentage := totalDiscount.Decimal().Div(subtotal.Decimal()).Shift(2).Round(2)
 				orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 					ItemPercentageDiscountCode: &app.ItemPercentageDiscountCodeInput{
 						Code:       "Order Discount",
-						Percentage: math.Round(percentage*100) / 100,
+						Percentage: percentage,
 					},
 				}
-				fmt.Printf("  → Adding order-level discount: Order Discount (%.2f%%)\n", percentage)
+				fmt.Printf("  → Adding order-level discount: Order Discount (%s%%)\n", percentage.StringFixed(2))
 			} else {
 				orderInput.DiscountCode = &app.OrderCreateDiscountCodeInput{
 					ItemFixedDiscountCode: &app.ItemFixedDiscountCodeInput{
 						Code: "Order Discount",
 						AmountSet: &app.MoneyBagInput{
 							ShopMoney: &app.MoneyInput{
-								Amount:       fmt.Sprintf("%.2f", totalDiscount),
-								CurrencyCode: "USD",
+								Amount:       totalDiscount,
+								CurrencyCode: currency,
 							},
 						},
 					},
 				}
-				fmt.Printf("  → Adding order-level discount: Order Discount ($%.2f)\n", totalDiscount)
+				fmt.Printf("  → Adding order-level discount: Order Discount ($%s)\n", totalDiscount.StringFixed())
 			}
 		}
 	}
@@ -482,12 +492,12 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 
 		// Add property to store compare-at price (Shopify orderCreate doesn't support compare_at directly)
 		if item.OriginPrice != "" {
-			if originPrice, ok := parsePrice(item.OriginPrice); ok {
+			if originPrice, ok := parsePrice(item.OriginPrice, currency); ok {
 				lineItem.Properties = append(lineItem.Properties, app.LineItemPropertyInput{
 					Name:  "compare_at_price",
-					Value: fmt.Sprintf("%.2f", originPrice),
+					Value: originPrice.StringFixed(),
 				})
-				fmt.Printf("  → Storing compare at price property: $%.2f\n", originPrice)
+				fmt.Printf("  → Storing compare at price property: $%s\n", originPrice.StringFixed())
 			}
 		}
 
@@ -499,19 +509,19 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 		// Note discount in properties for reference
 		if len(item.DiscountApplications) > 0 {
 			discount := item.DiscountApplications[0]
-			if discountAmount, ok := parsePrice(item.TotalDiscount); ok {
+			if discountAmount, ok := parsePrice(item.TotalDiscount, currency); ok {
 				lineItem.Properties = append(lineItem.Properties, app.LineItemPropertyInput{
 					Name:  "line_discount_amount",
-					Value: fmt.Sprintf("%.2f", discountAmount),
+					Value: discountAmount.StringFixed(),
 				})
-				fmt.Printf("  → Line item discount: %s ($%.2f) - will use variant price\n", 
-					discount.Title, discountAmount)
+				fmt.Printf("  → Line item discount: %s ($%s) - will use variant price\n", 
+					discount.Title, discountAmount.StringFixed())
 			}
 		} else if item.TotalDiscount != "" {
-			if discountAmount, ok := parsePrice(item.TotalDiscount); ok {
+			if discountAmount, ok := parsePrice(item.TotalDiscount, currency); ok {
 				lineItem.Properties = append(lineItem.Properties, app.LineItemPropertyInput{
 					Name:  "line_discount_amount",
-					Value: fmt.Sprintf("%.2f", discountAmount),
+					Value: discountAmount.StringFixed(),
 				})
 			}
 		}
@@ -520,54 +530,54 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 		// Calculate tax amount per line item based on order tax lines
 		if len(inputData.Order.TaxLines) > 0 {
 			// Get item price ex tax for proportion calculation
-			var itemPriceExTax float64
-			if priceExTax, ok := parsePrice(item.PriceExTax); ok {
-				itemPriceExTax = priceExTax * float64(item.Quantity)
-			} else if price, ok := parsePrice(item.Price); ok {
-				if totalTax, ok := parsePrice(item.TotalTax); ok {
-					itemPriceExTax = (price - totalTax) * float64(item.Quantity)
+			var itemPriceExTax app.Money
+			if priceExTax, ok := parsePrice(item.PriceExTax, currency); ok {
+				itemPriceExTax = priceExTax.MulInt(item.Quantity)
+			} else if price, ok := parsePrice(item.Price, currency); ok {
+				if totalTax, ok := parsePrice(item.TotalTax, currency); ok {
+					itemPriceExTax = price.Sub(totalTax).MulInt(item.Quantity)
 				} else {
-					itemPriceExTax = price * float64(item.Quantity)
+					itemPriceExTax = price.MulInt(item.Quantity)
 				}
 			}
 
 			// Calculate total price ex tax for all items
-			var totalPriceExTax float64
+			var totalPriceExTax app.Money
 			for _, it := range inputData.Order.Items {
-				if px, ok := parsePrice(it.PriceExTax); ok {
-					totalPriceExTax += px * float64(it.Quantity)
-				} else if p, ok := parsePrice(it.Price); ok {
-					if tt, ok := parsePrice(it.TotalTax); ok {
-						totalPriceExTax += (p - tt) * float64(it.Quantity)
+				if px, ok := parsePrice(it.PriceExTax, currency); ok {
+					totalPriceExTax = totalPriceExTax.Add(px.MulInt(it.Quantity))
+				} else if p, ok := parsePrice(it.Price, currency); ok {
+					if tt, ok := parsePrice(it.TotalTax, currency); ok {
+						totalPriceExTax = totalPriceExTax.Add(p.Sub(tt).MulInt(it.Quantity))
 					} else {
-						totalPriceExTax += p * float64(it.Quantity)
+						totalPriceExTax = totalPriceExTax.Add(p.MulInt(it.Quantity))
 					}
 				}
 			}
 
 			// Distribute tax lines proportionally to this line item
-			if totalPriceExTax > 0 {
-				itemProportion := itemPriceExTax / totalPriceExTax
+			if totalPriceExTax.IsPositive() {
+				itemProportion := itemPriceExTax.Decimal().Div(totalPriceExTax.Decimal())
 				for _, orderTaxLine := range inputData.Order.TaxLines {
 					if !orderTaxLine.IsUsed {
 						continue
 					}
-					orderTaxPrice, _ := parsePrice(orderTaxLine.Price)
-					itemTaxAmount := orderTaxPrice * itemProportion
-					taxRate, _ := strconv.ParseFloat(orderTaxLine.Rate, 64)
+					orderTaxPrice, _ := parsePrice(orderTaxLine.Price, currency)
+					itemTaxAmount := orderTaxPrice.Mul(itemProportion).Round(app.RoundHalfUp)
+					taxRate := parseRate(orderTaxLine.Rate)
 
 					lineItem.TaxLines = append(lineItem.TaxLines, app.OrderCreateTaxLineInput{
 						Title: orderTaxLine.Title,
-						Rate:  fmt.Sprintf("%.4f", taxRate),
+						Rate:  taxRate,
 						PriceSet: &app.MoneyBagInput{
 							ShopMoney: &app.MoneyInput{
-								Amount:       fmt.Sprintf("%.2f", math.Round(itemTaxAmount*100)/100),
-								CurrencyCode: "USD",
+								Amount:       itemTaxAmount,
+								CurrencyCode: currency,
 							},
 						},
 					})
-					fmt.Printf("  → Adding tax line to line item: %s (rate: %.4f, amount: $%.2f)\n", 
-						orderTaxLine.Title, taxRate, math.Round(itemTaxAmount*100)/100)
+					fmt.Printf("  → Adding tax line to line item: %s (rate: %s, amount: $%s)\n", 
+						orderTaxLine.Title, taxRate.StringFixed(4), itemTaxAmount.StringFixed())
 				}
 			}
 		}
@@ -583,14 +593,14 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 			if !tl.IsUsed {
 				continue
 			}
-			rate, _ := strconv.ParseFloat(tl.Rate, 64)
+			price, _ := parsePrice(tl.Price, currency)
 			orderTaxLines = append(orderTaxLines, app.OrderCreateTaxLineInput{
 				Title: tl.Title,
-				Rate:  fmt.Sprintf("%.4f", rate),
+				Rate:  parseRate(tl.Rate),
 				PriceSet: &app.MoneyBagInput{
 					ShopMoney: &app.MoneyInput{
-						Amount:       tl.Price,
-						CurrencyCode: "USD",
+						Amount:       price,
+						CurrencyCode: currency,
 					},
 				},
 			})
@@ -640,6 +650,7 @@ func buildOrderInputFromInput(inputData *InputData) (app.OrderInput, error) {
 
 // buildDraftOrderFromInput maps data from input.json to DraftOrderInput
 func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error) {
+	currency := inputData.Order.Currency
 	draftInput := app.DraftOrderInput{
 		Email: inputData.Order.Email,
 		Note:  inputData.Order.Note,
@@ -649,34 +660,34 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	// Add order-level discount from input.json if available
 	if len(inputData.Order.DiscountApplications) > 0 {
 		discount := inputData.Order.DiscountApplications[0]
-		value, _ := strconv.ParseFloat(discount.Value, 64)
+		value := parseRate(discount.Value)
 		draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 			Title:       discount.Title,
 			Description: discount.Title,
 		}
 		if discount.ValueType == "percentage" {
 			draftInput.AppliedDiscount.ValueType = "PERCENTAGE"
-			draftInput.AppliedDiscount.Value = math.Round(value*100) / 100
+			draftInput.AppliedDiscount.Value = value.Round(2)
 		} else {
 			draftInput.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-			if amount, ok := parsePrice(discount.Amount); ok {
-				draftInput.AppliedDiscount.Value = math.Round(amount*100) / 100
+			if amount, ok := parsePrice(discount.Amount, currency); ok {
+				draftInput.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
 			}
 		}
 	} else if inputData.Order.TotalDiscounts != "" {
-		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts); ok {
-			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice); ok && subtotal > 0 {
-				percentage := (totalDiscount / subtotal) * 100
+		if totalDiscount, ok := parsePrice(inputData.Order.TotalDiscounts, currency); ok {
+			if subtotal, ok := parsePrice(inputData.Order.SubtotalPrice, currency); ok && subtotal.IsPositive() {
+				percentage := totalDiscount.Decimal().Div(subtotal.Decimal()).Shift(2)
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "PERCENTAGE",
-					Value:       math.Round(percentage*100) / 100,
+					Value:       percentage.Round(2),
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
 			} else {
 				draftInput.AppliedDiscount = &app.AppliedDiscountInput{
 					ValueType:   "FIXED_AMOUNT",
-					Value:       math.Round(totalDiscount*100) / 100,
+					Value:       totalDiscount.Round(app.RoundHalfUp).Decimal(),
 					Title:       "Order Discount",
 					Description: fmt.Sprintf("Discount: %s", inputData.Order.TotalDiscounts),
 				}
@@ -698,14 +709,14 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 		// IMPORTANT: originalUnitPrice = original price (will show strikethrough)
 		// The discounted price will be calculated by Shopify based on appliedDiscount
 		if item.OriginPrice != "" {
-			if originPrice, ok := parsePrice(item.OriginPrice); ok {
+			if originPrice, ok := parsePrice(item.OriginPrice, currency); ok {
 				lineItem.OriginalUnitPrice = originPrice
-				fmt.Printf("  → Setting originalUnitPrice: $%.2f (will show strikethrough)\n", originPrice)
+				fmt.Printf("  → Setting originalUnitPrice: $%s (will show strikethrough)\n", originPrice.StringFixed())
 			}
-		} else if price, ok := parsePrice(item.Price); ok {
+		} else if price, ok := parsePrice(item.Price, currency); ok {
 			// If no OriginPrice, use Price as originalUnitPrice
 			lineItem.OriginalUnitPrice = price
-			fmt.Printf("  → Setting originalUnitPrice: $%.2f (will show strikethrough)\n", price)
+			fmt.Printf("  → Setting originalUnitPrice: $%s (will show strikethrough)\n", price.StringFixed())
 		}
 
 		// Add discount from input.json for this line item
@@ -716,22 +727,22 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 				Description: discount.Title,
 			}
 			if discount.ValueType == "percentage" {
-				value, _ := strconv.ParseFloat(discount.Value, 64)
+				value := parseRate(discount.Value)
 				lineItem.AppliedDiscount.ValueType = "PERCENTAGE"
 				// For percentage, value should be the percentage (e.g., 10 for 10%)
-				lineItem.AppliedDiscount.Value = math.Round(value*100) / 100
-				fmt.Printf("  → Adding line item discount: %s (%.2f%%)\n", discount.Title, value)
+				lineItem.AppliedDiscount.Value = value.Round(2)
+				fmt.Printf("  → Adding line item discount: %s (%s%%)\n", discount.Title, value.StringFixed(2))
 			} else {
 				lineItem.AppliedDiscount.ValueType = "FIXED_AMOUNT"
-				if amount, ok := parsePrice(discount.Amount); ok {
-					lineItem.AppliedDiscount.Value = math.Round(amount*100) / 100
-					fmt.Printf("  → Adding line item discount: %s ($%.2f)\n", discount.Title, amount)
+				if amount, ok := parsePrice(discount.Amount, currency); ok {
+					lineItem.AppliedDiscount.Value = amount.Round(app.RoundHalfUp).Decimal()
+					fmt.Printf("  → Adding line item discount: %s ($%s)\n", discount.Title, amount.StringFixed())
 				}
 			}
 		} else if item.TotalDiscount != "" {
-			if totalDiscount, ok := parsePrice(item.TotalDiscount); ok {
-				if price, ok := parsePrice(item.Price); ok && price > 0 {
-					percentage := (totalDiscount / price) * 100
+			if totalDiscount, ok := parsePrice(item.TotalDiscount, currency); ok {
+				if price, ok := parsePrice(item.Price, currency); ok && price.IsPositive() {
+					percentage := totalDiscount.Decimal().Div(price.Decimal()).Shift(2)
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "PERCENTAGE",
 						Value:       percentage,
@@ -741,7 +752,7 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 				} else {
 					lineItem.AppliedDiscount = &app.AppliedDiscountInput{
 						ValueType:   "FIXED_AMOUNT",
-						Value:       totalDiscount,
+						Value:       totalDiscount.Decimal(),
 						Title:       "Item Discount",
 						Description: fmt.Sprintf("Discount: %s", item.TotalDiscount),
 					}
@@ -787,57 +798,33 @@ func buildDraftOrderFromInput(inputData *InputData) (app.DraftOrderInput, error)
 	return draftInput, nil
 }
 
-// parsePrice parses a price string to float64
-func parsePrice(priceStr string) (float64, bool) {
+// parsePrice parses a price string to app.Money in the order's currency
+func parsePrice(priceStr, currency string) (app.Money, bool) {
 	if priceStr == "" {
-		return 0, false
+		return app.Money{}, false
 	}
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := app.ParseMoney(priceStr, currency)
 	if err != nil {
-		return 0, false
+		return app.Money{}, false
 	}
 	return price, true
 }
 
-// parseFloat is a helper to parse float with error handling (returns 0 on error)
-func parseFloat(s string) float64 {
-	val, _ := strconv.ParseFloat(s, 64)
-	return val
+// parseRate parses a tax rate or percentage string such as "0.065", "" being 0
+func parseRate(rateStr string) decimal.Decimal {
+	rate, _ := decimal.NewFromString(rateStr)
+	return rate
 }
 
 // addCustomTaxLineItemToDraftOrder adds a custom line item (tax) to draft order using REST API
-func addCustomTaxLineItemToDraftOrder(draftOrderNum, taxTitle string, taxAmount float64) error {
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set")
-	}
-
-	apiVersion := "2025-10"
-	url := fmt.Sprintf("https://%s/admin/api/%s/draft_orders/%s.json", shopDomain, apiVersion, draftOrderNum)
+func addCustomTaxLineItemToDraftOrder(draftOrderNum, taxTitle string, taxAmount app.Money) error {
+	draftOrderPath := fmt.Sprintf("draft_orders/%s.json", draftOrderNum)
 
 	// First, get the draft order to see existing line items
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	getReq, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create GET request: %w", err)
-	}
-	getReq.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	getResp, err := client.Do(getReq)
+	_, getBodyBytes, err := app.CallAdminREST(http.MethodGet, draftOrderPath, nil)
 	if err != nil {
 		return fmt.Errorf("failed to fetch draft order: %w", err)
 	}
-	defer getResp.Body.Close()
-
-	getBodyBytes, err := io.ReadAll(getResp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var draftOrderData map[string]interface{}
 	if err := json.Unmarshal(getBodyBytes, &draftOrderData); err != nil {
@@ -858,7 +845,7 @@ func addCustomTaxLineItemToDraftOrder(draftOrderNum, taxTitle string, taxAmount
 	// Add custom tax line item
 	customTaxItem := map[string]interface{}{
 		"title":   taxTitle,
-		"price":   fmt.Sprintf("%.2f", taxAmount),
+		"price":   taxAmount.StringFixed(),
 		"quantity": 1,
 		"taxable": false,
 		"requires_shipping": false,
@@ -875,251 +862,60 @@ func addCustomTaxLineItemToDraftOrder(draftOrderNum, taxTitle string, taxAmount
 		},
 	}
 
-	updateBody, err := json.Marshal(updatePayload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	putReq, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(updateBody))
-	if err != nil {
-		return fmt.Errorf("failed to create PUT request: %w", err)
-	}
-	putReq.Header.Set("Content-Type", "application/json")
-	putReq.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	putResp, err := client.Do(putReq)
+	status, bodyBytes, err := app.CallAdminREST(http.MethodPut, draftOrderPath, updatePayload)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return err
 	}
-	defer putResp.Body.Close()
 
-	if putResp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(putResp.Body)
-		return fmt.Errorf("API returned status %d: %s", putResp.StatusCode, string(bodyBytes))
+	if status != http.StatusOK {
+		return fmt.Errorf("API returned status %d: %s", status, string(bodyBytes))
 	}
 
 	return nil
 }
 
-// queryOrderDetails queries the order to get detailed information including tax
+// queryOrderDetails prints the order totals, tax lines and the discounts and tax lines of each line item
 func queryOrderDetails(orderID string) {
-	const query = `
-		query GetOrder($id: ID!) {
-			order(id: $id) {
-				id
-				name
-				email
-				legacyResourceId
-				totalPriceSet {
-					shopMoney {
-						amount
-						currencyCode
-					}
-				}
-				totalTaxSet {
-					shopMoney {
-						amount
-						currencyCode
-					}
-				}
-				taxLines {
-					title
-					rate
-					priceSet {
-						shopMoney {
-							amount
-							currencyCode
-						}
-					}
-				}
-				lineItems(first: 10) {
-					edges {
-						node {
-							id
-							title
-							quantity
-							originalUnitPriceSet {
-								shopMoney {
-									amount
-									currencyCode
-								}
-							}
-							discountAllocations {
-								allocatedAmountSet {
-									shopMoney {
-										amount
-										currencyCode
-									}
-								}
-								discountApplication {
-									... on DiscountCodeApplication {
-										code
-										value
-									}
-									... on ScriptDiscountApplication {
-										title
-										value
-									}
-									... on AutomaticDiscountApplication {
-										title
-									}
-									... on ManualDiscountApplication {
-										title
-										value
-									}
-								}
-							}
-							taxLines {
-								title
-								rate
-								priceSet {
-									shopMoney {
-										amount
-										currencyCode
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}`
-
-	variables := map[string]interface{}{
-		"id": orderID,
-	}
-
-	resp, err := app.CallAdminGraphQL(query, variables)
+	order, err := app.GetOrder(orderID, app.WithOrderSections(app.OrderSectionLineItems))
 	if err != nil {
 		log.Printf("Warning: Failed to query order details: %v\n", err)
 		return
 	}
 
-	if data, ok := resp["data"].(map[string]interface{}); ok {
-		if order, ok := data["order"].(map[string]interface{}); ok {
-			fmt.Println("\n=== Order Details ===")
-			if name, ok := order["name"].(string); ok {
-				fmt.Printf("Order Name: %s\n", name)
-			}
-			if email, ok := order["email"].(string); ok {
-				fmt.Printf("Email: %s\n", email)
-			}
-			if totalPrice, ok := order["totalPriceSet"].(map[string]interface{}); ok {
-				if shopMoney, ok := totalPrice["shopMoney"].(map[string]interface{}); ok {
-					if amount, ok := shopMoney["amount"].(string); ok {
-						fmt.Printf("Total Price: %s %s\n", amount, shopMoney["currencyCode"])
-					}
-				}
-			}
-			if totalTax, ok := order["totalTaxSet"].(map[string]interface{}); ok {
-				if shopMoney, ok := totalTax["shopMoney"].(map[string]interface{}); ok {
-					if amount, ok := shopMoney["amount"].(string); ok && amount != "" {
-						fmt.Printf("Total Tax: %s %s\n", amount, shopMoney["currencyCode"])
-					}
-				}
-			}
-			if taxLines, ok := order["taxLines"].([]interface{}); ok && len(taxLines) > 0 {
-				fmt.Println("\nOrder-Level Tax Lines:")
-				for i, tl := range taxLines {
-					if taxLine, ok := tl.(map[string]interface{}); ok {
-						title := ""
-						rate := ""
-						amount := ""
-						if titleVal, ok := taxLine["title"].(string); ok {
-							title = titleVal
-						}
-						if rateVal, ok := taxLine["rate"].(float64); ok {
-							rate = fmt.Sprintf("%.4f", rateVal)
-						} else if rateStr, ok := taxLine["rate"].(string); ok {
-							rate = rateStr
-						}
-						if priceSet, ok := taxLine["priceSet"].(map[string]interface{}); ok {
-							if shopMoney, ok := priceSet["shopMoney"].(map[string]interface{}); ok {
-								if amountVal, ok := shopMoney["amount"].(string); ok {
-									amount = amountVal
-								}
-							}
-						}
-						fmt.Printf("  %d. %s - Rate: %s, Amount: %s\n", i+1, title, rate, amount)
-					}
-				}
+	fmt.Println("\n=== Order Details ===")
+	fmt.Printf("Order Name: %s\n", order.Name)
+	fmt.Printf("Email: %s\n", order.Email)
+	fmt.Printf("Total Price: %s %s\n", order.TotalPriceSet.ShopMoney.Amount, order.TotalPriceSet.ShopMoney.CurrencyCode)
+	if tax := order.TotalTaxSet.ShopMoney; !tax.Amount.IsZero() {
+		fmt.Printf("Total Tax: %s %s\n", tax.Amount, tax.CurrencyCode)
+	}
+	if len(order.TaxLines) > 0 {
+		fmt.Println("\nOrder-Level Tax Lines:")
+		for i, taxLine := range order.TaxLines {
+			fmt.Printf("  %d. %s - Rate: %s, Amount: %s\n", i+1, taxLine.Title, taxLine.Rate.StringFixed(4), taxLine.PriceSet.ShopMoney.Amount)
+		}
+	}
+
+	fmt.Println("\nLine Items Details:")
+	for i, lineItem := range order.LineItems {
+		fmt.Printf("  Line Item %d (%s):\n", i+1, lineItem.Title)
+
+		if len(lineItem.DiscountAllocations) > 0 {
+			fmt.Printf("    Discounts:\n")
+			for j, allocation := range lineItem.DiscountAllocations {
+				fmt.Printf("      Discount %d: %s - Amount: %s\n", j+1, allocation.DiscountApplication.Name(), allocation.AllocatedAmountSet.ShopMoney.Amount)
 			}
-			
-			// Check tax lines and discounts in line items
-			if lineItems, ok := order["lineItems"].(map[string]interface{}); ok {
-				if edges, ok := lineItems["edges"].([]interface{}); ok {
-					fmt.Println("\nLine Items Details:")
-					for i, edge := range edges {
-						if edgeMap, ok := edge.(map[string]interface{}); ok {
-							if node, ok := edgeMap["node"].(map[string]interface{}); ok {
-								title := ""
-								if titleVal, ok := node["title"].(string); ok {
-									title = titleVal
-								}
-								fmt.Printf("  Line Item %d (%s):\n", i+1, title)
-								
-								// Check for discounts
-								if discountAllocations, ok := node["discountAllocations"].([]interface{}); ok && len(discountAllocations) > 0 {
-									fmt.Printf("    Discounts:\n")
-									for j, da := range discountAllocations {
-										if daMap, ok := da.(map[string]interface{}); ok {
-											discountAmount := ""
-											discountTitle := ""
-											if allocatedAmount, ok := daMap["allocatedAmountSet"].(map[string]interface{}); ok {
-												if shopMoney, ok := allocatedAmount["shopMoney"].(map[string]interface{}); ok {
-													if amount, ok := shopMoney["amount"].(string); ok {
-														discountAmount = amount
-													}
-												}
-											}
-											if discountApp, ok := daMap["discountApplication"].(map[string]interface{}); ok {
-												if t, ok := discountApp["title"].(string); ok {
-													discountTitle = t
-												} else if code, ok := discountApp["code"].(string); ok {
-													discountTitle = code
-												}
-											}
-											fmt.Printf("      Discount %d: %s - Amount: %s\n", j+1, discountTitle, discountAmount)
-										}
-									}
-								} else {
-									fmt.Printf("    ⚠ No discounts found\n")
-								}
-								
-								// Check for tax lines
-								if taxLines, ok := node["taxLines"].([]interface{}); ok && len(taxLines) > 0 {
-									fmt.Printf("    Tax Lines:\n")
-									for j, tl := range taxLines {
-										if taxLine, ok := tl.(map[string]interface{}); ok {
-											taxTitle := ""
-											rate := ""
-											amount := ""
-											if titleVal, ok := taxLine["title"].(string); ok {
-												taxTitle = titleVal
-											}
-											if rateVal, ok := taxLine["rate"].(float64); ok {
-												rate = fmt.Sprintf("%.4f", rateVal)
-											} else if rateStr, ok := taxLine["rate"].(string); ok {
-												rate = rateStr
-											}
-											if priceSet, ok := taxLine["priceSet"].(map[string]interface{}); ok {
-												if shopMoney, ok := priceSet["shopMoney"].(map[string]interface{}); ok {
-													if amountVal, ok := shopMoney["amount"].(string); ok {
-														amount = amountVal
-													}
-												}
-											}
-											fmt.Printf("      Tax %d: %s - Rate: %s, Amount: %s\n", j+1, taxTitle, rate, amount)
-										}
-									}
-								} else {
-									fmt.Printf("    No tax lines\n")
-								}
-							}
-						}
-					}
-				}
+		} else {
+			fmt.Printf("    ⚠ No discounts found\n")
+		}
+
+		if len(lineItem.TaxLines) > 0 {
+			fmt.Printf("    Tax Lines:\n")
+			for j, taxLine := range lineItem.TaxLines {
+				fmt.Printf("      Tax %d: %s - Rate: %s, Amount: %s\n", j+1, taxLine.Title, taxLine.Rate.StringFixed(4), taxLine.PriceSet.ShopMoney.Amount)
 			}
+		} else {
+			fmt.Printf("    No tax lines\n")
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -180,17 +181,30 @@ func getCustomerAddresses(customerID string) ([]map[string]interface{}, string,
 				defaultAddress {
 					id
 				}
-				addresses {
-					id
-					firstName
-					lastName
-					address1
-					address2
-					city
-					province
-					country
-					zip
-					phone
+			}
+		}`
+
+	// Addresses are paged separately so customers with many addresses are listed in full
+	const addressesQuery = `
+		query GetCustomerAddressesPage($id: ID!, $first: Int!, $after: String) {
+			customer(id: $id) {
+				addressesV2(first: $first, after: $after) {
+					nodes {
+						id
+						firstName
+						lastName
+						address1
+						address2
+						city
+						province
+						country
+						zip
+						phone
+					}
+					pageInfo {
+						hasNextPage
+						endCursor
+					}
 				}
 			}
 		}`
@@ -222,19 +236,13 @@ func getCustomerAddresses(customerID string) ([]map[string]interface{}, string,
 		}
 	}
 
-	addressesData, ok := customer["addresses"].([]interface{})
-	if !ok {
-		return []map[string]interface{}{}, defaultAddressID, nil
+	addressVars := map[string]interface{}{
+		"id":    customerID,
+		"first": 250,
 	}
-
-	var addresses []map[string]interface{}
-	for _, addr := range addressesData {
-		addrMap, ok := addr.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		addresses = append(addresses, addrMap)
+	addresses, err := app.CollectAll(app.Paginate[map[string]interface{}](context.Background(), app.DefaultClient(), addressesQuery, addressVars, "customer", "addressesV2"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get addresses: %w", err)
 	}
 
 	return addresses, defaultAddressID, nil
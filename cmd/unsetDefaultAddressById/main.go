@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,11 @@ import (
 )
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Accept address ID only
 	// Example: go run cmd/unsetDefaultAddressById/main.go 10146295447792
 	if len(os.Args) < 2 {
@@ -138,8 +144,8 @@ func findCustomerFromAddress(addressGID string) (string, error) {
 
 	// Search customers and check their addresses
 	const query = `
-		query SearchCustomersWithAddresses($query: String!) {
-			customers(first: 50, query: $query) {
+		query SearchCustomersWithAddresses($query: String!, $first: Int!, $after: String) {
+			customers(first: $first, after: $after, query: $query) {
 				edges {
 					node {
 						id
@@ -163,36 +169,11 @@ func findCustomerFromAddress(addressGID string) (string, error) {
 		"query": "",
 	}
 
-	resp, err := app.CallAdminGraphQL(query, variables)
-	if err != nil {
-		return "", fmt.Errorf("failed to call API: %w", err)
-	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("no data in response")
-	}
-
-	customers, ok := data["customers"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("customers not found in response")
-	}
-
-	edges, ok := customers["edges"].([]interface{})
-	if !ok {
-		return "", fmt.Errorf("no customers found")
-	}
-
-	// Check each customer's addresses
-	for _, edge := range edges {
-		edgeMap, ok := edge.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		node, ok := edgeMap["node"].(map[string]interface{})
-		if !ok {
-			continue
+	// Check each customer's addresses, following pageInfo until the address is found
+	customers := app.Paginate[map[string]interface{}](context.Background(), app.DefaultClient(), query, variables, "customers")
+	for node, err := range customers {
+		if err != nil {
+			return "", fmt.Errorf("failed to call API: %w", err)
 		}
 
 		customerID, ok := node["id"].(string)
@@ -288,50 +269,34 @@ func checkIfDefaultAddress(customerID, addressGID string) (bool, error) {
 	return addressIDNum == defaultIDNum, nil
 }
 
-// getCustomerAddresses gets all addresses for a customer
+// getCustomerAddresses gets all addresses for a customer, following pageInfo across pages
 func getCustomerAddresses(customerID string) ([]map[string]interface{}, error) {
 	const query = `
-		query GetCustomerAddresses($id: ID!) {
+		query GetCustomerAddresses($id: ID!, $first: Int!, $after: String) {
 			customer(id: $id) {
-				id
-				addresses {
-					id
+				addressesV2(first: $first, after: $after) {
+					nodes {
+						id
+					}
+					pageInfo {
+						hasNextPage
+						endCursor
+					}
 				}
 			}
 		}`
 
 	variables := map[string]interface{}{
-		"id": customerID,
+		"id":    customerID,
+		"first": 250,
 	}
 
-	resp, err := app.CallAdminGraphQL(query, variables)
+	addresses, err := app.CollectAll(app.Paginate[map[string]interface{}](context.Background(), app.DefaultClient(), query, variables, "customer", "addressesV2"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to call API: %w", err)
-	}
-
-	data, ok := resp["data"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("no data in response")
-	}
-
-	customer, ok := data["customer"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("customer not found in response")
-	}
-
-	addressesData, ok := customer["addresses"].([]interface{})
-	if !ok {
-		return []map[string]interface{}{}, nil
-	}
-
-	var addresses []map[string]interface{}
-	for _, addr := range addressesData {
-		addrMap, ok := addr.(map[string]interface{})
-		if !ok {
-			continue
+		if app.IsNotFound(err) {
+			return nil, fmt.Errorf("customer not found in response")
 		}
-
-		addresses = append(addresses, addrMap)
+		return nil, fmt.Errorf("failed to call API: %w", err)
 	}
 
 	return addresses, nil
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -18,6 +19,11 @@ type Input struct {
 }
 
 func main() {
+	if err := app.ServeMetrics(os.Getenv("SHOPIFY_METRICS_ADDR")); err != nil {
+		log.Fatal(err)
+	}
+	defer app.ShutdownTelemetry(context.Background())
+
 	// Require env vars for Shopify Admin API
 	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
 	accessToken := os.Getenv("SHOPIFY_API_SECRET")
@@ -138,33 +144,18 @@ func deleteShippingNoteMetafield(orderID string) error {
 		return nil
 	}
 
-	shopDomain := os.Getenv("SHOPIFY_SHOP_DOMAIN")
-	accessToken := os.Getenv("SHOPIFY_API_SECRET")
-	if shopDomain == "" || accessToken == "" {
-		return fmt.Errorf("SHOPIFY_SHOP_DOMAIN and SHOPIFY_API_SECRET must be set in environment variables")
-	}
-
 	numericID := extractIDFromGID(metafieldID)
 	if numericID == "" {
 		return fmt.Errorf("invalid metafield gid: %s", metafieldID)
 	}
 
-	url := fmt.Sprintf("https://%s/admin/api/2024-07/metafields/%s.json", shopDomain, numericID)
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to build DELETE request: %w", err)
-	}
-	req.Header.Set("X-Shopify-Access-Token", accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	status, _, err := app.CallAdminREST(http.MethodDelete, fmt.Sprintf("metafields/%s.json", numericID), nil)
 	if err != nil {
 		return fmt.Errorf("failed to call REST delete: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("REST delete failed with status %d", resp.StatusCode)
+	if status != http.StatusOK && status != http.StatusNoContent {
+		return fmt.Errorf("REST delete failed with status %d", status)
 	}
 
 	return nil